@@ -0,0 +1,160 @@
+package btc
+
+import (
+	"errors"
+	"strconv"
+)
+
+// Account purposes of the standard derivation schemes
+const (
+	/* BIP44Purpose accounts receive to P2PKH addresses */
+	BIP44Purpose AccountPurpose = 44
+	/* BIP49Purpose accounts receive to P2SH-P2WPKH addresses */
+	BIP49Purpose AccountPurpose = 49
+	/* BIP84Purpose accounts receive to P2WPKH addresses */
+	BIP84Purpose AccountPurpose = 84
+	/* BIP86Purpose accounts receive to key-path only P2TR addresses */
+	BIP86Purpose AccountPurpose = 86
+)
+
+/* Chains of an account, external addresses are given out and internal addresses receive change */
+const (
+	externalChain uint32 = 0
+	internalChain uint32 = 1
+)
+
+// NewAccount derives the account at m/purpose'/coin_type'/index' of a seed
+func NewAccount(seed []byte, purpose AccountPurpose, index uint32, network *Network) (*Account, error) {
+	switch purpose {
+	case BIP44Purpose, BIP49Purpose, BIP84Purpose, BIP86Purpose:
+	default:
+		return nil, errors.New("unknown account purpose")
+	}
+	if index >= HardenedKeyStart {
+		return nil, errors.New("invalid account index")
+	}
+
+	master, err := NewMasterKey(seed, network)
+	if err != nil {
+		return nil, err
+	}
+
+	/* The account key is exported with the SLIP-132 version of its script type */
+	master.Type = purpose.extendedKeyType()
+
+	key := master
+	for _, i := range []uint32{uint32(purpose), network.CoinType, index} {
+		key, err = key.Child(i + HardenedKeyStart)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Account{
+		Purpose:     purpose,
+		Index:       index,
+		ExtendedKey: key,
+		Network:     network,
+	}, nil
+}
+
+// NewAccountFromExtendedKey imports an account from its extended key, the purpose is inferred from the version (ypub for BIP49, zpub for BIP84) and must be given for xpub keys which are used by both BIP44 and BIP86 accounts
+func NewAccountFromExtendedKey(key *ExtendedKey, purpose ...AccountPurpose) (*Account, error) {
+	if key.Depth != 3 || key.ChildNumber < HardenedKeyStart {
+		return nil, errors.New("extended key is not an account key")
+	}
+	if len(purpose) > 1 {
+		return nil, errors.New("an account has a single purpose")
+	}
+
+	var inferred AccountPurpose
+	switch key.Type {
+	case P2PKHExtendedKey:
+		/* BIP44 and BIP86 accounts share the version */
+	case P2SHP2WPKHExtendedKey:
+		inferred = BIP49Purpose
+	case P2WPKHExtendedKey:
+		inferred = BIP84Purpose
+	default:
+		return nil, errors.New("multisig extended keys are not single key accounts")
+	}
+
+	if len(purpose) == 0 {
+		if inferred == 0 {
+			return nil, errors.New("the purpose of the account cannot be inferred from the version of the extended key")
+		}
+		purpose = []AccountPurpose{inferred}
+	}
+
+	switch purpose[0] {
+	case BIP44Purpose, BIP49Purpose, BIP84Purpose, BIP86Purpose:
+	default:
+		return nil, errors.New("unknown account purpose")
+	}
+	if purpose[0].extendedKeyType() != key.Type {
+		return nil, errors.New("account purpose does not match the version of the extended key")
+	}
+
+	return &Account{
+		Purpose:     purpose[0],
+		Index:       key.ChildNumber - HardenedKeyStart,
+		ExtendedKey: key,
+		Network:     key.Network,
+	}, nil
+}
+
+/* extendedKeyType returns the SLIP-132 type of the keys of accounts of the purpose, P2TR keys have no dedicated version */
+func (p AccountPurpose) extendedKeyType() ExtendedKeyType {
+	switch p {
+	case BIP49Purpose:
+		return P2SHP2WPKHExtendedKey
+	case BIP84Purpose:
+		return P2WPKHExtendedKey
+	}
+	return P2PKHExtendedKey
+}
+
+// Path returns the derivation path of the account
+func (a *Account) Path() string {
+	return "m/" + strconv.FormatUint(uint64(a.Purpose), 10) + "'/" +
+		strconv.FormatUint(uint64(a.Network.CoinType), 10) + "'/" +
+		strconv.FormatUint(uint64(a.Index), 10) + "'"
+}
+
+// ReceiveAddress computes the external address at index of the account
+func (a *Account) ReceiveAddress(index uint32) (string, error) {
+	return a.address(externalChain, index)
+}
+
+// ChangeAddress computes the internal address at index of the account
+func (a *Account) ChangeAddress(index uint32) (string, error) {
+	return a.address(internalChain, index)
+}
+
+/* address derives the key at chain/index of the account and encodes it with the script type of the purpose */
+func (a *Account) address(chain uint32, index uint32) (string, error) {
+	if index >= HardenedKeyStart {
+		return "", errors.New("invalid address index")
+	}
+
+	key, err := a.ExtendedKey.Child(chain)
+	if err != nil {
+		return "", err
+	}
+	key, err = key.Child(index)
+	if err != nil {
+		return "", err
+	}
+
+	switch a.Purpose {
+	case BIP44Purpose:
+		return key.PublicKey.Address(true)
+	case BIP49Purpose:
+		return key.PublicKey.NestedSegwitAddress(true)
+	case BIP84Purpose:
+		return key.PublicKey.SegwitAddress(true)
+	case BIP86Purpose:
+		return key.PublicKey.TaprootAddress(nil)
+	}
+	return "", errors.New("unknown account purpose")
+}
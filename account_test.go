@@ -0,0 +1,138 @@
+package btc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccount(t *testing.T) {
+	seed, err := NewSeedFromMnemonic("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", "", EnglishWordlist)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		purpose AccountPurpose
+		network *Network
+		path    string
+		receive []string
+		change  string
+	}{
+		{
+			purpose: BIP44Purpose,
+			network: MainNetwork,
+			path:    "m/44'/0'/0'",
+			receive: []string{"1LqBGSKuX5yYUonjxT5qGfpUsXKYYWeabA", "1Ak8PffB2meyfYnbXZR9EGfLfFZVpzJvQP"},
+		},
+		{
+			purpose: BIP49Purpose,
+			network: TestNetwork,
+			path:    "m/49'/1'/0'",
+			receive: []string{"2Mww8dCYPUpKHofjgcXcBCEGmniw9CoaiD2"},
+		},
+		{
+			purpose: BIP84Purpose,
+			network: MainNetwork,
+			path:    "m/84'/0'/0'",
+			receive: []string{"bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu", "bc1qnjg0jd8228aq7egyzacy8cys3knf9xvrerkf9g"},
+			change:  "bc1q8c6fshw2dlwun7ekn9qwf37cu2rn755upcp6el",
+		},
+		{
+			purpose: BIP86Purpose,
+			network: MainNetwork,
+			path:    "m/86'/0'/0'",
+			receive: []string{"bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr", "bc1p4qhjn9zdvkux4e44uhx8tc55attvtyu358kutcqkudyccelu0was9fqzwh"},
+			change:  "bc1p3qkhfews2uk44qtvauqyr2ttdsw7svhkl9nkm9s9c3x4ax5h60wqwruhk7",
+		},
+	}
+
+	for _, test := range tests {
+		account, err := NewAccount(seed, test.purpose, 0, test.network)
+		if !assert.Nil(t, err, test.path) {
+			continue
+		}
+		assert.Equal(t, test.path, account.Path())
+
+		for i, expected := range test.receive {
+			address, err := account.ReceiveAddress(uint32(i))
+			assert.Nil(t, err)
+			assert.Equal(t, expected, address, test.path)
+		}
+		if test.change != "" {
+			address, err := account.ChangeAddress(0)
+			assert.Nil(t, err)
+			assert.Equal(t, test.change, address, test.path)
+		}
+	}
+
+	/* The account key matches the one derived from its path */
+	master, err := NewMasterKey(seed, RegTestNetwork)
+	assert.Nil(t, err)
+	expected, err := master.Derive("m/84'/1'/3'")
+	assert.Nil(t, err)
+	account, err := NewAccount(seed, BIP84Purpose, 3, RegTestNetwork)
+	assert.Nil(t, err)
+	assert.Equal(t, "m/84'/1'/3'", account.Path())
+	expected, err = expected.WithType(P2WPKHExtendedKey)
+	assert.Nil(t, err)
+	assert.Equal(t, expected.String(), account.ExtendedKey.String())
+
+	/* Watch-only accounts derive the same addresses from the account public key */
+	watchOnly := *account
+	watchOnly.ExtendedKey = account.ExtendedKey.Neuter()
+	a, err := account.ChangeAddress(7)
+	assert.Nil(t, err)
+	b, err := watchOnly.ChangeAddress(7)
+	assert.Nil(t, err)
+	assert.Equal(t, a, b)
+
+	_, err = NewAccount(seed, 45, 0, MainNetwork)
+	assert.NotNil(t, err)
+	_, err = NewAccount(seed, BIP84Purpose, HardenedKeyStart, MainNetwork)
+	assert.NotNil(t, err)
+	_, err = account.ReceiveAddress(HardenedKeyStart)
+	assert.NotNil(t, err)
+}
+
+func TestNewAccountFromExtendedKey(t *testing.T) {
+	seed, err := NewSeedFromMnemonic("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", "", EnglishWordlist)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, purpose := range []AccountPurpose{BIP44Purpose, BIP49Purpose, BIP84Purpose, BIP86Purpose} {
+		account, err := NewAccount(seed, purpose, 0, MainNetwork)
+		if !assert.Nil(t, err) {
+			continue
+		}
+		expected, err := account.ReceiveAddress(0)
+		assert.Nil(t, err)
+
+		/* The purpose is given or inferred from the version */
+		imported, err := NewAccountFromExtendedKey(account.ExtendedKey.Neuter(), purpose)
+		if assert.Nil(t, err, account.Path()) {
+			assert.Equal(t, purpose, imported.Purpose)
+			address, err := imported.ReceiveAddress(0)
+			assert.Nil(t, err)
+			assert.Equal(t, expected, address, account.Path())
+		}
+		imported, err = NewAccountFromExtendedKey(account.ExtendedKey.Neuter())
+		if purpose == BIP44Purpose || purpose == BIP86Purpose {
+			/* xpub keys are ambiguous */
+			assert.NotNil(t, err, account.Path())
+		} else if assert.Nil(t, err, account.Path()) {
+			assert.Equal(t, purpose, imported.Purpose)
+		}
+	}
+
+	/* The purpose must match the version */
+	account, err := NewAccount(seed, BIP86Purpose, 0, MainNetwork)
+	assert.Nil(t, err)
+	_, err = NewAccountFromExtendedKey(account.ExtendedKey, BIP84Purpose)
+	assert.NotNil(t, err)
+	_, err = NewAccountFromExtendedKey(account.ExtendedKey, 45)
+	assert.NotNil(t, err)
+	_, err = NewAccountFromExtendedKey(account.ExtendedKey, BIP44Purpose, BIP86Purpose)
+	assert.NotNil(t, err)
+}
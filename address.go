@@ -0,0 +1,184 @@
+package btc
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// Address types
+const (
+	P2PKHAddress AddressType = iota
+	P2SHAddress
+	P2WPKHAddress
+	P2WSHAddress
+	P2TRAddress
+	/* Segwit addresses of future witness versions or lengths */
+	WitnessUnknownAddress
+)
+
+/* knownNetworks is used to report addresses of another network */
+func knownNetworks() []*Network {
+	return []*Network{MainNetwork, TestNetwork, RegTestNetwork}
+}
+
+// ParseAddress decodes and validates a base58 (P2PKH, P2SH) or bech32/bech32m (P2WPKH, P2WSH, P2TR) address of network
+func ParseAddress(s string, network *Network) (*Address, error) {
+	/* The human readable part of a segwit address is everything before the last 1 */
+	if pos := strings.LastIndexByte(s, '1'); pos > 0 {
+		hrp := strings.ToLower(s[0:pos])
+		if hrp == network.Bech32HRP {
+			return parseSegwitAddress(s, network)
+		}
+		for _, n := range knownNetworks() {
+			if hrp == n.Bech32HRP {
+				return nil, errors.New("segwit address belongs to a different network")
+			}
+		}
+	}
+
+	payload, err := base58CheckDecode(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) != 21 {
+		return nil, errors.New("invalid base58 address length")
+	}
+
+	version := strings.ToUpper(hex.EncodeToString(payload[0:1]))
+	address := &Address{
+		Hash:    payload[1:],
+		Network: network,
+	}
+
+	switch version {
+	case strings.ToUpper(network.PubKeyHashPrefix):
+		address.Type = P2PKHAddress
+		return address, nil
+	case strings.ToUpper(network.ScriptHashPrefix):
+		address.Type = P2SHAddress
+		return address, nil
+	}
+
+	for _, n := range knownNetworks() {
+		if version == strings.ToUpper(n.PubKeyHashPrefix) || version == strings.ToUpper(n.ScriptHashPrefix) {
+			return nil, errors.New("base58 address belongs to a different network")
+		}
+	}
+	return nil, errors.New("unknown base58 address version")
+}
+
+/* parseSegwitAddress decodes a bech32 or bech32m address and infers its type from the witness program */
+func parseSegwitAddress(s string, network *Network) (*Address, error) {
+	version, program, err := decodeSegwitAddress(network.Bech32HRP, s)
+	if err != nil {
+		return nil, err
+	}
+
+	address := &Address{
+		Type:           WitnessUnknownAddress,
+		WitnessVersion: version,
+		WitnessProgram: program,
+		Network:        network,
+	}
+
+	switch {
+	case version == 0 && len(program) == 20:
+		address.Type = P2WPKHAddress
+	case version == 0 && len(program) == 32:
+		address.Type = P2WSHAddress
+	case version == 1 && len(program) == 32:
+		address.Type = P2TRAddress
+	}
+	return address, nil
+}
+
+// IsSegwit returns true if the address pays to a witness program
+func (a *Address) IsSegwit() bool {
+	return a.Type != P2PKHAddress && a.Type != P2SHAddress
+}
+
+// Script returns the output script (scriptPubKey) the address pays to
+func (a *Address) Script() []byte {
+	switch a.Type {
+	case P2PKHAddress:
+		/* OP_DUP OP_HASH160 <hash> OP_EQUALVERIFY OP_CHECKSIG */
+		script := append([]byte{0x76, 0xa9, 0x14}, a.Hash...)
+		return append(script, 0x88, 0xac)
+	case P2SHAddress:
+		/* OP_HASH160 <hash> OP_EQUAL */
+		script := append([]byte{0xa9, 0x14}, a.Hash...)
+		return append(script, 0x87)
+	}
+
+	/* OP_n <program> */
+	version := a.WitnessVersion
+	if version > 0 {
+		version += 0x50
+	}
+	script := []byte{version, byte(len(a.WitnessProgram))}
+	return append(script, a.WitnessProgram...)
+}
+
+// String returns the encoded address
+func (a *Address) String() string {
+	switch a.Type {
+	case P2PKHAddress, P2SHAddress:
+		prefix := a.Network.PubKeyHashPrefix
+		if a.Type == P2SHAddress {
+			prefix = a.Network.ScriptHashPrefix
+		}
+		version, _ := hex.DecodeString(prefix)
+		return base58CheckEncode(append(version, a.Hash...))
+	}
+
+	address, _ := encodeSegwitAddress(a.Network.Bech32HRP, a.WitnessVersion, a.WitnessProgram)
+	return address
+}
+
+// ScriptHashAddress computes the P2SH address of a redeem script
+func ScriptHashAddress(redeemScript []byte, network *Network) (string, error) {
+	/* Scripts bigger than 520 bytes cannot be pushed to the stack to be redeemed */
+	if len(redeemScript) > 520 {
+		return "", errors.New("redeem script too large")
+	}
+
+	address := &Address{
+		Type:    P2SHAddress,
+		Hash:    hash160(redeemScript),
+		Network: network,
+	}
+	return address.String(), nil
+}
+
+// AddressFromScript returns the address an output script pays to, P2PK, P2MS and null data scripts have no address
+func AddressFromScript(script []byte, network *Network) (*Address, error) {
+	if version, program, ok := witnessProgram(script); ok {
+		address := &Address{
+			Type:           WitnessUnknownAddress,
+			WitnessVersion: byte(version),
+			WitnessProgram: program,
+			Network:        network,
+		}
+
+		switch {
+		case version == 0 && len(program) == 20:
+			address.Type = P2WPKHAddress
+		case version == 0 && len(program) == 32:
+			address.Type = P2WSHAddress
+		case version == 0:
+			return nil, errors.New("invalid witness program length")
+		case version == 1 && len(program) == 32:
+			address.Type = P2TRAddress
+		}
+		return address, nil
+	}
+
+	switch Script(script).Type() {
+	case P2PKHScript:
+		return &Address{Type: P2PKHAddress, Hash: script[3:23], Network: network}, nil
+	case P2SHScript:
+		return &Address{Type: P2SHAddress, Hash: script[2:22], Network: network}, nil
+	}
+	return nil, errors.New("script has no address")
+}
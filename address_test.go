@@ -0,0 +1,191 @@
+package btc
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAddress(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/key_io_valid.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/* address, scriptPubKey, metadata */
+	var valid [][]json.RawMessage
+	err = json.Unmarshal(data, &valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chains := map[string]*Network{
+		"main":     MainNetwork,
+		"testnet4": TestNetwork,
+		"signet":   TestNetwork,
+		"regtest":  RegTestNetwork,
+	}
+
+	for _, value := range valid {
+		var s, script string
+		var metadata struct {
+			Chain       string
+			IsPrivkey   bool
+			TryCaseFlip bool
+		}
+		json.Unmarshal(value[0], &s)
+		json.Unmarshal(value[1], &script)
+		json.Unmarshal(value[2], &metadata)
+		if metadata.IsPrivkey {
+			continue
+		}
+		network := chains[metadata.Chain]
+
+		address, err := ParseAddress(s, network)
+		assert.Nil(t, err, s)
+		if err != nil {
+			continue
+		}
+		assert.Equal(t, script, hex.EncodeToString(address.Script()), s)
+
+		/* Segwit addresses are always encoded in lowercase */
+		encoded := s
+		if address.IsSegwit() {
+			encoded = strings.ToLower(s)
+		}
+		assert.Equal(t, encoded, address.String())
+
+		fromScript, err := AddressFromScript(address.Script(), network)
+		if assert.Nil(t, err, s) {
+			assert.Equal(t, encoded, fromScript.String())
+		}
+
+		if metadata.TryCaseFlip {
+			_, err = ParseAddress(strings.ToUpper(s), network)
+			assert.Nil(t, err, s)
+		}
+
+		/* Addresses are rejected on the other networks */
+		for _, other := range []*Network{MainNetwork, TestNetwork} {
+			if other.Bech32HRP != network.Bech32HRP && other.PubKeyHashPrefix != network.PubKeyHashPrefix {
+				_, err = ParseAddress(s, other)
+				assert.NotNil(t, err, s)
+			}
+		}
+	}
+}
+
+func TestParseAddressInvalid(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/key_io_invalid.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var invalid [][]string
+	err = json.Unmarshal(data, &invalid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, value := range invalid {
+		for _, network := range []*Network{MainNetwork, TestNetwork, RegTestNetwork} {
+			_, err := ParseAddress(value[0], network)
+			assert.NotNil(t, err, value[0])
+		}
+	}
+}
+
+func TestParseAddressTypes(t *testing.T) {
+	var addresses = []struct {
+		Address string
+		Network *Network
+		Type    AddressType
+		Error   string
+	}{
+		{"1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2", MainNetwork, P2PKHAddress, ""},
+		{"3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy", MainNetwork, P2SHAddress, ""},
+		{"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", MainNetwork, P2WPKHAddress, ""},
+		{"BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4", MainNetwork, P2WPKHAddress, ""},
+		{"tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7", TestNetwork, P2WSHAddress, ""},
+		{"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0", MainNetwork, P2TRAddress, ""},
+		{"bc1zw508d6qejxtdg4y5r3zarvaryvaxxpcs", MainNetwork, WitnessUnknownAddress, ""},
+
+		{"1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2", TestNetwork, P2PKHAddress, "base58 address belongs to a different network"},
+		{"1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN3", MainNetwork, P2PKHAddress, "invalid checksum"},
+		{"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", TestNetwork, P2WPKHAddress, "segwit address belongs to a different network"},
+		{"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t5", MainNetwork, P2WPKHAddress, "invalid bech32 checksum"},
+		{"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kV8f3t4", MainNetwork, P2WPKHAddress, "mixed case bech32 string"},
+		{"BC1QR508D6QEJXTDG4Y5R3ZARVARYV98GJ9P", MainNetwork, P2WPKHAddress, "invalid witness program length for witness version 0"},
+		{"bc1pw508d6qejxtdg4y5r3zarvary0c5xw7kw508d6qejxtdg4y5r3zarvary0c5xw7kt5nd6y", MainNetwork, WitnessUnknownAddress, ""},
+	}
+
+	for _, value := range addresses {
+		address, err := ParseAddress(value.Address, value.Network)
+		if value.Error != "" {
+			assert.EqualError(t, err, value.Error, value.Address)
+			continue
+		}
+		assert.Nil(t, err, value.Address)
+		assert.Equal(t, value.Type, address.Type, value.Address)
+	}
+}
+
+func TestAddressFromScriptInvalid(t *testing.T) {
+	scripts := []string{
+		/* P2PK, P2MS, null data and a version 0 witness program of an invalid length */
+		"2103a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bdac",
+		"512103a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd51ae",
+		"6a0401020304",
+		"0010000102030405060708090a0b0c0d0e0f",
+	}
+	for _, value := range scripts {
+		script, _ := hex.DecodeString(value)
+		_, err := AddressFromScript(script, MainNetwork)
+		assert.NotNil(t, err, value)
+	}
+}
+
+func TestScriptHashAddress(t *testing.T) {
+	var scripts = []struct {
+		Script  string
+		Network *Network
+		Address string
+	}{
+		/* OP_TRUE */
+		{"51", MainNetwork, "3MaB7QVq3k4pQx3BhsvEADgzQonLSBwMdj"},
+		/* BIP49 P2WPKH redeem script */
+		{"001438971f73930f6c141d977ac4fd4a727c854935b3", TestNetwork, "2Mww8dCYPUpKHofjgcXcBCEGmniw9CoaiD2"},
+		{"001438971f73930f6c141d977ac4fd4a727c854935b3", MainNetwork, "36NvZTcMsMowbt78wPzJaHHWaNiyR73Y4g"},
+	}
+	for _, value := range scripts {
+		script, _ := hex.DecodeString(value.Script)
+		address, err := ScriptHashAddress(script, value.Network)
+		assert.Nil(t, err)
+		assert.Equal(t, value.Address, address)
+
+		parsed, err := ParseAddress(address, value.Network)
+		assert.Nil(t, err)
+		assert.Equal(t, P2SHAddress, parsed.Type)
+		assert.Equal(t, hash160(script), parsed.Hash)
+	}
+
+	_, err := ScriptHashAddress(make([]byte, 521), MainNetwork)
+	assert.NotNil(t, err)
+}
+
+func TestNestedSegwitAddress(t *testing.T) {
+	/* BIP49 test vector */
+	publicKey, err := PublicFromHex("03a1af804ac108a8a51782198c2d034b28bf90c8803f5a53f76276fa69a4eae77f", TestNetwork)
+	assert.Nil(t, err)
+
+	address, err := publicKey.NestedSegwitAddress(true)
+	assert.Nil(t, err)
+	assert.Equal(t, "2Mww8dCYPUpKHofjgcXcBCEGmniw9CoaiD2", address)
+
+	_, err = publicKey.NestedSegwitAddress(false)
+	assert.NotNil(t, err)
+}
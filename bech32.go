@@ -0,0 +1,248 @@
+package btc
+
+import (
+	"errors"
+	"strings"
+)
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+var bech32Generator = []uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+/* Checksum constants of bech32 (BIP173) and bech32m (BIP350) */
+const (
+	bech32Const  uint32 = 1
+	bech32mConst uint32 = 0x2bc830a3
+)
+
+func bech32Polymod(values []byte) uint32 {
+	chk := uint32(1)
+	for _, v := range values {
+		b := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (b>>uint(i))&1 == 1 {
+				chk ^= bech32Generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+/* bech32HRPExpand returns the high bits of each character, a zero, then the low bits */
+func bech32HRPExpand(hrp string) []byte {
+	expanded := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]>>5)
+	}
+	expanded = append(expanded, 0)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]&31)
+	}
+	return expanded
+}
+
+func bech32Checksum(hrp string, data []byte, constant uint32) []byte {
+	values := append(bech32HRPExpand(hrp), data...)
+	values = append(values, 0, 0, 0, 0, 0, 0)
+	polymod := bech32Polymod(values) ^ constant
+
+	checksum := make([]byte, 6)
+	for i := range checksum {
+		checksum[i] = byte(polymod>>uint(5*(5-i))) & 31
+	}
+	return checksum
+}
+
+// Bech32Encode encodes 5 bits groups of data with a human readable part (BIP173)
+func Bech32Encode(hrp string, data []byte) (string, error) {
+	return bech32Encode(hrp, data, bech32Const)
+}
+
+// Bech32mEncode encodes 5 bits groups of data with a human readable part (BIP350)
+func Bech32mEncode(hrp string, data []byte) (string, error) {
+	return bech32Encode(hrp, data, bech32mConst)
+}
+
+// Bech32Decode decodes a bech32 string into its human readable part and 5 bits groups of data (BIP173)
+func Bech32Decode(s string) (string, []byte, error) {
+	hrp, data, constant, err := bech32Decode(s)
+	if err != nil {
+		return "", nil, err
+	}
+	if constant != bech32Const {
+		return "", nil, errors.New("invalid bech32 checksum")
+	}
+	return hrp, data, nil
+}
+
+// Bech32mDecode decodes a bech32m string into its human readable part and 5 bits groups of data (BIP350)
+func Bech32mDecode(s string) (string, []byte, error) {
+	hrp, data, constant, err := bech32Decode(s)
+	if err != nil {
+		return "", nil, err
+	}
+	if constant != bech32mConst {
+		return "", nil, errors.New("invalid bech32m checksum")
+	}
+	return hrp, data, nil
+}
+
+func bech32Encode(hrp string, data []byte, constant uint32) (string, error) {
+	if len(hrp) < 1 || len(hrp)+len(data)+7 > 90 {
+		return "", errors.New("invalid bech32 length")
+	}
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return "", errors.New("invalid human readable part character")
+		}
+	}
+	if strings.ToLower(hrp) != hrp && strings.ToUpper(hrp) != hrp {
+		return "", errors.New("mixed case human readable part")
+	}
+	hrp = strings.ToLower(hrp)
+
+	var sb strings.Builder
+	sb.WriteString(hrp)
+	sb.WriteByte('1')
+	for _, d := range append(append([]byte{}, data...), bech32Checksum(hrp, data, constant)...) {
+		if d > 31 {
+			return "", errors.New("invalid data value")
+		}
+		sb.WriteByte(bech32Charset[d])
+	}
+	return sb.String(), nil
+}
+
+/* bech32Decode decodes a bech32 or bech32m string and returns the checksum constant it matches */
+func bech32Decode(s string) (string, []byte, uint32, error) {
+	if len(s) > 90 {
+		return "", nil, 0, errors.New("bech32 string too long")
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < 33 || s[i] > 126 {
+			return "", nil, 0, errors.New("invalid bech32 character")
+		}
+	}
+	if strings.ToLower(s) != s && strings.ToUpper(s) != s {
+		return "", nil, 0, errors.New("mixed case bech32 string")
+	}
+	s = strings.ToLower(s)
+
+	/* The separator is the last 1 of the string */
+	pos := strings.LastIndexByte(s, '1')
+	if pos < 1 {
+		return "", nil, 0, errors.New("missing human readable part")
+	}
+	if pos+7 > len(s) {
+		return "", nil, 0, errors.New("bech32 checksum too short")
+	}
+
+	hrp := s[0:pos]
+	data := make([]byte, 0, len(s)-pos-1)
+	for i := pos + 1; i < len(s); i++ {
+		d := strings.IndexByte(bech32Charset, s[i])
+		if d < 0 {
+			return "", nil, 0, errors.New("invalid bech32 data character")
+		}
+		data = append(data, byte(d))
+	}
+
+	constant := bech32Polymod(append(bech32HRPExpand(hrp), data...))
+	if constant != bech32Const && constant != bech32mConst {
+		return "", nil, 0, errors.New("invalid bech32 checksum")
+	}
+
+	return hrp, data[0 : len(data)-6], constant, nil
+}
+
+/* convertBits regroups bits of data from groups of `from` bits to groups of `to` bits */
+func convertBits(data []byte, from uint, to uint, pad bool) ([]byte, error) {
+	acc := uint32(0)
+	bits := uint(0)
+	maxv := uint32(1)<<to - 1
+
+	var converted []byte
+	for _, value := range data {
+		if uint32(value)>>from != 0 {
+			return nil, errors.New("invalid data range")
+		}
+		acc = acc<<from | uint32(value)
+		bits += from
+		for bits >= to {
+			bits -= to
+			converted = append(converted, byte(acc>>bits&maxv))
+		}
+	}
+
+	if pad {
+		if bits > 0 {
+			converted = append(converted, byte(acc<<(to-bits)&maxv))
+		}
+	} else if bits >= from {
+		return nil, errors.New("excessive padding")
+	} else if acc<<(to-bits)&maxv != 0 {
+		return nil, errors.New("non-zero padding")
+	}
+	return converted, nil
+}
+
+/* encodeSegwitAddress encodes a witness program to a segwit address, bech32 for version 0 and bech32m otherwise */
+func encodeSegwitAddress(hrp string, version byte, program []byte) (string, error) {
+	data, err := convertBits(program, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+
+	constant := bech32mConst
+	if version == 0 {
+		constant = bech32Const
+	}
+
+	address, err := bech32Encode(hrp, append([]byte{version}, data...), constant)
+	if err != nil {
+		return "", err
+	}
+
+	/* Make sure the address can be decoded back */
+	_, _, err = decodeSegwitAddress(hrp, address)
+	if err != nil {
+		return "", err
+	}
+	return address, nil
+}
+
+/* decodeSegwitAddress decodes a segwit address into its witness version and program */
+func decodeSegwitAddress(hrp string, address string) (byte, []byte, error) {
+	decodedHRP, data, constant, err := bech32Decode(address)
+	if err != nil {
+		return 0, nil, err
+	}
+	if decodedHRP != hrp {
+		return 0, nil, errors.New("invalid human readable part")
+	}
+	if len(data) < 1 {
+		return 0, nil, errors.New("empty data section")
+	}
+
+	version := data[0]
+	if version > 16 {
+		return 0, nil, errors.New("invalid witness version")
+	}
+	if (version == 0 && constant != bech32Const) || (version != 0 && constant != bech32mConst) {
+		return 0, nil, errors.New("invalid checksum for witness version")
+	}
+
+	program, err := convertBits(data[1:], 5, 8, false)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(program) < 2 || len(program) > 40 {
+		return 0, nil, errors.New("invalid witness program length")
+	}
+	if version == 0 && len(program) != 20 && len(program) != 32 {
+		return 0, nil, errors.New("invalid witness program length for witness version 0")
+	}
+
+	return version, program, nil
+}
@@ -0,0 +1,373 @@
+package btc
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/text/unicode/norm"
+)
+
+/* Prefixes of the base58 payloads of BIP38 */
+var (
+	bip38NonECMultiplyPrefix     = []byte{0x01, 0x42}
+	bip38ECMultiplyPrefix        = []byte{0x01, 0x43}
+	bip38ConfirmationCodePrefix  = []byte{0x64, 0x3b, 0xf6, 0xa8, 0x9a}
+	bip38IntermediateMagic       = []byte{0x2c, 0xe9, 0xb3, 0xe1, 0xff, 0x39, 0xe2, 0x53}
+	bip38IntermediateMagicLotSeq = []byte{0x2c, 0xe9, 0xb3, 0xe1, 0xff, 0x39, 0xe2, 0x51}
+)
+
+/* Lot and sequence numbers are packed as lot*4096 + sequence */
+const (
+	bip38MaxLot      uint32 = 1048575
+	bip38MaxSequence uint32 = 4095
+)
+
+/* Bits of the flag byte */
+const (
+	bip38FlagNonECMultiply byte = 0xc0
+	bip38FlagCompressed    byte = 0x20
+	bip38FlagLotSequence   byte = 0x04
+)
+
+// EncryptBIP38 encrypts the private key with a passphrase (BIP38 without EC multiplication)
+func (p *PrivateKey) EncryptBIP38(passphrase string) (string, error) {
+	publicKey, valid := p.GetPublicKey()
+	if !valid {
+		return "", errors.New("invalid private key")
+	}
+	address, err := publicKey.Address(p.Compressed)
+	if err != nil {
+		return "", err
+	}
+	addressHash := doubleHash([]byte(address))[0:4]
+
+	derived, err := scrypt.Key(bip38Passphrase(passphrase), addressHash, 16384, 8, 8, 64)
+	if err != nil {
+		return "", err
+	}
+
+	flag := bip38FlagNonECMultiply
+	if p.Compressed {
+		flag |= bip38FlagCompressed
+	}
+
+	key := paddedBytes(p.Key, 32)
+	payload := append(append([]byte{}, bip38NonECMultiplyPrefix...), flag)
+	payload = append(payload, addressHash...)
+	payload = append(payload, bip38Encrypt(xorBytes(key[0:16], derived[0:16]), derived[32:64])...)
+	payload = append(payload, bip38Encrypt(xorBytes(key[16:32], derived[16:32]), derived[32:64])...)
+	return base58CheckEncode(payload), nil
+}
+
+// DecryptBIP38 decrypts a BIP38 encrypted private key, with or without EC multiplication
+func DecryptBIP38(encrypted string, passphrase string, network *Network) (*PrivateKey, error) {
+	payload, err := base58CheckDecode(encrypted)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) != 39 {
+		return nil, errors.New("invalid encrypted key length")
+	}
+
+	flag := payload[2]
+	compressed := flag&bip38FlagCompressed != 0
+	addressHash := payload[3:7]
+
+	var key *big.Int
+	switch {
+	case bytes.Equal(payload[0:2], bip38NonECMultiplyPrefix):
+		if flag&^bip38FlagCompressed != bip38FlagNonECMultiply {
+			return nil, errors.New("invalid encrypted key flag")
+		}
+
+		derived, err := scrypt.Key(bip38Passphrase(passphrase), addressHash, 16384, 8, 8, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		half1 := xorBytes(bip38Decrypt(payload[7:23], derived[32:64]), derived[0:16])
+		half2 := xorBytes(bip38Decrypt(payload[23:39], derived[32:64]), derived[16:32])
+		key = new(big.Int).SetBytes(append(half1, half2...))
+	case bytes.Equal(payload[0:2], bip38ECMultiplyPrefix):
+		if flag&^(bip38FlagCompressed|bip38FlagLotSequence) != 0 {
+			return nil, errors.New("invalid encrypted key flag")
+		}
+		ownerEntropy := payload[7:15]
+
+		passFactor, err := bip38PassFactor(passphrase, ownerEntropy, flag&bip38FlagLotSequence != 0)
+		if err != nil {
+			return nil, err
+		}
+		derived, err := bip38DerivedKey(bip38PassPoint(passFactor), addressHash, ownerEntropy)
+		if err != nil {
+			return nil, err
+		}
+
+		/* encryptedpart2 holds the end of encryptedpart1 and seedb[16:24] */
+		part2 := xorBytes(bip38Decrypt(payload[23:39], derived[32:64]), derived[16:32])
+		encryptedPart1 := append(append([]byte{}, payload[15:23]...), part2[0:8]...)
+		seedB := xorBytes(bip38Decrypt(encryptedPart1, derived[32:64]), derived[0:16])
+		seedB = append(seedB, part2[8:16]...)
+
+		factorB := new(big.Int).SetBytes(doubleHash(seedB))
+		key = new(big.Int).Mul(passFactor, factorB)
+		key.Mod(key, secp256k1.N)
+	default:
+		return nil, errors.New("unknown encrypted key prefix")
+	}
+
+	if key.Sign() == 0 || key.Cmp(secp256k1.N) >= 0 {
+		return nil, errors.New("invalid private key")
+	}
+
+	privateKey, err := PrivateFromHex(paddedHex(key), network)
+	if err != nil {
+		return nil, err
+	}
+	privateKey.SetCompressed(compressed)
+
+	/* The address hash verifies the passphrase */
+	publicKey, valid := privateKey.GetPublicKey()
+	if !valid {
+		return nil, errors.New("invalid private key")
+	}
+	address, err := publicKey.Address(compressed)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(doubleHash([]byte(address))[0:4], addressHash) {
+		return nil, errors.New("invalid passphrase")
+	}
+
+	return privateKey, nil
+}
+
+// NewBIP38IntermediateCode creates the intermediate code of a passphrase, which lets a third party generate keys encrypted with the passphrase
+func NewBIP38IntermediateCode(passphrase string) (string, error) {
+	ownerSalt := make([]byte, 8)
+	if _, err := rand.Read(ownerSalt); err != nil {
+		return "", err
+	}
+	return bip38IntermediateCode(passphrase, ownerSalt, false)
+}
+
+// NewBIP38IntermediateCodeWithLot creates an intermediate code whose keys carry a lot and sequence number
+func NewBIP38IntermediateCodeWithLot(passphrase string, lot uint32, sequence uint32) (string, error) {
+	if lot > bip38MaxLot || sequence > bip38MaxSequence {
+		return "", errors.New("lot or sequence number out of range")
+	}
+
+	ownerEntropy := make([]byte, 8)
+	if _, err := rand.Read(ownerEntropy[0:4]); err != nil {
+		return "", err
+	}
+	binary.BigEndian.PutUint32(ownerEntropy[4:8], lot*4096+sequence)
+	return bip38IntermediateCode(passphrase, ownerEntropy, true)
+}
+
+/* bip38IntermediateCode encodes the passpoint of the passphrase with its owner entropy */
+func bip38IntermediateCode(passphrase string, ownerEntropy []byte, lotSequence bool) (string, error) {
+	passFactor, err := bip38PassFactor(passphrase, ownerEntropy, lotSequence)
+	if err != nil {
+		return "", err
+	}
+
+	magic := bip38IntermediateMagic
+	if lotSequence {
+		magic = bip38IntermediateMagicLotSeq
+	}
+	payload := append(append([]byte{}, magic...), ownerEntropy...)
+	payload = append(payload, bip38PassPoint(passFactor)...)
+	return base58CheckEncode(payload), nil
+}
+
+// NewBIP38EncryptedKey generates a new private key encrypted with the passphrase of an intermediate code, it returns the encrypted key, its confirmation code and its address
+func NewBIP38EncryptedKey(intermediateCode string, compressed bool, network *Network) (string, string, string, error) {
+	seedB := make([]byte, 24)
+	if _, err := rand.Read(seedB); err != nil {
+		return "", "", "", err
+	}
+	return bip38EncryptedKey(intermediateCode, seedB, compressed, network)
+}
+
+/* bip38EncryptedKey encrypts the private key passfactor*factorb, only the owner of the passphrase knows passfactor */
+func bip38EncryptedKey(intermediateCode string, seedB []byte, compressed bool, network *Network) (string, string, string, error) {
+	payload, err := base58CheckDecode(intermediateCode)
+	if err != nil {
+		return "", "", "", err
+	}
+	if len(payload) != 49 {
+		return "", "", "", errors.New("invalid intermediate code length")
+	}
+
+	var flag byte
+	switch {
+	case bytes.Equal(payload[0:8], bip38IntermediateMagic):
+	case bytes.Equal(payload[0:8], bip38IntermediateMagicLotSeq):
+		flag |= bip38FlagLotSequence
+	default:
+		return "", "", "", errors.New("invalid intermediate code magic")
+	}
+	if compressed {
+		flag |= bip38FlagCompressed
+	}
+	ownerEntropy := payload[8:16]
+
+	passPoint, err := publicFromBytes(payload[16:49], network)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	factorB := new(big.Int).SetBytes(doubleHash(seedB))
+	if factorB.Sign() == 0 || factorB.Cmp(secp256k1.N) >= 0 {
+		return "", "", "", errors.New("invalid seed")
+	}
+
+	generated := scalarMult(factorB, passPoint.point())
+	address, err := (&PublicKey{X: generated.X, Y: generated.Y, Compressed: compressed, Network: network}).Address(compressed)
+	if err != nil {
+		return "", "", "", err
+	}
+	addressHash := doubleHash([]byte(address))[0:4]
+
+	derived, err := bip38DerivedKey(payload[16:49], addressHash, ownerEntropy)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	part1 := bip38Encrypt(xorBytes(seedB[0:16], derived[0:16]), derived[32:64])
+	part2 := bip38Encrypt(xorBytes(append(append([]byte{}, part1[8:16]...), seedB[16:24]...), derived[16:32]), derived[32:64])
+
+	encrypted := append(append([]byte{}, bip38ECMultiplyPrefix...), flag)
+	encrypted = append(encrypted, addressHash...)
+	encrypted = append(encrypted, ownerEntropy...)
+	encrypted = append(encrypted, part1[0:8]...)
+	encrypted = append(encrypted, part2...)
+
+	/* The confirmation code encrypts pointb = factorb*G, the prefix byte is masked with the last bit of derivedhalf2 */
+	pointB := bip38PassPoint(factorB)
+	confirmation := append(append([]byte{}, bip38ConfirmationCodePrefix...), flag)
+	confirmation = append(confirmation, addressHash...)
+	confirmation = append(confirmation, ownerEntropy...)
+	confirmation = append(confirmation, pointB[0]^(derived[63]&0x01))
+	confirmation = append(confirmation, bip38Encrypt(xorBytes(pointB[1:17], derived[0:16]), derived[32:64])...)
+	confirmation = append(confirmation, bip38Encrypt(xorBytes(pointB[17:33], derived[16:32]), derived[32:64])...)
+
+	return base58CheckEncode(encrypted), base58CheckEncode(confirmation), address, nil
+}
+
+// VerifyBIP38ConfirmationCode checks a confirmation code with the passphrase and returns the address of the encrypted key
+func VerifyBIP38ConfirmationCode(confirmationCode string, passphrase string, network *Network) (string, error) {
+	payload, err := base58CheckDecode(confirmationCode)
+	if err != nil {
+		return "", err
+	}
+	if len(payload) != 51 || !bytes.Equal(payload[0:5], bip38ConfirmationCodePrefix) {
+		return "", errors.New("invalid confirmation code")
+	}
+
+	flag := payload[5]
+	if flag&^(bip38FlagCompressed|bip38FlagLotSequence) != 0 {
+		return "", errors.New("invalid confirmation code flag")
+	}
+	compressed := flag&bip38FlagCompressed != 0
+	addressHash := payload[6:10]
+	ownerEntropy := payload[10:18]
+
+	passFactor, err := bip38PassFactor(passphrase, ownerEntropy, flag&bip38FlagLotSequence != 0)
+	if err != nil {
+		return "", err
+	}
+	derived, err := bip38DerivedKey(bip38PassPoint(passFactor), addressHash, ownerEntropy)
+	if err != nil {
+		return "", err
+	}
+
+	pointB := []byte{payload[18] ^ (derived[63] & 0x01)}
+	pointB = append(pointB, xorBytes(bip38Decrypt(payload[19:35], derived[32:64]), derived[0:16])...)
+	pointB = append(pointB, xorBytes(bip38Decrypt(payload[35:51], derived[32:64]), derived[16:32])...)
+	publicKey, err := publicFromBytes(pointB, network)
+	if err != nil {
+		return "", errors.New("invalid passphrase")
+	}
+
+	generated := scalarMult(passFactor, publicKey.point())
+	address, err := (&PublicKey{X: generated.X, Y: generated.Y, Compressed: compressed, Network: network}).Address(compressed)
+	if err != nil {
+		return "", err
+	}
+	if !bytes.Equal(doubleHash([]byte(address))[0:4], addressHash) {
+		return "", errors.New("invalid passphrase")
+	}
+	return address, nil
+}
+
+/* bip38Passphrase returns the NFC normalized UTF-8 bytes of a passphrase */
+func bip38Passphrase(passphrase string) []byte {
+	return []byte(norm.NFC.String(passphrase))
+}
+
+/* bip38PassFactor derives passfactor from the passphrase, only the first 4 bytes of the owner entropy are salt when it holds a lot and sequence number */
+func bip38PassFactor(passphrase string, ownerEntropy []byte, lotSequence bool) (*big.Int, error) {
+	ownerSalt := ownerEntropy
+	if lotSequence {
+		ownerSalt = ownerEntropy[0:4]
+	}
+
+	preFactor, err := scrypt.Key(bip38Passphrase(passphrase), ownerSalt, 16384, 8, 8, 32)
+	if err != nil {
+		return nil, err
+	}
+	passFactor := preFactor
+	if lotSequence {
+		passFactor = doubleHash(append(preFactor, ownerEntropy...))
+	}
+
+	factor := new(big.Int).SetBytes(passFactor)
+	if factor.Sign() == 0 || factor.Cmp(secp256k1.N) >= 0 {
+		return nil, errors.New("invalid passphrase factor")
+	}
+	return factor, nil
+}
+
+/* bip38PassPoint returns the compressed serialization of factor*G, the passpoint of passfactor and pointb of factorb */
+func bip38PassPoint(factor *big.Int) []byte {
+	P := scalarBaseMult(factor)
+	return (&PublicKey{X: P.X, Y: P.Y}).serialize(true)
+}
+
+/* bip38DerivedKey derives the 64 bytes key encrypting seedb and pointb from the passpoint */
+func bip38DerivedKey(passPoint []byte, addressHash []byte, ownerEntropy []byte) ([]byte, error) {
+	salt := append(append([]byte{}, addressHash...), ownerEntropy...)
+	return scrypt.Key(passPoint, salt, 1024, 1, 1, 64)
+}
+
+/* bip38Encrypt encrypts a 16 bytes block with AES-256 in ECB mode */
+func bip38Encrypt(block []byte, key []byte) []byte {
+	cipher, _ := aes.NewCipher(key)
+	out := make([]byte, 16)
+	cipher.Encrypt(out, block)
+	return out
+}
+
+/* bip38Decrypt decrypts a 16 bytes block with AES-256 in ECB mode */
+func bip38Decrypt(block []byte, key []byte) []byte {
+	cipher, _ := aes.NewCipher(key)
+	out := make([]byte, 16)
+	cipher.Decrypt(out, block)
+	return out
+}
+
+/* xorBytes returns a xor b, both have the same length */
+func xorBytes(a []byte, b []byte) []byte {
+	out := make([]byte, len(a))
+	for i := range a {
+		out[i] = a[i] ^ b[i]
+	}
+	return out
+}
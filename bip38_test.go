@@ -0,0 +1,151 @@
+package btc
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type bip38Vectors struct {
+	NoECMultiply []struct {
+		Passphrase string `json:"passphrase"`
+		Encrypted  string `json:"encrypted"`
+		WIF        string `json:"wif"`
+	} `json:"no_ec_multiply"`
+	ECMultiply []struct {
+		Passphrase   string `json:"passphrase"`
+		Intermediate string `json:"intermediate"`
+		Encrypted    string `json:"encrypted"`
+		Address      string `json:"address"`
+		WIF          string `json:"wif"`
+		Confirmation string `json:"confirmation"`
+		Lot          uint32 `json:"lot"`
+		Sequence     uint32 `json:"sequence"`
+	} `json:"ec_multiply"`
+}
+
+func loadBIP38Vectors(t *testing.T) bip38Vectors {
+	data, err := ioutil.ReadFile("testdata/bip38_vectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var vectors bip38Vectors
+	err = json.Unmarshal(data, &vectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return vectors
+}
+
+func TestBIP38(t *testing.T) {
+	vectors := loadBIP38Vectors(t)
+
+	for _, test := range vectors.NoECMultiply {
+		key, err := PrivateFromWIF(test.WIF, MainNetwork)
+		if !assert.Nil(t, err, test.WIF) {
+			continue
+		}
+
+		encrypted, err := key.EncryptBIP38(test.Passphrase)
+		assert.Nil(t, err)
+		assert.Equal(t, test.Encrypted, encrypted)
+
+		decrypted, err := DecryptBIP38(test.Encrypted, test.Passphrase, MainNetwork)
+		if !assert.Nil(t, err, test.Encrypted) {
+			continue
+		}
+		assert.Equal(t, test.WIF, decrypted.WIF)
+		assert.Equal(t, key.Compressed, decrypted.Compressed)
+	}
+
+	/* A wrong passphrase does not match the address hash */
+	_, err := DecryptBIP38(vectors.NoECMultiply[0].Encrypted, "TestingOneTwoThree!", MainNetwork)
+	assert.NotNil(t, err)
+}
+
+func TestBIP38ECMultiply(t *testing.T) {
+	vectors := loadBIP38Vectors(t)
+
+	for _, test := range vectors.ECMultiply {
+		decrypted, err := DecryptBIP38(test.Encrypted, test.Passphrase, MainNetwork)
+		if !assert.Nil(t, err, test.Encrypted) {
+			continue
+		}
+		assert.Equal(t, test.WIF, decrypted.WIF)
+		publicKey, _ := decrypted.GetPublicKey()
+		address, err := publicKey.Address(false)
+		assert.Nil(t, err)
+		assert.Equal(t, test.Address, address)
+
+		/* The intermediate code is deterministic given its owner entropy */
+		payload, err := base58CheckDecode(test.Intermediate)
+		if !assert.Nil(t, err) {
+			continue
+		}
+		ownerEntropy := payload[8:16]
+		intermediate, err := bip38IntermediateCode(test.Passphrase, ownerEntropy, test.Lot != 0)
+		assert.Nil(t, err)
+		assert.Equal(t, test.Intermediate, intermediate)
+
+		if test.Confirmation != "" {
+			assert.Equal(t, test.Lot*4096+test.Sequence, binary.BigEndian.Uint32(ownerEntropy[4:8]))
+
+			address, err := VerifyBIP38ConfirmationCode(test.Confirmation, test.Passphrase, MainNetwork)
+			assert.Nil(t, err)
+			assert.Equal(t, test.Address, address)
+		}
+	}
+}
+
+func TestBIP38ECMultiplyGeneration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("scrypt is slow")
+	}
+
+	passphrase := "TestingOneTwoThree"
+	intermediate, err := NewBIP38IntermediateCode(passphrase)
+	assert.Nil(t, err)
+	assert.Equal(t, "passphrase", intermediate[0:10])
+
+	lotIntermediate, err := NewBIP38IntermediateCodeWithLot(passphrase, 263183, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "passphrase", lotIntermediate[0:10])
+
+	_, err = NewBIP38IntermediateCodeWithLot(passphrase, 1048576, 1)
+	assert.NotNil(t, err)
+	_, err = NewBIP38IntermediateCodeWithLot(passphrase, 0, 4096)
+	assert.NotNil(t, err)
+
+	for _, code := range []string{intermediate, lotIntermediate} {
+		for _, compressed := range []bool{false, true} {
+			encrypted, confirmation, address, err := NewBIP38EncryptedKey(code, compressed, MainNetwork)
+			if !assert.Nil(t, err) {
+				continue
+			}
+			assert.Equal(t, "6P", encrypted[0:2])
+			assert.Equal(t, "cfrm38", confirmation[0:6])
+
+			/* Only the owner of the passphrase can decrypt the key and check the confirmation code */
+			key, err := DecryptBIP38(encrypted, passphrase, MainNetwork)
+			if !assert.Nil(t, err) {
+				continue
+			}
+			assert.Equal(t, compressed, key.Compressed)
+			publicKey, _ := key.GetPublicKey()
+			keyAddress, err := publicKey.Address(compressed)
+			assert.Nil(t, err)
+			assert.Equal(t, address, keyAddress)
+
+			confirmedAddress, err := VerifyBIP38ConfirmationCode(confirmation, passphrase, MainNetwork)
+			assert.Nil(t, err)
+			assert.Equal(t, address, confirmedAddress)
+
+			_, err = VerifyBIP38ConfirmationCode(confirmation, "Satoshi", MainNetwork)
+			assert.NotNil(t, err)
+		}
+	}
+}
@@ -14,6 +14,9 @@ var MainNetwork *Network
 // TestNetwork contains testnet parameters
 var TestNetwork *Network
 
+// RegTestNetwork contains regtest parameters
+var RegTestNetwork *Network
+
 func init() {
 	secp256k1.A, _ = new(big.Int).SetString("0000000000000000000000000000000000000000000000000000000000000000", 16)
 	secp256k1.B, _ = new(big.Int).SetString("0000000000000000000000000000000000000000000000000000000000000007", 16)
@@ -28,10 +31,75 @@ func init() {
 	MainNetwork = &Network{
 		PrivKeyPrefix:    "80",
 		PubKeyHashPrefix: "00",
+		ScriptHashPrefix: "05",
+		Bech32HRP:        "bc",
+
+		CoinType: 0,
+
+		ExtendedPrivKeyPrefix: "0488ADE4",
+		ExtendedPubKeyPrefix:  "0488B21E",
+
+		SLIP132PrivKeyPrefixes: map[ExtendedKeyType]string{
+			P2SHP2WPKHExtendedKey: "049D7878",
+			P2WPKHExtendedKey:     "04B2430C",
+			P2SHP2WSHExtendedKey:  "0295B005",
+			P2WSHExtendedKey:      "02AA7A99",
+		},
+		SLIP132PubKeyPrefixes: map[ExtendedKeyType]string{
+			P2SHP2WPKHExtendedKey: "049D7CB2",
+			P2WPKHExtendedKey:     "04B24746",
+			P2SHP2WSHExtendedKey:  "0295B43F",
+			P2WSHExtendedKey:      "02AA7ED3",
+		},
 	}
 
 	TestNetwork = &Network{
 		PrivKeyPrefix:    "EF",
 		PubKeyHashPrefix: "6F",
+		ScriptHashPrefix: "C4",
+		Bech32HRP:        "tb",
+
+		CoinType: 1,
+
+		ExtendedPrivKeyPrefix: "04358394",
+		ExtendedPubKeyPrefix:  "043587CF",
+
+		SLIP132PrivKeyPrefixes: map[ExtendedKeyType]string{
+			P2SHP2WPKHExtendedKey: "044A4E28",
+			P2WPKHExtendedKey:     "045F18BC",
+			P2SHP2WSHExtendedKey:  "024285B5",
+			P2WSHExtendedKey:      "02575048",
+		},
+		SLIP132PubKeyPrefixes: map[ExtendedKeyType]string{
+			P2SHP2WPKHExtendedKey: "044A5262",
+			P2WPKHExtendedKey:     "045F1CF6",
+			P2SHP2WSHExtendedKey:  "024289EF",
+			P2WSHExtendedKey:      "02575483",
+		},
+	}
+
+	RegTestNetwork = &Network{
+		PrivKeyPrefix:    "EF",
+		PubKeyHashPrefix: "6F",
+		ScriptHashPrefix: "C4",
+		Bech32HRP:        "bcrt",
+
+		CoinType: 1,
+
+		ExtendedPrivKeyPrefix: "04358394",
+		ExtendedPubKeyPrefix:  "043587CF",
+
+		SLIP132PrivKeyPrefixes: map[ExtendedKeyType]string{
+			P2SHP2WPKHExtendedKey: "044A4E28",
+			P2WPKHExtendedKey:     "045F18BC",
+			P2SHP2WSHExtendedKey:  "024285B5",
+			P2WSHExtendedKey:      "02575048",
+		},
+		SLIP132PubKeyPrefixes: map[ExtendedKeyType]string{
+			P2SHP2WPKHExtendedKey: "044A5262",
+			P2WPKHExtendedKey:     "045F1CF6",
+			P2SHP2WSHExtendedKey:  "024289EF",
+			P2WSHExtendedKey:      "02575483",
+		},
 	}
 }
@@ -0,0 +1,421 @@
+package btc
+
+import (
+	"math/big"
+	"math/bits"
+	"sync"
+
+	"github.com/aureleoules/ecdsa"
+)
+
+/* The zero value of ecdsa.Point is the point at infinity */
+
+/* jacobianPoint is the curve point (x/z^2, y/z^3), z = 0 is the point at infinity */
+type jacobianPoint struct {
+	x, y, z fieldElement
+}
+
+/* affinePoint is a curve point with z = 1, it is never the point at infinity */
+type affinePoint struct {
+	x, y fieldElement
+}
+
+/* generatorTables are the precomputed multiples of G */
+type generatorTables struct {
+	/* comb[i][j] = j*16^i*G for j in 1..15, used by the constant-time base multiplication */
+	comb [64][16]affinePoint
+	/* odd[i] = (2i+1)*G, used by the variable-time wNAF multiplication */
+	odd [generatorWindowSize]affinePoint
+}
+
+/* generatorWindow is the wNAF window of G, wider than the one of other points since its table is computed once */
+const generatorWindow = 8
+
+const generatorWindowSize = 1 << (generatorWindow - 2)
+
+/* pointWindow is the wNAF window of the other points */
+const pointWindow = 5
+
+var (
+	generatorOnce  sync.Once
+	generatorTable *generatorTables
+)
+
+/* jacobianFromPoint converts an affine point */
+func jacobianFromPoint(P ecdsa.Point) jacobianPoint {
+	if P.IsInfinity() {
+		return jacobianPoint{}
+	}
+	return jacobianPoint{x: fieldFromBig(P.X), y: fieldFromBig(P.Y), z: fieldOne}
+}
+
+/* point converts p to affine coordinates */
+func (p jacobianPoint) point() ecdsa.Point {
+	if p.isInfinity() == 1 {
+		return ecdsa.Point{}
+	}
+	zInverse := p.z.inverse()
+	zInverse2 := zInverse.square()
+	return ecdsa.Point{X: p.x.mul(zInverse2).big(), Y: p.y.mul(zInverse2).mul(zInverse).big()}
+}
+
+/* isInfinity returns 1 if p is the point at infinity */
+func (p jacobianPoint) isInfinity() uint64 {
+	return p.z.isZero()
+}
+
+/* negate returns -p */
+func (p jacobianPoint) negate() jacobianPoint {
+	return jacobianPoint{x: p.x, y: p.y.negate(), z: p.z}
+}
+
+/* jacobian converts a to Jacobian coordinates */
+func (a affinePoint) jacobian() jacobianPoint {
+	return jacobianPoint{x: a.x, y: a.y, z: fieldOne}
+}
+
+/* negate returns -a */
+func (a affinePoint) negate() affinePoint {
+	return affinePoint{x: a.x, y: a.y.negate()}
+}
+
+/* jacobianSelect returns p if cond is 1 and q if cond is 0, in constant time */
+func jacobianSelect(cond uint64, p jacobianPoint, q jacobianPoint) jacobianPoint {
+	return jacobianPoint{
+		x: fieldSelect(cond, p.x, q.x),
+		y: fieldSelect(cond, p.y, q.y),
+		z: fieldSelect(cond, p.z, q.z),
+	}
+}
+
+/* affineSelect returns a if cond is 1 and b if cond is 0, in constant time */
+func affineSelect(cond uint64, a affinePoint, b affinePoint) affinePoint {
+	return affinePoint{x: fieldSelect(cond, a.x, b.x), y: fieldSelect(cond, a.y, b.y)}
+}
+
+/* equalWord returns 1 if a = b, in constant time */
+func equalWord(a uint64, b uint64) uint64 {
+	x := a ^ b
+	return 1 ^ (x|-x)>>63
+}
+
+/* double computes 2*p (dbl-2009-l), the double of the point at infinity is the point at infinity */
+func (p jacobianPoint) double() jacobianPoint {
+	a := p.x.square()
+	b := p.y.square()
+	c := b.square()
+	d := p.x.add(b).square().sub(a).sub(c)
+	d = d.add(d)
+	e := a.add(a).add(a)
+
+	x := e.square().sub(d.add(d))
+	c8 := c.add(c)
+	c8 = c8.add(c8)
+	c8 = c8.add(c8)
+	y := e.mul(d.sub(x)).sub(c8)
+	z := p.y.mul(p.z)
+	return jacobianPoint{x: x, y: y, z: z.add(z)}
+}
+
+/* addRaw computes p + q (add-2007-bl) when p and q are distinct and not at infinity, it also returns whether p and q have the same x and y coordinates */
+func (p jacobianPoint) addRaw(q jacobianPoint) (jacobianPoint, uint64, uint64) {
+	z1z1 := p.z.square()
+	z2z2 := q.z.square()
+	u1 := p.x.mul(z2z2)
+	u2 := q.x.mul(z1z1)
+	s1 := p.y.mul(q.z).mul(z2z2)
+	s2 := q.y.mul(p.z).mul(z1z1)
+
+	h := u2.sub(u1)
+	i := h.add(h).square()
+	j := h.mul(i)
+	r := s2.sub(s1)
+	r = r.add(r)
+	v := u1.mul(i)
+
+	x := r.square().sub(j).sub(v.add(v))
+	s1j := s1.mul(j)
+	y := r.mul(v.sub(x)).sub(s1j.add(s1j))
+	z := p.z.add(q.z).square().sub(z1z1).sub(z2z2).mul(h)
+	return jacobianPoint{x: x, y: y, z: z}, h.isZero(), r.isZero()
+}
+
+/* addAffineRaw computes p + q (madd-2007-bl) when p and q are distinct and p is not at infinity, it also returns whether p and q have the same x and y coordinates */
+func (p jacobianPoint) addAffineRaw(q affinePoint) (jacobianPoint, uint64, uint64) {
+	z1z1 := p.z.square()
+	u2 := q.x.mul(z1z1)
+	s2 := q.y.mul(p.z).mul(z1z1)
+
+	h := u2.sub(p.x)
+	hh := h.square()
+	i := hh.add(hh)
+	i = i.add(i)
+	j := h.mul(i)
+	r := s2.sub(p.y)
+	r = r.add(r)
+	v := p.x.mul(i)
+
+	x := r.square().sub(j).sub(v.add(v))
+	yj := p.y.mul(j)
+	y := r.mul(v.sub(x)).sub(yj.add(yj))
+	z := p.z.add(h).square().sub(z1z1).sub(hh)
+	return jacobianPoint{x: x, y: y, z: z}, h.isZero(), r.isZero()
+}
+
+/* add computes p + q in constant time, the doubling and infinity cases are computed and selected */
+func (p jacobianPoint) add(q jacobianPoint) jacobianPoint {
+	sum, sameX, sameY := p.addRaw(q)
+	/* P + (-P) has h = 0 and therefore z = 0 */
+	sum = jacobianSelect(sameX&sameY, p.double(), sum)
+	sum = jacobianSelect(p.isInfinity(), q, sum)
+	return jacobianSelect(q.isInfinity(), p, sum)
+}
+
+/* addAffine computes p + q in constant time */
+func (p jacobianPoint) addAffine(q affinePoint) jacobianPoint {
+	sum, sameX, sameY := p.addAffineRaw(q)
+	sum = jacobianSelect(sameX&sameY, p.double(), sum)
+	return jacobianSelect(p.isInfinity(), q.jacobian(), sum)
+}
+
+/* addVar computes p + q in variable time, for public points only */
+func (p jacobianPoint) addVar(q jacobianPoint) jacobianPoint {
+	if p.isInfinity() == 1 {
+		return q
+	}
+	if q.isInfinity() == 1 {
+		return p
+	}
+	sum, sameX, sameY := p.addRaw(q)
+	if sameX == 1 && sameY == 1 {
+		return p.double()
+	}
+	return sum
+}
+
+/* addAffineVar computes p + q in variable time, for public points only */
+func (p jacobianPoint) addAffineVar(q affinePoint) jacobianPoint {
+	if p.isInfinity() == 1 {
+		return q.jacobian()
+	}
+	sum, sameX, sameY := p.addAffineRaw(q)
+	if sameX == 1 && sameY == 1 {
+		return p.double()
+	}
+	return sum
+}
+
+/* batchToAffine converts points which are not at infinity to affine coordinates with a single inversion (Montgomery's trick) */
+func batchToAffine(points []jacobianPoint) []affinePoint {
+	products := make([]fieldElement, len(points))
+	product := fieldOne
+	for i, p := range points {
+		product = product.mul(p.z)
+		products[i] = product
+	}
+
+	affine := make([]affinePoint, len(points))
+	inverse := product.inverse()
+	for i := len(points) - 1; i >= 0; i-- {
+		/* inverse = (z0*...*zi)^-1 */
+		zInverse := inverse
+		if i > 0 {
+			zInverse = inverse.mul(products[i-1])
+		}
+		inverse = inverse.mul(points[i].z)
+
+		zInverse2 := zInverse.square()
+		affine[i] = affinePoint{x: points[i].x.mul(zInverse2), y: points[i].y.mul(zInverse2).mul(zInverse)}
+	}
+	return affine
+}
+
+/* generator returns the tables of G, they are computed on first use */
+func generator() *generatorTables {
+	generatorOnce.Do(func() {
+		G := jacobianFromPoint(secp256k1.G)
+
+		points := make([]jacobianPoint, 0, 64*15+generatorWindowSize)
+		base := G
+		for i := 0; i < 64; i++ {
+			multiple := base
+			for j := 1; j < 16; j++ {
+				points = append(points, multiple)
+				multiple = multiple.addVar(base)
+			}
+			/* multiple = 16*base */
+			base = multiple
+		}
+
+		G2 := G.double()
+		odd := G
+		for i := 0; i < generatorWindowSize; i++ {
+			points = append(points, odd)
+			odd = odd.addVar(G2)
+		}
+
+		affine := batchToAffine(points)
+		generatorTable = &generatorTables{}
+		for i := 0; i < 64; i++ {
+			copy(generatorTable.comb[i][1:], affine[i*15:(i+1)*15])
+		}
+		copy(generatorTable.odd[:], affine[64*15:])
+	})
+	return generatorTable
+}
+
+/* baseMult computes k*G in constant time, k is split in 64 groups of 4 bits whose multiples of G are all precomputed so only additions are needed */
+func baseMult(k scalar) jacobianPoint {
+	table := generator()
+
+	var R jacobianPoint
+	for i := 0; i < 64; i++ {
+		d := k.nibble(i)
+
+		/* All the entries are read so that memory accesses do not depend on k */
+		entry := table.comb[i][1]
+		for j := 2; j < 16; j++ {
+			entry = affineSelect(equalWord(uint64(j), d), table.comb[i][j], entry)
+		}
+		R = jacobianSelect(equalWord(d, 0), R, R.addAffine(entry))
+	}
+	return R
+}
+
+/* pointMult computes k*P in constant time with a fixed 4 bits window */
+func pointMult(k scalar, P jacobianPoint) jacobianPoint {
+	var table [16]jacobianPoint
+	table[1] = P
+	for i := 2; i < 16; i++ {
+		table[i] = table[i-1].add(P)
+	}
+
+	var R jacobianPoint
+	for i := 63; i >= 0; i-- {
+		R = R.double().double().double().double()
+
+		d := k.nibble(i)
+		var entry jacobianPoint
+		for j := 1; j < 16; j++ {
+			entry = jacobianSelect(equalWord(uint64(j), d), table[j], entry)
+		}
+		R = R.add(entry)
+	}
+	return R
+}
+
+/* wnaf returns the width w non-adjacent form of k, least significant digit first, each non-zero digit is odd and lower than 2^(w-1) in absolute value */
+func (k scalar) wnaf(w uint) []int8 {
+	var n [5]uint64
+	copy(n[:4], k[:])
+
+	digits := make([]int8, 0, 257)
+	for n[0]|n[1]|n[2]|n[3]|n[4] != 0 {
+		var d int64
+		if n[0]&1 == 1 {
+			d = int64(n[0] & (1<<w - 1))
+			if d >= 1<<(w-1) {
+				d -= 1 << w
+			}
+
+			/* n -= d, the lowest w bits of n become 0 */
+			var carry uint64
+			if d > 0 {
+				n[0], carry = bits.Sub64(n[0], uint64(d), 0)
+				for i := 1; i < 5; i++ {
+					n[i], carry = bits.Sub64(n[i], 0, carry)
+				}
+			} else {
+				n[0], carry = bits.Add64(n[0], uint64(-d), 0)
+				for i := 1; i < 5; i++ {
+					n[i], carry = bits.Add64(n[i], 0, carry)
+				}
+			}
+		}
+		digits = append(digits, int8(d))
+
+		for i := 0; i < 4; i++ {
+			n[i] = n[i]>>1 | n[i+1]<<63
+		}
+		n[4] >>= 1
+	}
+	return digits
+}
+
+/* doubleMult computes u1*G + u2*P in variable time with interleaved wNAF (Strauss), for public scalars only */
+func doubleMult(u1 scalar, u2 scalar, P jacobianPoint) jacobianPoint {
+	return multiMult(u1, []scalar{u2}, []jacobianPoint{P})
+}
+
+/* multiMult computes u1*G + u[0]*P[0] + ... + u[k]*P[k] in variable time with interleaved wNAF (Strauss), for public scalars only */
+func multiMult(u1 scalar, u []scalar, P []jacobianPoint) jacobianPoint {
+	table := generator()
+	naf1 := u1.wnaf(generatorWindow)
+	length := len(naf1)
+
+	nafs := make([][]int8, len(P))
+	/* odd[j][i] = (2i+1)*P[j] */
+	odd := make([][1 << (pointWindow - 2)]jacobianPoint, len(P))
+	for j := range P {
+		if P[j].isInfinity() == 1 {
+			continue
+		}
+		nafs[j] = u[j].wnaf(pointWindow)
+		if len(nafs[j]) > length {
+			length = len(nafs[j])
+		}
+
+		P2 := P[j].double()
+		odd[j][0] = P[j]
+		for i := 1; i < len(odd[j]); i++ {
+			odd[j][i] = odd[j][i-1].addVar(P2)
+		}
+	}
+
+	var R jacobianPoint
+	for i := length - 1; i >= 0; i-- {
+		R = R.double()
+
+		if i < len(naf1) && naf1[i] > 0 {
+			R = R.addAffineVar(table.odd[naf1[i]/2])
+		} else if i < len(naf1) && naf1[i] < 0 {
+			R = R.addAffineVar(table.odd[-naf1[i]/2].negate())
+		}
+
+		for j, naf := range nafs {
+			if i < len(naf) && naf[i] > 0 {
+				R = R.addVar(odd[j][naf[i]/2])
+			} else if i < len(naf) && naf[i] < 0 {
+				R = R.addVar(odd[j][-naf[i]/2].negate())
+			}
+		}
+	}
+	return R
+}
+
+/* addPoints computes P + Q, including the P + (-P) case */
+func addPoints(P ecdsa.Point, Q ecdsa.Point) ecdsa.Point {
+	return jacobianFromPoint(P).addVar(jacobianFromPoint(Q)).point()
+}
+
+/* scalarMult computes k*P, k is converted by scalarFromSecret and multiplied in constant time */
+func scalarMult(k *big.Int, P ecdsa.Point) ecdsa.Point {
+	s, _ := scalarFromSecret(k)
+	return pointMult(s, jacobianFromPoint(P)).point()
+}
+
+/* scalarBaseMult computes k*G, k is converted by scalarFromSecret and multiplied in constant time */
+func scalarBaseMult(k *big.Int) ecdsa.Point {
+	s, _ := scalarFromSecret(k)
+	return baseMult(s).point()
+}
+
+/* doubleScalarMult computes u1*G + u2*P in variable time, for signature verification */
+func doubleScalarMult(u1 scalar, u2 scalar, P ecdsa.Point) ecdsa.Point {
+	return doubleMult(u1, u2, jacobianFromPoint(P)).point()
+}
+
+/* point returns the curve point of a public key */
+func (p *PublicKey) point() ecdsa.Point {
+	return ecdsa.Point{X: p.X, Y: p.Y}
+}
@@ -0,0 +1,239 @@
+package btc
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/aureleoules/ecdsa"
+	"github.com/stretchr/testify/assert"
+)
+
+/* referenceScalarMult is the previous math/big double-and-add, kept to cross-check and benchmark the constant-time arithmetic */
+func referenceScalarMult(k *big.Int, P ecdsa.Point) ecdsa.Point {
+	var R ecdsa.Point
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		R = referenceAddPoints(R, R)
+		if k.Bit(i) == 1 {
+			R = referenceAddPoints(R, P)
+		}
+	}
+	return R
+}
+
+/* referenceAddPoints computes P + Q with the affine formulas of the ecdsa package */
+func referenceAddPoints(P ecdsa.Point, Q ecdsa.Point) ecdsa.Point {
+	if P.IsInfinity() {
+		return Q
+	}
+	if Q.IsInfinity() {
+		return P
+	}
+	if P.X.Cmp(Q.X) == 0 {
+		if P.Y.Cmp(Q.Y) != 0 || P.Y.Sign() == 0 {
+			return ecdsa.Point{}
+		}
+		R, _ := secp256k1.PointDoubling(P, Q)
+		return *R
+	}
+	R, _ := secp256k1.PointAddition(P, Q)
+	return *R
+}
+
+/* curveTestScalars returns edge cases and random scalars */
+func curveTestScalars(count int) []*big.Int {
+	nMinus := func(i int64) *big.Int { return new(big.Int).Sub(secp256k1.N, big.NewInt(i)) }
+	allNibbles, _ := new(big.Int).SetString("fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210", 16)
+	scalars := []*big.Int{
+		big.NewInt(0), big.NewInt(1), big.NewInt(2), big.NewInt(15), big.NewInt(16), big.NewInt(17),
+		nMinus(1), nMinus(2), secp256k1.N, new(big.Int).Add(secp256k1.N, big.NewInt(1)),
+		new(big.Int).Lsh(big.NewInt(1), 252), allNibbles,
+	}
+	r := rand.New(rand.NewSource(3))
+	for i := 0; i < count; i++ {
+		scalars = append(scalars, new(big.Int).Rand(r, secp256k1.N))
+	}
+	return scalars
+}
+
+func assertPointEqual(t *testing.T, expected ecdsa.Point, actual ecdsa.Point, msgAndArgs ...interface{}) {
+	assert.Equal(t, expected.IsInfinity(), actual.IsInfinity(), msgAndArgs...)
+	if !expected.IsInfinity() && !actual.IsInfinity() {
+		assertBigEqual(t, expected.X, actual.X, msgAndArgs...)
+		assertBigEqual(t, expected.Y, actual.Y, msgAndArgs...)
+	}
+}
+
+func TestScalarMult(t *testing.T) {
+	P := referenceScalarMult(big.NewInt(123456789), secp256k1.G)
+	for _, k := range curveTestScalars(20) {
+		expected := referenceScalarMult(k, secp256k1.G)
+		assertPointEqual(t, expected, scalarBaseMult(k), k.String())
+		assertPointEqual(t, expected, scalarMult(k, secp256k1.G), k.String())
+		assertPointEqual(t, referenceScalarMult(k, P), scalarMult(k, P), k.String())
+	}
+
+	/* Multiplying the point at infinity */
+	assertPointEqual(t, ecdsa.Point{}, scalarMult(big.NewInt(5), ecdsa.Point{}))
+}
+
+func TestDoubleScalarMult(t *testing.T) {
+	scalars := curveTestScalars(10)
+	P := referenceScalarMult(big.NewInt(987654321), secp256k1.G)
+	for i, u1 := range scalars {
+		u2 := scalars[(i*3+1)%len(scalars)]
+		expected := referenceAddPoints(referenceScalarMult(u1, secp256k1.G), referenceScalarMult(u2, P))
+		assertPointEqual(t, expected, doubleScalarMult(scalarFromBig(u1), scalarFromBig(u2), P), u1.String())
+	}
+
+	/* u1*G + u2*P is the point at infinity when P = -(u1/u2)*G */
+	u1 := scalarFromBig(big.NewInt(42))
+	u2 := scalarFromBig(big.NewInt(7))
+	Q := negatePoint(scalarBaseMult(big.NewInt(6)))
+	assertPointEqual(t, ecdsa.Point{}, doubleScalarMult(u1, u2, Q))
+	assertPointEqual(t, scalarBaseMult(big.NewInt(42)), doubleScalarMult(u1, u2, ecdsa.Point{}))
+}
+
+func TestMultiMult(t *testing.T) {
+	scalars := curveTestScalars(10)
+	u := make([]scalar, 0, len(scalars))
+	points := make([]jacobianPoint, 0, len(scalars))
+	expected := referenceScalarMult(scalars[0], secp256k1.G)
+	for i, k := range scalars {
+		P := referenceScalarMult(big.NewInt(int64(i+2)), secp256k1.G)
+		u = append(u, scalarFromBig(k))
+		points = append(points, jacobianFromPoint(P))
+		expected = referenceAddPoints(expected, referenceScalarMult(k, P))
+	}
+	assertPointEqual(t, expected, multiMult(scalarFromBig(scalars[0]), u, points).point())
+
+	/* Points at infinity and zero scalars are skipped */
+	points[1] = jacobianPoint{}
+	u[2] = scalar{}
+	expected = referenceScalarMult(scalars[0], secp256k1.G)
+	for i, k := range scalars {
+		if i != 1 && i != 2 {
+			expected = referenceAddPoints(expected, referenceScalarMult(k, referenceScalarMult(big.NewInt(int64(i+2)), secp256k1.G)))
+		}
+	}
+	assertPointEqual(t, expected, multiMult(scalarFromBig(scalars[0]), u, points).point())
+	assertPointEqual(t, ecdsa.Point{}, multiMult(scalar{}, nil, nil).point())
+}
+
+func TestAddPoints(t *testing.T) {
+	P := scalarBaseMult(big.NewInt(3))
+	Q := scalarBaseMult(big.NewInt(5))
+	assertPointEqual(t, scalarBaseMult(big.NewInt(8)), addPoints(P, Q))
+	assertPointEqual(t, scalarBaseMult(big.NewInt(6)), addPoints(P, P))
+	assertPointEqual(t, ecdsa.Point{}, addPoints(P, negatePoint(P)))
+	assertPointEqual(t, P, addPoints(P, ecdsa.Point{}))
+	assertPointEqual(t, P, addPoints(ecdsa.Point{}, P))
+	assertPointEqual(t, ecdsa.Point{}, addPoints(ecdsa.Point{}, ecdsa.Point{}))
+
+	/* Constant-time additions cover the same cases */
+	J := jacobianFromPoint(P)
+	assertPointEqual(t, scalarBaseMult(big.NewInt(6)), J.add(J).point())
+	assertPointEqual(t, ecdsa.Point{}, J.add(J.negate()).point())
+	assertPointEqual(t, P, J.add(jacobianPoint{}).point())
+	assertPointEqual(t, P, jacobianPoint{}.add(J).point())
+	assertPointEqual(t, scalarBaseMult(big.NewInt(6)), J.addAffine(affinePoint{x: J.x, y: J.y}).point())
+}
+
+func BenchmarkScalarBaseMult(b *testing.B) {
+	k := curveTestScalars(1)[12]
+	b.Run("ecdsa", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			secp256k1.ScalarMult(k, secp256k1.G)
+		}
+	})
+	b.Run("big", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			referenceScalarMult(k, secp256k1.G)
+		}
+	})
+	b.Run("comb", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			scalarBaseMult(k)
+		}
+	})
+}
+
+func BenchmarkScalarMult(b *testing.B) {
+	k := curveTestScalars(1)[12]
+	P := scalarBaseMult(big.NewInt(123456789))
+	b.Run("big", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			referenceScalarMult(k, P)
+		}
+	})
+	b.Run("window", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			scalarMult(k, P)
+		}
+	})
+}
+
+func BenchmarkVerify(b *testing.B) {
+	key := GeneratePrivateKey(MainNetwork)
+	publicKey, _ := key.GetPublicKey()
+	hash := doubleHash([]byte("benchmark"))
+	signature, err := key.Sign(hash)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	/* The verification of the previous releases computed u1*G + u2*Q with two double-and-add multiplications */
+	b.Run("big", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			w := new(big.Int).ModInverse(signature.S, secp256k1.N)
+			u1 := new(big.Int).Mul(hashToInt(hash), w)
+			u1.Mod(u1, secp256k1.N)
+			u2 := new(big.Int).Mul(signature.R, w)
+			u2.Mod(u2, secp256k1.N)
+			referenceAddPoints(referenceScalarMult(u1, secp256k1.G), referenceScalarMult(u2, publicKey.point()))
+		}
+	})
+	b.Run("wnaf", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			publicKey.Verify(hash, signature)
+		}
+	})
+}
+
+func BenchmarkVerifySchnorrBatch(b *testing.B) {
+	var publicKeys []*PublicKey
+	var messages, signatures [][]byte
+	for i := 0; i < 64; i++ {
+		key := GeneratePrivateKey(MainNetwork)
+		publicKey, _ := key.GetPublicKey()
+		message := doubleHash([]byte(key.WIF))
+		signature, err := key.SignSchnorr(message, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		publicKeys = append(publicKeys, publicKey)
+		messages = append(messages, message)
+		signatures = append(signatures, signature)
+	}
+
+	b.Run("single", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for j := range publicKeys {
+				publicKeys[j].VerifySchnorr(messages[j], signatures[j])
+			}
+		}
+	})
+	b.Run("batch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			VerifySchnorrBatch(publicKeys, messages, signatures)
+		}
+	})
+}
+
+func BenchmarkSign(b *testing.B) {
+	key := GeneratePrivateKey(MainNetwork)
+	hash := doubleHash([]byte("benchmark"))
+	for i := 0; i < b.N; i++ {
+		key.Sign(hash)
+	}
+}
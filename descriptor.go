@@ -0,0 +1,822 @@
+package btc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Descriptor types
+const (
+	PKDescriptor DescriptorType = iota
+	PKHDescriptor
+	WPKHDescriptor
+	SHDescriptor
+	WSHDescriptor
+	MultiDescriptor
+	SortedMultiDescriptor
+	TRDescriptor
+	AddrDescriptor
+	RawDescriptor
+)
+
+// Descriptor ranges
+const (
+	NotRanged DescriptorRange = iota
+	/* UnhardenedRange keys end with /*, HardenedRange keys with /*h or /*' */
+	UnhardenedRange
+	HardenedRange
+)
+
+var descriptorNames = map[DescriptorType]string{
+	PKDescriptor:          "pk",
+	PKHDescriptor:         "pkh",
+	WPKHDescriptor:        "wpkh",
+	SHDescriptor:          "sh",
+	WSHDescriptor:         "wsh",
+	MultiDescriptor:       "multi",
+	SortedMultiDescriptor: "sortedmulti",
+	TRDescriptor:          "tr",
+	AddrDescriptor:        "addr",
+	RawDescriptor:         "raw",
+}
+
+/* descriptorContext is where a script expression appears, it restricts the allowed expressions and keys */
+type descriptorContext int
+
+const (
+	topContext descriptorContext = iota
+	shContext
+	/* wshContext is also used for the key of wpkh */
+	wshContext
+	/* tapContext is used for the internal key and the leaves of tr */
+	tapContext
+)
+
+/* maxTaprootTreeDepth is the maximum depth of a taproot script tree (BIP341) */
+const maxTaprootTreeDepth = 128
+
+/* maxBareMultisigKeys is the maximum number of keys of a top level multisig, larger bare multisig scripts are not standard */
+const maxBareMultisigKeys = 3
+
+/* The position of a character in descriptorInputCharset gives its checksum symbols (BIP380) */
+const descriptorInputCharset = "0123456789()[],'/*abcdefgh@:$%{}" +
+	"IJKLMNOPQRSTUVWXYZ&+-.;<=>?!^_|~" +
+	"ijklmnopqrstuvwxyzABCDEFGH`#\"\\ "
+
+const descriptorChecksumCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+/* descriptorPolymod feeds a symbol to the BCH code of the descriptor checksum */
+func descriptorPolymod(c uint64, value int) uint64 {
+	generators := []uint64{0xf5dee51989, 0xa9fdca3312, 0x1bab10e32d, 0x3706b1677a, 0x644d626ffd}
+
+	top := c >> 35
+	c = (c&0x7ffffffff)<<5 ^ uint64(value)
+	for i, generator := range generators {
+		if (top>>uint(i))&1 == 1 {
+			c ^= generator
+		}
+	}
+	return c
+}
+
+// DescriptorChecksum computes the 8 characters checksum of a descriptor written without checksum (BIP380)
+func DescriptorChecksum(desc string) (string, error) {
+	c := uint64(1)
+	class, classCount := 0, 0
+	for i := 0; i < len(desc); i++ {
+		pos := strings.IndexByte(descriptorInputCharset, desc[i])
+		if pos < 0 {
+			return "", errors.New("invalid character in descriptor")
+		}
+
+		/* Each character is a symbol of its position in its group of 32, and groups of 3 characters add a symbol of their classes */
+		c = descriptorPolymod(c, pos&31)
+		class = class*3 + pos>>5
+		classCount++
+		if classCount == 3 {
+			c = descriptorPolymod(c, class)
+			class, classCount = 0, 0
+		}
+	}
+	if classCount > 0 {
+		c = descriptorPolymod(c, class)
+	}
+	for i := 0; i < 8; i++ {
+		c = descriptorPolymod(c, 0)
+	}
+	c ^= 1
+
+	checksum := make([]byte, 8)
+	for i := range checksum {
+		checksum[i] = descriptorChecksumCharset[(c>>uint(5*(7-i)))&31]
+	}
+	return string(checksum), nil
+}
+
+// ParseDescriptor parses an output script descriptor (BIP380), its checksum is verified if present
+func ParseDescriptor(desc string) (*Descriptor, error) {
+	var checksum string
+	if pos := strings.IndexByte(desc, '#'); pos >= 0 {
+		desc, checksum = desc[0:pos], desc[pos+1:]
+		if len(checksum) != 8 {
+			return nil, errors.New("descriptor checksum must be 8 characters long")
+		}
+	}
+
+	expected, err := DescriptorChecksum(desc)
+	if err != nil {
+		return nil, err
+	}
+	if checksum != "" && checksum != expected {
+		return nil, errors.New("invalid descriptor checksum")
+	}
+
+	return parseDescriptor(desc, topContext)
+}
+
+/* splitExpression splits name(args) into its name and its arguments */
+func splitExpression(expr string) (string, []string, bool) {
+	pos := strings.IndexByte(expr, '(')
+	if pos <= 0 || !strings.HasSuffix(expr, ")") {
+		return "", nil, false
+	}
+
+	args, ok := splitArguments(expr[pos+1 : len(expr)-1])
+	if !ok {
+		return "", nil, false
+	}
+	return expr[0:pos], args, true
+}
+
+/* splitArguments splits a list at the commas which are not nested in (), [] or {} */
+func splitArguments(list string) ([]string, bool) {
+	var args []string
+	depth, start := 0, 0
+	for i := 0; i < len(list); i++ {
+		switch list[i] {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+			if depth < 0 {
+				return nil, false
+			}
+		case ',':
+			if depth == 0 {
+				args = append(args, list[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, false
+	}
+	return append(args, list[start:]), true
+}
+
+/* parseDescriptor parses a script expression appearing in ctx */
+func parseDescriptor(expr string, ctx descriptorContext) (*Descriptor, error) {
+	name, args, ok := splitExpression(expr)
+	if !ok {
+		return nil, errors.New("expected a script expression")
+	}
+
+	switch name {
+	case "pk", "pkh", "wpkh":
+		if len(args) != 1 {
+			return nil, errors.New(name + " takes a single key")
+		}
+
+		d := &Descriptor{Type: PKDescriptor}
+		switch name {
+		case "pkh":
+			if ctx == tapContext {
+				return nil, errors.New("pkh is not allowed in tr")
+			}
+			d.Type = PKHDescriptor
+		case "wpkh":
+			if ctx != topContext && ctx != shContext {
+				return nil, errors.New("wpkh is only allowed at the top level or in sh")
+			}
+			d.Type = WPKHDescriptor
+			ctx = wshContext
+		}
+
+		key, err := parseDescriptorKey(args[0], ctx)
+		if err != nil {
+			return nil, err
+		}
+		d.Keys = []*DescriptorKey{key}
+		return d, nil
+
+	case "sh", "wsh":
+		if len(args) != 1 {
+			return nil, errors.New(name + " takes a single script")
+		}
+
+		d := &Descriptor{Type: SHDescriptor}
+		subCtx := shContext
+		if name == "wsh" {
+			if ctx != topContext && ctx != shContext {
+				return nil, errors.New("wsh is only allowed at the top level or in sh")
+			}
+			d.Type = WSHDescriptor
+			subCtx = wshContext
+		} else if ctx != topContext {
+			return nil, errors.New("sh is only allowed at the top level")
+		}
+
+		sub, err := parseDescriptor(args[0], subCtx)
+		if err != nil {
+			return nil, err
+		}
+		d.Sub = sub
+
+		/* The redeem script must fit in a stack element */
+		if subCtx == shContext && sub.scriptSize() > MaxScriptElementSize {
+			return nil, errors.New("P2SH script is too large")
+		}
+		return d, nil
+
+	case "multi", "sortedmulti":
+		if ctx == tapContext {
+			return nil, errors.New(name + " is not allowed in tr")
+		}
+		return parseMultiDescriptor(name, args, ctx)
+
+	case "tr":
+		if ctx != topContext {
+			return nil, errors.New("tr is only allowed at the top level")
+		}
+		if len(args) != 1 && len(args) != 2 {
+			return nil, errors.New("tr takes a key and an optional script tree")
+		}
+
+		key, err := parseDescriptorKey(args[0], tapContext)
+		if err != nil {
+			return nil, err
+		}
+		d := &Descriptor{Type: TRDescriptor, Keys: []*DescriptorKey{key}}
+
+		if len(args) == 2 {
+			d.Tree, err = parseDescriptorTree(args[1], 0)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return d, nil
+
+	case "addr", "raw":
+		if ctx != topContext {
+			return nil, errors.New(name + " is only allowed at the top level")
+		}
+		if len(args) != 1 {
+			return nil, errors.New(name + " takes a single argument")
+		}
+
+		if name == "raw" {
+			script, err := hex.DecodeString(args[0])
+			if err != nil {
+				return nil, err
+			}
+			return &Descriptor{Type: RawDescriptor, Raw: script}, nil
+		}
+
+		for _, network := range knownNetworks() {
+			if address, err := ParseAddress(args[0], network); err == nil {
+				return &Descriptor{Type: AddrDescriptor, Destination: address}, nil
+			}
+		}
+		return nil, errors.New("invalid address")
+	}
+
+	return nil, errors.New("unknown script expression " + name)
+}
+
+/* parseMultiDescriptor parses the threshold and keys of multi and sortedmulti */
+func parseMultiDescriptor(name string, args []string, ctx descriptorContext) (*Descriptor, error) {
+	if len(args) < 2 {
+		return nil, errors.New(name + " takes a threshold and keys")
+	}
+
+	threshold, err := parseDescriptorNumber(args[0])
+	if err != nil {
+		return nil, errors.New("invalid multisig threshold")
+	}
+
+	d := &Descriptor{Type: MultiDescriptor, Threshold: int(threshold)}
+	if name == "sortedmulti" {
+		d.Type = SortedMultiDescriptor
+	}
+
+	for _, arg := range args[1:] {
+		key, err := parseDescriptorKey(arg, ctx)
+		if err != nil {
+			return nil, err
+		}
+		d.Keys = append(d.Keys, key)
+	}
+
+	if len(d.Keys) > MaxPubKeysPerMultiSig {
+		return nil, errors.New("too many keys in multisig")
+	}
+	if ctx == topContext && len(d.Keys) > maxBareMultisigKeys {
+		return nil, errors.New("cannot have " + strconv.Itoa(len(d.Keys)) + " keys in bare multisig; only at most 3 pubkeys")
+	}
+	if d.Threshold < 1 || d.Threshold > len(d.Keys) {
+		return nil, errors.New("multisig threshold must be between 1 and the number of keys")
+	}
+	return d, nil
+}
+
+/* parseDescriptorTree parses a leaf script or a {left,right} branch of a taproot script tree */
+func parseDescriptorTree(expr string, depth int) (*DescriptorTree, error) {
+	if depth > maxTaprootTreeDepth {
+		return nil, errors.New("taproot script tree is too deep")
+	}
+
+	if !strings.HasPrefix(expr, "{") {
+		leaf, err := parseDescriptor(expr, tapContext)
+		if err != nil {
+			return nil, err
+		}
+		return &DescriptorTree{Leaf: leaf}, nil
+	}
+
+	if !strings.HasSuffix(expr, "}") {
+		return nil, errors.New("missing closing brace in script tree")
+	}
+	children, ok := splitArguments(expr[1 : len(expr)-1])
+	if !ok || len(children) != 2 {
+		return nil, errors.New("script tree branches must have two children")
+	}
+
+	left, err := parseDescriptorTree(children[0], depth+1)
+	if err != nil {
+		return nil, err
+	}
+	right, err := parseDescriptorTree(children[1], depth+1)
+	if err != nil {
+		return nil, err
+	}
+	return &DescriptorTree{Left: left, Right: right}, nil
+}
+
+/* parseDescriptorNumber parses an unsigned decimal number without sign or spaces */
+func parseDescriptorNumber(s string) (uint32, error) {
+	if s == "" || strings.Trim(s, "0123456789") != "" {
+		return 0, errors.New("invalid number")
+	}
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	return uint32(n), nil
+}
+
+/* parseDescriptorPath parses derivation steps, hardened steps end with h or ' */
+func parseDescriptorPath(elements []string) (path []uint32, apostrophe bool, err error) {
+	for _, element := range elements {
+		var offset uint32
+		if strings.HasSuffix(element, "'") || strings.HasSuffix(element, "h") {
+			apostrophe = apostrophe || strings.HasSuffix(element, "'")
+			element = element[0 : len(element)-1]
+			offset = HardenedKeyStart
+		}
+
+		index, err := parseDescriptorNumber(element)
+		if err != nil || index >= HardenedKeyStart {
+			return nil, false, errors.New("invalid derivation index")
+		}
+		path = append(path, index+offset)
+	}
+	return path, apostrophe, nil
+}
+
+/* parseDescriptorKey parses a key expression: an optional [fingerprint/path] origin followed by a hex public key, a WIF private key or an extended key with its derivation */
+func parseDescriptorKey(expr string, ctx descriptorContext) (*DescriptorKey, error) {
+	key := &DescriptorKey{}
+
+	if strings.HasPrefix(expr, "[") {
+		end := strings.IndexByte(expr, ']')
+		if end < 0 {
+			return nil, errors.New("key origin has no closing bracket")
+		}
+
+		origin := strings.Split(expr[1:end], "/")
+		if len(origin[0]) != 8 {
+			return nil, errors.New("key origin fingerprint must be 4 bytes long")
+		}
+		fingerprint, err := hex.DecodeString(origin[0])
+		if err != nil {
+			return nil, err
+		}
+
+		path, apostrophe, err := parseDescriptorPath(origin[1:])
+		if err != nil {
+			return nil, err
+		}
+		key.Fingerprint = fingerprint
+		key.OriginPath = path
+		key.Apostrophe = apostrophe
+		expr = expr[end+1:]
+	}
+
+	elements := strings.Split(expr, "/")
+
+	if b, err := hex.DecodeString(elements[0]); err == nil && len(b) > 0 {
+		if len(elements) > 1 {
+			return nil, errors.New("only extended keys can be derived")
+		}
+
+		if ctx == tapContext && len(b) == 32 {
+			key.PublicKey, err = PublicFromXOnly(elements[0], MainNetwork)
+			if err != nil {
+				return nil, err
+			}
+			key.XOnly = true
+			return key, nil
+		}
+
+		/* Hybrid keys are not allowed */
+		if !(len(b) == 33 && (b[0] == 0x02 || b[0] == 0x03)) && !(len(b) == 65 && b[0] == 0x04) {
+			return nil, errors.New("invalid public key")
+		}
+		key.PublicKey, err = publicFromBytes(b, MainNetwork)
+		if err != nil {
+			return nil, err
+		}
+		return key, checkDescriptorKeyCompressed(key.PublicKey.Compressed, ctx)
+	}
+
+	for _, network := range knownNetworks() {
+		privateKey, err := PrivateFromWIF(elements[0], network)
+		if err != nil {
+			continue
+		}
+		if len(elements) > 1 {
+			return nil, errors.New("only extended keys can be derived")
+		}
+
+		key.PrivateKey = privateKey
+		return key, checkDescriptorKeyCompressed(privateKey.Compressed, ctx)
+	}
+
+	for _, network := range knownNetworks() {
+		extendedKey, err := ExtendedFromBase58(elements[0], network)
+		/* The script type of descriptors is given by the expression, SLIP-132 versions are not accepted */
+		if err != nil || extendedKey.Type != P2PKHExtendedKey {
+			continue
+		}
+		key.ExtendedKey = extendedKey
+
+		/* The last step of ranged keys is the index the descriptor is expanded at */
+		steps := elements[1:]
+		if len(steps) > 0 {
+			switch steps[len(steps)-1] {
+			case "*":
+				key.Range = UnhardenedRange
+			case "*'":
+				key.Range = HardenedRange
+				key.Apostrophe = true
+			case "*h":
+				key.Range = HardenedRange
+			}
+			if key.Range != NotRanged {
+				steps = steps[0 : len(steps)-1]
+			}
+		}
+
+		path, apostrophe, err := parseDescriptorPath(steps)
+		if err != nil {
+			return nil, err
+		}
+		key.Path = path
+		key.Apostrophe = key.Apostrophe || apostrophe
+		return key, nil
+	}
+
+	return nil, errors.New("invalid key " + expr)
+}
+
+/* checkDescriptorKeyCompressed rejects uncompressed keys in segwit scripts */
+func checkDescriptorKeyCompressed(compressed bool, ctx descriptorContext) error {
+	if !compressed && (ctx == wshContext || ctx == tapContext) {
+		return errors.New("uncompressed keys are not allowed in segwit scripts")
+	}
+	return nil
+}
+
+/* formatDescriptorPath writes derivation steps, each one preceded by a slash */
+func formatDescriptorPath(path []uint32, apostrophe bool) string {
+	var s string
+	for _, index := range path {
+		s += "/" + strconv.FormatUint(uint64(index&^HardenedKeyStart), 10)
+		if index >= HardenedKeyStart {
+			s += hardenedMarker(apostrophe)
+		}
+	}
+	return s
+}
+
+func hardenedMarker(apostrophe bool) string {
+	if apostrophe {
+		return "'"
+	}
+	return "h"
+}
+
+// String returns the key expression
+func (k *DescriptorKey) String() string {
+	var s string
+	if k.Fingerprint != nil {
+		s = "[" + hex.EncodeToString(k.Fingerprint) + formatDescriptorPath(k.OriginPath, k.Apostrophe) + "]"
+	}
+
+	switch {
+	case k.ExtendedKey != nil:
+		s += k.ExtendedKey.String() + formatDescriptorPath(k.Path, k.Apostrophe)
+		switch k.Range {
+		case UnhardenedRange:
+			s += "/*"
+		case HardenedRange:
+			s += "/*" + hardenedMarker(k.Apostrophe)
+		}
+	case k.PrivateKey != nil:
+		s += k.PrivateKey.WIF
+	case k.XOnly:
+		s += k.PublicKey.FormatXOnly()
+	default:
+		s += k.PublicKey.Format(k.PublicKey.Compressed)
+	}
+	return s
+}
+
+// IsRange returns true if the key is an extended key ending with /*
+func (k *DescriptorKey) IsRange() bool {
+	return k.Range != NotRanged
+}
+
+// PublicKeyAt returns the public key of the key expression, index is the child derived by ranged keys
+func (k *DescriptorKey) PublicKeyAt(index uint32) (*PublicKey, error) {
+	if k.PublicKey != nil {
+		return k.PublicKey, nil
+	}
+	if k.PrivateKey != nil {
+		publicKey, valid := k.PrivateKey.GetPublicKey()
+		if !valid {
+			return nil, errors.New("invalid private key")
+		}
+		return publicKey, nil
+	}
+
+	path := k.Path
+	if k.Range != NotRanged {
+		if index >= HardenedKeyStart {
+			return nil, errors.New("descriptor index out of range")
+		}
+		if k.Range == HardenedRange {
+			index += HardenedKeyStart
+		}
+		path = append(append([]uint32{}, path...), index)
+	}
+
+	key := k.ExtendedKey
+	for _, step := range path {
+		var err error
+		key, err = key.Child(step)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return key.PublicKey, nil
+}
+
+/* serialize returns the public key at index as it appears in scripts, x-only in tapscript */
+func (k *DescriptorKey) serialize(index uint32, xOnly bool) ([]byte, error) {
+	publicKey, err := k.PublicKeyAt(index)
+	if err != nil {
+		return nil, err
+	}
+	if xOnly {
+		return paddedBytes(publicKey.X, 32), nil
+	}
+	return publicKey.serialize(publicKey.Compressed), nil
+}
+
+/* compressed returns true if the key is serialized on 33 bytes in scripts, extended keys always are */
+func (k *DescriptorKey) compressed() bool {
+	switch {
+	case k.PublicKey != nil:
+		return k.PublicKey.Compressed
+	case k.PrivateKey != nil:
+		return k.PrivateKey.Compressed
+	}
+	return true
+}
+
+// String returns the descriptor followed by its checksum
+func (d *Descriptor) String() string {
+	desc := d.expression()
+	checksum, _ := DescriptorChecksum(desc)
+	return desc + "#" + checksum
+}
+
+/* expression returns the descriptor without checksum */
+func (d *Descriptor) expression() string {
+	name := descriptorNames[d.Type]
+
+	var args []string
+	switch d.Type {
+	case SHDescriptor, WSHDescriptor:
+		args = []string{d.Sub.expression()}
+	case MultiDescriptor, SortedMultiDescriptor:
+		args = []string{strconv.Itoa(d.Threshold)}
+		for _, key := range d.Keys {
+			args = append(args, key.String())
+		}
+	case TRDescriptor:
+		args = []string{d.Keys[0].String()}
+		if d.Tree != nil {
+			args = append(args, d.Tree.String())
+		}
+	case AddrDescriptor:
+		args = []string{d.Destination.String()}
+	case RawDescriptor:
+		args = []string{hex.EncodeToString(d.Raw)}
+	default:
+		args = []string{d.Keys[0].String()}
+	}
+
+	return name + "(" + strings.Join(args, ",") + ")"
+}
+
+// String returns the tree expression of the script tree
+func (t *DescriptorTree) String() string {
+	if t.Leaf != nil {
+		return t.Leaf.expression()
+	}
+	return "{" + t.Left.String() + "," + t.Right.String() + "}"
+}
+
+// IsRange returns true if the descriptor has ranged keys and expands to a different script at each index
+func (d *Descriptor) IsRange() bool {
+	for _, key := range d.Keys {
+		if key.IsRange() {
+			return true
+		}
+	}
+	if d.Sub != nil && d.Sub.IsRange() {
+		return true
+	}
+	return d.Tree != nil && d.Tree.isRange()
+}
+
+func (t *DescriptorTree) isRange() bool {
+	if t.Leaf != nil {
+		return t.Leaf.IsRange()
+	}
+	return t.Left.isRange() || t.Right.isRange()
+}
+
+/* scriptSize returns the size of the script of pk, pkh and multisig descriptors, which does not depend on the index */
+func (d *Descriptor) scriptSize() int {
+	keySize := func(k *DescriptorKey) int {
+		if k.compressed() {
+			return 34
+		}
+		return 66
+	}
+
+	switch d.Type {
+	case PKDescriptor:
+		return keySize(d.Keys[0]) + 1
+	case MultiDescriptor, SortedMultiDescriptor:
+		size := 3
+		for _, key := range d.Keys {
+			size += keySize(key)
+		}
+		if len(d.Keys) > 16 {
+			size += 2
+		}
+		return size
+	}
+	return 25
+}
+
+// Script returns the output script of the descriptor, index is the child derived by ranged keys
+func (d *Descriptor) Script(index uint32) ([]byte, error) {
+	return d.script(index, false)
+}
+
+func (d *Descriptor) script(index uint32, tapscript bool) ([]byte, error) {
+	switch d.Type {
+	case SHDescriptor, WSHDescriptor:
+		sub, err := d.Sub.Script(index)
+		if err != nil {
+			return nil, err
+		}
+		if d.Type == SHDescriptor {
+			return (&Address{Type: P2SHAddress, Hash: hash160(sub)}).Script(), nil
+		}
+		return (&Address{Type: P2WSHAddress, WitnessProgram: singleHash(sub)}).Script(), nil
+	case TRDescriptor:
+		return d.taprootScript(index)
+	case AddrDescriptor:
+		return d.Destination.Script(), nil
+	case RawDescriptor:
+		return d.Raw, nil
+	}
+
+	keys := make([][]byte, len(d.Keys))
+	for i, key := range d.Keys {
+		var err error
+		keys[i], err = key.serialize(index, tapscript)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch d.Type {
+	case PKDescriptor:
+		return Script{}.PushData(keys[0]).AddOpcode(OpCheckSig), nil
+	case PKHDescriptor:
+		return (&Address{Type: P2PKHAddress, Hash: hash160(keys[0])}).Script(), nil
+	case WPKHDescriptor:
+		return (&Address{Type: P2WPKHAddress, WitnessProgram: hash160(keys[0])}).Script(), nil
+	}
+
+	/* multi and sortedmulti */
+	if d.Type == SortedMultiDescriptor {
+		sort.Slice(keys, func(i, j int) bool {
+			return bytes.Compare(keys[i], keys[j]) < 0
+		})
+	}
+	script := Script{}.PushData(scriptNum(d.Threshold).bytes())
+	for _, key := range keys {
+		script = script.PushData(key)
+	}
+	script = script.PushData(scriptNum(len(keys)).bytes())
+	return script.AddOpcode(OpCheckMultiSig), nil
+}
+
+/* taprootScript tweaks the internal key with the merkle root of the script tree */
+func (d *Descriptor) taprootScript(index uint32) ([]byte, error) {
+	internalKey, err := d.Keys[0].PublicKeyAt(index)
+	if err != nil {
+		return nil, err
+	}
+
+	var merkleRoot []byte
+	if d.Tree != nil {
+		merkleRoot, err = d.Tree.hash(index)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	outputKey, err := internalKey.TaprootTweak(merkleRoot)
+	if err != nil {
+		return nil, err
+	}
+	return (&Address{Type: P2TRAddress, WitnessVersion: 1, WitnessProgram: paddedBytes(outputKey.X, 32)}).Script(), nil
+}
+
+/* hash computes the tapleaf hash of leaves and the tapbranch hash of branches */
+func (t *DescriptorTree) hash(index uint32) ([]byte, error) {
+	if t.Leaf != nil {
+		script, err := t.Leaf.script(index, true)
+		if err != nil {
+			return nil, err
+		}
+		return TapLeafHash(TapLeafVersion, script), nil
+	}
+
+	left, err := t.Left.hash(index)
+	if err != nil {
+		return nil, err
+	}
+	right, err := t.Right.hash(index)
+	if err != nil {
+		return nil, err
+	}
+	return TapBranchHash(left, right), nil
+}
+
+// Address returns the address of the output script of the descriptor at index on network
+func (d *Descriptor) Address(index uint32, network *Network) (string, error) {
+	script, err := d.Script(index)
+	if err != nil {
+		return "", err
+	}
+
+	address, err := AddressFromScript(script, network)
+	if err != nil {
+		return "", err
+	}
+	return address.String(), nil
+}
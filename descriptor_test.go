@@ -0,0 +1,181 @@
+package btc
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type descriptorVector struct {
+	Description string   `json:"description"`
+	Descriptor  string   `json:"descriptor"`
+	Scripts     []string `json:"scripts"`
+}
+
+type descriptorVectors struct {
+	ChecksumValid   []descriptorVector `json:"checksum_valid"`
+	ChecksumInvalid []descriptorVector `json:"checksum_invalid"`
+	KeysValid       []descriptorVector `json:"keys_valid"`
+	KeysInvalid     []descriptorVector `json:"keys_invalid"`
+	Valid           []descriptorVector `json:"valid"`
+	Invalid         []descriptorVector `json:"invalid"`
+}
+
+func loadDescriptorVectors(t *testing.T) descriptorVectors {
+	data, err := ioutil.ReadFile("testdata/descriptor_vectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var vectors descriptorVectors
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		t.Fatal(err)
+	}
+	return vectors
+}
+
+func TestDescriptorChecksum(t *testing.T) {
+	vectors := loadDescriptorVectors(t)
+
+	for _, v := range vectors.ChecksumValid {
+		_, err := ParseDescriptor(v.Descriptor)
+		assert.Nil(t, err, v.Description)
+	}
+	for _, v := range vectors.ChecksumInvalid {
+		_, err := ParseDescriptor(v.Descriptor)
+		assert.NotNil(t, err, v.Description)
+	}
+
+	tests := []string{
+		"addr(mi7as51dvLJsizWnTMurtRmrP8hG2m1XvD)#gj9tznmy",
+		"addr(bcrt1q73qyfypp47hvgnkjqnav0j3k2lq3v76wg22dk8tmwuz5sfgv66xsvxg6uu)#9p3q328s",
+		"pkh([0c5f9a1e/1/1/1500]03832901c250025da2aebae2bfb38d5c703a57ab66ad477f9c578bfbcd78abca6f)#vchwd07g",
+		"wpkh([306c734f/84h/1h/0h]tpubDCJnY92ib4Zu3qd6wrBXEjG436tQdA2tDiJU2iSJYjkNS1darssPWKaBfojhjUF5vMLBcxbN2r93pmFMz2zyTEZuNx9JDo9rWqoHhATW3Uz/0/*)#7mh08dkg",
+		"wpkh(tprv8ZgxMBicQKsPd7Uf69XL1XwhmjHopUGep8GuEiJDZmbQz6o58LninorQAfcKZWARbtRtfnLcJ5MQ2AtHcQJCCRUcMRvmDUjyEmNUWwx8UbK/1/1/*)#kft60nuy",
+	}
+	for _, test := range tests {
+		d, err := ParseDescriptor(test)
+		if assert.Nil(t, err, test) {
+			assert.Equal(t, test, d.String())
+		}
+	}
+
+	/* Mixed hardened markers are written with a single one */
+	d, err := ParseDescriptor("tr([00000001/86h/1h/0']tprv8ZgxMBicQKsPd7Uf69XL1XwhmjHopUGep8GuEiJDZmbQz6o58LninorQAfcKZWARbtRtfnLcJ5MQ2AtHcQJCCRUcMRvmDUjyEmNUWwx8UbK/0/*)#7ew68cn8")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "tr([00000001/86'/1'/0']tprv8ZgxMBicQKsPd7Uf69XL1XwhmjHopUGep8GuEiJDZmbQz6o58LninorQAfcKZWARbtRtfnLcJ5MQ2AtHcQJCCRUcMRvmDUjyEmNUWwx8UbK/0/*)#0jtt2jc9", d.String())
+	}
+}
+
+func TestDescriptorKeys(t *testing.T) {
+	vectors := loadDescriptorVectors(t)
+
+	for _, v := range vectors.KeysValid {
+		d, err := ParseDescriptor("pk(" + v.Descriptor + ")")
+		if !assert.Nil(t, err, v.Description) {
+			continue
+		}
+		again, err := ParseDescriptor(d.String())
+		if assert.Nil(t, err, v.Description) {
+			assert.Equal(t, d.String(), again.String(), v.Description)
+		}
+	}
+	for _, v := range vectors.KeysInvalid {
+		_, err := ParseDescriptor("pk(" + v.Descriptor + ")")
+		assert.NotNil(t, err, v.Description)
+	}
+}
+
+func TestDescriptorScripts(t *testing.T) {
+	vectors := loadDescriptorVectors(t)
+
+	for _, v := range vectors.Valid {
+		d, err := ParseDescriptor(v.Descriptor)
+		if !assert.Nil(t, err, v.Descriptor) {
+			continue
+		}
+		assert.Equal(t, len(v.Scripts) > 1, d.IsRange(), v.Descriptor)
+
+		for i, expected := range v.Scripts {
+			script, err := d.Script(uint32(i))
+			assert.Nil(t, err, v.Descriptor)
+			assert.Equal(t, expected, hex.EncodeToString(script), v.Descriptor)
+		}
+
+		/* The checksummed descriptor parses to the same scripts */
+		again, err := ParseDescriptor(d.String())
+		if assert.Nil(t, err, v.Descriptor) {
+			script, _ := again.Script(0)
+			assert.Equal(t, v.Scripts[0], hex.EncodeToString(script), v.Descriptor)
+		}
+	}
+
+	for _, v := range vectors.Invalid {
+		_, err := ParseDescriptor(v.Descriptor)
+		assert.NotNil(t, err, v.Description)
+	}
+
+	/* The limit of bare multisig keys only applies at the top level */
+	keys := "03669b8afcec803a0d323e9a17f3ea8e68e8abe5a278020a929adbec52421adbd0,0260b2003c386519fc9eadf2b5cf124dd8eea4c4e68d5e154050a9346ea98ce600,0362a74e399c39ed5593852a30147f2959b56bb827dfa3e60e464b02ccf87dc5e8,0261345b53de74a4d721ef877c255429961b7e43714171ac06168d7e08c542a8b8"
+	_, err := ParseDescriptor("multi(1," + keys + ")")
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "cannot have 4 keys in bare multisig; only at most 3 pubkeys", err.Error())
+	}
+	_, err = ParseDescriptor("sh(sortedmulti(1," + keys + "))")
+	assert.Nil(t, err)
+	_, err = ParseDescriptor("wsh(multi(1," + keys + "))")
+	assert.Nil(t, err)
+}
+
+func TestDescriptorAddress(t *testing.T) {
+	tests := []struct {
+		descriptor string
+		index      uint32
+		network    *Network
+		address    string
+	}{
+		{
+			"pkh([bd16bee5/2147483647h]xpub69H7F5dQzmVd3vPuLKtcXJziMEQByuDidnX3YdwgtNsecY5HRGtAAQC5mXTt4dsv9RzyjgDjAQs9VGVV6ydYCHnprc9vvaA5YtqWyL6hyds/0)",
+			0, MainNetwork, "1NW82AiLUHSR7DSa1AwA72agkA5rgJrdcC",
+		},
+		{
+			"wpkh(03a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd)",
+			0, TestNetwork, "tb1qngw83fg8dz0k749cg7k3emc7v98wy0c7ltysd7",
+		},
+		{
+			"sh(wpkh(xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi/10/20/30/40/*h))",
+			0, MainNetwork, "3Fktwfew1dVGUoDoA1g8jJHFmPTgdq7Wwk",
+		},
+		{
+			"addr(3PUNyaW7M55oKWJ3kDukwk9bsKvryra15j)",
+			0, MainNetwork, "3PUNyaW7M55oKWJ3kDukwk9bsKvryra15j",
+		},
+		{
+			"tr(a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd)",
+			0, MainNetwork, "bc1pw74tdcrxlzn5r8z6ku2vztr86fgq0m245s72mjktf4afwzsf8ugs0gs8zu",
+		},
+	}
+
+	for _, test := range tests {
+		d, err := ParseDescriptor(test.descriptor)
+		if !assert.Nil(t, err, test.descriptor) {
+			continue
+		}
+		address, err := d.Address(test.index, test.network)
+		assert.Nil(t, err, test.descriptor)
+		assert.Equal(t, test.address, address, test.descriptor)
+	}
+
+	/* Bare public keys and multisig scripts have no address */
+	d, _ := ParseDescriptor("pk(03a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd)")
+	_, err := d.Address(0, MainNetwork)
+	assert.NotNil(t, err)
+
+	/* Hardened steps cannot be derived from extended public keys */
+	d, _ = ParseDescriptor("wpkh(xpub6ERApfZwUNrhLCkDtcHTcxd75RbzS1ed54G1LkBUHQVHQKqhMkhgbmJbZRkrgZw4koxb5JaHWkY4ALHY2grBGRjaDMzQLcgJvLJuZZvRcEL/*h)")
+	_, err = d.Script(0)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,52 @@
+package btc
+
+import (
+	"crypto/sha256"
+	"errors"
+	"reflect"
+
+	"github.com/aureleoules/ecdsa"
+)
+
+// ECDH computes the shared secret of the private key and the public key like libsecp256k1's default hash function: the SHA256 of the compressed shared point
+func (p *PrivateKey) ECDH(publicKey *PublicKey) ([]byte, error) {
+	S, err := p.ecdhPoint(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	compressed := append([]byte{0x02 | byte(S.Y.Bit(0))}, paddedBytes(S.X, 32)...)
+	secret := sha256.Sum256(compressed)
+	return secret[:], nil
+}
+
+// ECDHX computes the shared secret of the private key and the public key as the raw 32 bytes x coordinate of the shared point
+func (p *PrivateKey) ECDHX(publicKey *PublicKey) ([]byte, error) {
+	S, err := p.ecdhPoint(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	return paddedBytes(S.X, 32), nil
+}
+
+/* ecdhPoint computes the shared point d*Q in constant time */
+func (p *PrivateKey) ecdhPoint(publicKey *PublicKey) (ecdsa.Point, error) {
+	if !reflect.DeepEqual(p.Network, publicKey.Network) {
+		return ecdsa.Point{}, errors.New("different network")
+	}
+	if p.Key == nil {
+		return ecdsa.Point{}, errors.New("invalid private key")
+	}
+	d, valid := scalarFromSecret(p.Key)
+	if valid == 0 {
+		return ecdsa.Point{}, errors.New("invalid private key")
+	}
+
+	Q := publicKey.point()
+	if Q.IsInfinity() || Q.X.Cmp(secp256k1.P) >= 0 || Q.Y.Cmp(secp256k1.P) >= 0 || !secp256k1.IsOnCurve(Q) {
+		return ecdsa.Point{}, errors.New("point is not on curve")
+	}
+
+	/* Q has prime order and d is in [1, n-1] so d*Q is never the point at infinity */
+	return pointMult(d, jacobianFromPoint(Q)).point(), nil
+}
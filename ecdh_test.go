@@ -0,0 +1,59 @@
+package btc
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestECDH(t *testing.T) {
+	tests := []struct {
+		privateKey string
+		publicKey  string
+		secret     string
+		x          string
+	}{
+		{"2bd806c97f0e00af1a1fc3328fa763a9269723c8db8fac4f93af71db186d6e90", "024edfcf9dfe6c0b5c83d1ab3f78d1b39a46ebac6798e08e19761f5ed89ec83c10", "4e06de2520d1fe909bcf244b0a0de57c92bc6e21e28c2cdb108d980ad7d709b6", "05aaea3882116920f603246a563cc2f3da5704bdf9d33ca60a29298956c26cf9"},
+		{"0000000000000000000000000000000000000000000000000000000000000001", "0379be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798", "fbd27dbb9e7f471bf3de3704a35e884e37d35c676dc2cc8c3cc574c3962376d2", "79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"},
+	}
+
+	for _, test := range tests {
+		privateKey, err := PrivateFromHex(test.privateKey, MainNetwork)
+		assert.Nil(t, err)
+		publicKey, err := PublicFromHex(test.publicKey, MainNetwork)
+		assert.Nil(t, err)
+
+		secret, err := privateKey.ECDH(publicKey)
+		assert.Nil(t, err)
+		assert.Equal(t, test.secret, hex.EncodeToString(secret))
+
+		x, err := privateKey.ECDHX(publicKey)
+		assert.Nil(t, err)
+		assert.Equal(t, test.x, hex.EncodeToString(x))
+	}
+
+	/* Both parties derive the same secret */
+	alice := GeneratePrivateKey(MainNetwork)
+	bob := GeneratePrivateKey(MainNetwork)
+	alicePublic, _ := alice.GetPublicKey()
+	bobPublic, _ := bob.GetPublicKey()
+	s1, err := alice.ECDH(bobPublic)
+	assert.Nil(t, err)
+	s2, err := bob.ECDH(alicePublic)
+	assert.Nil(t, err)
+	assert.Equal(t, s1, s2)
+
+	/* Points which are not on the curve are rejected */
+	invalid := &PublicKey{X: new(big.Int).Set(bobPublic.X), Y: new(big.Int).Add(bobPublic.Y, big.NewInt(1)), Network: MainNetwork}
+	_, err = alice.ECDH(invalid)
+	assert.NotNil(t, err)
+	_, err = alice.ECDHX(&PublicKey{Network: MainNetwork})
+	assert.NotNil(t, err)
+
+	/* Keys of different networks are rejected */
+	testPublic, _ := GeneratePrivateKey(TestNetwork).GetPublicKey()
+	_, err = alice.ECDH(testPublic)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,115 @@
+package btc
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+)
+
+/* eciesMagic prefixes messages encrypted with Electrum's ECIES format */
+const eciesMagic = "BIE1"
+
+// EncryptMessage encrypts a message to the public key with Electrum's ECIES (BIE1) format, the result is base64 encoded
+func (p *PublicKey) EncryptMessage(message []byte) (string, error) {
+	ephemeral := GeneratePrivateKey(p.Network)
+	return p.encryptMessage(message, ephemeral)
+}
+
+/* encryptMessage encrypts a message with the given ephemeral key: magic | ephemeral public key | AES-128-CBC ciphertext | HMAC-SHA256 */
+func (p *PublicKey) encryptMessage(message []byte, ephemeral *PrivateKey) (string, error) {
+	ephemeralPublic, valid := ephemeral.GetPublicKey()
+	if !valid {
+		return "", errors.New("invalid ephemeral key")
+	}
+	iv, encryptionKey, macKey, err := eciesKeys(ephemeral, p)
+	if err != nil {
+		return "", err
+	}
+
+	block, _ := aes.NewCipher(encryptionKey)
+	padded := pkcs7Pad(message, aes.BlockSize)
+	ciphertext := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, padded)
+
+	encrypted := append([]byte(eciesMagic), ephemeralPublic.serialize(true)...)
+	encrypted = append(encrypted, ciphertext...)
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(encrypted)
+	return base64.StdEncoding.EncodeToString(mac.Sum(encrypted)), nil
+}
+
+// DecryptMessage decrypts a base64 message encrypted to the public key of the private key with Electrum's ECIES (BIE1) format
+func (p *PrivateKey) DecryptMessage(encrypted string) ([]byte, error) {
+	b, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return nil, err
+	}
+	/* magic (4) | ephemeral public key (33) | ciphertext (16*k, k > 0) | mac (32) */
+	if len(b) < 85 || (len(b)-69)%aes.BlockSize != 0 {
+		return nil, errors.New("invalid encrypted message length")
+	}
+	if string(b[0:4]) != eciesMagic {
+		return nil, errors.New("invalid encrypted message magic")
+	}
+
+	ephemeralPublic, err := publicFromBytes(b[4:37], p.Network)
+	if err != nil {
+		return nil, err
+	}
+	iv, encryptionKey, macKey, err := eciesKeys(p, ephemeralPublic)
+	if err != nil {
+		return nil, err
+	}
+
+	/* The mac is checked before decrypting so that invalid paddings are not revealed */
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(b[:len(b)-32])
+	if !hmac.Equal(mac.Sum(nil), b[len(b)-32:]) {
+		return nil, errors.New("invalid encrypted message mac")
+	}
+
+	ciphertext := b[37 : len(b)-32]
+	block, _ := aes.NewCipher(encryptionKey)
+	padded := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(padded, ciphertext)
+	return pkcs7Unpad(padded, aes.BlockSize)
+}
+
+/* eciesKeys derives the iv, AES key and HMAC key from the SHA512 of the compressed ECDH point */
+func eciesKeys(privateKey *PrivateKey, publicKey *PublicKey) ([]byte, []byte, []byte, error) {
+	S, err := privateKey.ecdhPoint(publicKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	shared := &PublicKey{X: S.X, Y: S.Y}
+	key := sha512.Sum512(shared.serialize(true))
+	return key[0:16], key[16:32], key[32:64], nil
+}
+
+/* pkcs7Pad pads data to a multiple of the block size, a full block is added to aligned data */
+func pkcs7Pad(data []byte, blockSize int) []byte {
+	padding := blockSize - len(data)%blockSize
+	return append(append([]byte{}, data...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+}
+
+/* pkcs7Unpad removes and checks the padding of data */
+func pkcs7Unpad(data []byte, blockSize int) ([]byte, error) {
+	if len(data) == 0 || len(data)%blockSize != 0 {
+		return nil, errors.New("invalid padded data length")
+	}
+	padding := int(data[len(data)-1])
+	if padding == 0 || padding > blockSize {
+		return nil, errors.New("invalid padding")
+	}
+	for _, b := range data[len(data)-padding:] {
+		if int(b) != padding {
+			return nil, errors.New("invalid padding")
+		}
+	}
+	return data[:len(data)-padding], nil
+}
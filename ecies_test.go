@@ -0,0 +1,80 @@
+package btc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecryptElectrumMessage(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/electrum_ecies.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/* Outputs of Electrum's encrypt_message, the key is derived from the password "pw123" of its tests */
+	var vectors []struct {
+		PrivateKey string `json:"private_key"`
+		Message    string `json:"message"`
+		Encrypted  string `json:"encrypted"`
+	}
+	err = json.Unmarshal(data, &vectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, vector := range vectors {
+		key, err := PrivateFromHex(vector.PrivateKey, MainNetwork)
+		assert.Nil(t, err)
+
+		message, err := key.DecryptMessage(vector.Encrypted)
+		assert.Nil(t, err, vector.Encrypted)
+		assert.Equal(t, vector.Message, string(message))
+
+		/* Tampering with any part of the message is detected */
+		b, _ := base64.StdEncoding.DecodeString(vector.Encrypted)
+		for _, i := range []int{0, 4, 40, len(b) - 1} {
+			tampered := append([]byte{}, b...)
+			tampered[i] ^= 1
+			_, err := key.DecryptMessage(base64.StdEncoding.EncodeToString(tampered))
+			assert.NotNil(t, err, i)
+		}
+		_, err = key.DecryptMessage(base64.StdEncoding.EncodeToString(b[:len(b)-16]))
+		assert.NotNil(t, err)
+
+		/* Other keys cannot decrypt it */
+		_, err = GeneratePrivateKey(MainNetwork).DecryptMessage(vector.Encrypted)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestEncryptMessage(t *testing.T) {
+	key := GeneratePrivateKey(MainNetwork)
+	publicKey, _ := key.GetPublicKey()
+
+	for _, message := range [][]byte{{}, []byte("hello"), bytes.Repeat([]byte{0x10}, 16), bytes.Repeat([]byte("hey_there"), 100)} {
+		encrypted, err := publicKey.EncryptMessage(message)
+		assert.Nil(t, err)
+
+		decrypted, err := key.DecryptMessage(encrypted)
+		assert.Nil(t, err)
+		assert.Equal(t, message, decrypted)
+
+		/* A new ephemeral key is used for each message */
+		other, err := publicKey.EncryptMessage(message)
+		assert.Nil(t, err)
+		assert.NotEqual(t, encrypted, other)
+	}
+
+	/* Keys of different networks are rejected */
+	testPublic, _ := GeneratePrivateKey(TestNetwork).GetPublicKey()
+	_, err := testPublic.encryptMessage([]byte("hello"), key)
+	assert.NotNil(t, err)
+
+	_, err = key.DecryptMessage("not base64")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,373 @@
+package btc
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// HardenedKeyStart is the index of the first hardened child key
+const HardenedKeyStart uint32 = 0x80000000
+
+// Extended key types (SLIP-132)
+const (
+	/* P2PKHExtendedKey keys are serialized as xpub and tpub, they are also used for P2SH and P2TR */
+	P2PKHExtendedKey ExtendedKeyType = iota
+	/* P2SHP2WPKHExtendedKey keys are serialized as ypub and upub */
+	P2SHP2WPKHExtendedKey
+	/* P2WPKHExtendedKey keys are serialized as zpub and vpub */
+	P2WPKHExtendedKey
+	/* P2SHP2WSHExtendedKey keys are serialized as Ypub and Upub, they are cosigners of multisig scripts */
+	P2SHP2WSHExtendedKey
+	/* P2WSHExtendedKey keys are serialized as Zpub and Vpub, they are cosigners of multisig scripts */
+	P2WSHExtendedKey
+)
+
+/* extendedKeyTypes lists the extended key types in the order versions are matched */
+var extendedKeyTypes = []ExtendedKeyType{P2PKHExtendedKey, P2SHP2WPKHExtendedKey, P2WPKHExtendedKey, P2SHP2WSHExtendedKey, P2WSHExtendedKey}
+
+/* extendedKeyPrefix returns the hex version of extended keys of a type on a network */
+func extendedKeyPrefix(network *Network, keyType ExtendedKeyType, private bool) (string, bool) {
+	if keyType == P2PKHExtendedKey {
+		if private {
+			return network.ExtendedPrivKeyPrefix, true
+		}
+		return network.ExtendedPubKeyPrefix, true
+	}
+
+	prefixes := network.SLIP132PubKeyPrefixes
+	if private {
+		prefixes = network.SLIP132PrivKeyPrefixes
+	}
+	prefix, ok := prefixes[keyType]
+	return prefix, ok
+}
+
+// NewMasterKey computes the master extended private key of a seed
+func NewMasterKey(seed []byte, network *Network) (*ExtendedKey, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, errors.New("invalid seed length")
+	}
+
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	I := mac.Sum(nil)
+
+	key := new(big.Int).SetBytes(I[0:32])
+	if key.Sign() == 0 || key.Cmp(secp256k1.N) >= 0 {
+		return nil, errors.New("invalid master key")
+	}
+
+	return newExtendedPrivateKey(key, I[32:], 0, []byte{0, 0, 0, 0}, 0, network)
+}
+
+func newExtendedPrivateKey(key *big.Int, chainCode []byte, depth byte, parentFingerprint []byte, childNumber uint32, network *Network) (*ExtendedKey, error) {
+	privateKey, err := PrivateFromHex(paddedHex(key), network)
+	if err != nil {
+		return nil, err
+	}
+	/* Public keys of extended keys are always compressed */
+	privateKey.SetCompressed(true)
+
+	publicKey, valid := privateKey.GetPublicKey()
+	if !valid {
+		return nil, errors.New("invalid private key")
+	}
+
+	return &ExtendedKey{
+		PrivateKey:        privateKey,
+		PublicKey:         publicKey,
+		ChainCode:         chainCode,
+		Depth:             depth,
+		ParentFingerprint: parentFingerprint,
+		ChildNumber:       childNumber,
+		Network:           network,
+	}, nil
+}
+
+// IsPrivate returns true if the extended key holds a private key
+func (k *ExtendedKey) IsPrivate() bool {
+	return k.PrivateKey != nil
+}
+
+// Fingerprint returns the first 4 bytes of the hash160 of the compressed public key
+func (k *ExtendedKey) Fingerprint() []byte {
+	return hash160(k.PublicKey.serialize(true))[0:4]
+}
+
+// Neuter returns the extended public key of an extended key
+func (k *ExtendedKey) Neuter() *ExtendedKey {
+	return &ExtendedKey{
+		PublicKey:         k.PublicKey,
+		ChainCode:         k.ChainCode,
+		Depth:             k.Depth,
+		ParentFingerprint: k.ParentFingerprint,
+		ChildNumber:       k.ChildNumber,
+		Type:              k.Type,
+		Network:           k.Network,
+	}
+}
+
+// Child derives the child extended key at index, indexes from HardenedKeyStart are hardened
+func (k *ExtendedKey) Child(index uint32) (*ExtendedKey, error) {
+	if k.Depth == 255 {
+		return nil, errors.New("depth too large")
+	}
+
+	hardened := index >= HardenedKeyStart
+
+	var data []byte
+	if hardened {
+		if !k.IsPrivate() {
+			return nil, errors.New("cannot derive a hardened child from a public key")
+		}
+		/* 0x00 || ser256(k) || ser32(i) */
+		data = append([]byte{0x00}, paddedBytes(k.PrivateKey.Key, 32)...)
+	} else {
+		/* serP(point(k)) || ser32(i) */
+		data = k.PublicKey.serialize(true)
+	}
+	data = append(data, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(data[len(data)-4:], index)
+
+	mac := hmac.New(sha512.New, k.ChainCode)
+	mac.Write(data)
+	I := mac.Sum(nil)
+
+	tweak := new(big.Int).SetBytes(I[0:32])
+	if tweak.Cmp(secp256k1.N) >= 0 {
+		return nil, errors.New("invalid child key")
+	}
+
+	if k.IsPrivate() {
+		key := new(big.Int).Add(tweak, k.PrivateKey.Key)
+		key.Mod(key, secp256k1.N)
+		if key.Sign() == 0 {
+			return nil, errors.New("invalid child key")
+		}
+
+		child, err := newExtendedPrivateKey(key, I[32:], k.Depth+1, k.Fingerprint(), index, k.Network)
+		if err != nil {
+			return nil, err
+		}
+		child.Type = k.Type
+		return child, nil
+	}
+
+	/* point(IL) + Kpar */
+	tweakKey, err := PrivateFromHex(paddedHex(tweak), k.Network)
+	if err != nil {
+		return nil, err
+	}
+	tweakPoint, valid := tweakKey.GetPublicKey()
+	if !valid {
+		return nil, errors.New("invalid child key")
+	}
+	publicKey, err := AddPublicKeys(tweakPoint, k.PublicKey, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ExtendedKey{
+		PublicKey:         publicKey,
+		ChainCode:         I[32:],
+		Depth:             k.Depth + 1,
+		ParentFingerprint: k.Fingerprint(),
+		ChildNumber:       index,
+		Type:              k.Type,
+		Network:           k.Network,
+	}, nil
+}
+
+// ParsePath parses a derivation path such as m/84'/0'/0'/0/5 into child indexes
+func ParsePath(path string) ([]uint32, error) {
+	elements := strings.Split(path, "/")
+	if elements[0] == "m" || elements[0] == "M" {
+		elements = elements[1:]
+	}
+
+	indexes := make([]uint32, 0, len(elements))
+	for _, element := range elements {
+		var offset uint32
+		/* Hardened indexes are suffixed by ', h or H */
+		if strings.HasSuffix(element, "'") || strings.HasSuffix(element, "h") || strings.HasSuffix(element, "H") {
+			element = element[0 : len(element)-1]
+			offset = HardenedKeyStart
+		}
+
+		index, err := strconv.ParseUint(element, 10, 32)
+		if err != nil || uint32(index) >= HardenedKeyStart {
+			return nil, errors.New("invalid path element")
+		}
+		indexes = append(indexes, uint32(index)+offset)
+	}
+
+	return indexes, nil
+}
+
+// Derive derives the descendant extended key at path
+func (k *ExtendedKey) Derive(path string) (*ExtendedKey, error) {
+	indexes, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key := k
+	for _, index := range indexes {
+		key, err = key.Child(index)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// String returns the base58 serialization of the extended key with the version of its type (xpub, ypub, zpub, Ypub, Zpub and their private and testnet counterparts)
+func (k *ExtendedKey) String() string {
+	prefix, _ := extendedKeyPrefix(k.Network, k.Type, k.IsPrivate())
+	version, _ := hex.DecodeString(prefix)
+
+	payload := make([]byte, 0, 78)
+	payload = append(payload, version...)
+	payload = append(payload, k.Depth)
+	payload = append(payload, k.ParentFingerprint...)
+	payload = append(payload, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(payload[len(payload)-4:], k.ChildNumber)
+	payload = append(payload, k.ChainCode...)
+
+	if k.IsPrivate() {
+		payload = append(payload, 0x00)
+		payload = append(payload, paddedBytes(k.PrivateKey.Key, 32)...)
+	} else {
+		payload = append(payload, k.PublicKey.serialize(true)...)
+	}
+
+	return base58CheckEncode(payload)
+}
+
+// ExtendedFromBase58 imports an extended key from its base58 serialization
+func ExtendedFromBase58(key string, network *Network) (*ExtendedKey, error) {
+	payload, err := base58CheckDecode(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) != 78 {
+		return nil, errors.New("invalid extended key length")
+	}
+
+	version := strings.ToUpper(hex.EncodeToString(payload[0:4]))
+	depth := payload[4]
+	parentFingerprint := payload[5:9]
+	childNumber := binary.BigEndian.Uint32(payload[9:13])
+	chainCode := payload[13:45]
+	keyData := payload[45:78]
+
+	if depth == 0 {
+		if !bytes.Equal(parentFingerprint, []byte{0, 0, 0, 0}) {
+			return nil, errors.New("zero depth with non-zero parent fingerprint")
+		}
+		if childNumber != 0 {
+			return nil, errors.New("zero depth with non-zero index")
+		}
+	}
+
+	/* The version selects the type of the key and whether it is private */
+	var keyType ExtendedKeyType
+	var private, found bool
+	for _, t := range extendedKeyTypes {
+		if prefix, ok := extendedKeyPrefix(network, t, true); ok && strings.ToUpper(prefix) == version {
+			keyType, private, found = t, true, true
+			break
+		}
+		if prefix, ok := extendedKeyPrefix(network, t, false); ok && strings.ToUpper(prefix) == version {
+			keyType, private, found = t, false, true
+			break
+		}
+	}
+	if !found {
+		return nil, errors.New("unknown extended key version")
+	}
+
+	if private {
+		if keyData[0] != 0x00 {
+			return nil, errors.New("invalid private key prefix")
+		}
+
+		k := new(big.Int).SetBytes(keyData[1:])
+		if k.Sign() == 0 || k.Cmp(secp256k1.N) >= 0 {
+			return nil, errors.New("private key not in 1..n-1")
+		}
+
+		extendedKey, err := newExtendedPrivateKey(k, chainCode, depth, parentFingerprint, childNumber, network)
+		if err != nil {
+			return nil, err
+		}
+		extendedKey.Type = keyType
+		return extendedKey, nil
+	}
+
+	if keyData[0] != 0x02 && keyData[0] != 0x03 {
+		return nil, errors.New("invalid public key prefix")
+	}
+
+	publicKey, err := PublicFromHex(hex.EncodeToString(keyData), network)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ExtendedKey{
+		PublicKey:         publicKey,
+		ChainCode:         chainCode,
+		Depth:             depth,
+		ParentFingerprint: parentFingerprint,
+		ChildNumber:       childNumber,
+		Type:              keyType,
+		Network:           network,
+	}, nil
+}
+
+// WithType returns a copy of the extended key serialized with the version of keyType
+func (k *ExtendedKey) WithType(keyType ExtendedKeyType) (*ExtendedKey, error) {
+	if _, ok := extendedKeyPrefix(k.Network, keyType, k.IsPrivate()); !ok {
+		return nil, errors.New("unknown extended key type")
+	}
+
+	key := *k
+	key.Type = keyType
+	return &key, nil
+}
+
+// ConvertExtendedKey converts the base58 serialization of an extended key to the version of keyType, such as a zpub to an xpub
+func ConvertExtendedKey(key string, keyType ExtendedKeyType, network *Network) (string, error) {
+	extendedKey, err := ExtendedFromBase58(key, network)
+	if err != nil {
+		return "", err
+	}
+
+	extendedKey, err = extendedKey.WithType(keyType)
+	if err != nil {
+		return "", err
+	}
+	return extendedKey.String(), nil
+}
+
+// Address computes the address of the public key with the script type inferred from the type of the extended key
+func (k *ExtendedKey) Address() (string, error) {
+	switch k.Type {
+	case P2PKHExtendedKey:
+		return k.PublicKey.Address(true)
+	case P2SHP2WPKHExtendedKey:
+		return k.PublicKey.NestedSegwitAddress(true)
+	case P2WPKHExtendedKey:
+		return k.PublicKey.SegwitAddress(true)
+	case P2SHP2WSHExtendedKey, P2WSHExtendedKey:
+		return "", errors.New("multisig extended keys have no single key address")
+	}
+	return "", errors.New("unknown extended key type")
+}
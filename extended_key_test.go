@@ -0,0 +1,253 @@
+package btc
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtendedKey(t *testing.T) {
+	type chain struct {
+		Path string
+		XPub string
+		XPrv string
+	}
+	var vectors = []struct {
+		Seed   string
+		Chains []chain
+	}{
+		// Test vector 1
+		{
+			"000102030405060708090a0b0c0d0e0f",
+			[]chain{
+				{"m", "xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGhePY2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet8", "xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi"},
+				{"m/0'", "xpub68Gmy5EdvgibQVfPdqkBBCHxA5htiqg55crXYuXoQRKfDBFA1WEjWgP6LHhwBZeNK1VTsfTFUHCdrfp1bgwQ9xv5ski8PX9rL2dZXvgGDnw", "xprv9uHRZZhk6KAJC1avXpDAp4MDc3sQKNxDiPvvkX8Br5ngLNv1TxvUxt4cV1rGL5hj6KCesnDYUhd7oWgT11eZG7XnxHrnYeSvkzY7d2bhkJ7"},
+				{"m/0'/1", "xpub6ASuArnXKPbfEwhqN6e3mwBcDTgzisQN1wXN9BJcM47sSikHjJf3UFHKkNAWbWMiGj7Wf5uMash7SyYq527Hqck2AxYysAA7xmALppuCkwQ", "xprv9wTYmMFdV23N2TdNG573QoEsfRrWKQgWeibmLntzniatZvR9BmLnvSxqu53Kw1UmYPxLgboyZQaXwTCg8MSY3H2EU4pWcQDnRnrVA1xe8fs"},
+				{"m/0'/1/2'", "xpub6D4BDPcP2GT577Vvch3R8wDkScZWzQzMMUm3PWbmWvVJrZwQY4VUNgqFJPMM3No2dFDFGTsxxpG5uJh7n7epu4trkrX7x7DogT5Uv6fcLW5", "xprv9z4pot5VBttmtdRTWfWQmoH1taj2axGVzFqSb8C9xaxKymcFzXBDptWmT7FwuEzG3ryjH4ktypQSAewRiNMjANTtpgP4mLTj34bhnZX7UiM"},
+				{"m/0'/1/2'/2", "xpub6FHa3pjLCk84BayeJxFW2SP4XRrFd1JYnxeLeU8EqN3vDfZmbqBqaGJAyiLjTAwm6ZLRQUMv1ZACTj37sR62cfN7fe5JnJ7dh8zL4fiyLHV", "xprvA2JDeKCSNNZky6uBCviVfJSKyQ1mDYahRjijr5idH2WwLsEd4Hsb2Tyh8RfQMuPh7f7RtyzTtdrbdqqsunu5Mm3wDvUAKRHSC34sJ7in334"},
+				{"m/0'/1/2'/2/1000000000", "xpub6H1LXWLaKsWFhvm6RVpEL9P4KfRZSW7abD2ttkWP3SSQvnyA8FSVqNTEcYFgJS2UaFcxupHiYkro49S8yGasTvXEYBVPamhGW6cFJodrTHy", "xprvA41z7zogVVwxVSgdKUHDy1SKmdb533PjDz7J6N6mV6uS3ze1ai8FHa8kmHScGpWmj4WggLyQjgPie1rFSruoUihUZREPSL39UNdE3BBDu76"},
+			},
+		},
+		// Test vector 2
+		{
+			"fffcf9f6f3f0edeae7e4e1dedbd8d5d2cfccc9c6c3c0bdbab7b4b1aeaba8a5a29f9c999693908d8a8784817e7b7875726f6c696663605d5a5754514e4b484542",
+			[]chain{
+				{"m", "xpub661MyMwAqRbcFW31YEwpkMuc5THy2PSt5bDMsktWQcFF8syAmRUapSCGu8ED9W6oDMSgv6Zz8idoc4a6mr8BDzTJY47LJhkJ8UB7WEGuduB", "xprv9s21ZrQH143K31xYSDQpPDxsXRTUcvj2iNHm5NUtrGiGG5e2DtALGdso3pGz6ssrdK4PFmM8NSpSBHNqPqm55Qn3LqFtT2emdEXVYsCzC2U"},
+				{"m/0", "xpub69H7F5d8KSRgmmdJg2KhpAK8SR3DjMwAdkxj3ZuxV27CprR9LgpeyGmXUbC6wb7ERfvrnKZjXoUmmDznezpbZb7ap6r1D3tgFxHmwMkQTPH", "xprv9vHkqa6EV4sPZHYqZznhT2NPtPCjKuDKGY38FBWLvgaDx45zo9WQRUT3dKYnjwih2yJD9mkrocEZXo1ex8G81dwSM1fwqWpWkeS3v86pgKt"},
+				{"m/0/2147483647'", "xpub6ASAVgeehLbnwdqV6UKMHVzgqAG8Gr6riv3Fxxpj8ksbH9ebxaEyBLZ85ySDhKiLDBrQSARLq1uNRts8RuJiHjaDMBU4Zn9h8LZNnBC5y4a", "xprv9wSp6B7kry3Vj9m1zSnLvN3xH8RdsPP1Mh7fAaR7aRLcQMKTR2vidYEeEg2mUCTAwCd6vnxVrcjfy2kRgVsFawNzmjuHc2YmYRmagcEPdU9"},
+				{"m/0/2147483647'/1", "xpub6DF8uhdarytz3FWdA8TvFSvvAh8dP3283MY7p2V4SeE2wyWmG5mg5EwVvmdMVCQcoNJxGoWaU9DCWh89LojfZ537wTfunKau47EL2dhHKon", "xprv9zFnWC6h2cLgpmSA46vutJzBcfJ8yaJGg8cX1e5StJh45BBciYTRXSd25UEPVuesF9yog62tGAQtHjXajPPdbRCHuWS6T8XA2ECKADdw4Ef"},
+				{"m/0/2147483647'/1/2147483646'", "xpub6ERApfZwUNrhLCkDtcHTcxd75RbzS1ed54G1LkBUHQVHQKqhMkhgbmJbZRkrgZw4koxb5JaHWkY4ALHY2grBGRjaDMzQLcgJvLJuZZvRcEL", "xprvA1RpRA33e1JQ7ifknakTFpgNXPmW2YvmhqLQYMmrj4xJXXWYpDPS3xz7iAxn8L39njGVyuoseXzU6rcxFLJ8HFsTjSyQbLYnMpCqE2VbFWc"},
+				{"m/0/2147483647'/1/2147483646'/2", "xpub6FnCn6nSzZAw5Tw7cgR9bi15UV96gLZhjDstkXXxvCLsUXBGXPdSnLFbdpq8p9HmGsApME5hQTZ3emM2rnY5agb9rXpVGyy3bdW6EEgAtqt", "xprvA2nrNbFZABcdryreWet9Ea4LvTJcGsqrMzxHx98MMrotbir7yrKCEXw7nadnHM8Dq38EGfSh6dqA9QWTyefMLEcBYJUuekgW4BYPJcr9E7j"},
+			},
+		},
+		// Test vector 3
+		{
+			"4b381541583be4423346c643850da4b320e46a87ae3d2a4e6da11eba819cd4acba45d239319ac14f863b8d5ab5a0d0c64d2e8a1e7d1457df2e5a3c51c73235be",
+			[]chain{
+				{"m", "xpub661MyMwAqRbcEZVB4dScxMAdx6d4nFc9nvyvH3v4gJL378CSRZiYmhRoP7mBy6gSPSCYk6SzXPTf3ND1cZAceL7SfJ1Z3GC8vBgp2epUt13", "xprv9s21ZrQH143K25QhxbucbDDuQ4naNntJRi4KUfWT7xo4EKsHt2QJDu7KXp1A3u7Bi1j8ph3EGsZ9Xvz9dGuVrtHHs7pXeTzjuxBrCmmhgC6"},
+				{"m/0'", "xpub68NZiKmJWnxxS6aaHmn81bvJeTESw724CRDs6HbuccFQN9Ku14VQrADWgqbhhTHBaohPX4CjNLf9fq9MYo6oDaPPLPxSb7gwQN3ih19Zm4Y", "xprv9uPDJpEQgRQfDcW7BkF7eTya6RPxXeJCqCJGHuCJ4GiRVLzkTXBAJMu2qaMWPrS7AANYqdq6vcBcBUdJCVVFceUvJFjaPdGZ2y9WACViL4L"},
+			},
+		},
+		// Test vector 4
+		{
+			"3ddd5602285899a946114506157c7997e5444528f3003f6134712147db19b678",
+			[]chain{
+				{"m", "xpub661MyMwAqRbcGczjuMoRm6dXaLDEhW1u34gKenbeYqAix21mdUKJyuyu5F1rzYGVxyL6tmgBUAEPrEz92mBXjByMRiJdba9wpnN37RLLAXa", "xprv9s21ZrQH143K48vGoLGRPxgo2JNkJ3J3fqkirQC2zVdk5Dgd5w14S7fRDyHH4dWNHUgkvsvNDCkvAwcSHNAQwhwgNMgZhLtQC63zxwhQmRv"},
+				{"m/0'", "xpub69AUMk3qDBi3uW1sXgjCmVjJ2G6WQoYSnNHyzkmdCHEhSZ4tBok37xfFEqHd2AddP56Tqp4o56AePAgCjYdvpW2PU2jbUPFKsav5ut6Ch1m", "xprv9vB7xEWwNp9kh1wQRfCCQMnZUEG21LpbR9NPCNN1dwhiZkjjeGRnaALmPXCX7SgjFTiCTT6bXes17boXtjq3xLpcDjzEuGLQBM5ohqkao9G"},
+				{"m/0'/1'", "xpub6BJA1jSqiukeaesWfxe6sNK9CCGaujFFSJLomWHprUL9DePQ4JDkM5d88n49sMGJxrhpjazuXYWdMf17C9T5XnxkopaeS7jGk1GyyVziaMt", "xprv9xJocDuwtYCMNAo3Zw76WENQeAS6WGXQ55RCy7tDJ8oALr4FWkuVoHJeHVAcAqiZLE7Je3vZJHxspZdFHfnBEjHqU5hG1Jaj32dVoS6XLT1"},
+			},
+		},
+	}
+
+	for _, vector := range vectors {
+		seed, _ := hex.DecodeString(vector.Seed)
+		master, err := NewMasterKey(seed, MainNetwork)
+		assert.Nil(t, err)
+
+		for _, value := range vector.Chains {
+			/* Private derivation from the master key */
+			key, err := master.Derive(value.Path)
+			assert.Nil(t, err, value.Path)
+			assert.Equal(t, value.XPrv, key.String(), value.Path)
+			assert.Equal(t, value.XPub, key.Neuter().String(), value.Path)
+
+			/* Round trip */
+			xprv, err := ExtendedFromBase58(value.XPrv, MainNetwork)
+			assert.Nil(t, err)
+			assert.True(t, xprv.IsPrivate())
+			assert.Equal(t, value.XPrv, xprv.String())
+
+			xpub, err := ExtendedFromBase58(value.XPub, MainNetwork)
+			assert.Nil(t, err)
+			assert.False(t, xpub.IsPrivate())
+			assert.Equal(t, value.XPub, xpub.String())
+		}
+
+		/* Public derivation must match the neutered private derivation */
+		for i := 1; i < len(vector.Chains); i++ {
+			parent, _ := ExtendedFromBase58(vector.Chains[i-1].XPub, MainNetwork)
+			indexes, _ := ParsePath(vector.Chains[i].Path)
+			index := indexes[len(indexes)-1]
+
+			child, err := parent.Child(index)
+			if index >= HardenedKeyStart {
+				assert.NotNil(t, err)
+				continue
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, vector.Chains[i].XPub, child.String())
+		}
+	}
+}
+
+func TestExtendedFromBase58(t *testing.T) {
+	var invalid = []struct {
+		Key    string
+		Reason string
+	}{
+		{"xpub661MyMwAqRbcEYS8w7XLSVeEsBXy79zSzH1J8vCdxAZningWLdN3zgtU6LBpB85b3D2yc8sfvZU521AAwdZafEz7mnzBBsz4wKY5fTtTQBm", "pubkey version / prvkey mismatch"},
+		{"xprv9s21ZrQH143K24Mfq5zL5MhWK9hUhhGbd45hLXo2Pq2oqzMMo63oStZzFGTQQD3dC4H2D5GBj7vWvSQaaBv5cxi9gafk7NF3pnBju6dwKvH", "prvkey version / pubkey mismatch"},
+		{"xpub661MyMwAqRbcEYS8w7XLSVeEsBXy79zSzH1J8vCdxAZningWLdN3zgtU6Txnt3siSujt9RCVYsx4qHZGc62TG4McvMGcAUjeuwZdduYEvFn", "invalid pubkey prefix 04"},
+		{"xprv9s21ZrQH143K24Mfq5zL5MhWK9hUhhGbd45hLXo2Pq2oqzMMo63oStZzFGpWnsj83BHtEy5Zt8CcDr1UiRXuWCmTQLxEK9vbz5gPstX92JQ", "invalid prvkey prefix 04"},
+		{"xpub661MyMwAqRbcEYS8w7XLSVeEsBXy79zSzH1J8vCdxAZningWLdN3zgtU6N8ZMMXctdiCjxTNq964yKkwrkBJJwpzZS4HS2fxvyYUA4q2Xe4", "invalid pubkey prefix 01"},
+		{"xprv9s21ZrQH143K24Mfq5zL5MhWK9hUhhGbd45hLXo2Pq2oqzMMo63oStZzFAzHGBP2UuGCqWLTAPLcMtD9y5gkZ6Eq3Rjuahrv17fEQ3Qen6J", "invalid prvkey prefix 01"},
+		{"xprv9s2SPatNQ9Vc6GTbVMFPFo7jsaZySyzk7L8n2uqKXJen3KUmvQNTuLh3fhZMBoG3G4ZW1N2kZuHEPY53qmbZzCHshoQnNf4GvELZfqTUrcv", "zero depth with non-zero parent fingerprint"},
+		{"xpub661no6RGEX3uJkY4bNnPcw4URcQTrSibUZ4NqJEw5eBkv7ovTwgiT91XX27VbEXGENhYRCf7hyEbWrR3FewATdCEebj6znwMfQkhRYHRLpJ", "zero depth with non-zero parent fingerprint"},
+		{"xprv9s21ZrQH4r4TsiLvyLXqM9P7k1K3EYhA1kkD6xuquB5i39AU8KF42acDyL3qsDbU9NmZn6MsGSUYZEsuoePmjzsB3eFKSUEh3Gu1N3cqVUN", "zero depth with non-zero index"},
+		{"xpub661MyMwAuDcm6CRQ5N4qiHKrJ39Xe1R1NyfouMKTTWcguwVcfrZJaNvhpebzGerh7gucBvzEQWRugZDuDXjNDRmXzSZe4c7mnTK97pTvGS8", "zero depth with non-zero index"},
+		{"DMwo58pR1QLEFihHiXPVykYB6fJmsTeHvyTp7hRThAtCX8CvYzgPcn8XnmdfHGMQzT7ayAmfo4z3gY5KfbrZWZ6St24UVf2Qgo6oujFktLHdHY4", "unknown extended key version"},
+		{"DMwo58pR1QLEFihHiXPVykYB6fJmsTeHvyTp7hRThAtCX8CvYzgPcn8XnmdfHPmHJiEDXkTiJTVV9rHEBUem2mwVbbNfvT2MTcAqj3nesx8uBf9", "unknown extended key version"},
+		{"xprv9s21ZrQH143K24Mfq5zL5MhWK9hUhhGbd45hLXo2Pq2oqzMMo63oStZzF93Y5wvzdUayhgkkFoicQZcP3y52uPPxFnfoLZB21Teqt1VvEHx", "private key 0 not in 1..n-1"},
+		{"xprv9s21ZrQH143K24Mfq5zL5MhWK9hUhhGbd45hLXo2Pq2oqzMMo63oStZzFAzHGBP2UuGCqWLTAPLcMtD5SDKr24z3aiUvKr9bJpdrcLg1y3G", "private key n not in 1..n-1"},
+		{"xpub661MyMwAqRbcEYS8w7XLSVeEsBXy79zSzH1J8vCdxAZningWLdN3zgtU6Q5JXayek4PRsn35jii4veMimro1xefsM58PgBMrvdYre8QyULY", "invalid pubkey 020000000000000000000000000000000000000000000000000000000000000007"},
+		{"xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHL", "invalid checksum"},
+	}
+
+	for _, value := range invalid {
+		_, err := ExtendedFromBase58(value.Key, MainNetwork)
+		assert.NotNil(t, err, value.Reason)
+	}
+
+	/* Network mismatch */
+	_, err := ExtendedFromBase58("xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi", TestNetwork)
+	assert.NotNil(t, err)
+}
+
+func TestParsePath(t *testing.T) {
+	indexes, err := ParsePath("m/84'/0h/0H/0/5")
+	assert.Nil(t, err)
+	assert.Equal(t, []uint32{84 + HardenedKeyStart, HardenedKeyStart, HardenedKeyStart, 0, 5}, indexes)
+
+	indexes, err = ParsePath("m")
+	assert.Nil(t, err)
+	assert.Empty(t, indexes)
+
+	for _, path := range []string{"m/", "m/a", "m/-1", "m/2147483648", "m/0''", "m/0/x'"} {
+		_, err := ParsePath(path)
+		assert.NotNil(t, err, path)
+	}
+}
+
+func TestExtendedKeyFingerprint(t *testing.T) {
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	master, err := NewMasterKey(seed, MainNetwork)
+	assert.Nil(t, err)
+	assert.Equal(t, "3442193e", hex.EncodeToString(master.Fingerprint()))
+
+	child, err := master.Derive("m/0'")
+	assert.Nil(t, err)
+	assert.Equal(t, master.Fingerprint(), child.ParentFingerprint)
+	assert.Equal(t, byte(1), child.Depth)
+
+	/* Testnet serialization */
+	testnetMaster, err := NewMasterKey(seed, TestNetwork)
+	assert.Nil(t, err)
+	assert.Equal(t, "tprv", testnetMaster.String()[0:4])
+	assert.Equal(t, "tpub", testnetMaster.Neuter().String()[0:4])
+}
+
+func TestExtendedKeySLIP132(t *testing.T) {
+	seed, err := NewSeedFromMnemonic("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", "", EnglishWordlist)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/* BIP84 account keys */
+	zprv := "zprvAdG4iTXWBoARxkkzNpNh8r6Qag3irQB8PzEMkAFeTRXxHpbF9z4QgEvBRmfvqWvGp42t42nvgGpNgYSJA9iefm1yYNZKEm7z6qUWCroSQnE"
+	zpub := "zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs"
+
+	account, err := NewAccount(seed, BIP84Purpose, 0, MainNetwork)
+	assert.Nil(t, err)
+	assert.Equal(t, zprv, account.ExtendedKey.String())
+	assert.Equal(t, zpub, account.ExtendedKey.Neuter().String())
+
+	key, err := ExtendedFromBase58(zpub, MainNetwork)
+	assert.Nil(t, err)
+	assert.Equal(t, P2WPKHExtendedKey, key.Type)
+	assert.Equal(t, zpub, key.String())
+
+	/* The script type is inferred from the version */
+	child, err := key.Derive("0/0")
+	assert.Nil(t, err)
+	address, err := child.Address()
+	assert.Nil(t, err)
+	assert.Equal(t, "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu", address)
+
+	imported, err := NewAccountFromExtendedKey(key)
+	assert.Nil(t, err)
+	assert.Equal(t, BIP84Purpose, imported.Purpose)
+	assert.Equal(t, "m/84'/0'/0'", imported.Path())
+	address, err = imported.ChangeAddress(0)
+	assert.Nil(t, err)
+	assert.Equal(t, "bc1q8c6fshw2dlwun7ekn9qwf37cu2rn755upcp6el", address)
+
+	/* Conversions only change the version */
+	prefixes := map[ExtendedKeyType][]string{
+		P2PKHExtendedKey:      {"xprv", "xpub", "tprv", "tpub"},
+		P2SHP2WPKHExtendedKey: {"yprv", "ypub", "uprv", "upub"},
+		P2WPKHExtendedKey:     {"zprv", "zpub", "vprv", "vpub"},
+		P2SHP2WSHExtendedKey:  {"Yprv", "Ypub", "Uprv", "Upub"},
+		P2WSHExtendedKey:      {"Zprv", "Zpub", "Vprv", "Vpub"},
+	}
+	testnetKey, err := ExtendedFromBase58(zprv, MainNetwork)
+	assert.Nil(t, err)
+	testnetKey.Network = TestNetwork
+	testnet := testnetKey.String()
+	for keyType, p := range prefixes {
+		for i, k := range []string{zprv, zpub, testnet, testnetKey.Neuter().String()} {
+			network := MainNetwork
+			if i >= 2 {
+				network = TestNetwork
+			}
+
+			converted, err := ConvertExtendedKey(k, keyType, network)
+			assert.Nil(t, err)
+			assert.Equal(t, p[i], converted[0:4])
+
+			parsed, err := ExtendedFromBase58(converted, network)
+			assert.Nil(t, err)
+			assert.Equal(t, keyType, parsed.Type)
+
+			back, err := ConvertExtendedKey(converted, P2WPKHExtendedKey, network)
+			assert.Nil(t, err)
+			assert.Equal(t, k, back)
+		}
+	}
+
+	/* Multisig versions have no single key address */
+	key, err = key.WithType(P2WSHExtendedKey)
+	assert.Nil(t, err)
+	_, err = key.Address()
+	assert.NotNil(t, err)
+	_, err = NewAccountFromExtendedKey(key)
+	assert.NotNil(t, err)
+
+	/* Descriptors only accept xpub and tpub */
+	_, err = ParseDescriptor("wpkh(" + zpub + "/0/*)")
+	assert.NotNil(t, err)
+	xpub, err := ConvertExtendedKey(zpub, P2PKHExtendedKey, MainNetwork)
+	assert.Nil(t, err)
+	_, err = ParseDescriptor("wpkh(" + xpub + "/0/*)")
+	assert.Nil(t, err)
+}
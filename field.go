@@ -0,0 +1,225 @@
+package btc
+
+import (
+	"math/big"
+	"math/bits"
+)
+
+/* fieldElement is an integer modulo the field size p of secp256k1 as 4 little-endian 64 bits limbs, it is always fully reduced */
+type fieldElement [4]uint64
+
+/* fieldP is p = 2^256 - 2^32 - 977 */
+var fieldP = fieldElement{0xfffffffefffffc2f, 0xffffffffffffffff, 0xffffffffffffffff, 0xffffffffffffffff}
+
+/* fieldC is 2^256 - p, so 2^256 = fieldC mod p */
+const fieldC uint64 = 0x1000003d1
+
+var fieldOne = fieldElement{1, 0, 0, 0}
+
+/* fieldFromBig returns x mod p, it branches on the value of x and is only used for coordinates of public points */
+func fieldFromBig(x *big.Int) fieldElement {
+	if x.Sign() < 0 || x.Cmp(secp256k1.P) >= 0 {
+		x = new(big.Int).Mod(x, secp256k1.P)
+	}
+	f, _ := fieldFromBytes(paddedBytes(x, 32))
+	return f
+}
+
+/* fieldFromBytes decodes a 32 bytes big-endian integer, it returns false if it is not lower than p */
+func fieldFromBytes(b []byte) (fieldElement, bool) {
+	var f fieldElement
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 8; j++ {
+			f[3-i] = f[3-i]<<8 | uint64(b[i*8+j])
+		}
+	}
+	_, borrow := f.subRaw(fieldP)
+	return f, borrow == 1
+}
+
+/* bytes returns the 32 bytes big-endian encoding of f */
+func (f fieldElement) bytes() []byte {
+	b := make([]byte, 32)
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 8; j++ {
+			b[i*8+j] = byte(f[3-i] >> uint(56-8*j))
+		}
+	}
+	return b
+}
+
+/* big returns f as a big integer */
+func (f fieldElement) big() *big.Int {
+	return new(big.Int).SetBytes(f.bytes())
+}
+
+/* isZero returns 1 if f is 0, in constant time */
+func (f fieldElement) isZero() uint64 {
+	x := f[0] | f[1] | f[2] | f[3]
+	return 1 ^ (x|-x)>>63
+}
+
+/* equal returns 1 if f = g, in constant time */
+func (f fieldElement) equal(g fieldElement) uint64 {
+	return fieldElement{f[0] ^ g[0], f[1] ^ g[1], f[2] ^ g[2], f[3] ^ g[3]}.isZero()
+}
+
+/* isOdd returns the lowest bit of f */
+func (f fieldElement) isOdd() uint64 {
+	return f[0] & 1
+}
+
+/* fieldSelect returns a if cond is 1 and b if cond is 0, in constant time */
+func fieldSelect(cond uint64, a fieldElement, b fieldElement) fieldElement {
+	mask := -cond
+	return fieldElement{
+		b[0] ^ (mask & (a[0] ^ b[0])),
+		b[1] ^ (mask & (a[1] ^ b[1])),
+		b[2] ^ (mask & (a[2] ^ b[2])),
+		b[3] ^ (mask & (a[3] ^ b[3])),
+	}
+}
+
+/* subRaw returns f - g mod 2^256 and the borrow */
+func (f fieldElement) subRaw(g fieldElement) (fieldElement, uint64) {
+	var r fieldElement
+	var borrow uint64
+	r[0], borrow = bits.Sub64(f[0], g[0], 0)
+	r[1], borrow = bits.Sub64(f[1], g[1], borrow)
+	r[2], borrow = bits.Sub64(f[2], g[2], borrow)
+	r[3], borrow = bits.Sub64(f[3], g[3], borrow)
+	return r, borrow
+}
+
+/* add returns f + g mod p */
+func (f fieldElement) add(g fieldElement) fieldElement {
+	var r fieldElement
+	var carry uint64
+	r[0], carry = bits.Add64(f[0], g[0], 0)
+	r[1], carry = bits.Add64(f[1], g[1], carry)
+	r[2], carry = bits.Add64(f[2], g[2], carry)
+	r[3], carry = bits.Add64(f[3], g[3], carry)
+
+	/* f + g < 2p, p is subtracted if the sum overflowed or is not lower than p */
+	s, borrow := r.subRaw(fieldP)
+	return fieldSelect(carry|(1^borrow), s, r)
+}
+
+/* sub returns f - g mod p */
+func (f fieldElement) sub(g fieldElement) fieldElement {
+	r, borrow := f.subRaw(g)
+	mask := -borrow
+
+	var carry uint64
+	r[0], carry = bits.Add64(r[0], fieldP[0]&mask, 0)
+	r[1], carry = bits.Add64(r[1], fieldP[1]&mask, carry)
+	r[2], carry = bits.Add64(r[2], fieldP[2]&mask, carry)
+	r[3], _ = bits.Add64(r[3], fieldP[3]&mask, carry)
+	return r
+}
+
+/* negate returns -f mod p */
+func (f fieldElement) negate() fieldElement {
+	return fieldElement{}.sub(f)
+}
+
+/* mul returns f * g mod p */
+func (f fieldElement) mul(g fieldElement) fieldElement {
+	var t [8]uint64
+	for i := 0; i < 4; i++ {
+		var carry uint64
+		for j := 0; j < 4; j++ {
+			hi, lo := bits.Mul64(f[i], g[j])
+			var c uint64
+			lo, c = bits.Add64(lo, t[i+j], 0)
+			hi += c
+			lo, c = bits.Add64(lo, carry, 0)
+			hi += c
+			t[i+j] = lo
+			carry = hi
+		}
+		t[i+4] = carry
+	}
+	return fieldReduce(t)
+}
+
+/* square returns f^2 mod p */
+func (f fieldElement) square() fieldElement {
+	return f.mul(f)
+}
+
+/* squareN returns f^(2^n) mod p */
+func (f fieldElement) squareN(n int) fieldElement {
+	for i := 0; i < n; i++ {
+		f = f.mul(f)
+	}
+	return f
+}
+
+/* fieldReduce reduces a 512 bits integer modulo p using 2^256 = fieldC mod p */
+func fieldReduce(t [8]uint64) fieldElement {
+	/* r = t[0..3] + t[4..7]*c < 2^290 */
+	var r [5]uint64
+	var carry uint64
+	for i := 0; i < 4; i++ {
+		hi, lo := bits.Mul64(t[4+i], fieldC)
+		var c uint64
+		lo, c = bits.Add64(lo, t[i], 0)
+		hi += c
+		lo, c = bits.Add64(lo, carry, 0)
+		hi += c
+		r[i] = lo
+		carry = hi
+	}
+	r[4] = carry
+
+	/* Fold the limb above 2^256 again, an overflow of this addition leaves a small value which absorbs another c */
+	hi, lo := bits.Mul64(r[4], fieldC)
+	var f fieldElement
+	f[0], carry = bits.Add64(r[0], lo, 0)
+	f[1], carry = bits.Add64(r[1], hi, carry)
+	f[2], carry = bits.Add64(r[2], 0, carry)
+	f[3], carry = bits.Add64(r[3], 0, carry)
+
+	f[0], carry = bits.Add64(f[0], carry*fieldC, 0)
+	f[1], carry = bits.Add64(f[1], 0, carry)
+	f[2], carry = bits.Add64(f[2], 0, carry)
+	f[3], _ = bits.Add64(f[3], 0, carry)
+
+	s, borrow := f.subRaw(fieldP)
+	return fieldSelect(1^borrow, s, f)
+}
+
+/* fieldPowChain computes the common prefix x2, x22 and x223 = f^(2^223 - 1) of the addition chains of inverse and sqrt */
+func (f fieldElement) fieldPowChain() (x2 fieldElement, x22 fieldElement, x223 fieldElement) {
+	x2 = f.square().mul(f)
+	x3 := x2.square().mul(f)
+	x6 := x3.squareN(3).mul(x3)
+	x9 := x6.squareN(3).mul(x3)
+	x11 := x9.squareN(2).mul(x2)
+	x22 = x11.squareN(11).mul(x11)
+	x44 := x22.squareN(22).mul(x22)
+	x88 := x44.squareN(44).mul(x44)
+	x176 := x88.squareN(88).mul(x88)
+	x220 := x176.squareN(44).mul(x44)
+	x223 = x220.squareN(3).mul(x3)
+	return
+}
+
+/* inverse returns f^-1 = f^(p-2) mod p, the inverse of 0 is 0 */
+func (f fieldElement) inverse() fieldElement {
+	x2, x22, x223 := f.fieldPowChain()
+	t := x223.squareN(23).mul(x22)
+	t = t.squareN(5).mul(f)
+	t = t.squareN(3).mul(x2)
+	return t.squareN(2).mul(f)
+}
+
+/* sqrt returns a square root f^((p+1)/4) of f and 1 if f is a square */
+func (f fieldElement) sqrt() (fieldElement, uint64) {
+	x2, x22, x223 := f.fieldPowChain()
+	t := x223.squareN(23).mul(x22)
+	t = t.squareN(6).mul(x2)
+	r := t.squareN(2)
+	return r, r.square().equal(f)
+}
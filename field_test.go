@@ -0,0 +1,73 @@
+package btc
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/* fieldTestValues returns edge cases and random integers lower than p */
+func fieldTestValues() []*big.Int {
+	pMinus := func(i int64) *big.Int { return new(big.Int).Sub(secp256k1.P, big.NewInt(i)) }
+	values := []*big.Int{
+		big.NewInt(0), big.NewInt(1), big.NewInt(2), big.NewInt(7),
+		pMinus(1), pMinus(2), new(big.Int).Rsh(secp256k1.P, 1),
+		new(big.Int).Lsh(big.NewInt(1), 255), new(big.Int).Lsh(big.NewInt(1), 64),
+	}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		values = append(values, new(big.Int).Rand(r, secp256k1.P))
+	}
+	return values
+}
+
+/* assertBigEqual compares the values of big integers, whose representations of 0 may differ */
+func assertBigEqual(t *testing.T, expected *big.Int, actual *big.Int, msgAndArgs ...interface{}) {
+	assert.Equal(t, expected.String(), actual.String(), msgAndArgs...)
+}
+
+func TestFieldArithmetic(t *testing.T) {
+	P := secp256k1.P
+	values := fieldTestValues()
+	for i, a := range values {
+		b := values[(i*7+3)%len(values)]
+		fa := fieldFromBig(a)
+		fb := fieldFromBig(b)
+		assertBigEqual(t, a, fa.big())
+
+		expected := new(big.Int).Add(a, b)
+		assertBigEqual(t, expected.Mod(expected, P), fa.add(fb).big(), a.String())
+		expected = new(big.Int).Sub(a, b)
+		assertBigEqual(t, expected.Mod(expected, P), fa.sub(fb).big(), a.String())
+		expected = new(big.Int).Mul(a, b)
+		assertBigEqual(t, expected.Mod(expected, P), fa.mul(fb).big(), a.String())
+		expected = new(big.Int).Neg(a)
+		assertBigEqual(t, expected.Mod(expected, P), fa.negate().big(), a.String())
+		assert.Equal(t, uint64(a.Bit(0)), fa.isOdd())
+
+		if a.Sign() == 0 {
+			assert.Equal(t, uint64(1), fa.isZero())
+			assert.Equal(t, uint64(1), fa.inverse().isZero())
+			continue
+		}
+		assert.Equal(t, uint64(0), fa.isZero())
+		assertBigEqual(t, new(big.Int).ModInverse(a, P), fa.inverse().big(), a.String())
+
+		root, isSquare := fa.sqrt()
+		expectedRoot := new(big.Int).ModSqrt(a, P)
+		assert.Equal(t, expectedRoot != nil, isSquare == 1, a.String())
+		if expectedRoot != nil {
+			assert.Equal(t, uint64(1), root.square().equal(fa), a.String())
+		}
+	}
+
+	/* Encodings of integers not lower than p are rejected */
+	_, valid := fieldFromBytes(paddedBytes(P, 32))
+	assert.False(t, valid)
+	_, valid = fieldFromBytes(paddedBytes(new(big.Int).Sub(P, big.NewInt(1)), 32))
+	assert.True(t, valid)
+	assertBigEqual(t, big.NewInt(5), fieldFromBig(new(big.Int).Add(P, big.NewInt(5))).big())
+	assertBigEqual(t, new(big.Int).Sub(P, big.NewInt(5)), fieldFromBig(big.NewInt(-5)).big())
+}
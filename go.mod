@@ -8,4 +8,5 @@ require (
 	github.com/mr-tron/base58 v1.1.2
 	github.com/stretchr/testify v1.4.0
 	golang.org/x/crypto v0.0.0-20191011191535-87dc89f01550
+	golang.org/x/text v0.3.2
 )
@@ -0,0 +1,1314 @@
+package btc
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+
+	"golang.org/x/crypto/ripemd160"
+)
+
+// Script verification flags
+const (
+	ScriptVerifyP2SH ScriptFlags = 1 << iota
+	ScriptVerifyStrictEncoding
+	ScriptVerifyDERSignatures
+	ScriptVerifyLowS
+	ScriptVerifyNullDummy
+	ScriptVerifySigPushOnly
+	ScriptVerifyMinimalData
+	ScriptVerifyDiscourageUpgradableNops
+	ScriptVerifyCleanStack
+	ScriptVerifyCheckLockTimeVerify
+	ScriptVerifyCheckSequenceVerify
+	ScriptVerifyWitness
+	ScriptVerifyDiscourageUpgradableWitnessProgram
+	ScriptVerifyMinimalIf
+	ScriptVerifyNullFail
+	ScriptVerifyWitnessPubKeyType
+	ScriptVerifyConstScriptCode
+	ScriptVerifyTaproot
+	ScriptVerifyDiscourageUpgradableTaprootVersion
+	ScriptVerifyDiscourageOpSuccess
+	ScriptVerifyDiscourageUpgradablePubKeyType
+)
+
+// StandardVerifyFlags are the rules of all the soft forks activated on the network
+const StandardVerifyFlags = ScriptVerifyP2SH | ScriptVerifyDERSignatures | ScriptVerifyLowS | ScriptVerifyNullDummy |
+	ScriptVerifyCheckLockTimeVerify | ScriptVerifyCheckSequenceVerify | ScriptVerifyWitness | ScriptVerifyTaproot
+
+// LockTimeThreshold is the lock time value from which lock times are timestamps instead of block heights
+const LockTimeThreshold = 500000000
+
+/* Relative lock time bits of input sequences (BIP68) */
+const (
+	sequenceLockTimeDisableFlag = 1 << 31
+	sequenceLockTimeTypeFlag    = 1 << 22
+	sequenceLockTimeMask        = 0x0000ffff
+)
+
+/* Tapscript signature budget (BIP342) */
+const (
+	validationWeightPerSigOp = 50
+	validationWeightOffset   = 50
+)
+
+/* Script errors, named after Bitcoin Core's ScriptError values */
+var (
+	errEvalFalse                          = errors.New("script evaluated without error but finished with a false/empty top stack element")
+	errOpReturn                           = errors.New("OP_RETURN was encountered")
+	errScriptNum                          = errors.New("invalid script number")
+	errScriptSize                         = errors.New("script is too big")
+	errPushSize                           = errors.New("push value size limit exceeded")
+	errOpCount                            = errors.New("operation limit exceeded")
+	errStackSize                          = errors.New("stack size limit exceeded")
+	errSigCount                           = errors.New("signature count negative or greater than pubkey count")
+	errPubKeyCount                        = errors.New("pubkey count negative or limit exceeded")
+	errVerify                             = errors.New("script failed an OP_VERIFY operation")
+	errEqualVerify                        = errors.New("script failed an OP_EQUALVERIFY operation")
+	errCheckMultiSigVerify                = errors.New("script failed an OP_CHECKMULTISIGVERIFY operation")
+	errCheckSigVerify                     = errors.New("script failed an OP_CHECKSIGVERIFY operation")
+	errNumEqualVerify                     = errors.New("script failed an OP_NUMEQUALVERIFY operation")
+	errBadOpcode                          = errors.New("opcode missing or not understood")
+	errDisabledOpcode                     = errors.New("attempted to use a disabled opcode")
+	errInvalidStackOperation              = errors.New("operation not valid with the current stack size")
+	errInvalidAltStackOperation           = errors.New("operation not valid with the current altstack size")
+	errUnbalancedConditional              = errors.New("invalid OP_IF construction")
+	errNegativeLockTime                   = errors.New("negative locktime")
+	errUnsatisfiedLockTime                = errors.New("locktime requirement not satisfied")
+	errSigHashType                        = errors.New("signature hash type missing or not understood")
+	errSigDER                             = errors.New("non-canonical DER signature")
+	errMinimalData                        = errors.New("data push larger than necessary")
+	errSigPushOnly                        = errors.New("only push operators allowed in signatures")
+	errSigHighS                           = errors.New("non-canonical signature: S value is unnecessarily high")
+	errSigNullDummy                       = errors.New("dummy CHECKMULTISIG argument must be zero")
+	errPubKeyType                         = errors.New("public key is neither compressed or uncompressed")
+	errCleanStack                         = errors.New("stack size must be exactly one after execution")
+	errMinimalIf                          = errors.New("OP_IF/NOTIF argument must be minimal")
+	errSigNullFail                        = errors.New("signature must be zero for failed CHECK(MULTI)SIG operation")
+	errDiscourageUpgradableNops           = errors.New("NOPx reserved for soft-fork upgrades")
+	errDiscourageUpgradableWitnessProgram = errors.New("witness version reserved for soft-fork upgrades")
+	errDiscourageUpgradableTaprootVersion = errors.New("taproot version reserved for soft-fork upgrades")
+	errDiscourageOpSuccess                = errors.New("OP_SUCCESSx reserved for soft-fork upgrades")
+	errDiscourageUpgradablePubKeyType     = errors.New("public key version reserved for soft-fork upgrades")
+	errWitnessProgramWrongLength          = errors.New("witness program has incorrect length")
+	errWitnessProgramWitnessEmpty         = errors.New("witness program was passed an empty witness")
+	errWitnessProgramMismatch             = errors.New("witness program hash mismatch")
+	errWitnessMalleated                   = errors.New("witness requires empty scriptSig")
+	errWitnessMalleatedP2SH               = errors.New("witness requires only-redeemscript scriptSig")
+	errWitnessUnexpected                  = errors.New("witness provided for non-witness script")
+	errWitnessPubKeyType                  = errors.New("using non-compressed keys in segwit")
+	errSchnorrSigSize                     = errors.New("invalid schnorr signature size")
+	errSchnorrSigHashType                 = errors.New("invalid schnorr signature hash type")
+	errSchnorrSig                         = errors.New("invalid schnorr signature")
+	errTaprootWrongControlSize            = errors.New("invalid taproot control block size")
+	errTapscriptValidationWeight          = errors.New("too much signature validation relative to witness weight")
+	errTapscriptCheckMultiSig             = errors.New("OP_CHECKMULTISIG(VERIFY) is not available in tapscript")
+	errTapscriptMinimalIf                 = errors.New("OP_IF/NOTIF argument must be minimal in tapscript")
+	errTapscriptEmptyPubKey               = errors.New("empty public key in tapscript")
+	errOpCodeSeparator                    = errors.New("using OP_CODESEPARATOR in non-witness script")
+	errSigFindAndDelete                   = errors.New("signature is found in scriptCode")
+)
+
+/* sigVersion is the kind of script being executed, it selects the signature hash algorithm */
+type sigVersion int
+
+const (
+	sigVersionBase sigVersion = iota
+	sigVersionWitnessV0
+	sigVersionTaproot
+	sigVersionTapscript
+)
+
+/* scriptEngine verifies the scripts of one input of a transaction */
+type scriptEngine struct {
+	tx       *Transaction
+	index    int
+	prevOuts []*TxOut
+	flags    ScriptFlags
+
+	/* Tapscript execution data */
+	tapLeafHash          []byte
+	codeSeparatorPos     uint32
+	validationWeightLeft int64
+}
+
+// VerifyInput executes the scripts of input index, prevOuts are the outputs spent by every input of the transaction
+func (tx *Transaction) VerifyInput(index int, prevOuts []*TxOut, flags ScriptFlags) error {
+	if index < 0 || index >= len(tx.Inputs) {
+		return errors.New("input index out of range")
+	}
+	if len(prevOuts) != len(tx.Inputs) {
+		return errors.New("one spent output is required per input")
+	}
+	/* Like in Bitcoin Core, CLEANSTACK requires WITNESS which requires P2SH */
+	if (flags&ScriptVerifyWitness != 0 && flags&ScriptVerifyP2SH == 0) ||
+		(flags&ScriptVerifyCleanStack != 0 && flags&ScriptVerifyWitness == 0) {
+		return errors.New("invalid script verification flags")
+	}
+
+	e := &scriptEngine{
+		tx:       tx,
+		index:    index,
+		prevOuts: prevOuts,
+		flags:    flags,
+	}
+	in := tx.Inputs[index]
+	return e.verifyScript(in.ScriptSig, prevOuts[index].ScriptPubKey, in.Witness)
+}
+
+// Verify checks the transaction and executes the scripts of all its inputs
+func (tx *Transaction) Verify(prevOuts []*TxOut, flags ScriptFlags) error {
+	err := tx.Check()
+	if err != nil {
+		return err
+	}
+	for i := range tx.Inputs {
+		err = tx.VerifyInput(i, prevOuts, flags)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/* verifyScript is Bitcoin Core's VerifyScript */
+func (e *scriptEngine) verifyScript(scriptSig []byte, scriptPubKey []byte, witness [][]byte) error {
+	if e.flags&ScriptVerifySigPushOnly != 0 && !isPushOnly(scriptSig) {
+		return errSigPushOnly
+	}
+
+	/* scriptSig and scriptPubKey are evaluated on the same stack, not concatenated */
+	stack, err := e.evalScript(nil, scriptSig, sigVersionBase)
+	if err != nil {
+		return err
+	}
+	var stackCopy [][]byte
+	if e.flags&ScriptVerifyP2SH != 0 {
+		stackCopy = append(stackCopy, stack...)
+	}
+	stack, err = e.evalScript(stack, scriptPubKey, sigVersionBase)
+	if err != nil {
+		return err
+	}
+	if len(stack) == 0 || !castToBool(stack[len(stack)-1]) {
+		return errEvalFalse
+	}
+
+	hadWitness := false
+	if e.flags&ScriptVerifyWitness != 0 {
+		version, program, ok := witnessProgram(scriptPubKey)
+		if ok {
+			hadWitness = true
+			/* The scriptSig must be empty, otherwise it would be malleable */
+			if len(scriptSig) != 0 {
+				return errWitnessMalleated
+			}
+			err = e.verifyWitnessProgram(witness, version, program, false)
+			if err != nil {
+				return err
+			}
+			/* Bypass the clean stack check */
+			stack = stack[:1]
+		}
+	}
+
+	if e.flags&ScriptVerifyP2SH != 0 && isPayToScriptHash(scriptPubKey) {
+		if !isPushOnly(scriptSig) {
+			return errSigPushOnly
+		}
+
+		/* Execute the redeem script, the last element pushed by scriptSig, on the scriptSig stack */
+		stack = stackCopy
+		redeemScript := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		stack, err = e.evalScript(stack, redeemScript, sigVersionBase)
+		if err != nil {
+			return err
+		}
+		if len(stack) == 0 || !castToBool(stack[len(stack)-1]) {
+			return errEvalFalse
+		}
+
+		if e.flags&ScriptVerifyWitness != 0 {
+			version, program, ok := witnessProgram(redeemScript)
+			if ok {
+				hadWitness = true
+				/* The scriptSig must be exactly a push of the redeem script */
+				if !bytes.Equal(scriptSig, pushData(redeemScript)) {
+					return errWitnessMalleatedP2SH
+				}
+				err = e.verifyWitnessProgram(witness, version, program, true)
+				if err != nil {
+					return err
+				}
+				stack = stack[:1]
+			}
+		}
+	}
+
+	/* The clean stack rule is only checked after the P2SH and witness evaluations */
+	if e.flags&ScriptVerifyCleanStack != 0 && len(stack) != 1 {
+		return errCleanStack
+	}
+
+	if e.flags&ScriptVerifyWitness != 0 && !hadWitness && len(witness) > 0 {
+		return errWitnessUnexpected
+	}
+	return nil
+}
+
+/* verifyWitnessProgram is Bitcoin Core's VerifyWitnessProgram */
+func (e *scriptEngine) verifyWitnessProgram(witness [][]byte, version int, program []byte, isP2SH bool) error {
+	switch {
+	case version == 0 && len(program) == 32:
+		/* P2WSH: the last witness element is the script, its sha256 is the program */
+		if len(witness) == 0 {
+			return errWitnessProgramWitnessEmpty
+		}
+		script := witness[len(witness)-1]
+		if !bytes.Equal(singleHash(script), program) {
+			return errWitnessProgramMismatch
+		}
+		return e.executeWitnessScript(witness[:len(witness)-1], script, sigVersionWitnessV0)
+
+	case version == 0 && len(program) == 20:
+		/* P2WPKH: OP_DUP OP_HASH160 <program> OP_EQUALVERIFY OP_CHECKSIG */
+		if len(witness) != 2 {
+			return errWitnessProgramMismatch
+		}
+		script := append([]byte{OpDup, OpHash160, 0x14}, program...)
+		script = append(script, OpEqualVerify, OpCheckSig)
+		return e.executeWitnessScript(witness, script, sigVersionWitnessV0)
+
+	case version == 0:
+		return errWitnessProgramWrongLength
+
+	case version == 1 && len(program) == 32 && !isP2SH:
+		if e.flags&ScriptVerifyTaproot == 0 {
+			return nil
+		}
+		stack := witness
+		if len(stack) == 0 {
+			return errWitnessProgramWitnessEmpty
+		}
+		/* Drop the annex, it is committed to by the signature hash */
+		if taprootAnnex(stack) != nil {
+			stack = stack[:len(stack)-1]
+		}
+
+		/* Key path spending */
+		if len(stack) == 1 {
+			return e.checkSchnorrSignature(stack[0], program, sigVersionTaproot)
+		}
+
+		/* Script path spending */
+		control := stack[len(stack)-1]
+		script := stack[len(stack)-2]
+		stack = stack[:len(stack)-2]
+		if len(control) < 33 || len(control) > 33+32*128 || (len(control)-33)%32 != 0 {
+			return errTaprootWrongControlSize
+		}
+		leafVersion := control[0] & 0xfe
+		e.tapLeafHash = TapLeafHash(leafVersion, script)
+		if !verifyTaprootCommitment(control, program, e.tapLeafHash) {
+			return errWitnessProgramMismatch
+		}
+
+		if leafVersion == TapLeafVersion {
+			e.validationWeightLeft = int64(witnessSize(witness)) + validationWeightOffset
+			return e.executeWitnessScript(stack, script, sigVersionTapscript)
+		}
+		if e.flags&ScriptVerifyDiscourageUpgradableTaprootVersion != 0 {
+			return errDiscourageUpgradableTaprootVersion
+		}
+		return nil
+
+	case version == 1 && bytes.Equal(program, []byte{0x4e, 0x73}) && !isP2SH:
+		/* Pay to anchor */
+		return nil
+	}
+
+	/* Other versions are left for future soft forks */
+	if e.flags&ScriptVerifyDiscourageUpgradableWitnessProgram != 0 {
+		return errDiscourageUpgradableWitnessProgram
+	}
+	return nil
+}
+
+/* witnessSize returns the serialized size of a witness stack */
+func witnessSize(witness [][]byte) int {
+	size := len(compactSize(uint64(len(witness))))
+	for _, item := range witness {
+		size += len(compactSize(uint64(len(item)))) + len(item)
+	}
+	return size
+}
+
+/* verifyTaprootCommitment checks that the output key is the internal key of the control block tweaked with the merkle root of the leaf */
+func verifyTaprootCommitment(control []byte, program []byte, tapLeafHash []byte) bool {
+	merkleRoot := tapLeafHash
+	for i := 33; i < len(control); i += 32 {
+		merkleRoot = TapBranchHash(merkleRoot, control[i:i+32])
+	}
+
+	internalKey := &PublicKey{X: new(big.Int).SetBytes(control[1:33])}
+	Q, err := internalKey.TaprootTweak(merkleRoot)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(paddedBytes(Q.X, 32), program) && Q.Y.Bit(0) == uint(control[0]&1)
+}
+
+/* executeWitnessScript runs a witness script, which must leave exactly one true element on the stack */
+func (e *scriptEngine) executeWitnessScript(witnessStack [][]byte, script []byte, version sigVersion) error {
+	if version == sigVersionTapscript {
+		/* OP_SUCCESSx opcodes make the script succeed before anything else is checked */
+		pc := 0
+		for pc < len(script) {
+			opcode, _, next, ok := getScriptOp(script, pc)
+			if !ok {
+				return errBadOpcode
+			}
+			if isOpSuccess(opcode) {
+				if e.flags&ScriptVerifyDiscourageOpSuccess != 0 {
+					return errDiscourageOpSuccess
+				}
+				return nil
+			}
+			pc = next
+		}
+
+		if len(witnessStack) > MaxStackSize {
+			return errStackSize
+		}
+	}
+
+	for _, item := range witnessStack {
+		if len(item) > MaxScriptElementSize {
+			return errPushSize
+		}
+	}
+
+	/* Copy the stack so that the witness of the transaction is left untouched */
+	stack, err := e.evalScript(append([][]byte{}, witnessStack...), script, version)
+	if err != nil {
+		return err
+	}
+
+	if len(stack) != 1 {
+		return errCleanStack
+	}
+	if !castToBool(stack[0]) {
+		return errEvalFalse
+	}
+	return nil
+}
+
+/* castToBool returns false for empty values, zeros and negative zero */
+func castToBool(b []byte) bool {
+	for i := range b {
+		if b[i] != 0 {
+			/* Negative zero */
+			if i == len(b)-1 && b[i] == 0x80 {
+				return false
+			}
+			return true
+		}
+	}
+	return false
+}
+
+/* isDisabledOpcode returns true for the opcodes disabled since CVE-2010-5137 */
+func isDisabledOpcode(opcode byte) bool {
+	switch opcode {
+	case OpCat, OpSubStr, OpLeft, OpRight, OpInvert, OpAnd, OpOr, OpXor,
+		Op2Mul, Op2Div, OpMul, OpDiv, OpMod, OpLShift, OpRShift:
+		return true
+	}
+	return false
+}
+
+/* conditionStack tracks nested OP_IF branches, only the number of branches and the position of the first false one matter */
+type conditionStack struct {
+	size          int
+	firstFalsePos int
+}
+
+func newConditionStack() *conditionStack {
+	return &conditionStack{firstFalsePos: -1}
+}
+
+func (c *conditionStack) empty() bool {
+	return c.size == 0
+}
+
+func (c *conditionStack) allTrue() bool {
+	return c.firstFalsePos == -1
+}
+
+func (c *conditionStack) push(value bool) {
+	if c.firstFalsePos == -1 && !value {
+		c.firstFalsePos = c.size
+	}
+	c.size++
+}
+
+func (c *conditionStack) pop() {
+	c.size--
+	if c.firstFalsePos == c.size {
+		c.firstFalsePos = -1
+	}
+}
+
+func (c *conditionStack) toggleTop() {
+	if c.firstFalsePos == -1 {
+		c.firstFalsePos = c.size - 1
+	} else if c.firstFalsePos == c.size-1 {
+		c.firstFalsePos = -1
+	}
+}
+
+/* evalScript is Bitcoin Core's EvalScript, it returns the stack left by the script */
+func (e *scriptEngine) evalScript(stack [][]byte, script []byte, version sigVersion) ([][]byte, error) {
+	if version != sigVersionTapscript && len(script) > MaxScriptSize {
+		return stack, errScriptSize
+	}
+
+	requireMinimal := e.flags&ScriptVerifyMinimalData != 0
+	conditions := newConditionStack()
+	var altStack [][]byte
+	opCount := 0
+	codeHashBegin := 0
+	e.codeSeparatorPos = 0xffffffff
+
+	/* top returns the element at position i from the top of the stack, -1 being the top */
+	top := func(i int) []byte {
+		return stack[len(stack)+i]
+	}
+	pop := func() []byte {
+		item := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		return item
+	}
+	num := func(i int, maxSize int) (scriptNum, error) {
+		n, err := parseScriptNum(top(i), requireMinimal, maxSize)
+		if err != nil {
+			return 0, errScriptNum
+		}
+		return n, nil
+	}
+
+	pc := 0
+	for opcodePos := uint32(0); pc < len(script); opcodePos++ {
+		exec := conditions.allTrue()
+
+		opcode, data, next, ok := getScriptOp(script, pc)
+		pc = next
+		if !ok {
+			return stack, errBadOpcode
+		}
+		if len(data) > MaxScriptElementSize {
+			return stack, errPushSize
+		}
+
+		/* OP_RESERVED does not count towards the opcode limit */
+		if version != sigVersionTapscript && opcode > Op16 {
+			opCount++
+			if opCount > MaxOpsPerScript {
+				return stack, errOpCount
+			}
+		}
+
+		if isDisabledOpcode(opcode) {
+			return stack, errDisabledOpcode
+		}
+
+		/* Checked even in unexecuted branches */
+		if opcode == OpCodeSeparator && version == sigVersionBase && e.flags&ScriptVerifyConstScriptCode != 0 {
+			return stack, errOpCodeSeparator
+		}
+
+		if exec && opcode <= OpPushData4 {
+			if requireMinimal && !checkMinimalPush(data, opcode) {
+				return stack, errMinimalData
+			}
+			stack = append(stack, data)
+		} else if exec || (opcode >= OpIf && opcode <= OpEndIf) {
+			switch opcode {
+			case Op1Negate, Op1, Op2, Op3, Op4, Op5, Op6, Op7, Op8, Op9, Op10, Op11, Op12, Op13, Op14, Op15, Op16:
+				stack = append(stack, scriptNum(int(opcode)-int(Op1-1)).bytes())
+
+			case OpNop:
+
+			case OpCheckLockTimeVerify:
+				/* NOP2 when not enabled */
+				if e.flags&ScriptVerifyCheckLockTimeVerify == 0 {
+					break
+				}
+				if len(stack) < 1 {
+					return stack, errInvalidStackOperation
+				}
+				/* 5 bytes numbers to go beyond the year 2038 */
+				lockTime, err := num(-1, 5)
+				if err != nil {
+					return stack, err
+				}
+				if lockTime < 0 {
+					return stack, errNegativeLockTime
+				}
+				if !e.checkLockTime(int64(lockTime)) {
+					return stack, errUnsatisfiedLockTime
+				}
+
+			case OpCheckSequenceVerify:
+				/* NOP3 when not enabled */
+				if e.flags&ScriptVerifyCheckSequenceVerify == 0 {
+					break
+				}
+				if len(stack) < 1 {
+					return stack, errInvalidStackOperation
+				}
+				sequence, err := num(-1, 5)
+				if err != nil {
+					return stack, err
+				}
+				if sequence < 0 {
+					return stack, errNegativeLockTime
+				}
+				/* Sequences with the disable flag are left for future soft forks */
+				if sequence&sequenceLockTimeDisableFlag != 0 {
+					break
+				}
+				if !e.checkSequence(int64(sequence)) {
+					return stack, errUnsatisfiedLockTime
+				}
+
+			case OpNop1, OpNop4, OpNop5, OpNop6, OpNop7, OpNop8, OpNop9, OpNop10:
+				if e.flags&ScriptVerifyDiscourageUpgradableNops != 0 {
+					return stack, errDiscourageUpgradableNops
+				}
+
+			case OpIf, OpNotIf:
+				value := false
+				if exec {
+					if len(stack) < 1 {
+						return stack, errInvalidStackOperation
+					}
+					condition := top(-1)
+					/* Consensus rule in tapscript, policy in witness v0 scripts */
+					if version == sigVersionTapscript && (len(condition) > 1 || (len(condition) == 1 && condition[0] != 1)) {
+						return stack, errTapscriptMinimalIf
+					}
+					if version == sigVersionWitnessV0 && e.flags&ScriptVerifyMinimalIf != 0 &&
+						(len(condition) > 1 || (len(condition) == 1 && condition[0] != 1)) {
+						return stack, errMinimalIf
+					}
+					value = castToBool(condition)
+					if opcode == OpNotIf {
+						value = !value
+					}
+					pop()
+				}
+				conditions.push(value)
+
+			case OpElse:
+				if conditions.empty() {
+					return stack, errUnbalancedConditional
+				}
+				conditions.toggleTop()
+
+			case OpEndIf:
+				if conditions.empty() {
+					return stack, errUnbalancedConditional
+				}
+				conditions.pop()
+
+			case OpVerify:
+				if len(stack) < 1 {
+					return stack, errInvalidStackOperation
+				}
+				if !castToBool(top(-1)) {
+					return stack, errVerify
+				}
+				pop()
+
+			case OpReturn:
+				return stack, errOpReturn
+
+			case OpToAltStack:
+				if len(stack) < 1 {
+					return stack, errInvalidStackOperation
+				}
+				altStack = append(altStack, pop())
+
+			case OpFromAltStack:
+				if len(altStack) < 1 {
+					return stack, errInvalidAltStackOperation
+				}
+				stack = append(stack, altStack[len(altStack)-1])
+				altStack = altStack[:len(altStack)-1]
+
+			case Op2Drop:
+				if len(stack) < 2 {
+					return stack, errInvalidStackOperation
+				}
+				stack = stack[:len(stack)-2]
+
+			case Op2Dup:
+				if len(stack) < 2 {
+					return stack, errInvalidStackOperation
+				}
+				stack = append(stack, top(-2), top(-1))
+
+			case Op3Dup:
+				if len(stack) < 3 {
+					return stack, errInvalidStackOperation
+				}
+				stack = append(stack, top(-3), top(-2), top(-1))
+
+			case Op2Over:
+				if len(stack) < 4 {
+					return stack, errInvalidStackOperation
+				}
+				stack = append(stack, top(-4), top(-3))
+
+			case Op2Rot:
+				if len(stack) < 6 {
+					return stack, errInvalidStackOperation
+				}
+				x1, x2 := top(-6), top(-5)
+				stack = append(stack[:len(stack)-6], stack[len(stack)-4:]...)
+				stack = append(stack, x1, x2)
+
+			case Op2Swap:
+				if len(stack) < 4 {
+					return stack, errInvalidStackOperation
+				}
+				n := len(stack)
+				stack[n-4], stack[n-2] = stack[n-2], stack[n-4]
+				stack[n-3], stack[n-1] = stack[n-1], stack[n-3]
+
+			case OpIfDup:
+				if len(stack) < 1 {
+					return stack, errInvalidStackOperation
+				}
+				if castToBool(top(-1)) {
+					stack = append(stack, top(-1))
+				}
+
+			case OpDepth:
+				stack = append(stack, scriptNum(len(stack)).bytes())
+
+			case OpDrop:
+				if len(stack) < 1 {
+					return stack, errInvalidStackOperation
+				}
+				pop()
+
+			case OpDup:
+				if len(stack) < 1 {
+					return stack, errInvalidStackOperation
+				}
+				stack = append(stack, top(-1))
+
+			case OpNip:
+				if len(stack) < 2 {
+					return stack, errInvalidStackOperation
+				}
+				x2 := pop()
+				stack[len(stack)-1] = x2
+
+			case OpOver:
+				if len(stack) < 2 {
+					return stack, errInvalidStackOperation
+				}
+				stack = append(stack, top(-2))
+
+			case OpPick, OpRoll:
+				if len(stack) < 2 {
+					return stack, errInvalidStackOperation
+				}
+				n, err := num(-1, 4)
+				if err != nil {
+					return stack, err
+				}
+				pop()
+				depth := n.int32()
+				if depth < 0 || depth >= len(stack) {
+					return stack, errInvalidStackOperation
+				}
+				item := top(-depth - 1)
+				if opcode == OpRoll {
+					i := len(stack) - depth - 1
+					stack = append(stack[:i], stack[i+1:]...)
+				}
+				stack = append(stack, item)
+
+			case OpRot:
+				if len(stack) < 3 {
+					return stack, errInvalidStackOperation
+				}
+				n := len(stack)
+				stack[n-3], stack[n-2], stack[n-1] = stack[n-2], stack[n-1], stack[n-3]
+
+			case OpSwap:
+				if len(stack) < 2 {
+					return stack, errInvalidStackOperation
+				}
+				n := len(stack)
+				stack[n-2], stack[n-1] = stack[n-1], stack[n-2]
+
+			case OpTuck:
+				if len(stack) < 2 {
+					return stack, errInvalidStackOperation
+				}
+				n := len(stack)
+				x1, x2 := stack[n-2], stack[n-1]
+				stack = append(stack[:n-2], x2, x1, x2)
+
+			case OpSize:
+				if len(stack) < 1 {
+					return stack, errInvalidStackOperation
+				}
+				stack = append(stack, scriptNum(len(top(-1))).bytes())
+
+			case OpEqual, OpEqualVerify:
+				if len(stack) < 2 {
+					return stack, errInvalidStackOperation
+				}
+				equal := bytes.Equal(top(-2), top(-1))
+				stack = append(stack[:len(stack)-2], boolBytes(equal))
+				if opcode == OpEqualVerify {
+					if !equal {
+						return stack, errEqualVerify
+					}
+					pop()
+				}
+
+			case Op1Add, Op1Sub, OpNegate, OpAbs, OpNot, Op0NotEqual:
+				if len(stack) < 1 {
+					return stack, errInvalidStackOperation
+				}
+				n, err := num(-1, 4)
+				if err != nil {
+					return stack, err
+				}
+				switch opcode {
+				case Op1Add:
+					n++
+				case Op1Sub:
+					n--
+				case OpNegate:
+					n = -n
+				case OpAbs:
+					if n < 0 {
+						n = -n
+					}
+				case OpNot:
+					n = boolNum(n == 0)
+				case Op0NotEqual:
+					n = boolNum(n != 0)
+				}
+				pop()
+				stack = append(stack, n.bytes())
+
+			case OpAdd, OpSub, OpBoolAnd, OpBoolOr, OpNumEqual, OpNumEqualVerify, OpNumNotEqual,
+				OpLessThan, OpGreaterThan, OpLessThanOrEqual, OpGreaterThanOrEqual, OpMin, OpMax:
+				if len(stack) < 2 {
+					return stack, errInvalidStackOperation
+				}
+				a, err := num(-2, 4)
+				if err != nil {
+					return stack, err
+				}
+				b, err := num(-1, 4)
+				if err != nil {
+					return stack, err
+				}
+
+				var n scriptNum
+				switch opcode {
+				case OpAdd:
+					n = a + b
+				case OpSub:
+					n = a - b
+				case OpBoolAnd:
+					n = boolNum(a != 0 && b != 0)
+				case OpBoolOr:
+					n = boolNum(a != 0 || b != 0)
+				case OpNumEqual, OpNumEqualVerify:
+					n = boolNum(a == b)
+				case OpNumNotEqual:
+					n = boolNum(a != b)
+				case OpLessThan:
+					n = boolNum(a < b)
+				case OpGreaterThan:
+					n = boolNum(a > b)
+				case OpLessThanOrEqual:
+					n = boolNum(a <= b)
+				case OpGreaterThanOrEqual:
+					n = boolNum(a >= b)
+				case OpMin:
+					n = a
+					if b < a {
+						n = b
+					}
+				case OpMax:
+					n = a
+					if b > a {
+						n = b
+					}
+				}
+				stack = append(stack[:len(stack)-2], n.bytes())
+
+				if opcode == OpNumEqualVerify {
+					if !castToBool(top(-1)) {
+						return stack, errNumEqualVerify
+					}
+					pop()
+				}
+
+			case OpWithin:
+				if len(stack) < 3 {
+					return stack, errInvalidStackOperation
+				}
+				x, err := num(-3, 4)
+				if err != nil {
+					return stack, err
+				}
+				min, err := num(-2, 4)
+				if err != nil {
+					return stack, err
+				}
+				max, err := num(-1, 4)
+				if err != nil {
+					return stack, err
+				}
+				stack = append(stack[:len(stack)-3], boolBytes(min <= x && x < max))
+
+			case OpRipemd160, OpSha1, OpSha256, OpHash160, OpHash256:
+				if len(stack) < 1 {
+					return stack, errInvalidStackOperation
+				}
+				item := pop()
+				var hash []byte
+				switch opcode {
+				case OpRipemd160:
+					h := ripemd160.New()
+					h.Write(item)
+					hash = h.Sum(nil)
+				case OpSha1:
+					h := sha1.Sum(item)
+					hash = h[:]
+				case OpSha256:
+					h := sha256.Sum256(item)
+					hash = h[:]
+				case OpHash160:
+					hash = hash160(item)
+				case OpHash256:
+					hash = doubleHash(item)
+				}
+				stack = append(stack, hash)
+
+			case OpCodeSeparator:
+				/* Signatures only commit to the script after the last executed OP_CODESEPARATOR */
+				codeHashBegin = pc
+				e.codeSeparatorPos = opcodePos
+
+			case OpCheckSig, OpCheckSigVerify:
+				if len(stack) < 2 {
+					return stack, errInvalidStackOperation
+				}
+				success, err := e.evalCheckSig(top(-2), top(-1), script[codeHashBegin:], version)
+				if err != nil {
+					return stack, err
+				}
+				stack = append(stack[:len(stack)-2], boolBytes(success))
+				if opcode == OpCheckSigVerify {
+					if !success {
+						return stack, errCheckSigVerify
+					}
+					pop()
+				}
+
+			case OpCheckSigAdd:
+				/* Only available in tapscript */
+				if version != sigVersionTapscript {
+					return stack, errBadOpcode
+				}
+				if len(stack) < 3 {
+					return stack, errInvalidStackOperation
+				}
+				n, err := num(-2, 4)
+				if err != nil {
+					return stack, err
+				}
+				success, err := e.evalCheckSig(top(-3), top(-1), nil, version)
+				if err != nil {
+					return stack, err
+				}
+				if success {
+					n++
+				}
+				stack = append(stack[:len(stack)-3], n.bytes())
+
+			case OpCheckMultiSig, OpCheckMultiSigVerify:
+				if version == sigVersionTapscript {
+					return stack, errTapscriptCheckMultiSig
+				}
+				var err error
+				stack, err = e.evalCheckMultiSig(stack, script[codeHashBegin:], version, &opCount)
+				if err != nil {
+					return stack, err
+				}
+				if opcode == OpCheckMultiSigVerify {
+					if !castToBool(top(-1)) {
+						return stack, errCheckMultiSigVerify
+					}
+					pop()
+				}
+
+			default:
+				return stack, errBadOpcode
+			}
+		}
+
+		if len(stack)+len(altStack) > MaxStackSize {
+			return stack, errStackSize
+		}
+	}
+
+	if !conditions.empty() {
+		return stack, errUnbalancedConditional
+	}
+	return stack, nil
+}
+
+/* boolBytes returns the stack value of a boolean */
+func boolBytes(b bool) []byte {
+	if b {
+		return []byte{1}
+	}
+	return []byte{}
+}
+
+func boolNum(b bool) scriptNum {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+/* evalCheckMultiSig executes OP_CHECKMULTISIG: [sigs...] nSigs [pubkeys...] nPubKeys -- bool */
+func (e *scriptEngine) evalCheckMultiSig(stack [][]byte, scriptCode []byte, version sigVersion, opCount *int) ([][]byte, error) {
+	requireMinimal := e.flags&ScriptVerifyMinimalData != 0
+	top := func(i int) []byte {
+		return stack[len(stack)+i]
+	}
+
+	i := 1
+	if len(stack) < i {
+		return stack, errInvalidStackOperation
+	}
+
+	n, err := parseScriptNum(top(-i), requireMinimal, 4)
+	if err != nil {
+		return stack, errScriptNum
+	}
+	keysCount := n.int32()
+	if keysCount < 0 || keysCount > MaxPubKeysPerMultiSig {
+		return stack, errPubKeyCount
+	}
+	*opCount += keysCount
+	if *opCount > MaxOpsPerScript {
+		return stack, errOpCount
+	}
+	i++
+	iKey := i
+	/* Position of the last non-signature item, used to clean up the stack with NULLFAIL */
+	iKey2 := keysCount + 2
+	i += keysCount
+	if len(stack) < i {
+		return stack, errInvalidStackOperation
+	}
+
+	n, err = parseScriptNum(top(-i), requireMinimal, 4)
+	if err != nil {
+		return stack, errScriptNum
+	}
+	sigsCount := n.int32()
+	if sigsCount < 0 || sigsCount > keysCount {
+		return stack, errSigCount
+	}
+	i++
+	iSig := i
+	i += sigsCount
+	if len(stack) < i {
+		return stack, errInvalidStackOperation
+	}
+
+	/* Signatures are removed from pre-segwit script codes */
+	for k := 0; k < sigsCount; k++ {
+		if version == sigVersionBase {
+			var found int
+			scriptCode, found = findAndDelete(scriptCode, pushData(top(-iSig-k)))
+			if found > 0 && e.flags&ScriptVerifyConstScriptCode != 0 {
+				return stack, errSigFindAndDelete
+			}
+		}
+	}
+
+	success := true
+	for success && sigsCount > 0 {
+		signature := top(-iSig)
+		pubKey := top(-iKey)
+
+		/* The order of the encoding checks is observable with STRICTENC */
+		err = e.checkSignatureEncoding(signature)
+		if err != nil {
+			return stack, err
+		}
+		err = e.checkPubKeyEncoding(pubKey, version)
+		if err != nil {
+			return stack, err
+		}
+
+		if e.checkECDSASignature(signature, pubKey, scriptCode, version) {
+			iSig++
+			sigsCount--
+		}
+		iKey++
+		keysCount--
+
+		/* Fail early when there are more signatures left than keys */
+		if sigsCount > keysCount {
+			success = false
+		}
+	}
+
+	/* Remove the arguments, with NULLFAIL all signatures must be empty if the operation failed */
+	for ; i > 1; i-- {
+		if !success && e.flags&ScriptVerifyNullFail != 0 && iKey2 == 0 && len(top(-1)) > 0 {
+			return stack, errSigNullFail
+		}
+		if iKey2 > 0 {
+			iKey2--
+		}
+		stack = stack[:len(stack)-1]
+	}
+
+	/* A bug consumes one more element, it must be empty with NULLDUMMY */
+	if len(stack) < 1 {
+		return stack, errInvalidStackOperation
+	}
+	if e.flags&ScriptVerifyNullDummy != 0 && len(top(-1)) > 0 {
+		return stack, errSigNullDummy
+	}
+	stack[len(stack)-1] = boolBytes(success)
+	return stack, nil
+}
+
+/* evalCheckSig checks one signature, a false result without error lets the script continue */
+func (e *scriptEngine) evalCheckSig(signature []byte, pubKey []byte, scriptCode []byte, version sigVersion) (bool, error) {
+	if version == sigVersionTapscript {
+		return e.evalCheckSigTapscript(signature, pubKey)
+	}
+
+	/* Signatures are removed from pre-segwit script codes */
+	if version == sigVersionBase {
+		var found int
+		scriptCode, found = findAndDelete(scriptCode, pushData(signature))
+		if found > 0 && e.flags&ScriptVerifyConstScriptCode != 0 {
+			return false, errSigFindAndDelete
+		}
+	}
+
+	err := e.checkSignatureEncoding(signature)
+	if err != nil {
+		return false, err
+	}
+	err = e.checkPubKeyEncoding(pubKey, version)
+	if err != nil {
+		return false, err
+	}
+
+	success := e.checkECDSASignature(signature, pubKey, scriptCode, version)
+	if !success && e.flags&ScriptVerifyNullFail != 0 && len(signature) > 0 {
+		return false, errSigNullFail
+	}
+	return success, nil
+}
+
+/* evalCheckSigTapscript checks one signature in tapscript (BIP342) */
+func (e *scriptEngine) evalCheckSigTapscript(signature []byte, pubKey []byte) (bool, error) {
+	/* An empty signature is a failed check, other signatures use the validation budget */
+	success := len(signature) > 0
+	if success {
+		e.validationWeightLeft -= validationWeightPerSigOp
+		if e.validationWeightLeft < 0 {
+			return false, errTapscriptValidationWeight
+		}
+	}
+
+	switch len(pubKey) {
+	case 0:
+		return false, errTapscriptEmptyPubKey
+	case 32:
+		if success {
+			err := e.checkSchnorrSignature(signature, pubKey, sigVersionTapscript)
+			if err != nil {
+				return false, err
+			}
+		}
+	default:
+		/* Unknown public key types are left for future soft forks */
+		if e.flags&ScriptVerifyDiscourageUpgradablePubKeyType != 0 {
+			return false, errDiscourageUpgradablePubKeyType
+		}
+	}
+	return success, nil
+}
+
+/* checkSignatureEncoding applies the DERSIG, LOW_S and STRICTENC rules to a signature followed by its hash type */
+func (e *scriptEngine) checkSignatureEncoding(signature []byte) error {
+	/* Empty signatures are a compact way to provide an invalid signature */
+	if len(signature) == 0 {
+		return nil
+	}
+
+	if e.flags&(ScriptVerifyDERSignatures|ScriptVerifyLowS|ScriptVerifyStrictEncoding) != 0 &&
+		checkDER(signature[:len(signature)-1]) != nil {
+		return errSigDER
+	}
+	if e.flags&ScriptVerifyLowS != 0 {
+		s, ok := signatureFromDERLax(signature[:len(signature)-1])
+		if !ok || !s.IsLowS() {
+			return errSigHighS
+		}
+	}
+	if e.flags&ScriptVerifyStrictEncoding != 0 {
+		hashType := SigHashType(signature[len(signature)-1]) &^ SigHashAnyoneCanPay
+		if hashType < SigHashAll || hashType > SigHashSingle {
+			return errSigHashType
+		}
+	}
+	return nil
+}
+
+/* checkPubKeyEncoding applies the STRICTENC and WITNESS_PUBKEYTYPE rules to a public key */
+func (e *scriptEngine) checkPubKeyEncoding(pubKey []byte, version sigVersion) error {
+	compressed := len(pubKey) == 33 && (pubKey[0] == 0x02 || pubKey[0] == 0x03)
+	uncompressed := len(pubKey) == 65 && pubKey[0] == 0x04
+
+	if e.flags&ScriptVerifyStrictEncoding != 0 && !compressed && !uncompressed {
+		return errPubKeyType
+	}
+	/* Only compressed keys are accepted in segwit */
+	if e.flags&ScriptVerifyWitnessPubKeyType != 0 && version == sigVersionWitnessV0 && !compressed {
+		return errWitnessPubKeyType
+	}
+	return nil
+}
+
+/* checkECDSASignature verifies a signature followed by its hash type, signatures are not required to be strict DER nor low S */
+func (e *scriptEngine) checkECDSASignature(signature []byte, pubKey []byte, scriptCode []byte, version sigVersion) bool {
+	publicKey, err := publicFromBytes(pubKey, nil)
+	if err != nil || len(signature) == 0 {
+		return false
+	}
+	hashType := SigHashType(signature[len(signature)-1])
+
+	var hash []byte
+	if version == sigVersionWitnessV0 {
+		hash, err = e.tx.WitnessV0SigHash(e.index, scriptCode, e.prevOuts[e.index].Value, hashType)
+	} else {
+		hash, err = e.tx.LegacySigHash(e.index, scriptCode, hashType)
+	}
+	if err != nil {
+		return false
+	}
+
+	s, ok := signatureFromDERLax(signature[:len(signature)-1])
+	if !ok {
+		return false
+	}
+	s.normalize()
+	return publicKey.Verify(hash, s)
+}
+
+/* checkSchnorrSignature verifies a BIP340 signature, optionally followed by its hash type, of a taproot spend */
+func (e *scriptEngine) checkSchnorrSignature(signature []byte, pubKey []byte, version sigVersion) error {
+	if len(signature) != 64 && len(signature) != 65 {
+		return errSchnorrSigSize
+	}
+
+	hashType := SigHashDefault
+	if len(signature) == 65 {
+		hashType = SigHashType(signature[64])
+		signature = signature[:64]
+		/* SIGHASH_DEFAULT must be implicit */
+		if hashType == SigHashDefault {
+			return errSchnorrSigHashType
+		}
+	}
+
+	var leafHash []byte
+	codeSeparatorPos := uint32(0xffffffff)
+	if version == sigVersionTapscript {
+		leafHash = e.tapLeafHash
+		codeSeparatorPos = e.codeSeparatorPos
+	}
+	hash, err := e.tx.taprootSigHash(e.index, e.prevOuts, hashType, leafHash, codeSeparatorPos)
+	if err != nil {
+		return errSchnorrSigHashType
+	}
+
+	publicKey := &PublicKey{X: new(big.Int).SetBytes(pubKey)}
+	if !publicKey.VerifySchnorr(hash, signature) {
+		return errSchnorrSig
+	}
+	return nil
+}
+
+/* checkLockTime compares the operand of OP_CHECKLOCKTIMEVERIFY with the lock time of the transaction (BIP65) */
+func (e *scriptEngine) checkLockTime(lockTime int64) bool {
+	txLockTime := int64(e.tx.LockTime)
+
+	/* Both must be block heights or both timestamps */
+	if (txLockTime < LockTimeThreshold) != (lockTime < LockTimeThreshold) {
+		return false
+	}
+	if lockTime > txLockTime {
+		return false
+	}
+
+	/* The lock time is ignored when the input is final */
+	return e.tx.Inputs[e.index].Sequence != 0xffffffff
+}
+
+/* checkSequence compares the operand of OP_CHECKSEQUENCEVERIFY with the sequence of the input (BIP112) */
+func (e *scriptEngine) checkSequence(sequence int64) bool {
+	txSequence := int64(e.tx.Inputs[e.index].Sequence)
+
+	/* Relative lock times only apply from version 2, the version is compared as unsigned */
+	if uint32(e.tx.Version) < 2 {
+		return false
+	}
+	if txSequence&sequenceLockTimeDisableFlag != 0 {
+		return false
+	}
+
+	mask := int64(sequenceLockTimeTypeFlag | sequenceLockTimeMask)
+	txSequence &= mask
+	sequence &= mask
+
+	/* Both must be block counts or both time intervals */
+	if (txSequence < sequenceLockTimeTypeFlag) != (sequence < sequenceLockTimeTypeFlag) {
+		return false
+	}
+	return sequence <= txSequence
+}
@@ -0,0 +1,466 @@
+package btc
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/* Names used by Bitcoin Core's test vectors */
+var scriptFlagNames = map[string]ScriptFlags{
+	"P2SH":                                  ScriptVerifyP2SH,
+	"STRICTENC":                             ScriptVerifyStrictEncoding,
+	"DERSIG":                                ScriptVerifyDERSignatures,
+	"LOW_S":                                 ScriptVerifyLowS,
+	"SIGPUSHONLY":                           ScriptVerifySigPushOnly,
+	"MINIMALDATA":                           ScriptVerifyMinimalData,
+	"NULLDUMMY":                             ScriptVerifyNullDummy,
+	"DISCOURAGE_UPGRADABLE_NOPS":            ScriptVerifyDiscourageUpgradableNops,
+	"CLEANSTACK":                            ScriptVerifyCleanStack,
+	"MINIMALIF":                             ScriptVerifyMinimalIf,
+	"NULLFAIL":                              ScriptVerifyNullFail,
+	"CHECKLOCKTIMEVERIFY":                   ScriptVerifyCheckLockTimeVerify,
+	"CHECKSEQUENCEVERIFY":                   ScriptVerifyCheckSequenceVerify,
+	"WITNESS":                               ScriptVerifyWitness,
+	"DISCOURAGE_UPGRADABLE_WITNESS_PROGRAM": ScriptVerifyDiscourageUpgradableWitnessProgram,
+	"WITNESS_PUBKEYTYPE":                    ScriptVerifyWitnessPubKeyType,
+	"CONST_SCRIPTCODE":                      ScriptVerifyConstScriptCode,
+	"TAPROOT":                               ScriptVerifyTaproot,
+	"DISCOURAGE_UPGRADABLE_PUBKEYTYPE":      ScriptVerifyDiscourageUpgradablePubKeyType,
+	"DISCOURAGE_OP_SUCCESS":                 ScriptVerifyDiscourageOpSuccess,
+	"DISCOURAGE_UPGRADABLE_TAPROOT_VERSION": ScriptVerifyDiscourageUpgradableTaprootVersion,
+}
+
+var scriptErrorNames = map[string]error{
+	"OK":                                    nil,
+	"EVAL_FALSE":                            errEvalFalse,
+	"OP_RETURN":                             errOpReturn,
+	"SCRIPT_SIZE":                           errScriptSize,
+	"PUSH_SIZE":                             errPushSize,
+	"OP_COUNT":                              errOpCount,
+	"STACK_SIZE":                            errStackSize,
+	"SIG_COUNT":                             errSigCount,
+	"PUBKEY_COUNT":                          errPubKeyCount,
+	"VERIFY":                                errVerify,
+	"EQUALVERIFY":                           errEqualVerify,
+	"CHECKMULTISIGVERIFY":                   errCheckMultiSigVerify,
+	"CHECKSIGVERIFY":                        errCheckSigVerify,
+	"NUMEQUALVERIFY":                        errNumEqualVerify,
+	"BAD_OPCODE":                            errBadOpcode,
+	"DISABLED_OPCODE":                       errDisabledOpcode,
+	"INVALID_STACK_OPERATION":               errInvalidStackOperation,
+	"INVALID_ALTSTACK_OPERATION":            errInvalidAltStackOperation,
+	"UNBALANCED_CONDITIONAL":                errUnbalancedConditional,
+	"NEGATIVE_LOCKTIME":                     errNegativeLockTime,
+	"UNSATISFIED_LOCKTIME":                  errUnsatisfiedLockTime,
+	"SIG_HASHTYPE":                          errSigHashType,
+	"SIG_DER":                               errSigDER,
+	"MINIMALDATA":                           errMinimalData,
+	"SIG_PUSHONLY":                          errSigPushOnly,
+	"SIG_HIGH_S":                            errSigHighS,
+	"SIG_NULLDUMMY":                         errSigNullDummy,
+	"PUBKEYTYPE":                            errPubKeyType,
+	"CLEANSTACK":                            errCleanStack,
+	"MINIMALIF":                             errMinimalIf,
+	"NULLFAIL":                              errSigNullFail,
+	"DISCOURAGE_UPGRADABLE_NOPS":            errDiscourageUpgradableNops,
+	"DISCOURAGE_UPGRADABLE_WITNESS_PROGRAM": errDiscourageUpgradableWitnessProgram,
+	"WITNESS_PROGRAM_WRONG_LENGTH":          errWitnessProgramWrongLength,
+	"WITNESS_PROGRAM_WITNESS_EMPTY":         errWitnessProgramWitnessEmpty,
+	"WITNESS_PROGRAM_MISMATCH":              errWitnessProgramMismatch,
+	"WITNESS_MALLEATED":                     errWitnessMalleated,
+	"WITNESS_MALLEATED_P2SH":                errWitnessMalleatedP2SH,
+	"WITNESS_UNEXPECTED":                    errWitnessUnexpected,
+	"WITNESS_PUBKEYTYPE":                    errWitnessPubKeyType,
+	"TAPSCRIPT_EMPTY_PUBKEY":                errTapscriptEmptyPubKey,
+	"OP_CODESEPARATOR":                      errOpCodeSeparator,
+	"SIG_FINDANDDELETE":                     errSigFindAndDelete,
+	"SCRIPTNUM":                             errScriptNum,
+}
+
+/* parseCoreScript parses the script notation of Bitcoin Core's test vectors */
+func parseCoreScript(s string) ([]byte, error) {
+	var script []byte
+	for _, word := range strings.Fields(s) {
+		n, err := strconv.ParseInt(word, 10, 64)
+		switch {
+		case err == nil && strings.TrimLeft(word, "-0123456789") == "":
+			if n < -0xffffffff || n > 0xffffffff {
+				return nil, errors.New("number out of range")
+			}
+			switch {
+			case n == 0:
+				script = append(script, Op0)
+			case n == -1 || (n >= 1 && n <= 16):
+				script = append(script, byte(n+int64(Op1-1)))
+			default:
+				script = append(script, pushData(scriptNum(n).bytes())...)
+			}
+
+		case strings.HasPrefix(word, "0x") && len(word) > 2:
+			b, err := hex.DecodeString(word[2:])
+			if err != nil {
+				return nil, err
+			}
+			script = append(script, b...)
+
+		case len(word) >= 2 && word[0] == '\'' && word[len(word)-1] == '\'':
+			script = append(script, pushData([]byte(word[1:len(word)-1]))...)
+
+		default:
+			opcode, ok := OpcodeFromName(word)
+			if !ok {
+				return nil, errors.New("unknown opcode " + word)
+			}
+			script = append(script, opcode)
+		}
+	}
+	return script, nil
+}
+
+func parseCoreFlags(s string) ScriptFlags {
+	var flags ScriptFlags
+	if s == "" || s == "NONE" {
+		return flags
+	}
+	for _, name := range strings.Split(s, ",") {
+		flags |= scriptFlagNames[name]
+	}
+	return flags
+}
+
+/* validFlags returns false for the combinations rejected by VerifyInput */
+func validFlags(flags ScriptFlags) bool {
+	if flags&ScriptVerifyWitness != 0 && flags&ScriptVerifyP2SH == 0 {
+		return false
+	}
+	return flags&ScriptVerifyCleanStack == 0 || flags&ScriptVerifyWitness != 0
+}
+
+/* trimFlags removes the flags whose requirements are not set */
+func trimFlags(flags ScriptFlags) ScriptFlags {
+	if flags&ScriptVerifyP2SH == 0 {
+		flags &^= ScriptVerifyWitness
+	}
+	if flags&ScriptVerifyWitness == 0 {
+		flags &^= ScriptVerifyCleanStack
+	}
+	return flags
+}
+
+/* fillFlags adds the flags required by the ones set */
+func fillFlags(flags ScriptFlags) ScriptFlags {
+	if flags&ScriptVerifyCleanStack != 0 {
+		flags |= ScriptVerifyWitness
+	}
+	if flags&ScriptVerifyWitness != 0 {
+		flags |= ScriptVerifyP2SH
+	}
+	return flags
+}
+
+/* scriptTestTransaction builds the spending transaction of Bitcoin Core's script tests */
+func scriptTestTransaction(scriptSig []byte, scriptPubKey []byte, witness [][]byte, amount int64) (*Transaction, *TxOut) {
+	credit := &Transaction{
+		Version: 1,
+		Inputs: []*TxIn{{
+			PreviousOutPoint: OutPoint{Hash: make([]byte, 32), Index: 0xffffffff},
+			ScriptSig:        []byte{Op0, Op0},
+			Sequence:         0xffffffff,
+		}},
+		Outputs: []*TxOut{{Value: amount, ScriptPubKey: scriptPubKey}},
+	}
+
+	return &Transaction{
+		Version: 1,
+		Inputs: []*TxIn{{
+			PreviousOutPoint: OutPoint{Hash: doubleHash(credit.SerializeNoWitness()), Index: 0},
+			ScriptSig:        scriptSig,
+			Witness:          witness,
+			Sequence:         0xffffffff,
+		}},
+		Outputs: []*TxOut{{Value: amount, ScriptPubKey: []byte{}}},
+	}, credit.Outputs[0]
+}
+
+func TestScriptVectors(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/script_tests.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/* [[witness..., amount]?, scriptSig, scriptPubKey, flags, expected error, comments...] */
+	var vectors [][]interface{}
+	err = json.Unmarshal(data, &vectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/* Internal key of the generated taproot outputs */
+	internalKey := &PublicKey{X: secp256k1.G.X}
+
+	for _, vector := range vectors {
+		var witness [][]byte
+		var amount int64
+		var tapLeafHash []byte
+
+		if items, ok := vector[0].([]interface{}); ok {
+			for _, item := range items[:len(items)-1] {
+				s := item.(string)
+				switch {
+				case strings.HasPrefix(s, "#SCRIPT#"):
+					script, err := parseCoreScript(s[len("#SCRIPT#"):])
+					assert.Nil(t, err)
+					witness = append(witness, script)
+				case s == "#CONTROLBLOCK#":
+					tapLeafHash = TapLeafHash(TapLeafVersion, witness[len(witness)-1])
+					Q, err := internalKey.TaprootTweak(tapLeafHash)
+					assert.Nil(t, err)
+					control := append([]byte{TapLeafVersion | byte(Q.Y.Bit(0))}, paddedBytes(internalKey.X, 32)...)
+					witness = append(witness, control)
+				default:
+					b, err := hex.DecodeString(s)
+					assert.Nil(t, err)
+					witness = append(witness, b)
+				}
+			}
+			amount = int64(math.Round(items[len(items)-1].(float64) * 1e8))
+			vector = vector[1:]
+		}
+		if len(vector) < 4 {
+			continue
+		}
+		test := vector[0].(string) + " | " + vector[1].(string) + " | " + vector[2].(string)
+
+		scriptSig, err := parseCoreScript(vector[0].(string))
+		assert.Nil(t, err, test)
+		var scriptPubKey []byte
+		if vector[1].(string) == "0x51 0x20 #TAPROOTOUTPUT#" {
+			Q, err := internalKey.TaprootTweak(tapLeafHash)
+			assert.Nil(t, err)
+			scriptPubKey = append([]byte{Op1, 0x20}, paddedBytes(Q.X, 32)...)
+		} else {
+			scriptPubKey, err = parseCoreScript(vector[1].(string))
+			assert.Nil(t, err, test)
+		}
+		flags := parseCoreFlags(vector[2].(string))
+		if flags&ScriptVerifyCleanStack != 0 {
+			flags |= ScriptVerifyP2SH | ScriptVerifyWitness
+		}
+		expected, ok := scriptErrorNames[vector[3].(string)]
+		assert.True(t, ok, test)
+
+		tx, prevOut := scriptTestTransaction(scriptSig, scriptPubKey, witness, amount)
+		prevOuts := []*TxOut{prevOut}
+		assert.Equal(t, expected, tx.VerifyInput(0, prevOuts, flags), test)
+		if testing.Short() {
+			continue
+		}
+
+		/* Removing flags from a passing test or adding flags to a failing test does not change the result */
+		for _, flag := range scriptFlagNames {
+			combined := flags | flag
+			if expected == nil {
+				combined = flags &^ flag
+			}
+			if !validFlags(combined) {
+				continue
+			}
+			err = tx.VerifyInput(0, prevOuts, combined)
+			assert.Equal(t, expected == nil, err == nil, test)
+		}
+	}
+}
+
+/* transactionVector parses a test of tx_valid.json or tx_invalid.json, it reports the test as failed and returns nil if the test cannot be parsed */
+func transactionVector(t *testing.T, vector []interface{}) (*Transaction, []*TxOut) {
+	test := vector[1].(string)
+
+	scripts := make(map[string]*TxOut)
+	for _, item := range vector[0].([]interface{}) {
+		input := item.([]interface{})
+		outPoint, err := NewOutPoint(input[0].(string), uint32(int64(input[1].(float64))))
+		if err != nil {
+			t.Errorf("%s: invalid prevout: %v", test, err)
+			return nil, nil
+		}
+		script, err := parseCoreScript(input[2].(string))
+		if err != nil {
+			t.Errorf("%s: invalid prevout script: %v", test, err)
+			return nil, nil
+		}
+		var amount int64
+		if len(input) > 3 {
+			amount = int64(input[3].(float64))
+		}
+		scripts[outPoint.TxID()+":"+strconv.Itoa(int(outPoint.Index))] = &TxOut{Value: amount, ScriptPubKey: script}
+	}
+
+	tx, err := TransactionFromHex(test)
+	if err != nil {
+		t.Errorf("%s: invalid transaction: %v", test, err)
+		return nil, nil
+	}
+
+	prevOuts := make([]*TxOut, len(tx.Inputs))
+	for i, in := range tx.Inputs {
+		prevOuts[i] = scripts[in.PreviousOutPoint.TxID()+":"+strconv.Itoa(int(in.PreviousOutPoint.Index))]
+		if prevOuts[i] == nil {
+			t.Errorf("%s: missing prevout of input %d", test, i)
+			return nil, nil
+		}
+	}
+	return tx, prevOuts
+}
+
+/* verifyInputs returns true if the scripts of all inputs are valid */
+func verifyInputs(tx *Transaction, prevOuts []*TxOut, flags ScriptFlags) bool {
+	for i := range tx.Inputs {
+		if tx.VerifyInput(i, prevOuts, flags) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func TestTransactionVectors(t *testing.T) {
+	var allFlags ScriptFlags
+	for _, flag := range scriptFlagNames {
+		allFlags |= flag
+	}
+
+	for _, file := range []string{"tx_valid", "tx_invalid"} {
+		data, err := ioutil.ReadFile("testdata/" + file + ".json")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		/* [[[prevout hash, prevout index, prevout scriptPubKey, amount?], ...], serialized transaction, flags] */
+		var vectors [][]interface{}
+		err = json.Unmarshal(data, &vectors)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, vector := range vectors {
+			if _, ok := vector[0].([]interface{}); !ok {
+				continue
+			}
+			test := vector[1].(string)
+			tx, prevOuts := transactionVector(t, vector)
+			if tx == nil {
+				continue
+			}
+
+			if file == "tx_valid" {
+				assert.Nil(t, tx.Check(), test)
+
+				/* Flags are the ones excluded from verification */
+				excluded := parseCoreFlags(vector[2].(string))
+				flags := allFlags &^ excluded
+				assert.True(t, verifyInputs(tx, prevOuts, flags), test)
+				if testing.Short() {
+					continue
+				}
+
+				/* Removing any flag does not invalidate the transaction and the excluded flags are necessary */
+				for _, flag := range scriptFlagNames {
+					assert.True(t, verifyInputs(tx, prevOuts, trimFlags(flags&^flag)), test)
+
+					stricter := allFlags &^ trimFlags(excluded&^flag)
+					if excluded&flag != 0 && validFlags(stricter) {
+						assert.False(t, verifyInputs(tx, prevOuts, stricter), test)
+					}
+				}
+				continue
+			}
+
+			if tx.Check() != nil {
+				assert.Equal(t, "BADTX", vector[2].(string), test)
+				continue
+			}
+			flags := parseCoreFlags(vector[2].(string))
+			assert.False(t, verifyInputs(tx, prevOuts, flags), test)
+			if testing.Short() {
+				continue
+			}
+
+			/* Adding any flag does not validate the transaction and all flags are necessary */
+			for _, flag := range scriptFlagNames {
+				assert.False(t, verifyInputs(tx, prevOuts, fillFlags(flags|flag)), test)
+
+				if flags&flag != 0 && trimFlags(flags&^flag) != flags {
+					assert.True(t, verifyInputs(tx, prevOuts, trimFlags(flags&^flag)), test)
+				}
+			}
+		}
+	}
+}
+
+func TestVerifyInput(t *testing.T) {
+	key, err := PrivateFromHex("e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35", MainNetwork)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key.SetCompressed(true)
+	pub, _ := key.GetPublicKey()
+
+	outPoint, _ := NewOutPoint("4ce18f49ba153a51bcda9bb80d7f978e3de6e81b5fc326f00465464530c052f4", 1)
+	tx := &Transaction{
+		Version: 2,
+		Inputs: []*TxIn{
+			{PreviousOutPoint: outPoint, Sequence: 0xfffffffd},
+			{PreviousOutPoint: OutPoint{Hash: outPoint.Hash, Index: 2}, Sequence: 0xfffffffd},
+		},
+		Outputs: []*TxOut{{Value: 150000, ScriptPubKey: append([]byte{Op0, 0x14}, hash160(pub.serialize(true))...)}},
+	}
+
+	/* P2WPKH and P2TR key path outputs of the same key */
+	tweaked, err := key.TaprootTweak(nil)
+	assert.Nil(t, err)
+	Q, err := pub.TaprootTweak(nil)
+	assert.Nil(t, err)
+	prevOuts := []*TxOut{
+		{Value: 100000, ScriptPubKey: append([]byte{Op0, 0x14}, hash160(pub.serialize(true))...)},
+		{Value: 60000, ScriptPubKey: append([]byte{Op1, 0x20}, paddedBytes(Q.X, 32)...)},
+	}
+
+	scriptCode := append([]byte{OpDup, OpHash160, 0x14}, hash160(pub.serialize(true))...)
+	scriptCode = append(scriptCode, OpEqualVerify, OpCheckSig)
+	signature, err := key.SignWitnessV0Input(tx, 0, scriptCode, prevOuts[0].Value, SigHashAll)
+	assert.Nil(t, err)
+	tx.Inputs[0].Witness = [][]byte{signature, pub.serialize(true)}
+
+	signature, err = tweaked.SignTaprootInput(tx, 1, prevOuts, SigHashDefault, nil)
+	assert.Nil(t, err)
+	tx.Inputs[1].Witness = [][]byte{signature}
+
+	assert.Nil(t, tx.Verify(prevOuts, StandardVerifyFlags))
+
+	/* Invalid arguments */
+	assert.NotNil(t, tx.VerifyInput(2, prevOuts, StandardVerifyFlags))
+	assert.NotNil(t, tx.VerifyInput(0, prevOuts[:1], StandardVerifyFlags))
+	assert.NotNil(t, tx.VerifyInput(0, prevOuts, ScriptVerifyWitness))
+
+	/* Spent amounts are committed to */
+	prevOuts[0].Value++
+	assert.Equal(t, errEvalFalse, tx.VerifyInput(0, prevOuts, StandardVerifyFlags))
+	assert.Equal(t, errSchnorrSig, tx.VerifyInput(1, prevOuts, StandardVerifyFlags))
+	prevOuts[0].Value--
+
+	/* Outputs are committed to */
+	tx.Outputs[0].Value--
+	assert.Equal(t, errEvalFalse, tx.VerifyInput(0, prevOuts, StandardVerifyFlags))
+	assert.Equal(t, errSchnorrSig, tx.VerifyInput(1, prevOuts, StandardVerifyFlags))
+
+	/* Spending a P2WPKH output without a witness */
+	tx.Inputs[0].Witness = nil
+	assert.Equal(t, errWitnessProgramMismatch, tx.VerifyInput(0, prevOuts, StandardVerifyFlags))
+}
@@ -0,0 +1,277 @@
+package btc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+)
+
+/* messageMagic prefixes messages signed with the legacy format (BIP137) */
+const messageMagic = "Bitcoin Signed Message:\n"
+
+/* First header bytes of BIP137 signatures of segwit addresses, P2PKH signatures use the headers of compact signatures */
+const (
+	messageHeaderP2SHP2WPKH byte = 35
+	messageHeaderP2WPKH     byte = 39
+)
+
+// MessageHash computes the hash signed by legacy (BIP137) message signatures
+func MessageHash(message string) []byte {
+	data := append(compactSize(uint64(len(messageMagic))), messageMagic...)
+	data = append(data, compactSize(uint64(len(message)))...)
+	data = append(data, message...)
+	return doubleHash(data)
+}
+
+// SignMessage signs a message with the legacy format (BIP137), addressType is the type of the address of the key: P2PKH, P2SH (P2SH-P2WPKH) or P2WPKH
+func (p *PrivateKey) SignMessage(message string, addressType AddressType) (string, error) {
+	var header byte
+	switch addressType {
+	case P2PKHAddress:
+	case P2SHAddress:
+		header = messageHeaderP2SHP2WPKH
+	case P2WPKHAddress:
+		header = messageHeaderP2WPKH
+	default:
+		return "", errors.New("legacy message signatures only support P2PKH, P2SH-P2WPKH and P2WPKH addresses")
+	}
+	if addressType != P2PKHAddress && !p.Compressed {
+		return "", errors.New("segwit addresses require compressed public keys")
+	}
+
+	/* P2PKH signatures are compact signatures, segwit ones only change the header */
+	compact, err := p.SignCompact(MessageHash(message))
+	if err != nil {
+		return "", err
+	}
+	if addressType != P2PKHAddress {
+		compact[0] = header + (compact[0]-compactHeader)&3
+	}
+	return base64.StdEncoding.EncodeToString(compact), nil
+}
+
+// VerifyMessage checks a base64 legacy (BIP137) or BIP322 signature of a message by address
+func VerifyMessage(address string, message string, signature string, network *Network) bool {
+	a, err := ParseAddress(address, network)
+	if err != nil {
+		return false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	if len(decoded) == 65 {
+		return verifyMessageBIP137(a, message, decoded)
+	}
+	return verifyMessageBIP322(a, message, decoded) == nil
+}
+
+/* verifyMessageBIP137 recovers the public key of a compact signature and compares its address, segwit headers are accepted for any address type of compressed keys like most wallets do */
+func verifyMessageBIP137(address *Address, message string, compact []byte) bool {
+	header := compact[0]
+	if header < compactHeader || header >= messageHeaderP2WPKH+4 {
+		return false
+	}
+
+	/* Segwit headers are mapped to the header of compressed compact signatures */
+	if header >= messageHeaderP2SHP2WPKH {
+		compact = append([]byte{compactHeader + 4 + (header-compactHeader)&3}, compact[1:]...)
+	}
+	publicKey, err := RecoverCompact(MessageHash(message), compact, address.Network)
+	if err != nil {
+		return false
+	}
+
+	compressed := publicKey.Compressed
+	var expected string
+	switch address.Type {
+	case P2PKHAddress:
+		expected, err = publicKey.Address(compressed)
+	case P2SHAddress:
+		expected, err = publicKey.NestedSegwitAddress(compressed)
+	case P2WPKHAddress:
+		expected, err = publicKey.SegwitAddress(compressed)
+	default:
+		return false
+	}
+	return err == nil && expected == address.String()
+}
+
+// MessageHashBIP322 computes the tagged hash of a message committed to by BIP322 signatures
+func MessageHashBIP322(message string) []byte {
+	return taggedHash("BIP0322-signed-message", []byte(message))
+}
+
+/* bip322ToSpend returns the virtual transaction whose output is spent by the signature */
+func bip322ToSpend(message string, scriptPubKey []byte) *Transaction {
+	return &Transaction{
+		Version: 0,
+		Inputs: []*TxIn{{
+			PreviousOutPoint: OutPoint{Hash: make([]byte, 32), Index: 0xffffffff},
+			ScriptSig:        Script{}.AddOpcode(Op0).PushData(MessageHashBIP322(message)),
+			Sequence:         0,
+		}},
+		Outputs: []*TxOut{{
+			Value:        0,
+			ScriptPubKey: scriptPubKey,
+		}},
+		LockTime: 0,
+	}
+}
+
+/* bip322ToSign returns the unsigned virtual transaction spending the output of toSpend to an OP_RETURN output */
+func bip322ToSign(toSpend *Transaction) *Transaction {
+	return &Transaction{
+		Version: 0,
+		Inputs: []*TxIn{{
+			PreviousOutPoint: OutPoint{Hash: doubleHash(toSpend.SerializeNoWitness()), Index: 0},
+			Sequence:         0,
+		}},
+		Outputs: []*TxOut{{
+			Value:        0,
+			ScriptPubKey: Script{}.AddOpcode(OpReturn),
+		}},
+		LockTime: 0,
+	}
+}
+
+// SignMessageBIP322 signs a message with the simple (witness only) or full (whole transaction) BIP322 format, P2PKH and P2SH (P2SH-P2WPKH) addresses require the full format
+func (p *PrivateKey) SignMessageBIP322(message string, addressType AddressType, full bool) (string, error) {
+	publicKey, valid := p.GetPublicKey()
+	if !valid {
+		return "", errors.New("invalid private key")
+	}
+
+	var address string
+	var err error
+	switch addressType {
+	case P2PKHAddress:
+		address, err = publicKey.Address(p.Compressed)
+	case P2SHAddress:
+		address, err = publicKey.NestedSegwitAddress(p.Compressed)
+	case P2WPKHAddress:
+		address, err = publicKey.SegwitAddress(p.Compressed)
+	case P2TRAddress:
+		address, err = publicKey.TaprootAddress(nil)
+	default:
+		return "", errors.New("BIP322 signatures only support P2PKH, P2SH-P2WPKH, P2WPKH and P2TR addresses")
+	}
+	if err != nil {
+		return "", err
+	}
+	if !full && (addressType == P2PKHAddress || addressType == P2SHAddress) {
+		return "", errors.New("simple BIP322 signatures require a segwit address")
+	}
+
+	a, err := ParseAddress(address, p.Network)
+	if err != nil {
+		return "", err
+	}
+	toSpend := bip322ToSpend(message, a.Script())
+	toSign := bip322ToSign(toSpend)
+	in := toSign.Inputs[0]
+
+	/* P2PKH script code of the P2WPKH program, also the script spent by P2PKH */
+	pubKey := publicKey.serialize(p.Compressed)
+	scriptCode := (&Address{Type: P2PKHAddress, Hash: hash160(pubKey)}).Script()
+
+	switch addressType {
+	case P2PKHAddress:
+		signature, err := p.SignLegacyInput(toSign, 0, scriptCode, SigHashAll)
+		if err != nil {
+			return "", err
+		}
+		in.ScriptSig = Script{}.PushData(signature).PushData(pubKey)
+	case P2SHAddress, P2WPKHAddress:
+		signature, err := p.SignWitnessV0Input(toSign, 0, scriptCode, 0, SigHashAll)
+		if err != nil {
+			return "", err
+		}
+		in.Witness = [][]byte{signature, pubKey}
+		if addressType == P2SHAddress {
+			in.ScriptSig = Script{}.PushData(append([]byte{0x00, 0x14}, hash160(pubKey)...))
+		}
+	case P2TRAddress:
+		tweaked, err := p.TaprootTweak(nil)
+		if err != nil {
+			return "", err
+		}
+		signature, err := tweaked.SignTaprootInput(toSign, 0, toSpend.Outputs, SigHashDefault, nil)
+		if err != nil {
+			return "", err
+		}
+		in.Witness = [][]byte{signature}
+	}
+
+	if full {
+		return base64.StdEncoding.EncodeToString(toSign.Serialize()), nil
+	}
+	return base64.StdEncoding.EncodeToString(serializeWitness(in.Witness)), nil
+}
+
+/* verifyMessageBIP322 executes the scripts of a simple or full BIP322 signature */
+func verifyMessageBIP322(address *Address, message string, signature []byte) error {
+	toSpend := bip322ToSpend(message, address.Script())
+	expected := bip322ToSign(toSpend)
+
+	/* Simple signatures are a witness stack, full signatures a transaction */
+	var toSign *Transaction
+	if witness, err := parseWitness(signature); err == nil {
+		toSign = expected
+		toSign.Inputs[0].Witness = witness
+	} else {
+		toSign, err = TransactionFromBytes(signature)
+		if err != nil {
+			return errors.New("invalid BIP322 signature encoding")
+		}
+
+		/* Proofs of funds with additional inputs are not supported */
+		if len(toSign.Inputs) != 1 || len(toSign.Outputs) != 1 {
+			return errors.New("BIP322 transaction must have one input and one output")
+		}
+		in := toSign.Inputs[0]
+		if !bytes.Equal(in.PreviousOutPoint.Hash, expected.Inputs[0].PreviousOutPoint.Hash) || in.PreviousOutPoint.Index != 0 {
+			return errors.New("BIP322 transaction does not spend the message")
+		}
+		out := toSign.Outputs[0]
+		if out.Value != 0 || !bytes.Equal(out.ScriptPubKey, expected.Outputs[0].ScriptPubKey) {
+			return errors.New("BIP322 transaction output must be an empty OP_RETURN")
+		}
+	}
+
+	return toSign.VerifyInput(0, toSpend.Outputs, StandardVerifyFlags)
+}
+
+/* serializeWitness encodes a witness stack as in transactions */
+func serializeWitness(witness [][]byte) []byte {
+	b := compactSize(uint64(len(witness)))
+	for _, item := range witness {
+		b = appendVarBytes(b, item)
+	}
+	return b
+}
+
+/* parseWitness decodes a witness stack, all the bytes must be consumed */
+func parseWitness(b []byte) ([][]byte, error) {
+	r := &byteReader{b: b}
+	count, err := r.readCount()
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, errors.New("empty witness")
+	}
+
+	witness := make([][]byte, count)
+	for i := range witness {
+		witness[i], err = r.readVarBytes()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if r.remaining() != 0 {
+		return nil, errors.New("trailing bytes after witness")
+	}
+	return witness, nil
+}
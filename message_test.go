@@ -0,0 +1,137 @@
+package btc
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageHashes(t *testing.T) {
+	assert.Equal(t, "c90c269c4f8fcbe6880f72a721ddfbf1914268a794cbb21cfafee13770ae19f1", hex.EncodeToString(MessageHashBIP322("")))
+	assert.Equal(t, "f0eb03b1a75ac6d9847f55c624a99169b5dccba2a31f5b23bea77ba270de0a7a", hex.EncodeToString(MessageHashBIP322("Hello World")))
+
+	/* Virtual transactions of BIP322 */
+	script, err := hex.DecodeString("00142b05d564e6a7a33c087f16e0f730d1440123799d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for message, txids := range map[string][2]string{
+		"":            {"c5680aa69bb8d860bf82d4e9cd3504b55dde018de765a91bb566283c545a99a7", "1e9654e951a5ba44c8604c4de6c67fd78a27e81dcadcfe1edf638ba3aaebaed6"},
+		"Hello World": {"b79d196740ad5217771c1098fc4a4b51e0535c32236c71f1ea4d61a2d603352b", "88737ae86f2077145f93cc4b153ae9a1cb8d56afa511988c149c5c8c9d93bddf"},
+	} {
+		toSpend := bip322ToSpend(message, script)
+		assert.Equal(t, txids[0], toSpend.TxID())
+		assert.Equal(t, txids[1], bip322ToSign(toSpend).TxID())
+	}
+}
+
+func TestSignMessage(t *testing.T) {
+	key, err := PrivateFromWIF("cUeKHd5orzT3mz8P9pxyREHfsWtVfgsfDjiZZBcjUBAaGk1BTj7N", TestNetwork)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signature, err := key.SignMessage("This is just a test message", P2PKHAddress)
+	assert.Nil(t, err)
+	assert.Equal(t, "INbVnW4e6PeRmsv2Qgu8NuopvrVjkcxob+sX8OcZG0SALhWybUjzMLPdAsXI46YZGb0KQTRii+wWIQzRpG/U+S0=", signature)
+	assert.True(t, VerifyMessage("mpLQjfK79b7CCV4VMJWEWAj5Mpx8Up5zxB", "This is just a test message", signature, TestNetwork))
+	assert.False(t, VerifyMessage("mpLQjfK79b7CCV4VMJWEWAj5Mpx8Up5zxB", "This is just a test message.", signature, TestNetwork))
+
+	tests := []struct {
+		address   string
+		message   string
+		signature string
+		valid     bool
+	}{
+		{"15CRxFdyRpGZLW9w8HnHvVduizdL5jKNbs", "Trust no one", "IPojfrX2dfPnH26UegfbGQQLrdK844DlHq5157/P6h57WyuS/Qsl+h/WSVGDF4MUi4rWSswW38oimDYfNNUBUOk=", true},
+		{"11canuhp9X2NocwCq7xNrQYTmUgZAnLK3", "Trust me", "IIcaIENoYW5jZWxsb3Igb24gYnJpbmsgb2Ygc2Vjb25kIGJhaWxvdXQgZm9yIGJhbmtzIAaHRtbCeDZINyavx14=", true},
+		{"15CRxFdyRpGZLW9w8HnHvVduizdL5jKNbs", "Trust me", "IPojfrX2dfPnH26UegfbGQQLrdK844DlHq5157/P6h57WyuS/Qsl+h/WSVGDF4MUi4rWSswW38oimDYfNNUBUOk=", false},
+		{"15CRxFdyRpGZLW9w8HnHvVduizdL5jKNbs", "Trust no one", "not base64", false},
+		{"invalid", "Trust no one", "IPojfrX2dfPnH26UegfbGQQLrdK844DlHq5157/P6h57WyuS/Qsl+h/WSVGDF4MUi4rWSswW38oimDYfNNUBUOk=", false},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.valid, VerifyMessage(test.address, test.message, test.signature, MainNetwork), test.address)
+	}
+
+	/* Each address type has its own header */
+	key = GeneratePrivateKey(MainNetwork)
+	key.SetCompressed(true)
+	publicKey, _ := key.GetPublicKey()
+	addresses := map[AddressType]func(bool) (string, error){
+		P2PKHAddress:  publicKey.Address,
+		P2SHAddress:   publicKey.NestedSegwitAddress,
+		P2WPKHAddress: publicKey.SegwitAddress,
+	}
+	for addressType, address := range addresses {
+		a, err := address(true)
+		assert.Nil(t, err)
+		signature, err := key.SignMessage("message", addressType)
+		assert.Nil(t, err)
+		assert.True(t, VerifyMessage(a, "message", signature, MainNetwork), a)
+		assert.False(t, VerifyMessage(a, "other message", signature, MainNetwork), a)
+	}
+	_, err = key.SignMessage("message", P2TRAddress)
+	assert.NotNil(t, err)
+
+	/* Uncompressed keys only have P2PKH addresses */
+	key.SetCompressed(false)
+	a, err := publicKey.Address(false)
+	assert.Nil(t, err)
+	signature, err = key.SignMessage("message", P2PKHAddress)
+	assert.Nil(t, err)
+	assert.True(t, VerifyMessage(a, "message", signature, MainNetwork))
+	_, err = key.SignMessage("message", P2WPKHAddress)
+	assert.NotNil(t, err)
+}
+
+func TestSignMessageBIP322(t *testing.T) {
+	tests := []struct {
+		address   string
+		message   string
+		signature string
+		valid     bool
+	}{
+		{"bc1q9vza2e8x573nczrlzms0wvx3gsqjx7vavgkx0l", "", "AkcwRAIgM2gBAQqvZX15ZiysmKmQpDrG83avLIT492QBzLnQIxYCIBaTpOaD20qRlEylyxFSeEA2ba9YOixpX8z46TSDtS40ASECx/EgAxlkQpQ9hYjgGu6EBCPMVPwVIVJqO4XCsMvViHI=", true},
+		{"bc1q9vza2e8x573nczrlzms0wvx3gsqjx7vavgkx0l", "Hello World", "AkcwRAIgZRfIY3p7/DoVTty6YZbWS71bc5Vct9p9Fia83eRmw2QCICK/ENGfwLtptFluMGs2KsqoNSk89pO7F29zJLUx9a/sASECx/EgAxlkQpQ9hYjgGu6EBCPMVPwVIVJqO4XCsMvViHI=", true},
+		{"bc1q9vza2e8x573nczrlzms0wvx3gsqjx7vavgkx0l", "Hello World", "AkgwRQIhAOzyynlqt93lOKJr+wmmxIens//zPzl9tqIOua93wO6MAiBi5n5EyAcPScOjf1lAqIUIQtr3zKNeavYabHyR8eGhowEhAsfxIAMZZEKUPYWI4BruhAQjzFT8FSFSajuFwrDL1Yhy", true},
+		{"bc1q9vza2e8x573nczrlzms0wvx3gsqjx7vavgkx0l", "", "AkcwRAIgZRfIY3p7/DoVTty6YZbWS71bc5Vct9p9Fia83eRmw2QCICK/ENGfwLtptFluMGs2KsqoNSk89pO7F29zJLUx9a/sASECx/EgAxlkQpQ9hYjgGu6EBCPMVPwVIVJqO4XCsMvViHI=", false},
+		{"bc1ppv609nr0vr25u07u95waq5lucwfm6tde4nydujnu8npg4q75mr5sxq8lt3", "Hello World", "AUHd69PrJQEv+oKTfZ8l+WROBHuy9HKrbFCJu7U1iK2iiEy1vMU5EfMtjc+VSHM7aU0SDbak5IUZRVno2P5mjSafAQ==", true},
+		{"bc1ppv609nr0vr25u07u95waq5lucwfm6tde4nydujnu8npg4q75mr5sxq8lt3", "Hello", "AUHd69PrJQEv+oKTfZ8l+WROBHuy9HKrbFCJu7U1iK2iiEy1vMU5EfMtjc+VSHM7aU0SDbak5IUZRVno2P5mjSafAQ==", false},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.valid, VerifyMessage(test.address, test.message, test.signature, MainNetwork), test.signature)
+	}
+
+	key, err := PrivateFromWIF("L3VFeEujGtevx9w18HD1fhRbCH67Az2dpCymeRE1SoPK6XQtaN2k", MainNetwork)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/* ECDSA signatures are deterministic, the second vector is signed with RFC6979 nonces */
+	signature, err := key.SignMessageBIP322("Hello World", P2WPKHAddress, false)
+	assert.Nil(t, err)
+	assert.Equal(t, tests[2].signature, signature)
+
+	publicKey, _ := key.GetPublicKey()
+	addresses := map[AddressType]func() (string, error){
+		P2PKHAddress:  func() (string, error) { return publicKey.Address(true) },
+		P2SHAddress:   func() (string, error) { return publicKey.NestedSegwitAddress(true) },
+		P2WPKHAddress: func() (string, error) { return publicKey.SegwitAddress(true) },
+		P2TRAddress:   func() (string, error) { return publicKey.TaprootAddress(nil) },
+	}
+	for addressType, address := range addresses {
+		a, err := address()
+		assert.Nil(t, err)
+		for _, full := range []bool{false, true} {
+			signature, err := key.SignMessageBIP322("message", addressType, full)
+			if !full && (addressType == P2PKHAddress || addressType == P2SHAddress) {
+				assert.NotNil(t, err, a)
+				continue
+			}
+			assert.Nil(t, err, a)
+			assert.True(t, VerifyMessage(a, "message", signature, MainNetwork), a)
+			assert.False(t, VerifyMessage(a, "other message", signature, MainNetwork), a)
+		}
+	}
+}
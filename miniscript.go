@@ -0,0 +1,2141 @@
+package btc
+
+import (
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Miniscript contexts
+const (
+	P2WSHMiniscript MiniscriptContext = iota
+	TapscriptMiniscript
+)
+
+// Miniscript fragments
+const (
+	Just0Fragment MiniscriptFragment = iota
+	Just1Fragment
+	PKKFragment
+	PKHFragment
+	OlderFragment
+	AfterFragment
+	SHA256Fragment
+	Hash256Fragment
+	RIPEMD160Fragment
+	Hash160Fragment
+	WrapAFragment
+	WrapSFragment
+	WrapCFragment
+	WrapDFragment
+	WrapVFragment
+	WrapJFragment
+	WrapNFragment
+	AndVFragment
+	AndBFragment
+	OrBFragment
+	OrCFragment
+	OrDFragment
+	OrIFragment
+	AndOrFragment
+	ThreshFragment
+	MultiFragment
+	MultiAFragment
+)
+
+/* Limits of miniscripts which are not consensus rules */
+const (
+	maxStandardP2WSHScriptSize = 3600
+	maxStandardP2WSHStackItems = 100
+	/* maxTapMiniscriptSize leaves room for a maximum sized witness and the rest of the transaction in a standard transaction */
+	maxTapMiniscriptSize = 329482
+	maxPubKeysPerMultiA  = 999
+)
+
+/* The position of a letter in miniscriptTypeLetters is the bit of the property in MiniscriptType */
+const miniscriptTypeLetters = "BVKWzonduefsmxghijk"
+
+var miniscriptFragmentNames = map[string]MiniscriptFragment{
+	"pk_k":      PKKFragment,
+	"pk_h":      PKHFragment,
+	"older":     OlderFragment,
+	"after":     AfterFragment,
+	"sha256":    SHA256Fragment,
+	"hash256":   Hash256Fragment,
+	"ripemd160": RIPEMD160Fragment,
+	"hash160":   Hash160Fragment,
+	"and_v":     AndVFragment,
+	"and_b":     AndBFragment,
+	"or_b":      OrBFragment,
+	"or_c":      OrCFragment,
+	"or_d":      OrDFragment,
+	"or_i":      OrIFragment,
+	"andor":     AndOrFragment,
+	"thresh":    ThreshFragment,
+	"multi":     MultiFragment,
+	"multi_a":   MultiAFragment,
+}
+
+/* mst returns the type with the properties named by letters */
+func mst(letters string) MiniscriptType {
+	var t MiniscriptType
+	for i := 0; i < len(letters); i++ {
+		t |= 1 << uint(strings.IndexByte(miniscriptTypeLetters, letters[i]))
+	}
+	return t
+}
+
+// Has returns true if the type has all the properties named by letters, "Bdu" for instance
+func (t MiniscriptType) Has(letters string) bool {
+	properties := mst(letters)
+	return t&properties == properties
+}
+
+// String returns the letters of the properties of the type
+func (t MiniscriptType) String() string {
+	var letters []byte
+	for i := 0; i < len(miniscriptTypeLetters); i++ {
+		if t&(1<<uint(i)) != 0 {
+			letters = append(letters, miniscriptTypeLetters[i])
+		}
+	}
+	return string(letters)
+}
+
+/* when returns the type if cond holds and the empty type otherwise */
+func (t MiniscriptType) when(cond bool) MiniscriptType {
+	if cond {
+		return t
+	}
+	return 0
+}
+
+/* mixesTimeLocks returns true if x and y use locks of the same kind but of different types (heights and times) */
+func mixesTimeLocks(x MiniscriptType, y MiniscriptType) bool {
+	return x.Has("g") && y.Has("h") || x.Has("h") && y.Has("g") || x.Has("i") && y.Has("j") || x.Has("j") && y.Has("i")
+}
+
+/* maxInt is the maximum of a set of integers, it is not valid for the empty set */
+type maxInt struct {
+	valid bool
+	value int
+}
+
+func newMaxInt(value int) maxInt {
+	return maxInt{valid: true, value: value}
+}
+
+/* add sums the maximums of two sets, at least one element of each is needed */
+func (a maxInt) add(b maxInt) maxInt {
+	if !a.valid || !b.valid {
+		return maxInt{}
+	}
+	return newMaxInt(a.value + b.value)
+}
+
+/* or is the maximum of the union of two sets */
+func (a maxInt) or(b maxInt) maxInt {
+	if !a.valid {
+		return b
+	}
+	if !b.valid || a.value >= b.value {
+		return a
+	}
+	return b
+}
+
+/* satInfo describes a set of script executions: netDiff is the most elements they remove from the stack, and exec is the most elements the stack can have during execution above its size at the end */
+type satInfo struct {
+	valid   bool
+	netDiff int
+	exec    int
+}
+
+func newSatInfo(netDiff int, exec int) satInfo {
+	return satInfo{valid: true, netDiff: netDiff, exec: exec}
+}
+
+/* Stack effects of the scripts miniscripts are made of */
+var (
+	satEmpty       = newSatInfo(0, 0)
+	satPush        = newSatInfo(-1, 0)
+	satHash        = newSatInfo(0, 0)
+	satNop         = newSatInfo(0, 0)
+	satIf          = newSatInfo(1, 1)
+	satBinaryOp    = newSatInfo(1, 1)
+	satDup         = newSatInfo(-1, 0)
+	satIfDupTrue   = newSatInfo(-1, 0)
+	satIfDupFalse  = newSatInfo(0, 0)
+	satEqualVerify = newSatInfo(2, 2)
+	satEqual       = newSatInfo(1, 1)
+	satSize        = newSatInfo(-1, 0)
+	satCheckSig    = newSatInfo(1, 1)
+	sat0NotEqual   = newSatInfo(0, 0)
+	satVerify      = newSatInfo(1, 1)
+)
+
+/* or is the union of two sets of executions */
+func (a satInfo) or(b satInfo) satInfo {
+	if !a.valid {
+		return b
+	}
+	if !b.valid {
+		return a
+	}
+	return newSatInfo(maxInts(a.netDiff, b.netDiff), maxInts(a.exec, b.exec))
+}
+
+/* add concatenates the executions of a with the executions of the next scripts */
+func (a satInfo) add(next ...satInfo) satInfo {
+	for _, b := range next {
+		if !a.valid || !b.valid {
+			return satInfo{}
+		}
+		a = newSatInfo(a.netDiff+b.netDiff, maxInts(b.exec, b.netDiff+a.exec))
+	}
+	return a
+}
+
+func maxInts(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+/* miniscriptOps counts the non-push opcodes of a script, keys of executed CHECKMULTISIGs are counted apart */
+type miniscriptOps struct {
+	count int
+	sat   maxInt
+	dsat  maxInt
+}
+
+/* miniscriptStackSize is the stack usage of satisfactions and dissatisfactions */
+type miniscriptStackSize struct {
+	sat  satInfo
+	dsat satInfo
+}
+
+/* miniscriptWitnessSize is the maximum size of satisfactions and dissatisfactions */
+type miniscriptWitnessSize struct {
+	sat  maxInt
+	dsat maxInt
+}
+
+/* newMiniscript builds an expression and computes its properties */
+func newMiniscript(ctx MiniscriptContext, fragment MiniscriptFragment, k uint32, keys []*PublicKey, data []byte, subs ...*Miniscript) *Miniscript {
+	m := &Miniscript{
+		Fragment: fragment,
+		K:        k,
+		Keys:     keys,
+		Data:     data,
+		Subs:     subs,
+		Context:  ctx,
+	}
+	m.ops = m.computeOps()
+	m.stackSize = m.computeStackSize()
+	m.witnessSize = m.computeWitnessSize()
+	m.Type = m.computeType()
+	m.scriptSize = m.computeScriptSize()
+	m.duplicateKeys = m.computeDuplicateKeys()
+	return m
+}
+
+/* computeType applies the typing rules of the fragment to the types of its subexpressions */
+func (m *Miniscript) computeType() MiniscriptType {
+	var x, y, z MiniscriptType
+	if len(m.Subs) > 0 {
+		x = m.Subs[0].Type
+	}
+	if len(m.Subs) > 1 {
+		y = m.Subs[1].Type
+	}
+	if len(m.Subs) > 2 {
+		z = m.Subs[2].Type
+	}
+
+	var t MiniscriptType
+	switch m.Fragment {
+	case PKKFragment:
+		t = mst("Konudemsxk")
+	case PKHFragment:
+		t = mst("Knudemsxk")
+	case OlderFragment:
+		t = mst("g").when(m.K&sequenceLockTimeTypeFlag != 0) | mst("h").when(m.K&sequenceLockTimeTypeFlag == 0) | mst("Bzfmxk")
+	case AfterFragment:
+		t = mst("i").when(m.K >= LockTimeThreshold) | mst("j").when(m.K < LockTimeThreshold) | mst("Bzfmxk")
+	case SHA256Fragment, RIPEMD160Fragment, Hash256Fragment, Hash160Fragment:
+		t = mst("Bonudmk")
+	case Just1Fragment:
+		t = mst("Bzufmxk")
+	case Just0Fragment:
+		t = mst("Bzudemsxk")
+	case WrapAFragment:
+		t = mst("W").when(x.Has("B")) | x&mst("ghijk") | x&mst("udfems") | mst("x")
+	case WrapSFragment:
+		t = mst("W").when(x.Has("Bo")) | x&mst("ghijk") | x&mst("udfemsx")
+	case WrapCFragment:
+		t = mst("B").when(x.Has("K")) | x&mst("ghijk") | x&mst("ondfem") | mst("us")
+	case WrapDFragment:
+		/* d: is only u in tapscript, where MINIMALIF is a consensus rule */
+		t = mst("B").when(x.Has("Vz")) | mst("o").when(x.Has("z")) | mst("e").when(x.Has("f")) |
+			x&mst("ghijk") | x&mst("ms") | mst("u").when(m.Context == TapscriptMiniscript) | mst("ndx")
+	case WrapVFragment:
+		t = mst("V").when(x.Has("B")) | x&mst("ghijk") | x&mst("zonms") | mst("fx")
+	case WrapJFragment:
+		t = mst("B").when(x.Has("Bn")) | mst("e").when(x.Has("f")) | x&mst("ghijk") | x&mst("oums") | mst("ndx")
+	case WrapNFragment:
+		t = x&mst("ghijk") | x&mst("Bzondfems") | mst("ux")
+	case AndVFragment:
+		t = (y & mst("KVB")).when(x.Has("V")) |
+			x&mst("n") | (y & mst("n")).when(x.Has("z")) |
+			((x | y) & mst("o")).when((x | y).Has("z")) |
+			x&y&mst("mz") |
+			(x|y)&mst("s") |
+			mst("f").when(y.Has("f") || x.Has("s")) |
+			y&mst("ux") |
+			(x|y)&mst("ghij") |
+			mst("k").when((x&y).Has("k") && !mixesTimeLocks(x, y))
+	case AndBFragment:
+		t = (x & mst("B")).when(y.Has("W")) |
+			((x | y) & mst("o")).when((x | y).Has("z")) |
+			x&mst("n") | (y & mst("n")).when(x.Has("z")) |
+			(x & y & mst("e")).when((x & y).Has("s")) |
+			x&y&mst("dzm") |
+			mst("f").when((x&y).Has("f") || x.Has("sf") || y.Has("sf")) |
+			(x|y)&mst("s") |
+			mst("ux") |
+			(x|y)&mst("ghij") |
+			mst("k").when((x&y).Has("k") && !mixesTimeLocks(x, y))
+	case OrBFragment:
+		t = mst("B").when(x.Has("Bd") && y.Has("Wd")) |
+			((x | y) & mst("o")).when((x | y).Has("z")) |
+			(x & y & mst("m")).when((x|y).Has("s") && (x&y).Has("e")) |
+			x&y&mst("zse") |
+			mst("dux") |
+			(x|y)&mst("ghij") |
+			x&y&mst("k")
+	case OrDFragment:
+		t = (y & mst("B")).when(x.Has("Bdu")) |
+			(x & mst("o")).when(y.Has("z")) |
+			(x & y & mst("m")).when(x.Has("e") && (x|y).Has("s")) |
+			x&y&mst("zs") |
+			y&mst("ufde") |
+			mst("x") |
+			(x|y)&mst("ghij") |
+			x&y&mst("k")
+	case OrCFragment:
+		t = (y & mst("V")).when(x.Has("Bdu")) |
+			(x & mst("o")).when(y.Has("z")) |
+			(x & y & mst("m")).when(x.Has("e") && (x|y).Has("s")) |
+			x&y&mst("zs") |
+			mst("fx") |
+			(x|y)&mst("ghij") |
+			x&y&mst("k")
+	case OrIFragment:
+		t = x&y&mst("VBKufs") |
+			mst("o").when((x & y).Has("z")) |
+			((x | y) & mst("e")).when((x | y).Has("f")) |
+			(x & y & mst("m")).when((x | y).Has("s")) |
+			(x|y)&mst("d") |
+			mst("x") |
+			(x|y)&mst("ghij") |
+			x&y&mst("k")
+	case AndOrFragment:
+		t = (y & z & mst("BKV")).when(x.Has("Bdu")) |
+			x&y&z&mst("z") |
+			((x | (y & z)) & mst("o")).when((x | (y & z)).Has("z")) |
+			y&z&mst("u") |
+			(z & mst("f")).when(x.Has("s") || y.Has("f")) |
+			z&mst("d") |
+			(z & mst("e")).when(x.Has("s") || y.Has("f")) |
+			(x & y & z & mst("m")).when(x.Has("e") && (x|y|z).Has("s")) |
+			z&(x|y)&mst("s") |
+			mst("x") |
+			(x|y|z)&mst("ghij") |
+			mst("k").when((x&y&z).Has("k") && !mixesTimeLocks(x, y))
+	case MultiFragment:
+		t = mst("Bnudemsk")
+	case MultiAFragment:
+		t = mst("Budemsk")
+	case ThreshFragment:
+		t = m.computeThreshType()
+	}
+	return sanitizeMiniscriptType(t)
+}
+
+func (m *Miniscript) computeThreshType() MiniscriptType {
+	allE, allM := true, true
+	args, numS := 0, 0
+	timeLocks := mst("k")
+	for i, sub := range m.Subs {
+		t := sub.Type
+		/* The first subexpression is Bdu and the next ones Wdu */
+		if i == 0 && !t.Has("Bdu") || i > 0 && !t.Has("Wdu") {
+			return 0
+		}
+		allE = allE && t.Has("e")
+		allM = allM && t.Has("m")
+		if t.Has("s") {
+			numS++
+		}
+		if !t.Has("z") {
+			if t.Has("o") {
+				args++
+			} else {
+				args += 2
+			}
+		}
+		/* Satisfying more than one subexpression must not mix time lock types */
+		timeLocks = (timeLocks|t)&mst("ghij") |
+			mst("k").when((timeLocks&t).Has("k") && (m.K <= 1 || !mixesTimeLocks(timeLocks, t)))
+	}
+
+	n := len(m.Subs)
+	k := int(m.K)
+	return mst("Bdu") |
+		mst("z").when(args == 0) |
+		mst("o").when(args == 1) |
+		mst("e").when(allE && numS == n) |
+		mst("m").when(allE && allM && numS >= n-k) |
+		mst("s").when(numS >= n-k+1) |
+		timeLocks
+}
+
+/* sanitizeMiniscriptType returns the empty type unless exactly one of the basic types B, V, K and W is set */
+func sanitizeMiniscriptType(t MiniscriptType) MiniscriptType {
+	basic := 0
+	for _, letter := range "BVKW" {
+		if t.Has(string(letter)) {
+			basic++
+		}
+	}
+	if basic != 1 {
+		return 0
+	}
+	return t
+}
+
+/* pushSize returns the size of the push of a number */
+func pushSize(n int) int {
+	return len(Script{}.PushData(scriptNum(n).bytes()))
+}
+
+func (m *Miniscript) computeScriptSize() int {
+	subSize := 0
+	for _, sub := range m.Subs {
+		subSize += sub.scriptSize
+	}
+
+	switch m.Fragment {
+	case Just0Fragment, Just1Fragment:
+		return 1
+	case PKKFragment:
+		if m.Context == TapscriptMiniscript {
+			return 33
+		}
+		return 34
+	case PKHFragment:
+		return 3 + 21
+	case OlderFragment, AfterFragment:
+		return 1 + pushSize(int(m.K))
+	case SHA256Fragment, Hash256Fragment:
+		return 4 + 2 + 33
+	case RIPEMD160Fragment, Hash160Fragment:
+		return 4 + 2 + 21
+	case MultiFragment:
+		return 1 + pushSize(len(m.Keys)) + pushSize(int(m.K)) + 34*len(m.Keys)
+	case MultiAFragment:
+		return (1+32+1)*len(m.Keys) + pushSize(int(m.K)) + 1
+	case AndVFragment:
+		return subSize
+	case WrapVFragment:
+		/* The VERIFY is merged with the last opcode of the subexpression when it has one */
+		if m.Subs[0].Type.Has("x") {
+			return subSize + 1
+		}
+		return subSize
+	case WrapSFragment, WrapCFragment, WrapNFragment, AndBFragment, OrBFragment:
+		return subSize + 1
+	case WrapAFragment, OrCFragment:
+		return subSize + 2
+	case WrapDFragment, OrDFragment, OrIFragment, AndOrFragment:
+		return subSize + 3
+	case WrapJFragment:
+		return subSize + 4
+	case ThreshFragment:
+		return subSize + len(m.Subs) + pushSize(int(m.K))
+	}
+	return 0
+}
+
+func (m *Miniscript) computeOps() miniscriptOps {
+	subs := m.Subs
+	switch m.Fragment {
+	case Just1Fragment:
+		return miniscriptOps{0, newMaxInt(0), maxInt{}}
+	case Just0Fragment:
+		return miniscriptOps{0, maxInt{}, newMaxInt(0)}
+	case PKKFragment:
+		return miniscriptOps{0, newMaxInt(0), newMaxInt(0)}
+	case PKHFragment:
+		return miniscriptOps{3, newMaxInt(0), newMaxInt(0)}
+	case OlderFragment, AfterFragment:
+		return miniscriptOps{1, newMaxInt(0), maxInt{}}
+	case SHA256Fragment, RIPEMD160Fragment, Hash256Fragment, Hash160Fragment:
+		return miniscriptOps{4, newMaxInt(0), maxInt{}}
+	case AndVFragment:
+		return miniscriptOps{subs[0].ops.count + subs[1].ops.count, subs[0].ops.sat.add(subs[1].ops.sat), maxInt{}}
+	case AndBFragment:
+		return miniscriptOps{
+			1 + subs[0].ops.count + subs[1].ops.count,
+			subs[0].ops.sat.add(subs[1].ops.sat),
+			subs[0].ops.dsat.add(subs[1].ops.dsat),
+		}
+	case OrBFragment:
+		return miniscriptOps{
+			1 + subs[0].ops.count + subs[1].ops.count,
+			subs[0].ops.sat.add(subs[1].ops.dsat).or(subs[1].ops.sat.add(subs[0].ops.dsat)),
+			subs[0].ops.dsat.add(subs[1].ops.dsat),
+		}
+	case OrDFragment:
+		return miniscriptOps{
+			3 + subs[0].ops.count + subs[1].ops.count,
+			subs[0].ops.sat.or(subs[1].ops.sat.add(subs[0].ops.dsat)),
+			subs[0].ops.dsat.add(subs[1].ops.dsat),
+		}
+	case OrCFragment:
+		return miniscriptOps{
+			2 + subs[0].ops.count + subs[1].ops.count,
+			subs[0].ops.sat.or(subs[1].ops.sat.add(subs[0].ops.dsat)),
+			maxInt{},
+		}
+	case OrIFragment:
+		return miniscriptOps{
+			3 + subs[0].ops.count + subs[1].ops.count,
+			subs[0].ops.sat.or(subs[1].ops.sat),
+			subs[0].ops.dsat.or(subs[1].ops.dsat),
+		}
+	case AndOrFragment:
+		return miniscriptOps{
+			3 + subs[0].ops.count + subs[1].ops.count + subs[2].ops.count,
+			subs[1].ops.sat.add(subs[0].ops.sat).or(subs[0].ops.dsat.add(subs[2].ops.sat)),
+			subs[0].ops.dsat.add(subs[2].ops.dsat),
+		}
+	case MultiFragment:
+		return miniscriptOps{1, newMaxInt(len(m.Keys)), newMaxInt(len(m.Keys))}
+	case MultiAFragment:
+		return miniscriptOps{len(m.Keys) + 1, newMaxInt(0), newMaxInt(0)}
+	case WrapSFragment, WrapCFragment, WrapNFragment:
+		return miniscriptOps{1 + subs[0].ops.count, subs[0].ops.sat, subs[0].ops.dsat}
+	case WrapAFragment:
+		return miniscriptOps{2 + subs[0].ops.count, subs[0].ops.sat, subs[0].ops.dsat}
+	case WrapDFragment:
+		return miniscriptOps{3 + subs[0].ops.count, subs[0].ops.sat, newMaxInt(0)}
+	case WrapJFragment:
+		return miniscriptOps{4 + subs[0].ops.count, subs[0].ops.sat, newMaxInt(0)}
+	case WrapVFragment:
+		count := subs[0].ops.count
+		if subs[0].Type.Has("x") {
+			count++
+		}
+		return miniscriptOps{count, subs[0].ops.sat, maxInt{}}
+	case ThreshFragment:
+		/* sats[j] is the most ops executed when j of the subexpressions seen so far are satisfied */
+		count := 0
+		sats := []maxInt{newMaxInt(0)}
+		for _, sub := range subs {
+			count += sub.ops.count + 1
+			next := []maxInt{sats[0].add(sub.ops.dsat)}
+			for j := 1; j < len(sats); j++ {
+				next = append(next, sats[j].add(sub.ops.dsat).or(sats[j-1].add(sub.ops.sat)))
+			}
+			sats = append(next, sats[len(sats)-1].add(sub.ops.sat))
+		}
+		if int(m.K) >= len(sats) {
+			return miniscriptOps{count, maxInt{}, sats[0]}
+		}
+		return miniscriptOps{count, sats[m.K], sats[0]}
+	}
+	return miniscriptOps{}
+}
+
+func (m *Miniscript) computeStackSize() miniscriptStackSize {
+	subs := m.Subs
+	switch m.Fragment {
+	case Just0Fragment:
+		return miniscriptStackSize{satInfo{}, satPush}
+	case Just1Fragment:
+		return miniscriptStackSize{satPush, satInfo{}}
+	case OlderFragment, AfterFragment:
+		return miniscriptStackSize{satPush.add(satNop), satInfo{}}
+	case PKKFragment:
+		return miniscriptStackSize{satPush, satPush}
+	case PKHFragment:
+		both := satDup.add(satHash, satPush, satEqualVerify)
+		return miniscriptStackSize{both, both}
+	case SHA256Fragment, RIPEMD160Fragment, Hash256Fragment, Hash160Fragment:
+		return miniscriptStackSize{satSize.add(satPush, satEqualVerify, satHash, satPush, satEqual), satInfo{}}
+	case AndOrFragment:
+		x, y, z := subs[0].stackSize, subs[1].stackSize, subs[2].stackSize
+		return miniscriptStackSize{
+			x.sat.add(satIf, y.sat).or(x.dsat.add(satIf, z.sat)),
+			x.dsat.add(satIf, z.dsat),
+		}
+	case AndVFragment:
+		return miniscriptStackSize{subs[0].stackSize.sat.add(subs[1].stackSize.sat), satInfo{}}
+	case AndBFragment:
+		x, y := subs[0].stackSize, subs[1].stackSize
+		return miniscriptStackSize{x.sat.add(y.sat, satBinaryOp), x.dsat.add(y.dsat, satBinaryOp)}
+	case OrBFragment:
+		x, y := subs[0].stackSize, subs[1].stackSize
+		return miniscriptStackSize{
+			x.sat.add(y.dsat).or(x.dsat.add(y.sat)).add(satBinaryOp),
+			x.dsat.add(y.dsat, satBinaryOp),
+		}
+	case OrCFragment:
+		x, y := subs[0].stackSize, subs[1].stackSize
+		return miniscriptStackSize{x.sat.add(satIf).or(x.dsat.add(satIf, y.sat)), satInfo{}}
+	case OrDFragment:
+		x, y := subs[0].stackSize, subs[1].stackSize
+		return miniscriptStackSize{
+			x.sat.add(satIfDupTrue, satIf).or(x.dsat.add(satIfDupFalse, satIf, y.sat)),
+			x.dsat.add(satIfDupFalse, satIf, y.dsat),
+		}
+	case OrIFragment:
+		x, y := subs[0].stackSize, subs[1].stackSize
+		return miniscriptStackSize{satIf.add(x.sat.or(y.sat)), satIf.add(x.dsat.or(y.dsat))}
+	case MultiFragment:
+		/* A dummy element and k signatures are replaced by the result, after pushing n keys, k and n */
+		both := newSatInfo(int(m.K), int(m.K)+len(m.Keys)+2)
+		return miniscriptStackSize{both, both}
+	case MultiAFragment:
+		/* n signatures or empty elements are replaced by the result, after pushing the first key */
+		both := newSatInfo(len(m.Keys)-1, len(m.Keys))
+		return miniscriptStackSize{both, both}
+	case WrapAFragment, WrapNFragment, WrapSFragment:
+		return subs[0].stackSize
+	case WrapCFragment:
+		x := subs[0].stackSize
+		return miniscriptStackSize{x.sat.add(satCheckSig), x.dsat.add(satCheckSig)}
+	case WrapDFragment:
+		return miniscriptStackSize{satDup.add(satIf, subs[0].stackSize.sat), satDup.add(satIf)}
+	case WrapVFragment:
+		return miniscriptStackSize{subs[0].stackSize.sat.add(satVerify), satInfo{}}
+	case WrapJFragment:
+		return miniscriptStackSize{
+			satSize.add(sat0NotEqual, satIf, subs[0].stackSize.sat),
+			satSize.add(sat0NotEqual, satIf),
+		}
+	case ThreshFragment:
+		/* sats[j] are the executions where j of the subexpressions seen so far are satisfied */
+		sats := []satInfo{satEmpty}
+		for i, sub := range subs {
+			add := satEmpty
+			if i > 0 {
+				add = satBinaryOp
+			}
+			next := []satInfo{sats[0].add(sub.stackSize.dsat, add)}
+			for j := 1; j < len(sats); j++ {
+				next = append(next, sats[j].add(sub.stackSize.dsat).or(sats[j-1].add(sub.stackSize.sat)).add(add))
+			}
+			sats = append(next, sats[len(sats)-1].add(sub.stackSize.sat, add))
+		}
+		sat := satInfo{}
+		if int(m.K) < len(sats) {
+			sat = sats[m.K].add(satPush, satEqual)
+		}
+		return miniscriptStackSize{sat, sats[0].add(satPush, satEqual)}
+	}
+	return miniscriptStackSize{}
+}
+
+func (m *Miniscript) computeWitnessSize() miniscriptWitnessSize {
+	sigSize, pubKeySize := 1+72, 1+33
+	if m.Context == TapscriptMiniscript {
+		sigSize, pubKeySize = 1+65, 1+32
+	}
+
+	subs := m.Subs
+	switch m.Fragment {
+	case Just0Fragment:
+		return miniscriptWitnessSize{maxInt{}, newMaxInt(0)}
+	case Just1Fragment, OlderFragment, AfterFragment:
+		return miniscriptWitnessSize{newMaxInt(0), maxInt{}}
+	case PKKFragment:
+		return miniscriptWitnessSize{newMaxInt(sigSize), newMaxInt(1)}
+	case PKHFragment:
+		return miniscriptWitnessSize{newMaxInt(sigSize + pubKeySize), newMaxInt(1 + pubKeySize)}
+	case SHA256Fragment, RIPEMD160Fragment, Hash256Fragment, Hash160Fragment:
+		return miniscriptWitnessSize{newMaxInt(1 + 32), maxInt{}}
+	case AndOrFragment:
+		return miniscriptWitnessSize{
+			subs[0].witnessSize.sat.add(subs[1].witnessSize.sat).or(subs[0].witnessSize.dsat.add(subs[2].witnessSize.sat)),
+			subs[0].witnessSize.dsat.add(subs[2].witnessSize.dsat),
+		}
+	case AndVFragment:
+		return miniscriptWitnessSize{subs[0].witnessSize.sat.add(subs[1].witnessSize.sat), maxInt{}}
+	case AndBFragment:
+		return miniscriptWitnessSize{
+			subs[0].witnessSize.sat.add(subs[1].witnessSize.sat),
+			subs[0].witnessSize.dsat.add(subs[1].witnessSize.dsat),
+		}
+	case OrBFragment:
+		return miniscriptWitnessSize{
+			subs[0].witnessSize.dsat.add(subs[1].witnessSize.sat).or(subs[0].witnessSize.sat.add(subs[1].witnessSize.dsat)),
+			subs[0].witnessSize.dsat.add(subs[1].witnessSize.dsat),
+		}
+	case OrCFragment:
+		return miniscriptWitnessSize{subs[0].witnessSize.sat.or(subs[0].witnessSize.dsat.add(subs[1].witnessSize.sat)), maxInt{}}
+	case OrDFragment:
+		return miniscriptWitnessSize{
+			subs[0].witnessSize.sat.or(subs[0].witnessSize.dsat.add(subs[1].witnessSize.sat)),
+			subs[0].witnessSize.dsat.add(subs[1].witnessSize.dsat),
+		}
+	case OrIFragment:
+		/* The branch is selected by a 1 or an empty element */
+		return miniscriptWitnessSize{
+			subs[0].witnessSize.sat.add(newMaxInt(2)).or(subs[1].witnessSize.sat.add(newMaxInt(1))),
+			subs[0].witnessSize.dsat.add(newMaxInt(2)).or(subs[1].witnessSize.dsat.add(newMaxInt(1))),
+		}
+	case MultiFragment:
+		return miniscriptWitnessSize{newMaxInt(int(m.K)*sigSize + 1), newMaxInt(int(m.K) + 1)}
+	case MultiAFragment:
+		return miniscriptWitnessSize{newMaxInt(int(m.K)*sigSize + len(m.Keys) - int(m.K)), newMaxInt(len(m.Keys))}
+	case WrapAFragment, WrapNFragment, WrapSFragment, WrapCFragment:
+		return subs[0].witnessSize
+	case WrapDFragment:
+		return miniscriptWitnessSize{newMaxInt(1 + 1).add(subs[0].witnessSize.sat), newMaxInt(1)}
+	case WrapVFragment:
+		return miniscriptWitnessSize{subs[0].witnessSize.sat, maxInt{}}
+	case WrapJFragment:
+		return miniscriptWitnessSize{subs[0].witnessSize.sat, newMaxInt(1)}
+	case ThreshFragment:
+		sats := []maxInt{newMaxInt(0)}
+		for _, sub := range subs {
+			next := []maxInt{sats[0].add(sub.witnessSize.dsat)}
+			for j := 1; j < len(sats); j++ {
+				next = append(next, sats[j].add(sub.witnessSize.dsat).or(sats[j-1].add(sub.witnessSize.sat)))
+			}
+			sats = append(next, sats[len(sats)-1].add(sub.witnessSize.sat))
+		}
+		if int(m.K) >= len(sats) {
+			return miniscriptWitnessSize{maxInt{}, sats[0]}
+		}
+		return miniscriptWitnessSize{sats[m.K], sats[0]}
+	}
+	return miniscriptWitnessSize{}
+}
+
+/* computeDuplicateKeys returns true if a key appears twice in the expression and its subexpressions */
+func (m *Miniscript) computeDuplicateKeys() bool {
+	for _, sub := range m.Subs {
+		if sub.duplicateKeys {
+			return true
+		}
+	}
+
+	seen := make(map[string]bool)
+	duplicate := false
+	var visit func(n *Miniscript)
+	visit = func(n *Miniscript) {
+		for _, key := range n.Keys {
+			s := string(m.keyBytes(key))
+			duplicate = duplicate || seen[s]
+			seen[s] = true
+		}
+		for _, sub := range n.Subs {
+			visit(sub)
+		}
+	}
+	visit(m)
+	return duplicate
+}
+
+/* keyBytes serializes a key as it is pushed in the script: compressed in P2WSH and x-only in tapscript */
+func (m *Miniscript) keyBytes(key *PublicKey) []byte {
+	if m.Context == TapscriptMiniscript {
+		return paddedBytes(key.X, 32)
+	}
+	return key.serialize(true)
+}
+
+/* keyString formats a key like keyBytes */
+func (m *Miniscript) keyString(key *PublicKey) string {
+	return hex.EncodeToString(m.keyBytes(key))
+}
+
+// ParseMiniscript parses a miniscript expression, it must be valid as a whole script (of type B)
+func ParseMiniscript(expr string, ctx MiniscriptContext) (*Miniscript, error) {
+	m, err := parseMiniscript(expr, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return m, m.checkTopLevel()
+}
+
+/* checkTopLevel returns why the miniscript is not valid as a whole script */
+func (m *Miniscript) checkTopLevel() error {
+	if m.Type == 0 {
+		return errors.New("miniscript is not well typed")
+	}
+	if !m.IsValid() {
+		return errors.New("miniscript exceeds the maximum script size")
+	}
+	if !m.IsValidTopLevel() {
+		return errors.New("miniscript must be of type B")
+	}
+	return nil
+}
+
+/* parseMiniscript parses an expression preceded by its wrappers and a colon */
+func parseMiniscript(expr string, ctx MiniscriptContext) (*Miniscript, error) {
+	colon := strings.IndexByte(expr, ':')
+	if colon <= 0 || strings.Trim(expr[0:colon], "abcdefghijklmnopqrstuvwxyz") != "" {
+		return parseMiniscriptFragment(expr, ctx)
+	}
+	if strings.Contains(expr[0:colon], "vv") {
+		return nil, errors.New("miniscript wrappers cannot contain vv")
+	}
+
+	m, err := parseMiniscriptFragment(expr[colon+1:], ctx)
+	if err != nil {
+		return nil, err
+	}
+	/* The first wrapper is the outermost */
+	for i := colon - 1; i >= 0; i-- {
+		switch expr[i] {
+		case 'a':
+			m = newMiniscript(ctx, WrapAFragment, 0, nil, nil, m)
+		case 's':
+			m = newMiniscript(ctx, WrapSFragment, 0, nil, nil, m)
+		case 'c':
+			m = newMiniscript(ctx, WrapCFragment, 0, nil, nil, m)
+		case 'd':
+			m = newMiniscript(ctx, WrapDFragment, 0, nil, nil, m)
+		case 'v':
+			m = newMiniscript(ctx, WrapVFragment, 0, nil, nil, m)
+		case 'j':
+			m = newMiniscript(ctx, WrapJFragment, 0, nil, nil, m)
+		case 'n':
+			m = newMiniscript(ctx, WrapNFragment, 0, nil, nil, m)
+		case 't':
+			/* t:X is and_v(X,1) */
+			m = newMiniscript(ctx, AndVFragment, 0, nil, nil, m, newMiniscript(ctx, Just1Fragment, 0, nil, nil))
+		case 'u':
+			/* u:X is or_i(X,0) */
+			m = newMiniscript(ctx, OrIFragment, 0, nil, nil, m, newMiniscript(ctx, Just0Fragment, 0, nil, nil))
+		case 'l':
+			/* l:X is or_i(0,X) */
+			m = newMiniscript(ctx, OrIFragment, 0, nil, nil, newMiniscript(ctx, Just0Fragment, 0, nil, nil), m)
+		default:
+			return nil, errors.New("unknown miniscript wrapper " + string(expr[i]))
+		}
+	}
+	return m, nil
+}
+
+/* parseMiniscriptFragment parses an expression without wrappers */
+func parseMiniscriptFragment(expr string, ctx MiniscriptContext) (*Miniscript, error) {
+	switch expr {
+	case "0":
+		return newMiniscript(ctx, Just0Fragment, 0, nil, nil), nil
+	case "1":
+		return newMiniscript(ctx, Just1Fragment, 0, nil, nil), nil
+	}
+
+	name, args, ok := splitExpression(expr)
+	if !ok {
+		return nil, errors.New("invalid miniscript expression " + expr)
+	}
+
+	switch name {
+	case "pk", "pkh", "pk_k", "pk_h":
+		if len(args) != 1 {
+			return nil, errors.New(name + " takes a single key")
+		}
+		key, err := parseMiniscriptKey(args[0], ctx)
+		if err != nil {
+			return nil, err
+		}
+		fragment := PKKFragment
+		if name == "pkh" || name == "pk_h" {
+			fragment = PKHFragment
+		}
+		m := newMiniscript(ctx, fragment, 0, []*PublicKey{key}, nil)
+		/* pk(K) is c:pk_k(K) and pkh(K) is c:pk_h(K) */
+		if name == "pk" || name == "pkh" {
+			m = newMiniscript(ctx, WrapCFragment, 0, nil, nil, m)
+		}
+		return m, nil
+	case "older", "after":
+		if len(args) != 1 {
+			return nil, errors.New(name + " takes a single lock time")
+		}
+		k, err := parseDescriptorNumber(args[0])
+		if err != nil || k < 1 || k >= 0x80000000 {
+			return nil, errors.New("invalid lock time " + args[0])
+		}
+		return newMiniscript(ctx, miniscriptFragmentNames[name], k, nil, nil), nil
+	case "sha256", "hash256", "ripemd160", "hash160":
+		size := 32
+		if name == "ripemd160" || name == "hash160" {
+			size = 20
+		}
+		data, err := hex.DecodeString(args[0])
+		if len(args) != 1 || err != nil || len(data) != size {
+			return nil, errors.New(name + " takes a " + strconv.Itoa(size) + " bytes hash")
+		}
+		return newMiniscript(ctx, miniscriptFragmentNames[name], 0, nil, data), nil
+	case "multi", "multi_a":
+		return parseMiniscriptMulti(name, args, ctx)
+	case "thresh":
+		if len(args) < 2 {
+			return nil, errors.New("thresh takes a threshold and subexpressions")
+		}
+		k, err := parseDescriptorNumber(args[0])
+		if err != nil || k < 1 || int(k) > len(args)-1 {
+			return nil, errors.New("invalid threshold " + args[0])
+		}
+		subs, err := parseMiniscripts(args[1:], ctx)
+		if err != nil {
+			return nil, err
+		}
+		return newMiniscript(ctx, ThreshFragment, k, nil, nil, subs...), nil
+	case "andor":
+		if len(args) != 3 {
+			return nil, errors.New("andor takes 3 subexpressions")
+		}
+		subs, err := parseMiniscripts(args, ctx)
+		if err != nil {
+			return nil, err
+		}
+		return newMiniscript(ctx, AndOrFragment, 0, nil, nil, subs...), nil
+	case "and_n":
+		if len(args) != 2 {
+			return nil, errors.New("and_n takes 2 subexpressions")
+		}
+		subs, err := parseMiniscripts(args, ctx)
+		if err != nil {
+			return nil, err
+		}
+		/* and_n(X,Y) is andor(X,Y,0) */
+		return newMiniscript(ctx, AndOrFragment, 0, nil, nil, subs[0], subs[1], newMiniscript(ctx, Just0Fragment, 0, nil, nil)), nil
+	case "and_v", "and_b", "or_b", "or_c", "or_d", "or_i":
+		if len(args) != 2 {
+			return nil, errors.New(name + " takes 2 subexpressions")
+		}
+		subs, err := parseMiniscripts(args, ctx)
+		if err != nil {
+			return nil, err
+		}
+		return newMiniscript(ctx, miniscriptFragmentNames[name], 0, nil, nil, subs...), nil
+	}
+	return nil, errors.New("unknown miniscript fragment " + name)
+}
+
+func parseMiniscripts(exprs []string, ctx MiniscriptContext) ([]*Miniscript, error) {
+	subs := make([]*Miniscript, len(exprs))
+	for i, expr := range exprs {
+		var err error
+		subs[i], err = parseMiniscript(expr, ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return subs, nil
+}
+
+/* parseMiniscriptMulti parses multi in P2WSH and multi_a in tapscript */
+func parseMiniscriptMulti(name string, args []string, ctx MiniscriptContext) (*Miniscript, error) {
+	fragment, maxKeys := MultiFragment, MaxPubKeysPerMultiSig
+	if name == "multi_a" {
+		fragment, maxKeys = MultiAFragment, maxPubKeysPerMultiA
+	}
+	if (fragment == MultiAFragment) != (ctx == TapscriptMiniscript) {
+		return nil, errors.New(name + " is not available in this context")
+	}
+	if len(args) < 2 || len(args)-1 > maxKeys {
+		return nil, errors.New(name + " takes a threshold and 1 to " + strconv.Itoa(maxKeys) + " keys")
+	}
+
+	k, err := parseDescriptorNumber(args[0])
+	if err != nil || k < 1 || int(k) > len(args)-1 {
+		return nil, errors.New("invalid threshold " + args[0])
+	}
+	keys := make([]*PublicKey, len(args)-1)
+	for i, arg := range args[1:] {
+		keys[i], err = parseMiniscriptKey(arg, ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return newMiniscript(ctx, fragment, k, keys, nil), nil
+}
+
+/* parseMiniscriptKey parses a compressed key, or a x-only key in tapscript */
+func parseMiniscriptKey(s string, ctx MiniscriptContext) (*PublicKey, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("invalid miniscript key " + s)
+	}
+	if ctx == TapscriptMiniscript && len(b) == 32 {
+		return PublicFromXOnly(s, MainNetwork)
+	}
+	if len(b) != 33 || (b[0] != 0x02 && b[0] != 0x03) {
+		return nil, errors.New("miniscript keys must be compressed")
+	}
+	return publicFromBytes(b, MainNetwork)
+}
+
+// String returns the miniscript expression
+func (m *Miniscript) String() string {
+	return m.expression(false)
+}
+
+/* expression formats the miniscript, wrapped is true if a wrapper letter precedes it */
+func (m *Miniscript) expression(wrapped bool) string {
+	prefix := ""
+	if wrapped {
+		prefix = ":"
+	}
+
+	switch m.Fragment {
+	case WrapAFragment:
+		return "a" + m.Subs[0].expression(true)
+	case WrapSFragment:
+		return "s" + m.Subs[0].expression(true)
+	case WrapCFragment:
+		switch m.Subs[0].Fragment {
+		case PKKFragment:
+			return prefix + "pk(" + m.keyString(m.Subs[0].Keys[0]) + ")"
+		case PKHFragment:
+			return prefix + "pkh(" + m.keyString(m.Subs[0].Keys[0]) + ")"
+		}
+		return "c" + m.Subs[0].expression(true)
+	case WrapDFragment:
+		return "d" + m.Subs[0].expression(true)
+	case WrapVFragment:
+		return "v" + m.Subs[0].expression(true)
+	case WrapJFragment:
+		return "j" + m.Subs[0].expression(true)
+	case WrapNFragment:
+		return "n" + m.Subs[0].expression(true)
+	case AndVFragment:
+		if m.Subs[1].Fragment == Just1Fragment {
+			return "t" + m.Subs[0].expression(true)
+		}
+	case OrIFragment:
+		if m.Subs[0].Fragment == Just0Fragment {
+			return "l" + m.Subs[1].expression(true)
+		}
+		if m.Subs[1].Fragment == Just0Fragment {
+			return "u" + m.Subs[0].expression(true)
+		}
+	}
+
+	var name string
+	var args []string
+	switch m.Fragment {
+	case Just0Fragment:
+		return prefix + "0"
+	case Just1Fragment:
+		return prefix + "1"
+	case OlderFragment, AfterFragment:
+		args = []string{strconv.FormatUint(uint64(m.K), 10)}
+	case SHA256Fragment, Hash256Fragment, RIPEMD160Fragment, Hash160Fragment:
+		args = []string{hex.EncodeToString(m.Data)}
+	case AndOrFragment:
+		/* and_n(X,Y) is andor(X,Y,0) */
+		if m.Subs[2].Fragment == Just0Fragment {
+			name = "and_n"
+			args = []string{m.Subs[0].expression(false), m.Subs[1].expression(false)}
+		}
+	case ThreshFragment, MultiFragment, MultiAFragment:
+		args = []string{strconv.FormatUint(uint64(m.K), 10)}
+	}
+	for _, key := range m.Keys {
+		args = append(args, m.keyString(key))
+	}
+	if name == "" {
+		for _, sub := range m.Subs {
+			args = append(args, sub.expression(false))
+		}
+		for fragmentName, fragment := range miniscriptFragmentNames {
+			if fragment == m.Fragment {
+				name = fragmentName
+			}
+		}
+	}
+	return prefix + name + "(" + strings.Join(args, ",") + ")"
+}
+
+// Script compiles the miniscript
+func (m *Miniscript) Script() []byte {
+	return m.appendScript(Script{}, false)
+}
+
+/* appendScript appends the script of the miniscript, verify is true if it is followed by OP_VERIFY which can then be merged with its last opcode */
+func (m *Miniscript) appendScript(script Script, verify bool) Script {
+	/* verifyOpcode returns the VERIFY variant of opcode if verify is set */
+	verifyOpcode := func(opcode byte, verifyVariant byte) byte {
+		if verify {
+			return verifyVariant
+		}
+		return opcode
+	}
+
+	switch m.Fragment {
+	case Just0Fragment:
+		return script.AddOpcode(Op0)
+	case Just1Fragment:
+		return script.AddOpcode(Op1)
+	case PKKFragment:
+		return script.PushData(m.keyBytes(m.Keys[0]))
+	case PKHFragment:
+		return script.AddOpcode(OpDup).AddOpcode(OpHash160).PushData(hash160(m.keyBytes(m.Keys[0]))).AddOpcode(OpEqualVerify)
+	case OlderFragment:
+		return script.PushData(scriptNum(m.K).bytes()).AddOpcode(OpCheckSequenceVerify)
+	case AfterFragment:
+		return script.PushData(scriptNum(m.K).bytes()).AddOpcode(OpCheckLockTimeVerify)
+	case SHA256Fragment, Hash256Fragment, RIPEMD160Fragment, Hash160Fragment:
+		opcodes := map[MiniscriptFragment]byte{
+			SHA256Fragment:    OpSha256,
+			Hash256Fragment:   OpHash256,
+			RIPEMD160Fragment: OpRipemd160,
+			Hash160Fragment:   OpHash160,
+		}
+		script = script.AddOpcode(OpSize).PushData(scriptNum(32).bytes()).AddOpcode(OpEqualVerify)
+		return script.AddOpcode(opcodes[m.Fragment]).PushData(m.Data).AddOpcode(verifyOpcode(OpEqual, OpEqualVerify))
+	case WrapAFragment:
+		return m.Subs[0].appendScript(script.AddOpcode(OpToAltStack), false).AddOpcode(OpFromAltStack)
+	case WrapSFragment:
+		return m.Subs[0].appendScript(script.AddOpcode(OpSwap), verify)
+	case WrapCFragment:
+		return m.Subs[0].appendScript(script, false).AddOpcode(verifyOpcode(OpCheckSig, OpCheckSigVerify))
+	case WrapDFragment:
+		return m.Subs[0].appendScript(script.AddOpcode(OpDup).AddOpcode(OpIf), false).AddOpcode(OpEndIf)
+	case WrapVFragment:
+		script = m.Subs[0].appendScript(script, true)
+		if m.Subs[0].Type.Has("x") {
+			script = script.AddOpcode(OpVerify)
+		}
+		return script
+	case WrapJFragment:
+		script = script.AddOpcode(OpSize).AddOpcode(Op0NotEqual).AddOpcode(OpIf)
+		return m.Subs[0].appendScript(script, false).AddOpcode(OpEndIf)
+	case WrapNFragment:
+		return m.Subs[0].appendScript(script, false).AddOpcode(Op0NotEqual)
+	case AndVFragment:
+		return m.Subs[1].appendScript(m.Subs[0].appendScript(script, false), verify)
+	case AndBFragment:
+		return m.Subs[1].appendScript(m.Subs[0].appendScript(script, false), false).AddOpcode(OpBoolAnd)
+	case OrBFragment:
+		return m.Subs[1].appendScript(m.Subs[0].appendScript(script, false), false).AddOpcode(OpBoolOr)
+	case OrDFragment:
+		script = m.Subs[0].appendScript(script, false).AddOpcode(OpIfDup).AddOpcode(OpNotIf)
+		return m.Subs[1].appendScript(script, false).AddOpcode(OpEndIf)
+	case OrCFragment:
+		script = m.Subs[0].appendScript(script, false).AddOpcode(OpNotIf)
+		return m.Subs[1].appendScript(script, false).AddOpcode(OpEndIf)
+	case OrIFragment:
+		script = m.Subs[0].appendScript(script.AddOpcode(OpIf), false).AddOpcode(OpElse)
+		return m.Subs[1].appendScript(script, false).AddOpcode(OpEndIf)
+	case AndOrFragment:
+		script = m.Subs[0].appendScript(script, false).AddOpcode(OpNotIf)
+		script = m.Subs[2].appendScript(script, false).AddOpcode(OpElse)
+		return m.Subs[1].appendScript(script, false).AddOpcode(OpEndIf)
+	case MultiFragment:
+		script = script.PushData(scriptNum(m.K).bytes())
+		for _, key := range m.Keys {
+			script = script.PushData(m.keyBytes(key))
+		}
+		script = script.PushData(scriptNum(len(m.Keys)).bytes())
+		return script.AddOpcode(verifyOpcode(OpCheckMultiSig, OpCheckMultiSigVerify))
+	case MultiAFragment:
+		for i, key := range m.Keys {
+			script = script.PushData(m.keyBytes(key))
+			if i == 0 {
+				script = script.AddOpcode(OpCheckSig)
+			} else {
+				script = script.AddOpcode(OpCheckSigAdd)
+			}
+		}
+		return script.PushData(scriptNum(m.K).bytes()).AddOpcode(verifyOpcode(OpNumEqual, OpNumEqualVerify))
+	case ThreshFragment:
+		for i, sub := range m.Subs {
+			script = sub.appendScript(script, false)
+			if i > 0 {
+				script = script.AddOpcode(OpAdd)
+			}
+		}
+		return script.PushData(scriptNum(m.K).bytes()).AddOpcode(verifyOpcode(OpEqual, OpEqualVerify))
+	}
+	return script
+}
+
+/* miniscriptOp is an opcode of a decoded script with the data it pushes, OP_1 to OP_16 push their number */
+type miniscriptOp struct {
+	opcode byte
+	data   []byte
+}
+
+/* decomposeMiniscript splits a script in reverse order into opcodes, the VERIFY variants are split in two opcodes */
+func decomposeMiniscript(script []byte) ([]miniscriptOp, bool) {
+	var ops []miniscriptOp
+	for pc := 0; pc < len(script); {
+		opcode, data, next, ok := getScriptOp(script, pc)
+		if !ok {
+			return nil, false
+		}
+
+		switch {
+		case opcode >= Op1 && opcode <= Op16:
+			data = []byte{opcode - Op1 + 1}
+		case opcode == OpCheckSigVerify, opcode == OpCheckMultiSigVerify, opcode == OpEqualVerify, opcode == OpNumEqualVerify:
+			/* Each VERIFY variant follows its base opcode in the opcode table */
+			ops = append(ops, miniscriptOp{opcode: opcode - 1})
+			opcode = OpVerify
+		case opcode <= OpPushData4:
+			if !checkMinimalPush(data, opcode) {
+				return nil, false
+			}
+		case next < len(script) && script[next] == OpVerify &&
+			(opcode == OpCheckSig || opcode == OpCheckMultiSig || opcode == OpEqual || opcode == OpNumEqual):
+			/* The VERIFY variant must be used instead */
+			return nil, false
+		}
+		ops = append(ops, miniscriptOp{opcode: opcode, data: data})
+		pc = next
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops, true
+}
+
+/* number reads a minimally pushed number of at most 4 bytes */
+func (op miniscriptOp) number() (int64, bool) {
+	if op.opcode == Op0 {
+		return 0, true
+	}
+	if len(op.data) == 0 || (op.opcode <= OpPushData4 && !checkMinimalPush(op.data, op.opcode)) {
+		return 0, false
+	}
+	n, err := parseScriptNum(op.data, true, 4)
+	if err != nil {
+		return 0, false
+	}
+	return int64(n), true
+}
+
+/* miniscriptDecodeState is what the decoder expects next while reading a script backwards */
+type miniscriptDecodeState int
+
+const (
+	/* decodeSingleBKV is an expression of type B, K or V which is not and_v */
+	decodeSingleBKV miniscriptDecodeState = iota
+	/* decodeBKV is an expression of type B, K or V, and_v included */
+	decodeBKV
+	/* decodeW is an expression of type W, wrapped with a: or s: */
+	decodeW
+	decodeMaybeAndV
+	decodeSwap
+	decodeAlt
+	decodeCheck
+	decodeDupIf
+	decodeVerify
+	decodeNonZero
+	decodeZeroNotEqual
+	decodeAndV
+	decodeAndB
+	decodeAndOr
+	decodeOrB
+	decodeOrC
+	decodeOrD
+	/* decodeThreshW reads the next subexpression of thresh, n subexpressions were read for the threshold k */
+	decodeThreshW
+	decodeThreshE
+	/* decodeEndIf is inside OP_IF structures: d:, j:, or_c, or_d, or_i and andor */
+	decodeEndIf
+	decodeEndIfNotIf
+	decodeEndIfElse
+)
+
+type miniscriptDecodeStep struct {
+	state miniscriptDecodeState
+	n     int64
+	k     int64
+}
+
+// DecodeMiniscript decodes a script compiled from a miniscript, keys are the candidates for the key hashes of pk_h
+func DecodeMiniscript(script []byte, ctx MiniscriptContext, keys []*PublicKey) (*Miniscript, error) {
+	maxSize := maxStandardP2WSHScriptSize
+	if ctx == TapscriptMiniscript {
+		maxSize = maxTapMiniscriptSize
+	}
+	if len(script) > maxSize {
+		return nil, errors.New("miniscript exceeds the maximum script size")
+	}
+
+	ops, ok := decomposeMiniscript(script)
+	if !ok {
+		return nil, errors.New("script is not a minimally encoded miniscript")
+	}
+
+	d := &miniscriptDecoder{ctx: ctx, ops: ops, keyHashes: make(map[string]*PublicKey)}
+	for _, key := range keys {
+		d.keyHashes[string(hash160(d.keyBytes(key)))] = key
+	}
+	m, ok := d.decode()
+	if !ok || d.pos != len(ops) {
+		return nil, errors.New("script is not a miniscript")
+	}
+	return m, m.checkTopLevel()
+}
+
+/* miniscriptDecoder reads the reversed opcodes of a script from pos */
+type miniscriptDecoder struct {
+	ctx       MiniscriptContext
+	ops       []miniscriptOp
+	pos       int
+	keyHashes map[string]*PublicKey
+
+	steps       []miniscriptDecodeStep
+	constructed []*Miniscript
+}
+
+func (d *miniscriptDecoder) keyBytes(key *PublicKey) []byte {
+	return (&Miniscript{Context: d.ctx}).keyBytes(key)
+}
+
+/* left returns the number of opcodes not read yet */
+func (d *miniscriptDecoder) left() int {
+	return len(d.ops) - d.pos
+}
+
+/* op returns the i-th opcode from the current position */
+func (d *miniscriptDecoder) op(i int) miniscriptOp {
+	return d.ops[d.pos+i]
+}
+
+func (d *miniscriptDecoder) push(states ...miniscriptDecodeState) {
+	for _, state := range states {
+		d.steps = append(d.steps, miniscriptDecodeStep{state: state})
+	}
+}
+
+/* build replaces the last constructed expressions with the fragment, they are its subexpressions in reverse order */
+func (d *miniscriptDecoder) build(fragment MiniscriptFragment, k uint32, count int) bool {
+	if len(d.constructed) < count {
+		return false
+	}
+	subs := make([]*Miniscript, count)
+	for i := range subs {
+		subs[i] = d.constructed[len(d.constructed)-1-i]
+	}
+	d.constructed = append(d.constructed[:len(d.constructed)-count], newMiniscript(d.ctx, fragment, k, nil, nil, subs...))
+	return true
+}
+
+func (d *miniscriptDecoder) decode() (*Miniscript, bool) {
+	d.push(decodeBKV)
+	for len(d.steps) > 0 {
+		/* Stop as soon as an invalid expression is built */
+		if len(d.constructed) > 0 && !d.constructed[len(d.constructed)-1].IsValid() {
+			return nil, false
+		}
+
+		step := d.steps[len(d.steps)-1]
+		d.steps = d.steps[:len(d.steps)-1]
+
+		ok := true
+		switch step.state {
+		case decodeSingleBKV:
+			ok = d.decodeSingle()
+		case decodeBKV:
+			d.push(decodeMaybeAndV, decodeSingleBKV)
+		case decodeW:
+			if d.left() < 1 {
+				return nil, false
+			}
+			if d.op(0).opcode == OpFromAltStack {
+				d.pos++
+				d.push(decodeAlt)
+			} else {
+				d.push(decodeSwap)
+			}
+			d.push(decodeBKV)
+		case decodeMaybeAndV:
+			/* These opcodes cannot end an expression */
+			if d.left() > 0 {
+				switch d.op(0).opcode {
+				case OpIf, OpElse, OpNotIf, OpToAltStack, OpSwap:
+				default:
+					d.push(decodeAndV, decodeBKV)
+				}
+			}
+		case decodeSwap, decodeAlt:
+			opcode, fragment := OpSwap, WrapSFragment
+			if step.state == decodeAlt {
+				opcode, fragment = OpToAltStack, WrapAFragment
+			}
+			if d.left() < 1 || d.op(0).opcode != opcode {
+				return nil, false
+			}
+			d.pos++
+			ok = d.build(fragment, 0, 1)
+		case decodeCheck:
+			ok = d.build(WrapCFragment, 0, 1)
+		case decodeDupIf:
+			ok = d.build(WrapDFragment, 0, 1)
+		case decodeVerify:
+			ok = d.build(WrapVFragment, 0, 1)
+		case decodeNonZero:
+			ok = d.build(WrapJFragment, 0, 1)
+		case decodeZeroNotEqual:
+			ok = d.build(WrapNFragment, 0, 1)
+		case decodeAndV:
+			ok = d.build(AndVFragment, 0, 2)
+		case decodeAndB:
+			ok = d.build(AndBFragment, 0, 2)
+		case decodeOrB:
+			ok = d.build(OrBFragment, 0, 2)
+		case decodeOrC:
+			ok = d.build(OrCFragment, 0, 2)
+		case decodeOrD:
+			ok = d.build(OrDFragment, 0, 2)
+		case decodeAndOr:
+			/* The script of andor(X,Y,Z) is [X] NOTIF [Z] ELSE [Y] ENDIF */
+			if len(d.constructed) < 3 {
+				return nil, false
+			}
+			c := d.constructed
+			x, z, y := c[len(c)-1], c[len(c)-2], c[len(c)-3]
+			d.constructed = append(c[:len(c)-3], newMiniscript(d.ctx, AndOrFragment, 0, nil, nil, x, y, z))
+		case decodeThreshW:
+			if d.left() < 1 {
+				return nil, false
+			}
+			if d.op(0).opcode == OpAdd {
+				d.pos++
+				d.steps = append(d.steps, miniscriptDecodeStep{decodeThreshW, step.n + 1, step.k})
+				d.push(decodeW)
+			} else {
+				/* The first subexpression is d, so it cannot be and_v */
+				d.steps = append(d.steps, miniscriptDecodeStep{decodeThreshE, step.n + 1, step.k})
+				d.push(decodeSingleBKV)
+			}
+		case decodeThreshE:
+			if step.k < 1 || step.k > step.n {
+				return nil, false
+			}
+			ok = d.build(ThreshFragment, uint32(step.k), int(step.n))
+		case decodeEndIf:
+			ok = d.decodeEndIf()
+		case decodeEndIfNotIf:
+			if d.left() < 1 {
+				return nil, false
+			}
+			if d.op(0).opcode == OpIfDup {
+				d.pos++
+				d.push(decodeOrD)
+			} else {
+				d.push(decodeOrC)
+			}
+			/* X of or_c and or_d is d, so it cannot be and_v */
+			d.push(decodeSingleBKV)
+		case decodeEndIfElse:
+			if d.left() < 1 {
+				return nil, false
+			}
+			switch d.op(0).opcode {
+			case OpIf:
+				d.pos++
+				ok = d.build(OrIFragment, 0, 2)
+			case OpNotIf:
+				d.pos++
+				/* X of andor is d, so it cannot be and_v */
+				d.push(decodeAndOr, decodeSingleBKV)
+			default:
+				return nil, false
+			}
+		}
+		if !ok {
+			return nil, false
+		}
+	}
+
+	if len(d.constructed) != 1 {
+		return nil, false
+	}
+	return d.constructed[0], true
+}
+
+/* decodeEndIf decides which OP_IF structure ends with the OP_ENDIF which was read */
+func (d *miniscriptDecoder) decodeEndIf() bool {
+	if d.left() < 1 {
+		return false
+	}
+	switch d.op(0).opcode {
+	case OpElse:
+		/* or_i or andor */
+		d.pos++
+		d.push(decodeEndIfElse, decodeBKV)
+	case OpIf:
+		/* d: or j: */
+		if d.left() >= 2 && d.op(1).opcode == OpDup {
+			d.pos += 2
+			d.push(decodeDupIf)
+		} else if d.left() >= 3 && d.op(1).opcode == Op0NotEqual && d.op(2).opcode == OpSize {
+			d.pos += 3
+			d.push(decodeNonZero)
+		} else {
+			return false
+		}
+	case OpNotIf:
+		/* or_c or or_d */
+		d.pos++
+		d.push(decodeEndIfNotIf)
+	default:
+		return false
+	}
+	return true
+}
+
+/* decodeSingle reads an expression which is not and_v, or pushes the steps to read it */
+func (d *miniscriptDecoder) decodeSingle() bool {
+	if d.left() < 1 {
+		return false
+	}
+	op := d.op(0)
+
+	constant := func(fragment MiniscriptFragment, k uint32, keys []*PublicKey, data []byte, size int) bool {
+		d.pos += size
+		d.constructed = append(d.constructed, newMiniscript(d.ctx, fragment, k, keys, data))
+		return true
+	}
+
+	switch {
+	case op.opcode == Op1:
+		return constant(Just1Fragment, 0, nil, nil, 1)
+	case op.opcode == Op0:
+		return constant(Just0Fragment, 0, nil, nil, 1)
+	case len(op.data) == 33 || len(op.data) == 32:
+		key, ok := d.decodeKey(op.data)
+		if !ok {
+			return false
+		}
+		return constant(PKKFragment, 0, []*PublicKey{key}, nil, 1)
+	case d.left() >= 5 && op.opcode == OpVerify && d.op(1).opcode == OpEqual && d.op(3).opcode == OpHash160 &&
+		d.op(4).opcode == OpDup && len(d.op(2).data) == 20:
+		key, ok := d.keyHashes[string(d.op(2).data)]
+		if !ok {
+			return false
+		}
+		return constant(PKHFragment, 0, []*PublicKey{key}, nil, 5)
+	}
+
+	if d.left() >= 2 && (op.opcode == OpCheckSequenceVerify || op.opcode == OpCheckLockTimeVerify) {
+		if n, ok := d.op(1).number(); ok {
+			if n < 1 || n > 0x7fffffff {
+				return false
+			}
+			fragment := OlderFragment
+			if op.opcode == OpCheckLockTimeVerify {
+				fragment = AfterFragment
+			}
+			return constant(fragment, uint32(n), nil, nil, 2)
+		}
+	}
+
+	if d.left() >= 7 && op.opcode == OpEqual && d.op(3).opcode == OpVerify && d.op(4).opcode == OpEqual && d.op(6).opcode == OpSize {
+		if n, ok := d.op(5).number(); ok && n == 32 {
+			hash := d.op(1).data
+			switch {
+			case d.op(2).opcode == OpSha256 && len(hash) == 32:
+				return constant(SHA256Fragment, 0, nil, hash, 7)
+			case d.op(2).opcode == OpRipemd160 && len(hash) == 20:
+				return constant(RIPEMD160Fragment, 0, nil, hash, 7)
+			case d.op(2).opcode == OpHash256 && len(hash) == 32:
+				return constant(Hash256Fragment, 0, nil, hash, 7)
+			case d.op(2).opcode == OpHash160 && len(hash) == 20:
+				return constant(Hash160Fragment, 0, nil, hash, 7)
+			}
+		}
+	}
+
+	if d.left() >= 3 && op.opcode == OpCheckMultiSig {
+		return d.decodeMulti()
+	}
+	if d.left() >= 4 && op.opcode == OpNumEqual {
+		return d.decodeMultiA()
+	}
+
+	/* The wrappers commute with and_v: c:and_v(X,Y) is the same script as and_v(X,c:Y) */
+	switch op.opcode {
+	case OpCheckSig:
+		d.pos++
+		d.push(decodeCheck, decodeSingleBKV)
+		return true
+	case OpVerify:
+		d.pos++
+		d.push(decodeVerify, decodeSingleBKV)
+		return true
+	case Op0NotEqual:
+		d.pos++
+		d.push(decodeZeroNotEqual, decodeSingleBKV)
+		return true
+	}
+
+	if d.left() >= 3 && op.opcode == OpEqual {
+		if k, ok := d.op(1).number(); ok {
+			if k < 1 {
+				return false
+			}
+			d.pos += 2
+			d.steps = append(d.steps, miniscriptDecodeStep{decodeThreshW, 0, k})
+			return true
+		}
+	}
+
+	/* and_v stays outside of and_b and or_b, which are only valid that way */
+	switch op.opcode {
+	case OpEndIf:
+		d.pos++
+		d.push(decodeEndIf, decodeBKV)
+		return true
+	case OpBoolAnd:
+		d.pos++
+		d.push(decodeAndB, decodeSingleBKV, decodeW)
+		return true
+	case OpBoolOr:
+		d.pos++
+		d.push(decodeOrB, decodeSingleBKV, decodeW)
+		return true
+	}
+	return false
+}
+
+/* decodeMulti reads <k> <keys...> <n> CHECKMULTISIG backwards */
+func (d *miniscriptDecoder) decodeMulti() bool {
+	if d.ctx == TapscriptMiniscript {
+		return false
+	}
+	n, ok := d.op(1).number()
+	if !ok || n < 1 || n > MaxPubKeysPerMultiSig || int64(d.left()) < 3+n {
+		return false
+	}
+	keys := make([]*PublicKey, n)
+	for i := range keys {
+		data := d.op(2 + i).data
+		if len(data) != 33 {
+			return false
+		}
+		/* The keys are read backwards */
+		keys[len(keys)-1-i], ok = d.decodeKey(data)
+		if !ok {
+			return false
+		}
+	}
+	k, ok := d.op(2 + int(n)).number()
+	if !ok || k < 1 || k > n {
+		return false
+	}
+	d.pos += 3 + int(n)
+	d.constructed = append(d.constructed, newMiniscript(d.ctx, MultiFragment, uint32(k), keys, nil))
+	return true
+}
+
+/* decodeMultiA reads <key> CHECKSIG <key> CHECKSIGADD ... <k> NUMEQUAL backwards */
+func (d *miniscriptDecoder) decodeMultiA() bool {
+	if d.ctx != TapscriptMiniscript {
+		return false
+	}
+	k, ok := d.op(1).number()
+	if !ok || k < 1 || k > maxPubKeysPerMultiA || int64(d.left()) < 2+k*2 {
+		return false
+	}
+
+	var keys []*PublicKey
+	for pos := 2; ; pos += 2 {
+		if d.left() < pos+2 {
+			return false
+		}
+		opcode, data := d.op(pos).opcode, d.op(pos+1).data
+		if (opcode != OpCheckSigAdd && opcode != OpCheckSig) || len(data) != 32 {
+			return false
+		}
+		key, ok := d.decodeKey(data)
+		if !ok {
+			return false
+		}
+		keys = append(keys, key)
+		if len(keys) > maxPubKeysPerMultiA {
+			return false
+		}
+		/* The first key is followed by OP_CHECKSIG */
+		if opcode == OpCheckSig {
+			break
+		}
+	}
+	if int64(len(keys)) < k {
+		return false
+	}
+	d.pos += 2 + len(keys)*2
+	for i, j := 0, len(keys)-1; i < j; i, j = i+1, j-1 {
+		keys[i], keys[j] = keys[j], keys[i]
+	}
+	d.constructed = append(d.constructed, newMiniscript(d.ctx, MultiAFragment, uint32(k), keys, nil))
+	return true
+}
+
+/* decodeKey parses a compressed key in P2WSH and a x-only key in tapscript */
+func (d *miniscriptDecoder) decodeKey(b []byte) (*PublicKey, bool) {
+	var key *PublicKey
+	var err error
+	if d.ctx == TapscriptMiniscript {
+		if len(b) != 32 {
+			return nil, false
+		}
+		key, err = PublicFromXOnly(hex.EncodeToString(b), MainNetwork)
+	} else {
+		if len(b) != 33 || (b[0] != 0x02 && b[0] != 0x03) {
+			return nil, false
+		}
+		key, err = publicFromBytes(b, MainNetwork)
+	}
+	return key, err == nil
+}
+
+// ScriptSize returns the size of the compiled script
+func (m *Miniscript) ScriptSize() int {
+	return m.scriptSize
+}
+
+// Ops returns the maximum number of non-push opcodes executed by a satisfaction, false if the miniscript cannot be satisfied
+func (m *Miniscript) Ops() (int, bool) {
+	return m.ops.count + m.ops.sat.value, m.ops.sat.valid
+}
+
+/* isBKW returns 1 if the miniscript leaves an element on the stack, that is if it is not of type V */
+func (m *Miniscript) isBKW() int {
+	if m.Type&mst("BKW") != 0 {
+		return 1
+	}
+	return 0
+}
+
+// StackSize returns the maximum number of witness elements of a satisfaction, false if the miniscript cannot be satisfied
+func (m *Miniscript) StackSize() (int, bool) {
+	return m.stackSize.sat.netDiff + m.isBKW(), m.stackSize.sat.valid
+}
+
+// ExecStackSize returns the maximum size of the stack while a satisfaction is executed, false if the miniscript cannot be satisfied
+func (m *Miniscript) ExecStackSize() (int, bool) {
+	return m.stackSize.sat.exec + m.isBKW(), m.stackSize.sat.valid
+}
+
+// MaxWitnessSize returns the maximum size in bytes of a satisfaction, without the script and the number of witness elements
+func (m *Miniscript) MaxWitnessSize() (int, bool) {
+	return m.witnessSize.sat.value, m.witnessSize.sat.valid
+}
+
+// IsValid returns true if the miniscript is well typed and not too large
+func (m *Miniscript) IsValid() bool {
+	if m.Type == 0 {
+		return false
+	}
+	if m.Context == TapscriptMiniscript {
+		return m.scriptSize <= maxTapMiniscriptSize
+	}
+	return m.scriptSize <= maxStandardP2WSHScriptSize
+}
+
+// IsValidTopLevel returns true if the miniscript is valid as a whole script
+func (m *Miniscript) IsValidTopLevel() bool {
+	return m.IsValid() && m.Type.Has("B")
+}
+
+// IsNonMalleable returns true if the miniscript can always be satisfied without third parties being able to change the satisfaction
+func (m *Miniscript) IsNonMalleable() bool {
+	return m.Type.Has("m")
+}
+
+// NeedsSignature returns true if every satisfaction needs a signature
+func (m *Miniscript) NeedsSignature() bool {
+	return m.Type.Has("s")
+}
+
+// HasTimeLockMix returns true if a satisfaction may need both a height and a time lock of the same kind, and thus cannot exist
+func (m *Miniscript) HasTimeLockMix() bool {
+	return !m.Type.Has("k")
+}
+
+// HasDuplicateKeys returns true if a key appears more than once
+func (m *Miniscript) HasDuplicateKeys() bool {
+	return m.duplicateKeys
+}
+
+// CheckOpsLimit returns true if the satisfactions do not exceed the limit of opcodes, which does not exist in tapscript
+func (m *Miniscript) CheckOpsLimit() bool {
+	if m.Context == TapscriptMiniscript {
+		return true
+	}
+	ops, ok := m.Ops()
+	return !ok || ops <= MaxOpsPerScript
+}
+
+// CheckStackSize returns true if the satisfactions do not exceed the stack limits, the execution stack limit in tapscript and the standard witness limit in P2WSH
+func (m *Miniscript) CheckStackSize() bool {
+	if m.Context == TapscriptMiniscript {
+		size, ok := m.ExecStackSize()
+		return !ok || size <= MaxStackSize
+	}
+	size, ok := m.StackSize()
+	return !ok || size <= maxStandardP2WSHStackItems
+}
+
+// ValidSatisfactions returns true if the non-malleable satisfactions are guaranteed to be valid
+func (m *Miniscript) ValidSatisfactions() bool {
+	return m.IsValid() && m.CheckOpsLimit() && m.CheckStackSize()
+}
+
+// IsSaneSubexpression returns true if the script semantics of the miniscript match its apparent policy
+func (m *Miniscript) IsSaneSubexpression() bool {
+	return m.ValidSatisfactions() && m.IsNonMalleable() && !m.HasTimeLockMix() && !m.HasDuplicateKeys()
+}
+
+// IsSane returns true if the miniscript is safe to use as a whole script
+func (m *Miniscript) IsSane() bool {
+	return m.IsValidTopLevel() && m.IsSaneSubexpression() && m.NeedsSignature()
+}
+
+// FindInsaneSub returns the deepest subexpression which is not sane, nil if there is none
+func (m *Miniscript) FindInsaneSub() *Miniscript {
+	for _, sub := range m.Subs {
+		if insane := sub.FindInsaneSub(); insane != nil {
+			return insane
+		}
+	}
+	if !m.IsSaneSubexpression() {
+		return m
+	}
+	return nil
+}
+
+/* satisfiable returns true if a satisfaction exists when all the keys, preimages and lock times are available */
+func (m *Miniscript) satisfiable() bool {
+	switch m.Fragment {
+	case Just0Fragment:
+		return false
+	case AndOrFragment:
+		return m.Subs[0].satisfiable() && m.Subs[1].satisfiable() || m.Subs[2].satisfiable()
+	case AndVFragment, AndBFragment:
+		return m.Subs[0].satisfiable() && m.Subs[1].satisfiable()
+	case OrBFragment, OrCFragment, OrDFragment, OrIFragment:
+		return m.Subs[0].satisfiable() || m.Subs[1].satisfiable()
+	case ThreshFragment:
+		count := 0
+		for _, sub := range m.Subs {
+			if sub.satisfiable() {
+				count++
+			}
+		}
+		return count >= int(m.K)
+	case WrapAFragment, WrapSFragment, WrapCFragment, WrapDFragment, WrapVFragment, WrapJFragment, WrapNFragment:
+		return m.Subs[0].satisfiable()
+	}
+	return true
+}
+
+/* miniscriptInput is a witness stack satisfying or dissatisfying an expression */
+type miniscriptInput struct {
+	available bool
+	/* hasSig is true if the stack contains a signature */
+	hasSig bool
+	/* malleable is true if third parties can change the stack */
+	malleable bool
+	/* nonCanon is true if the stack is not the canonical one, which is never chosen */
+	nonCanon bool
+	/* size is the serialized size of the elements */
+	size  int
+	stack [][]byte
+}
+
+/* Witness stacks the satisfactions are made of */
+var (
+	emptyInput   = miniscriptInput{available: true}
+	invalidInput = miniscriptInput{}
+	zeroInput    = newMiniscriptInput([]byte{})
+	/* zero32Input dissatisfies hash challenges, any other 32 bytes value also does */
+	zero32Input = newMiniscriptInput(make([]byte, 32)).withMalleable()
+	oneInput    = newMiniscriptInput([]byte{1})
+)
+
+func newMiniscriptInput(element []byte) miniscriptInput {
+	return miniscriptInput{available: true, size: len(element) + 1, stack: [][]byte{element}}
+}
+
+func (in miniscriptInput) withSig() miniscriptInput {
+	in.hasSig = true
+	return in
+}
+
+func (in miniscriptInput) withMalleable() miniscriptInput {
+	in.malleable = true
+	return in
+}
+
+func (in miniscriptInput) withNonCanon() miniscriptInput {
+	in.nonCanon = true
+	return in
+}
+
+/* add returns the stack a followed by b, the elements of b are the last ones consumed by the script */
+func (a miniscriptInput) add(b miniscriptInput) miniscriptInput {
+	if !a.available || !b.available {
+		return invalidInput
+	}
+	stack := make([][]byte, 0, len(a.stack)+len(b.stack))
+	return miniscriptInput{
+		available: true,
+		hasSig:    a.hasSig || b.hasSig,
+		malleable: a.malleable || b.malleable,
+		nonCanon:  a.nonCanon || b.nonCanon,
+		size:      a.size + b.size,
+		stack:     append(append(stack, a.stack...), b.stack...),
+	}
+}
+
+/* or chooses between two stacks, preferring the ones without signatures and then the non-malleable ones */
+func (a miniscriptInput) or(b miniscriptInput) miniscriptInput {
+	if !a.available {
+		return b
+	}
+	if !b.available {
+		return a
+	}
+	/* A stack without signature can be used by third parties instead of the other one */
+	if !a.hasSig && b.hasSig {
+		return a
+	}
+	if !b.hasSig && a.hasSig {
+		return b
+	}
+	if !a.hasSig && !b.hasSig {
+		a.malleable, b.malleable = true, true
+	} else {
+		if b.malleable && !a.malleable {
+			return a
+		}
+		if a.malleable && !b.malleable {
+			return b
+		}
+	}
+	if a.size <= b.size {
+		return a
+	}
+	return b
+}
+
+/* availableInput returns the stack of element if it was found */
+func availableInput(element []byte, found bool) miniscriptInput {
+	if !found {
+		return invalidInput
+	}
+	return newMiniscriptInput(element)
+}
+
+// Satisfy returns the smallest witness stack satisfying the miniscript which cannot be changed by third parties, the script is not included
+func (m *Miniscript) Satisfy(s *MiniscriptSatisfier) ([][]byte, error) {
+	_, sat := m.produceInput(s)
+	if !sat.available {
+		return nil, errors.New("miniscript cannot be satisfied")
+	}
+	if sat.malleable || !sat.hasSig {
+		return nil, errors.New("miniscript has no non-malleable satisfaction")
+	}
+	return sat.stack, nil
+}
+
+// SatisfyMalleable returns the smallest witness stack satisfying the miniscript, third parties may be able to change it
+func (m *Miniscript) SatisfyMalleable(s *MiniscriptSatisfier) ([][]byte, error) {
+	_, sat := m.produceInput(s)
+	if !sat.available {
+		return nil, errors.New("miniscript cannot be satisfied")
+	}
+	return sat.stack, nil
+}
+
+/* produceInput returns the best dissatisfaction and satisfaction of the expression */
+func (m *Miniscript) produceInput(s *MiniscriptSatisfier) (nsat miniscriptInput, sat miniscriptInput) {
+	switch m.Fragment {
+	case PKKFragment:
+		return zeroInput, s.signature(m.keyBytes(m.Keys[0]))
+	case PKHFragment:
+		key := newMiniscriptInput(m.keyBytes(m.Keys[0]))
+		return zeroInput.add(key), s.signature(m.keyBytes(m.Keys[0])).add(key)
+	case MultiAFragment:
+		/* sats[j] is the best stack with j signatures for the keys seen so far, the signature of the first key is consumed first */
+		sats := []miniscriptInput{emptyInput}
+		for i := range m.Keys {
+			sig := s.signature(m.keyBytes(m.Keys[len(m.Keys)-1-i]))
+			next := []miniscriptInput{sats[0].add(zeroInput)}
+			for j := 1; j < len(sats); j++ {
+				next = append(next, sats[j].add(zeroInput).or(sats[j-1].add(sig)))
+			}
+			sats = append(next, sats[len(sats)-1].add(sig))
+		}
+		return sats[0], sats[m.K]
+	case MultiFragment:
+		/* CHECKMULTISIG consumes an extra element */
+		sats := []miniscriptInput{zeroInput}
+		for _, key := range m.Keys {
+			sig := s.signature(m.keyBytes(key))
+			next := []miniscriptInput{sats[0]}
+			for j := 1; j < len(sats); j++ {
+				next = append(next, sats[j].or(sats[j-1].add(sig)))
+			}
+			sats = append(next, sats[len(sats)-1].add(sig))
+		}
+		nsat = zeroInput
+		for i := uint32(0); i < m.K; i++ {
+			nsat = nsat.add(zeroInput)
+		}
+		return nsat, sats[m.K]
+	case ThreshFragment:
+		/* sats[j] is the best stack satisfying j of the last subexpressions seen so far */
+		sats := []miniscriptInput{emptyInput}
+		for i := len(m.Subs) - 1; i >= 0; i-- {
+			subNsat, subSat := m.Subs[i].produceInput(s)
+			next := []miniscriptInput{sats[0].add(subNsat)}
+			for j := 1; j < len(sats); j++ {
+				next = append(next, sats[j].add(subNsat).or(sats[j-1].add(subSat)))
+			}
+			sats = append(next, sats[len(sats)-1].add(subSat))
+		}
+		/* Satisfying neither 0 nor k subexpressions also dissatisfies, but those stacks are malleable */
+		nsat = invalidInput
+		for i := range sats {
+			if i != 0 && i != int(m.K) {
+				sats[i] = sats[i].withMalleable().withNonCanon()
+			}
+			if i != int(m.K) {
+				nsat = nsat.or(sats[i])
+			}
+		}
+		return nsat, sats[m.K]
+	case OlderFragment:
+		if s.checkOlder(m.K) {
+			return invalidInput, emptyInput
+		}
+		return invalidInput, invalidInput
+	case AfterFragment:
+		if s.checkAfter(m.K) {
+			return invalidInput, emptyInput
+		}
+		return invalidInput, invalidInput
+	case SHA256Fragment:
+		return zero32Input, s.preimage(s.SHA256Preimages, m.Data)
+	case RIPEMD160Fragment:
+		return zero32Input, s.preimage(s.RIPEMD160Preimages, m.Data)
+	case Hash256Fragment:
+		return zero32Input, s.preimage(s.Hash256Preimages, m.Data)
+	case Hash160Fragment:
+		return zero32Input, s.preimage(s.Hash160Preimages, m.Data)
+	case AndVFragment:
+		_, xSat := m.Subs[0].produceInput(s)
+		yNsat, ySat := m.Subs[1].produceInput(s)
+		return yNsat.add(xSat).withNonCanon(), ySat.add(xSat)
+	case AndBFragment:
+		xNsat, xSat := m.Subs[0].produceInput(s)
+		yNsat, ySat := m.Subs[1].produceInput(s)
+		nsat = yNsat.add(xNsat).
+			or(ySat.add(xNsat).withMalleable().withNonCanon()).
+			or(yNsat.add(xSat).withMalleable().withNonCanon())
+		return nsat, ySat.add(xSat)
+	case OrBFragment:
+		xNsat, xSat := m.Subs[0].produceInput(s)
+		zNsat, zSat := m.Subs[1].produceInput(s)
+		sat = zNsat.add(xSat).or(zSat.add(xNsat)).or(zSat.add(xSat).withMalleable().withNonCanon())
+		return zNsat.add(xNsat), sat
+	case OrCFragment:
+		xNsat, xSat := m.Subs[0].produceInput(s)
+		_, zSat := m.Subs[1].produceInput(s)
+		return invalidInput, xSat.or(zSat.add(xNsat))
+	case OrDFragment:
+		xNsat, xSat := m.Subs[0].produceInput(s)
+		zNsat, zSat := m.Subs[1].produceInput(s)
+		return zNsat.add(xNsat), xSat.or(zSat.add(xNsat))
+	case OrIFragment:
+		xNsat, xSat := m.Subs[0].produceInput(s)
+		zNsat, zSat := m.Subs[1].produceInput(s)
+		return xNsat.add(oneInput).or(zNsat.add(zeroInput)), xSat.add(oneInput).or(zSat.add(zeroInput))
+	case AndOrFragment:
+		xNsat, xSat := m.Subs[0].produceInput(s)
+		yNsat, ySat := m.Subs[1].produceInput(s)
+		zNsat, zSat := m.Subs[2].produceInput(s)
+		return yNsat.add(xSat).withNonCanon().or(zNsat.add(xNsat)), ySat.add(xSat).or(zSat.add(xNsat))
+	case WrapAFragment, WrapSFragment, WrapCFragment, WrapNFragment:
+		return m.Subs[0].produceInput(s)
+	case WrapDFragment:
+		_, xSat := m.Subs[0].produceInput(s)
+		return zeroInput, xSat.add(oneInput)
+	case WrapJFragment:
+		/* A dissatisfaction of the subexpression with a non-zero top element could be used instead of the empty element */
+		xNsat, xSat := m.Subs[0].produceInput(s)
+		nsat = zeroInput
+		if xNsat.available && !xNsat.hasSig {
+			nsat = nsat.withMalleable()
+		}
+		return nsat, xSat
+	case WrapVFragment:
+		_, xSat := m.Subs[0].produceInput(s)
+		return invalidInput, xSat
+	case Just0Fragment:
+		return emptyInput, invalidInput
+	case Just1Fragment:
+		return invalidInput, emptyInput
+	}
+	return invalidInput, invalidInput
+}
+
+/* signature returns the stack of the signature of key */
+func (s *MiniscriptSatisfier) signature(key []byte) miniscriptInput {
+	sig, ok := s.Signatures[hex.EncodeToString(key)]
+	if !ok {
+		return invalidInput
+	}
+	return newMiniscriptInput(sig).withSig()
+}
+
+/* preimage returns the stack of the preimage of hash, which the script requires to be 32 bytes long */
+func (s *MiniscriptSatisfier) preimage(preimages map[string][]byte, hash []byte) miniscriptInput {
+	preimage, ok := preimages[hex.EncodeToString(hash)]
+	return availableInput(preimage, ok && len(preimage) == 32)
+}
+
+/* checkOlder returns true if the input sequence satisfies the relative lock time (BIP68) */
+func (s *MiniscriptSatisfier) checkOlder(sequence uint32) bool {
+	if uint32(s.TxVersion) < 2 || s.Sequence&sequenceLockTimeDisableFlag != 0 {
+		return false
+	}
+	mask := uint32(sequenceLockTimeTypeFlag | sequenceLockTimeMask)
+	sequence, txSequence := sequence&mask, s.Sequence&mask
+	if (sequence < sequenceLockTimeTypeFlag) != (txSequence < sequenceLockTimeTypeFlag) {
+		return false
+	}
+	return sequence <= txSequence
+}
+
+/* checkAfter returns true if the transaction lock time satisfies the lock time */
+func (s *MiniscriptSatisfier) checkAfter(lockTime uint32) bool {
+	if (lockTime < LockTimeThreshold) != (s.LockTime < LockTimeThreshold) {
+		return false
+	}
+	/* The lock time of the transaction is disabled when the input sequence is final */
+	return lockTime <= s.LockTime && s.Sequence != 0xffffffff
+}
@@ -0,0 +1,477 @@
+package btc
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ripemd160"
+)
+
+/* Test modes of Bitcoin Core's miniscript tests */
+const (
+	miniscriptValid            = 1
+	miniscriptNonMalleable     = 2
+	miniscriptNeedsSignature   = 4
+	miniscriptTimeLockMix      = 8
+	miniscriptP2WSHInvalid     = 16
+	miniscriptTapscriptInvalid = 32
+)
+
+type miniscriptVector struct {
+	Miniscript string `json:"miniscript"`
+	/* Script is "?" when it is not checked, Tapscript is "=" when it is the same as Script */
+	Script    string `json:"script"`
+	Tapscript string `json:"tapscript"`
+	Mode      int    `json:"mode"`
+
+	Ops        *int `json:"ops"`
+	Stack      *int `json:"stack"`
+	Witness    *int `json:"witness"`
+	TapWitness *int `json:"tap_witness"`
+	Exec       *int `json:"exec"`
+}
+
+type miniscriptVectors struct {
+	Tests         []miniscriptVector `json:"tests"`
+	DecodeInvalid []struct {
+		Description string `json:"description"`
+		Context     string `json:"context"`
+		Script      string `json:"script"`
+	} `json:"decode_invalid"`
+	ParseInvalid []string `json:"parse_invalid"`
+}
+
+func loadMiniscriptVectors(t *testing.T) miniscriptVectors {
+	data, err := ioutil.ReadFile("testdata/miniscript_vectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var vectors miniscriptVectors
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		t.Fatal(err)
+	}
+	return vectors
+}
+
+/* miniscriptTestData holds the keys and preimages of the test vectors: the private keys and preimages are 1 to 255 */
+type miniscriptTestData struct {
+	privateKeys []*PrivateKey
+	publicKeys  []*PublicKey
+
+	ripemd160Preimages map[string][]byte
+	sha256Preimages    map[string][]byte
+	hash160Preimages   map[string][]byte
+	hash256Preimages   map[string][]byte
+}
+
+func newMiniscriptTestData(t *testing.T) *miniscriptTestData {
+	data := &miniscriptTestData{
+		ripemd160Preimages: make(map[string][]byte),
+		sha256Preimages:    make(map[string][]byte),
+		hash160Preimages:   make(map[string][]byte),
+		hash256Preimages:   make(map[string][]byte),
+	}
+	for i := 1; i <= 255; i++ {
+		keyData := make([]byte, 32)
+		keyData[31] = byte(i)
+
+		privateKey, err := PrivateFromHex(hex.EncodeToString(keyData), MainNetwork)
+		if err != nil {
+			t.Fatal(err)
+		}
+		publicKey, _ := privateKey.GetPublicKey()
+		data.privateKeys = append(data.privateKeys, privateKey)
+		data.publicKeys = append(data.publicKeys, publicKey)
+
+		r := ripemd160.New()
+		r.Write(keyData)
+		data.ripemd160Preimages[hex.EncodeToString(r.Sum(nil))] = keyData
+		data.sha256Preimages[hex.EncodeToString(singleHash(keyData))] = keyData
+		data.hash160Preimages[hex.EncodeToString(hash160(keyData))] = keyData
+		data.hash256Preimages[hex.EncodeToString(doubleHash(keyData))] = keyData
+	}
+	return data
+}
+
+/* miniscriptChallenge is a condition of a miniscript: a signature, a preimage or a lock time */
+type miniscriptChallenge struct {
+	fragment MiniscriptFragment
+	key      string
+	k        uint32
+}
+
+func findMiniscriptChallenges(m *Miniscript, challenges map[miniscriptChallenge]bool) {
+	switch m.Fragment {
+	case PKKFragment, PKHFragment, MultiFragment, MultiAFragment:
+		for _, key := range m.Keys {
+			challenges[miniscriptChallenge{fragment: PKKFragment, key: m.keyString(key)}] = true
+		}
+	case SHA256Fragment, Hash256Fragment, RIPEMD160Fragment, Hash160Fragment:
+		challenges[miniscriptChallenge{fragment: m.Fragment, key: hex.EncodeToString(m.Data)}] = true
+	case OlderFragment, AfterFragment:
+		challenges[miniscriptChallenge{fragment: m.Fragment, k: m.K}] = true
+	}
+	for _, sub := range m.Subs {
+		findMiniscriptChallenges(sub, challenges)
+	}
+}
+
+/* lockTimeFor returns the largest lock time of the type of the first one, so that the lock times of this type are satisfied */
+func lockTimeFor(lockTimes []uint32, threshold uint32) (uint32, bool) {
+	if len(lockTimes) == 0 {
+		return 0, false
+	}
+	lockTime := lockTimes[0]
+	for _, k := range lockTimes[1:] {
+		if (k < threshold) == (lockTimes[0] < threshold) && k > lockTime {
+			lockTime = k
+		}
+	}
+	return lockTime, true
+}
+
+/* testMiniscriptSatisfy adds the challenges of the miniscript one by one and checks the satisfactions against the interpreter, only once all of them are added unless incremental is set */
+func testMiniscriptSatisfy(t *testing.T, data *miniscriptTestData, m *Miniscript, incremental bool) {
+	script := m.Script()
+	var scriptPubKey, control, leafHash []byte
+	if m.Context == TapscriptMiniscript {
+		internalKey := &PublicKey{X: secp256k1.G.X}
+		leafHash = TapLeafHash(TapLeafVersion, script)
+		Q, err := internalKey.TaprootTweak(leafHash)
+		assert.Nil(t, err)
+		control = append([]byte{TapLeafVersion | byte(Q.Y.Bit(0))}, paddedBytes(internalKey.X, 32)...)
+		scriptPubKey = append([]byte{Op1, 0x20}, paddedBytes(Q.X, 32)...)
+	} else {
+		scriptPubKey = append([]byte{Op0, 0x20}, singleHash(script)...)
+	}
+	prevOuts := []*TxOut{{Value: 100000, ScriptPubKey: scriptPubKey}}
+
+	privateKeys := make(map[string]*PrivateKey)
+	for i, publicKey := range data.publicKeys {
+		privateKeys[m.keyString(publicKey)] = data.privateKeys[i]
+	}
+
+	set := make(map[miniscriptChallenge]bool)
+	findMiniscriptChallenges(m, set)
+	var challenges []miniscriptChallenge
+	for challenge := range set {
+		challenges = append(challenges, challenge)
+	}
+	/* Sort before shuffling so that the order only depends on the seed */
+	sort.Slice(challenges, func(i, j int) bool {
+		a, b := challenges[i], challenges[j]
+		return a.fragment < b.fragment || a.fragment == b.fragment && (a.key < b.key || a.key == b.key && a.k < b.k)
+	})
+	rand.New(rand.NewSource(int64(len(script)))).Shuffle(len(challenges), func(i, j int) {
+		challenges[i], challenges[j] = challenges[j], challenges[i]
+	})
+
+	s := &MiniscriptSatisfier{
+		Signatures:         make(map[string][]byte),
+		RIPEMD160Preimages: make(map[string][]byte),
+		SHA256Preimages:    make(map[string][]byte),
+		Hash160Preimages:   make(map[string][]byte),
+		Hash256Preimages:   make(map[string][]byte),
+		TxVersion:          2,
+	}
+	var keys []string
+	var olders, afters []uint32
+	prevMalleable, prevNonMalleable := false, false
+
+	for add := -1; add < len(challenges); add++ {
+		if add >= 0 {
+			challenge := challenges[add]
+			switch challenge.fragment {
+			case PKKFragment:
+				keys = append(keys, challenge.key)
+			case SHA256Fragment:
+				s.SHA256Preimages[challenge.key] = data.sha256Preimages[challenge.key]
+			case Hash256Fragment:
+				s.Hash256Preimages[challenge.key] = data.hash256Preimages[challenge.key]
+			case RIPEMD160Fragment:
+				s.RIPEMD160Preimages[challenge.key] = data.ripemd160Preimages[challenge.key]
+			case Hash160Fragment:
+				s.Hash160Preimages[challenge.key] = data.hash160Preimages[challenge.key]
+			case OlderFragment:
+				olders = append(olders, challenge.k)
+			case AfterFragment:
+				afters = append(afters, challenge.k)
+			}
+		}
+		if !incremental && add < len(challenges)-1 {
+			continue
+		}
+
+		s.Sequence = 0xfffffffe
+		if sequence, ok := lockTimeFor(olders, sequenceLockTimeTypeFlag); ok {
+			s.Sequence = sequence
+		}
+		s.LockTime, _ = lockTimeFor(afters, LockTimeThreshold)
+
+		tx := &Transaction{
+			Version: s.TxVersion,
+			Inputs: []*TxIn{{
+				PreviousOutPoint: OutPoint{Hash: make([]byte, 32), Index: 0},
+				Sequence:         s.Sequence,
+			}},
+			Outputs:  []*TxOut{{Value: 90000, ScriptPubKey: []byte{Op1}}},
+			LockTime: s.LockTime,
+		}
+		for _, key := range keys {
+			var sig []byte
+			var err error
+			if m.Context == TapscriptMiniscript {
+				sig, err = privateKeys[key].SignTaprootInput(tx, 0, prevOuts, SigHashAll, leafHash)
+			} else {
+				sig, err = privateKeys[key].SignWitnessV0Input(tx, 0, script, prevOuts[0].Value, SigHashAll)
+			}
+			assert.Nil(t, err)
+			s.Signatures[key] = sig
+		}
+
+		malleable, errMalleable := m.SatisfyMalleable(s)
+		nonMalleable, errNonMalleable := m.Satisfy(s)
+		if errNonMalleable == nil {
+			stackSize, _ := m.StackSize()
+			assert.True(t, len(nonMalleable) <= stackSize, m.String())
+			assert.Nil(t, errMalleable, m.String())
+			assert.Equal(t, nonMalleable, malleable, m.String())
+
+			witnessSize, _ := m.MaxWitnessSize()
+			assert.True(t, witnessSize >= witnessElementsSize(nonMalleable), m.String())
+
+			if control == nil {
+				tx.Inputs[0].Witness = append(nonMalleable, script)
+			} else {
+				tx.Inputs[0].Witness = append(nonMalleable, script, control)
+			}
+			if m.ValidSatisfactions() {
+				assert.Nil(t, tx.Verify(prevOuts, StandardVerifyFlags), m.String())
+			}
+		}
+
+		if m.IsSane() {
+			assert.Equal(t, errMalleable == nil, errNonMalleable == nil, m.String())
+		}
+		/* Adding a condition never removes a satisfaction, though it can make the only non-malleable one malleable */
+		assert.True(t, errMalleable == nil || !prevMalleable, m.String())
+		if m.IsSane() || add < 0 || challenges[add].fragment == PKKFragment {
+			assert.True(t, errNonMalleable == nil || !prevNonMalleable, m.String())
+		}
+		prevMalleable, prevNonMalleable = errMalleable == nil, errNonMalleable == nil
+	}
+
+	/* Lock times of both types of a kind cannot be satisfied by a single transaction */
+	if !m.Type.Has("gh") && !m.Type.Has("ij") {
+		assert.Equal(t, m.satisfiable(), prevMalleable, m.String())
+		if m.IsSane() {
+			assert.Equal(t, m.satisfiable(), prevNonMalleable, m.String())
+		}
+	}
+}
+
+/* witnessElementsSize returns the serialized size of witness elements, without their count */
+func witnessElementsSize(stack [][]byte) int {
+	size := 0
+	for _, element := range stack {
+		size += len(compactSize(uint64(len(element)))) + len(element)
+	}
+	return size
+}
+
+/* testMiniscript checks a miniscript against a vector of Bitcoin Core */
+func testMiniscript(t *testing.T, data *miniscriptTestData, test miniscriptVector, ctx MiniscriptContext, incremental bool) {
+	m, err := ParseMiniscript(test.Miniscript, ctx)
+	hexScript, witness, invalidMode := test.Script, test.Witness, miniscriptP2WSHInvalid
+	if ctx == TapscriptMiniscript {
+		witness, invalidMode = test.TapWitness, miniscriptTapscriptInvalid
+		if test.Tapscript != "=" {
+			hexScript = test.Tapscript
+		}
+	}
+
+	if test.Mode == 0 || test.Mode&invalidMode != 0 {
+		assert.NotNil(t, err, test.Miniscript)
+		return
+	}
+	if !assert.Nil(t, err, test.Miniscript) {
+		return
+	}
+
+	assert.True(t, m.IsValid(), test.Miniscript)
+	assert.True(t, m.IsValidTopLevel(), test.Miniscript)
+	script := m.Script()
+	assert.Equal(t, len(script), m.ScriptSize(), test.Miniscript)
+	if hexScript != "?" {
+		assert.Equal(t, hexScript, hex.EncodeToString(script), test.Miniscript)
+	}
+	assert.Equal(t, test.Mode&miniscriptNonMalleable != 0, m.IsNonMalleable(), test.Miniscript)
+	assert.Equal(t, test.Mode&miniscriptNeedsSignature != 0, m.NeedsSignature(), test.Miniscript)
+	assert.Equal(t, test.Mode&miniscriptTimeLockMix != 0, m.HasTimeLockMix(), test.Miniscript)
+
+	decoded, err := DecodeMiniscript(script, ctx, data.publicKeys)
+	if assert.Nil(t, err, test.Miniscript) {
+		assert.Equal(t, script, decoded.Script(), test.Miniscript)
+	}
+
+	/* The expression parses back to the same script */
+	parsed, err := ParseMiniscript(m.String(), ctx)
+	if assert.Nil(t, err, m.String()) {
+		assert.Equal(t, script, parsed.Script(), m.String())
+	}
+
+	if test.Ops != nil {
+		ops, _ := m.Ops()
+		assert.Equal(t, *test.Ops, ops, test.Miniscript)
+	}
+	if test.Stack != nil {
+		stackSize, _ := m.StackSize()
+		assert.Equal(t, *test.Stack, stackSize, test.Miniscript)
+	}
+	if witness != nil {
+		witnessSize, _ := m.MaxWitnessSize()
+		assert.Equal(t, *witness, witnessSize, test.Miniscript)
+	}
+	if test.Exec != nil {
+		execStackSize, _ := m.ExecStackSize()
+		assert.Equal(t, *test.Exec, execStackSize, test.Miniscript)
+	}
+
+	testMiniscriptSatisfy(t, data, m, incremental)
+}
+
+func TestMiniscript(t *testing.T) {
+	vectors := loadMiniscriptVectors(t)
+	data := newMiniscriptTestData(t)
+
+	for _, test := range vectors.Tests {
+		testMiniscript(t, data, test, P2WSHMiniscript, true)
+		testMiniscript(t, data, test, TapscriptMiniscript, true)
+	}
+}
+
+func TestMiniscriptLarge(t *testing.T) {
+	data := newMiniscriptTestData(t)
+
+	/* More than 201 ops (99 keys), a stack larger than 100 elements (110 keys) and a script larger than 3600 bytes (200 keys) are only valid in tapscript */
+	for _, count := range []int{99, 110, 200} {
+		expr := ""
+		for i := 0; i < count-1; i++ {
+			expr += "and_b(pk(" + data.publicKeys[i].Format(true) + "),a:"
+		}
+		expr += "pk(" + data.publicKeys[count-1].Format(true) + ")" + strings.Repeat(")", count-1)
+
+		ops, stack, exec := count+(count-1)*3, count, count+1
+		test := miniscriptVector{
+			Miniscript: expr,
+			Script:     "?",
+			Tapscript:  "?",
+			Mode:       miniscriptValid | miniscriptNonMalleable | miniscriptNeedsSignature | miniscriptP2WSHInvalid,
+			Ops:        &ops,
+			Stack:      &stack,
+			Exec:       &exec,
+		}
+		if testing.Short() {
+			m, err := ParseMiniscript(expr, TapscriptMiniscript)
+			assert.Nil(t, err)
+			assert.True(t, m.IsSane())
+			continue
+		}
+		testMiniscript(t, data, test, P2WSHMiniscript, false)
+		testMiniscript(t, data, test, TapscriptMiniscript, false)
+	}
+
+	/* The execution stack reaches 1000 elements with 998 older, one more exceeds the limit */
+	for _, count := range []int{998, 999} {
+		expr := strings.Repeat("and_b(older(1),a:", count) + "pk(" + data.publicKeys[0].Format(true) + ")" + strings.Repeat(")", count)
+		m, err := ParseMiniscript(expr, TapscriptMiniscript)
+		if !assert.Nil(t, err) {
+			continue
+		}
+		assert.Equal(t, count == 998, m.CheckStackSize())
+		assert.Equal(t, count == 998, m.IsSane())
+
+		ops, stack, exec := 4*count+1, 1, count+2
+		test := miniscriptVector{
+			Miniscript: expr,
+			Script:     "?",
+			Tapscript:  "?",
+			Mode:       miniscriptValid | miniscriptNonMalleable | miniscriptNeedsSignature | miniscriptP2WSHInvalid,
+			Ops:        &ops,
+			Stack:      &stack,
+			Exec:       &exec,
+		}
+		if !testing.Short() {
+			testMiniscript(t, data, test, P2WSHMiniscript, false)
+			testMiniscript(t, data, test, TapscriptMiniscript, false)
+		}
+	}
+}
+
+func TestMiniscriptSanity(t *testing.T) {
+	/* CHECKMULTISIG costs an op per key, and its stack has a signature and the dummy element */
+	m, err := ParseMiniscript("multi(1,03d30199d74fb5a22d47b6e054e2f378cedacffcb89904a61d75d0dbd407143e65,03fff97bd5755eeea420453a14355235d382f6472f8568a18b2f057a1460297556,0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798)", P2WSHMiniscript)
+	assert.Nil(t, err)
+	ops, _ := m.Ops()
+	assert.Equal(t, 4, ops)
+	stackSize, _ := m.StackSize()
+	assert.Equal(t, 2, stackSize)
+
+	/* d: is not u in P2WSH, where MINIMALIF is only a policy rule */
+	_, err = ParseMiniscript("thresh(3,c:pk_k(03d30199d74fb5a22d47b6e054e2f378cedacffcb89904a61d75d0dbd407143e65),sc:pk_k(03fff97bd5755eeea420453a14355235d382f6472f8568a18b2f057a1460297556),sc:pk_k(0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798),sdv:older(32))", P2WSHMiniscript)
+	assert.NotNil(t, err)
+
+	tests := []struct {
+		miniscript string
+		sane       bool
+	}{
+		{"and_v(v:pk(03d30199d74fb5a22d47b6e054e2f378cedacffcb89904a61d75d0dbd407143e65),pk(03d30199d74fb5a22d47b6e054e2f378cedacffcb89904a61d75d0dbd407143e65))", false},
+		{"or_b(c:pk_k(03d30199d74fb5a22d47b6e054e2f378cedacffcb89904a61d75d0dbd407143e65),ac:pk_h(03d30199d74fb5a22d47b6e054e2f378cedacffcb89904a61d75d0dbd407143e65))", false},
+		{"or_i(and_b(pk(03d30199d74fb5a22d47b6e054e2f378cedacffcb89904a61d75d0dbd407143e65),s:pk(03fff97bd5755eeea420453a14355235d382f6472f8568a18b2f057a1460297556)),and_b(older(1),s:pk(03d30199d74fb5a22d47b6e054e2f378cedacffcb89904a61d75d0dbd407143e65)))", false},
+		{"thresh(2,pkh(03d30199d74fb5a22d47b6e054e2f378cedacffcb89904a61d75d0dbd407143e65),s:pk(03fff97bd5755eeea420453a14355235d382f6472f8568a18b2f057a1460297556),a:and_b(dv:older(1),s:pk(03d30199d74fb5a22d47b6e054e2f378cedacffcb89904a61d75d0dbd407143e65)))", false},
+		{"pk(03d30199d74fb5a22d47b6e054e2f378cedacffcb89904a61d75d0dbd407143e65)", true},
+	}
+	for _, test := range tests {
+		m, err := ParseMiniscript(test.miniscript, P2WSHMiniscript)
+		if assert.Nil(t, err, test.miniscript) {
+			assert.Equal(t, !test.sane, m.HasDuplicateKeys(), test.miniscript)
+			assert.Equal(t, test.sane, m.IsSane(), test.miniscript)
+		}
+	}
+
+	/* The insane subexpression closest to the leaves is reported, here because of the time lock mix */
+	m, err = ParseMiniscript("or_i(and_b(after(1),a:after(1000000000)),pk(03cdabb7f2dce7bfbd8a0b9570c6fd1e712e5d64045e9d6b517b3d5072251dc204))", P2WSHMiniscript)
+	assert.Nil(t, err)
+	assert.True(t, m.IsValid())
+	assert.False(t, m.IsSane())
+	if insane := m.FindInsaneSub(); assert.NotNil(t, insane) {
+		assert.Equal(t, "and_b(after(1),a:after(1000000000))", insane.String())
+	}
+}
+
+func TestMiniscriptInvalid(t *testing.T) {
+	vectors := loadMiniscriptVectors(t)
+
+	for _, test := range vectors.DecodeInvalid {
+		script, err := hex.DecodeString(test.Script)
+		assert.Nil(t, err)
+		ctx := P2WSHMiniscript
+		if test.Context == "tapscript" {
+			ctx = TapscriptMiniscript
+		}
+		_, err = DecodeMiniscript(script, ctx, nil)
+		assert.NotNil(t, err, test.Description)
+	}
+
+	for _, expr := range vectors.ParseInvalid {
+		_, err := ParseMiniscript(expr, P2WSHMiniscript)
+		assert.NotNil(t, err, expr)
+	}
+}
@@ -3,74 +3,98 @@ package btc
 import (
 	"crypto/rand"
 	"crypto/sha256"
-	"encoding/hex"
+	"crypto/sha512"
 	"errors"
-	"fmt"
-	"log"
 	"math/big"
 	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/text/unicode/norm"
 )
 
-// NewSeedFromMnemonic returns the hash of the mnemonic
+// NewSeedFromMnemonic validates the mnemonic checksum and returns its 64-byte BIP39 seed
 func NewSeedFromMnemonic(mnemonic string, passphrase string, wordlist Wordlist) ([]byte, error) {
-	words := strings.Split(mnemonic, " ")
-	binary := ""
-	for _, word := range words {
-		index := -1
-		for i := range wordlist {
-			if word == wordlist[i] {
-				index = i
-				break
-			}
-		}
-		if index < 0 {
-			return nil, errors.New("invalid mnemonic")
-		}
 
-		indexBinary := fmt.Sprintf("%b", index)
-		for len(indexBinary) < 11 {
-			indexBinary = "0" + indexBinary
-		}
+	if wordlist == nil {
+		return nil, errors.New("invalid wordlist")
+	}
 
-		binary += indexBinary
+	/* Both the mnemonic and the passphrase are NFKD normalized */
+	mnemonic = norm.NFKD.String(mnemonic)
+	passphrase = norm.NFKD.String(passphrase)
+
+	words := strings.Fields(mnemonic)
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return nil, errors.New("unsupported mnemonic length")
 	}
 
-	hash := ""
-	for i := 0; i < len(binary); i += 4 {
-		n := ""
-		for j := i; j-i < 4; j++ {
-			n += string(binary[j])
+	/* Each word encodes 11 bits of entropy + checksum */
+	indexes := wordlist.indexes()
+	bits := new(big.Int)
+	for _, word := range words {
+		index, ok := indexes[word]
+		if !ok {
+			return nil, errors.New("invalid mnemonic")
 		}
-		log.Println(n)
-		nInt, _ := new(big.Int).SetString(n, 2)
-		hexa := bigIntToHex(nInt)
-		hash += hexa
+
+		bits.Lsh(bits, 11)
+		bits.Or(bits, big.NewInt(int64(index)))
 	}
 
-	checksum := hash[len(hash)-2 : len(hash)]
+	checksumLength := uint(len(words) * 11 / 33)
+	entropyLength := len(words)*11 - int(checksumLength)
 
-	sha := sha256.New()
-	decoded, err := hex.DecodeString(hash[0 : len(hash)-2])
-	if err != nil {
-		return nil, err
+	checksum := new(big.Int).And(bits, big.NewInt(1<<checksumLength-1))
+	entropy := paddedBytes(new(big.Int).Rsh(bits, checksumLength), entropyLength/8)
+
+	/* Checksum is the first bits of the sha256 hash of the entropy */
+	hash := sha256.Sum256(entropy)
+	if int64(hash[0]>>(8-checksumLength)) != checksum.Int64() {
+		return nil, errors.New("invalid checksum")
 	}
-	sha.Write(decoded)
-	shaHash := sha.Sum(nil)
 
-	shaHashBig := new(big.Int).SetBytes(shaHash)
-	shaHashHex := bigIntToHex(shaHashBig)
+	salt := "mnemonic" + passphrase
+	return pbkdf2.Key([]byte(mnemonic), []byte(salt), 2048, 64, sha512.New), nil
+}
 
-	for len(shaHashHex) < 64 {
-		shaHashHex = "0" + shaHashHex
+/* indexedWordlist is a wordlist of the package with its index */
+type indexedWordlist struct {
+	words   Wordlist
+	indexes map[string]int
+}
+
+/* builtinWordlists are indexed once at init */
+var builtinWordlists []indexedWordlist
+
+func init() {
+	for _, w := range []Wordlist{
+		EnglishWordlist, FrenchWordlist, JapaneseWordlist, ItalianWordlist, SpanishWordlist,
+		KoreanWordlist, CzechWordlist, ChineseTraditionalWordlist, ChineseSimplifiedWordlist,
+	} {
+		builtinWordlists = append(builtinWordlists, indexedWordlist{w, w.buildIndexes()})
 	}
+}
 
-	if shaHashHex[0:2] != checksum {
-		return nil, errors.New("invalid checksum")
+/* indexes maps the NFKD normalized words of the wordlist to their position, the index of other wordlists than the ones of the package is built on each call */
+func (w Wordlist) indexes() map[string]int {
+	for _, builtin := range builtinWordlists {
+		if len(w) > 0 && len(w) == len(builtin.words) && &w[0] == &builtin.words[0] {
+			return builtin.indexes
+		}
 	}
+	return w.buildIndexes()
+}
 
-	log.Println(shaHashHex, len(shaHashHex))
-	log.Println(hash, checksum)
-	return nil, nil
+/* buildIndexes maps the NFKD normalized words of the wordlist to their first position */
+func (w Wordlist) buildIndexes() map[string]int {
+	indexes := make(map[string]int, len(w))
+	for i := range w {
+		normalized := norm.NFKD.String(w[i])
+		if _, duplicate := indexes[normalized]; !duplicate {
+			indexes[normalized] = i
+		}
+	}
+	return indexes
 }
 
 // NewMnemonic generates a new mnemonic phrase
@@ -109,38 +133,20 @@ func NewMnemonic(words int, wordlist Wordlist) (string, error) {
 		return "", err
 	}
 
-	sha := sha256.New()
-	sha.Write(entropy.Bytes())
-	hash := sha.Sum(nil)
-
-	checksumLength := entropyLength / 32
-
-	hashInt := new(big.Int).SetBytes(hash)
-
-	hashBinary := fmt.Sprintf("%b", hashInt)
-	checksumBinary := hashBinary[0:checksumLength]
+	/* Checksum is the first entropyLength / 32 bits of the sha256 hash of the entropy */
+	hash := sha256.Sum256(paddedBytes(entropy, entropyLength/8))
+	checksumLength := uint(entropyLength / 32)
 
-	b := fmt.Sprintf("%b", entropy)
+	bits := new(big.Int).Lsh(entropy, checksumLength)
+	bits.Or(bits, big.NewInt(int64(hash[0]>>(8-checksumLength))))
 
-	for len(b) < entropyLength {
-		b = "0" + b
-	}
-
-	b = b + checksumBinary
-	binary, _ := new(big.Int).SetString(b, 2)
-
-	var phrase []string
-	for i := 0; i < len(b); i += 11 {
-		str := ""
-		for j := i; j-i < 11; j++ {
-			if binary.Bit(j) == 0 {
-				str += "0"
-			} else {
-				str += "1"
-			}
-		}
-		n, _ := new(big.Int).SetString(str, 2)
-		phrase = append(phrase, wordlist[n.Int64()])
+	/* Split entropy + checksum in groups of 11 bits, most significant first */
+	phrase := make([]string, words)
+	mask := big.NewInt(2047)
+	for i := words - 1; i >= 0; i-- {
+		n := new(big.Int).And(bits, mask)
+		phrase[i] = wordlist[n.Int64()]
+		bits.Rsh(bits, 11)
 	}
 
 	return strings.Join(phrase, " "), nil
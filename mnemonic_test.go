@@ -1,6 +1,9 @@
 package btc
 
 import (
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
 	"strings"
 	"testing"
 
@@ -23,7 +26,89 @@ func TestNewMnemonic(t *testing.T) {
 }
 
 func TestNewSeedFromMnemonic(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/bip39_vectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var vectors map[string][]struct {
+		Entropy    string `json:"entropy"`
+		Mnemonic   string `json:"mnemonic"`
+		Passphrase string `json:"passphrase"`
+		Seed       string `json:"seed"`
+	}
+	err = json.Unmarshal(data, &vectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wordlists = map[string]Wordlist{
+		"english":             EnglishWordlist,
+		"french":              FrenchWordlist,
+		"japanese":            JapaneseWordlist,
+		"italian":             ItalianWordlist,
+		"spanish":             SpanishWordlist,
+		"korean":              KoreanWordlist,
+		"czech":               CzechWordlist,
+		"chinese_traditional": ChineseTraditionalWordlist,
+		"chinese_simplified":  ChineseSimplifiedWordlist,
+	}
+
+	for language, wordlist := range wordlists {
+		assert.NotEmpty(t, vectors[language], language)
+		for _, value := range vectors[language] {
+			seed, err := NewSeedFromMnemonic(value.Mnemonic, value.Passphrase, wordlist)
+			assert.Nil(t, err, value.Mnemonic)
+			assert.Equal(t, value.Seed, hex.EncodeToString(seed), value.Mnemonic)
+		}
+	}
+
+	var invalid = []string{
+		/* Bad checksum */
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon",
+		"legal winner thank year wave sausage worth useful legal winner thank thank",
+		/* Unknown word */
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon satoshi",
+		/* Unsupported length */
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+		"",
+	}
+	for _, mnemonic := range invalid {
+		_, err := NewSeedFromMnemonic(mnemonic, "TREZOR", EnglishWordlist)
+		assert.NotNil(t, err, mnemonic)
+	}
+
+	/* Mnemonic of a different language */
+	_, err = NewSeedFromMnemonic(vectors["english"][0].Mnemonic, "", FrenchWordlist)
+	assert.NotNil(t, err)
+}
+
+func TestNewMnemonicSeed(t *testing.T) {
+	for _, l := range []int{12, 15, 18, 21, 24} {
+		for i := 0; i < 20; i++ {
+			phrase, err := NewMnemonic(l, EnglishWordlist)
+			assert.Nil(t, err)
+
+			seed, err := NewSeedFromMnemonic(phrase, "", EnglishWordlist)
+			assert.Nil(t, err, phrase)
+			assert.Equal(t, 64, len(seed))
+		}
+	}
+}
+
+func TestNewSeedFromCustomWordlist(t *testing.T) {
+	wordlist := append(Wordlist{}, EnglishWordlist...)
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon "
 
-	NewSeedFromMnemonic("march assault engine warrior talent swarm pluck job prepare knife pipe man student dice receive analyst salute art clean wood enemy tourist lunch like", "", EnglishWordlist)
+	seed, err := NewSeedFromMnemonic(mnemonic+"about", "TREZOR", wordlist)
+	assert.Nil(t, err)
+	assert.Equal(t, "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04", hex.EncodeToString(seed))
 
+	/* Wordlists modified in place are indexed again */
+	wordlist[3] = "satoshi"
+	_, err = NewSeedFromMnemonic(mnemonic+"about", "TREZOR", wordlist)
+	assert.NotNil(t, err)
+	again, err := NewSeedFromMnemonic(mnemonic+"satoshi", "TREZOR", wordlist)
+	assert.Nil(t, err)
+	assert.NotEqual(t, seed, again)
 }
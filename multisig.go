@@ -0,0 +1,82 @@
+package btc
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+)
+
+/* maxP2SHMultiSigKeys is the standard limit of keys in P2SH multisig, more compressed keys exceed the 520 bytes of a redeem script */
+const maxP2SHMultiSigKeys = 15
+
+// NewMultiSig creates a threshold-of-n multisig of keys, the keys are sorted as in BIP67
+func NewMultiSig(threshold int, keys []*PublicKey, network *Network) (*MultiSig, error) {
+	if len(keys) > MaxPubKeysPerMultiSig {
+		return nil, errors.New("too many keys in multisig")
+	}
+	if threshold < 1 || threshold > len(keys) {
+		return nil, errors.New("multisig threshold must be between 1 and the number of keys")
+	}
+
+	sorted := make([]*PublicKey, len(keys))
+	copy(sorted, keys)
+	/* BIP67 sorts the keys by their serialization */
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].serialize(sorted[i].Compressed), sorted[j].serialize(sorted[j].Compressed)) < 0
+	})
+
+	return &MultiSig{
+		Threshold:  threshold,
+		PublicKeys: sorted,
+		Network:    network,
+	}, nil
+}
+
+// Script returns the OP_CHECKMULTISIG script, which is the redeem script of P2SH and the witness script of P2WSH
+func (m *MultiSig) Script() []byte {
+	script := Script{}.PushData(scriptNum(m.Threshold).bytes())
+	for _, key := range m.PublicKeys {
+		script = script.PushData(key.serialize(key.Compressed))
+	}
+	script = script.PushData(scriptNum(len(m.PublicKeys)).bytes())
+	return script.AddOpcode(OpCheckMultiSig)
+}
+
+// Address computes the P2SH address of the multisig
+func (m *MultiSig) Address() (string, error) {
+	if len(m.PublicKeys) > maxP2SHMultiSigKeys {
+		return "", errors.New("too many keys in P2SH multisig")
+	}
+	return ScriptHashAddress(m.Script(), m.Network)
+}
+
+// SegwitAddress computes the native segwit (P2WSH) address of the multisig
+func (m *MultiSig) SegwitAddress() (string, error) {
+	program, err := m.witnessProgram()
+	if err != nil {
+		return "", err
+	}
+	return encodeSegwitAddress(m.Network.Bech32HRP, 0, program)
+}
+
+// NestedSegwitAddress computes the P2SH-wrapped segwit (P2SH-P2WSH) address of the multisig
+func (m *MultiSig) NestedSegwitAddress() (string, error) {
+	program, err := m.witnessProgram()
+	if err != nil {
+		return "", err
+	}
+
+	/* The redeem script is the P2WSH witness program: 0 <sha256(script)> */
+	redeemScript := append([]byte{0x00, 0x20}, program...)
+	return ScriptHashAddress(redeemScript, m.Network)
+}
+
+/* witnessProgram returns the P2WSH program of the multisig, uncompressed keys are non-standard in witness scripts */
+func (m *MultiSig) witnessProgram() ([]byte, error) {
+	for _, key := range m.PublicKeys {
+		if !key.Compressed {
+			return nil, errors.New("segwit addresses require compressed public keys")
+		}
+	}
+	return singleHash(m.Script()), nil
+}
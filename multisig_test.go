@@ -0,0 +1,175 @@
+package btc
+
+import (
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func parseMultiSigKeys(t *testing.T, keys []string) []*PublicKey {
+	publicKeys := make([]*PublicKey, len(keys))
+	for i, key := range keys {
+		var err error
+		publicKeys[i], err = PublicFromHex(key, MainNetwork)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return publicKeys
+}
+
+func TestMultiSigBIP67(t *testing.T) {
+	tests := []struct {
+		threshold int
+		keys      []string
+		script    string
+		address   string
+	}{
+		{
+			threshold: 2,
+			keys: []string{
+				"02ff12471208c14bd580709cb2358d98975247d8765f92bc25eab3b2763ed605f8",
+				"02fe6f0a5a297eb38c391581c4413e084773ea23954d93f7753db7dc0adc188b2f",
+			},
+			script:  "522102fe6f0a5a297eb38c391581c4413e084773ea23954d93f7753db7dc0adc188b2f2102ff12471208c14bd580709cb2358d98975247d8765f92bc25eab3b2763ed605f852ae",
+			address: "39bgKC7RFbpoCRbtD5KEdkYKtNyhpsNa3Z",
+		},
+		{
+			threshold: 2,
+			keys: []string{
+				"02632b12f4ac5b1d1b72b2a3b508c19172de44f6f46bcee50ba33f3f9291e47ed0",
+				"027735a29bae7780a9755fae7a1c4374c656ac6a69ea9f3697fda61bb99a4f3e77",
+				"02e2cc6bd5f45edd43bebe7cb9b675f0ce9ed3efe613b177588290ad188d11b404",
+			},
+			script:  "522102632b12f4ac5b1d1b72b2a3b508c19172de44f6f46bcee50ba33f3f9291e47ed021027735a29bae7780a9755fae7a1c4374c656ac6a69ea9f3697fda61bb99a4f3e772102e2cc6bd5f45edd43bebe7cb9b675f0ce9ed3efe613b177588290ad188d11b40453ae",
+			address: "3CKHTjBKxCARLzwABMu9yD85kvtm7WnMfH",
+		},
+		{
+			threshold: 2,
+			keys: []string{
+				"022df8750480ad5b26950b25c7ba79d3e37d75f640f8e5d9bcd5b150a0f85014da",
+				"03e3818b65bcc73a7d64064106a859cc1a5a728c4345ff0b641209fba0d90de6e9",
+				"021f2f6e1e50cb6a953935c3601284925decd3fd21bc445712576873fb8c6ebc18",
+			},
+			script:  "5221021f2f6e1e50cb6a953935c3601284925decd3fd21bc445712576873fb8c6ebc1821022df8750480ad5b26950b25c7ba79d3e37d75f640f8e5d9bcd5b150a0f85014da2103e3818b65bcc73a7d64064106a859cc1a5a728c4345ff0b641209fba0d90de6e953ae",
+			address: "3Q4sF6tv9wsdqu2NtARzNCpQgwifm2rAba",
+		},
+	}
+
+	for _, test := range tests {
+		m, err := NewMultiSig(test.threshold, parseMultiSigKeys(t, test.keys), MainNetwork)
+		if !assert.Nil(t, err, test.address) {
+			continue
+		}
+		assert.Equal(t, test.script, hex.EncodeToString(m.Script()), test.address)
+
+		address, err := m.Address()
+		assert.Nil(t, err)
+		assert.Equal(t, test.address, address)
+	}
+}
+
+func TestMultiSigAddresses(t *testing.T) {
+	keys := []string{
+		"03669b8afcec803a0d323e9a17f3ea8e68e8abe5a278020a929adbec52421adbd0",
+		"0260b2003c386519fc9eadf2b5cf124dd8eea4c4e68d5e154050a9346ea98ce600",
+		"0362a74e399c39ed5593852a30147f2959b56bb827dfa3e60e464b02ccf87dc5e8",
+		"0261345b53de74a4d721ef877c255429961b7e43714171ac06168d7e08c542a8b8",
+		"02da72e8b46901a65d4374fe6315538d8f368557dda3a1dcf9ea903f3afe7314c8",
+		"0318c82dd0b53fd3a932d16e0ba9e278fcc937c582d5781be626ff16e201f72286",
+		"0297ccef1ef99f9d73dec9ad37476ddb232f1238aff877af19e72ba04493361009",
+		"02e502cfd5c3f972fe9a3e2a18827820638f96b6f347e54d63deb839011fd5765d",
+		"03e687710f0e3ebe81c1037074da939d409c0025f17eb86adb9427d28f0f7ae0e9",
+		"02c04d3a5274952acdbc76987f3184b346a483d43be40874624b29e3692c1df5af",
+		"02ed06e0f418b5b43a7ec01d1d7d27290fa15f75771cb69b642a51471c29c84acd",
+		"036d46073cbb9ffee90473f3da429abc8de7f8751199da44485682a989a4bebb24",
+		"02f5d1ff7c9029a80a4e36b9a5497027ef7f3e73384a4a94fbfe7c4e9164eec8bc",
+		"02e41deffd1b7cce11cde209a781adcffdabd1b91c0ba0375857a2bfd9302419f3",
+		"02d76625f7956a7fc505ab02556c23ee72d832f1bac391bcd2d3abce5710a13d06",
+		"0399eb0a5487515802dc14544cf10b3666623762fbed2ec38a3975716e2c29c232",
+	}
+
+	/* The addresses match the ones of sortedmulti descriptors */
+	for _, n := range []int{1, 3, 15, 16} {
+		for _, network := range []*Network{MainNetwork, TestNetwork} {
+			m, err := NewMultiSig(n, parseMultiSigKeys(t, keys[:n]), network)
+			if !assert.Nil(t, err) {
+				continue
+			}
+			sortedMulti := "sortedmulti(" + strconv.Itoa(n) + "," + strings.Join(keys[:n], ",") + ")"
+
+			expected := map[string]func() (string, error){
+				"sh(" + sortedMulti + ")":      m.Address,
+				"wsh(" + sortedMulti + ")":     m.SegwitAddress,
+				"sh(wsh(" + sortedMulti + "))": m.NestedSegwitAddress,
+			}
+			for descriptor, address := range expected {
+				a, err := address()
+				if n > 15 && strings.HasPrefix(descriptor, "sh(sortedmulti") {
+					assert.NotNil(t, err, descriptor)
+					continue
+				}
+				assert.Nil(t, err, descriptor)
+
+				d, err := ParseDescriptor(descriptor)
+				if !assert.Nil(t, err, descriptor) {
+					continue
+				}
+				script, err := d.Script(0)
+				assert.Nil(t, err, descriptor)
+				expectedAddress, err := AddressFromScript(script, network)
+				assert.Nil(t, err, descriptor)
+				assert.Equal(t, expectedAddress.String(), a, descriptor)
+			}
+		}
+	}
+}
+
+func TestMultiSigLimits(t *testing.T) {
+	var keys []*PublicKey
+	for i := 0; i < 21; i++ {
+		key := GeneratePrivateKey(MainNetwork)
+		publicKey, _ := key.GetPublicKey()
+		publicKey.Compressed = true
+		keys = append(keys, publicKey)
+	}
+
+	_, err := NewMultiSig(0, keys[:3], MainNetwork)
+	assert.NotNil(t, err)
+	_, err = NewMultiSig(4, keys[:3], MainNetwork)
+	assert.NotNil(t, err)
+	_, err = NewMultiSig(1, nil, MainNetwork)
+	assert.NotNil(t, err)
+	_, err = NewMultiSig(1, keys, MainNetwork)
+	assert.NotNil(t, err)
+
+	/* 20 keys are only standard in witness scripts */
+	unsorted := append([]*PublicKey{}, keys[:20]...)
+	m, err := NewMultiSig(20, keys[:20], MainNetwork)
+	assert.Nil(t, err)
+	_, err = m.Address()
+	assert.NotNil(t, err)
+	_, err = m.SegwitAddress()
+	assert.Nil(t, err)
+	_, err = m.NestedSegwitAddress()
+	assert.Nil(t, err)
+
+	/* The keys are not sorted in place */
+	assert.Equal(t, unsorted, keys[:20])
+
+	/* Uncompressed keys are only allowed in P2SH */
+	uncompressed := *keys[0]
+	uncompressed.Compressed = false
+	m, err = NewMultiSig(1, []*PublicKey{&uncompressed, keys[1]}, MainNetwork)
+	assert.Nil(t, err)
+	assert.Equal(t, 1+66+34+1+1, len(m.Script()))
+	_, err = m.Address()
+	assert.Nil(t, err)
+	_, err = m.SegwitAddress()
+	assert.NotNil(t, err)
+	_, err = m.NestedSegwitAddress()
+	assert.NotNil(t, err)
+}
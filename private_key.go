@@ -40,30 +40,41 @@ func CheckWIF(wif string) bool {
 	return hash2Hex[0:8] == checksum
 }
 
-// PrivateFromWIF imports a private key from its base58 address
+// PrivateFromWIF imports a private key from its base58 address, compressed or not
 func PrivateFromWIF(wif string, network *Network) (*PrivateKey, error) {
-
-	valid := CheckWIF(wif)
-	if !valid {
+	payload, err := base58CheckDecode(wif)
+	if err != nil {
 		return nil, errors.New("wif invalid")
 	}
 
-	bytes, err := base58.Decode(wif)
-	if err != nil {
-		return nil, err
+	/* Format: network byte || key || 0x01 if the public key is compressed */
+	var compressed bool
+	switch {
+	case len(payload) == 33:
+		compressed = false
+	case len(payload) == 34 && payload[33] == 0x01:
+		compressed = true
+	default:
+		return nil, errors.New("invalid wif length")
 	}
 
-	hexa := hex.EncodeToString(bytes)
+	if strings.ToUpper(hex.EncodeToString(payload[0:1])) != strings.ToUpper(network.PrivKeyPrefix) {
+		return nil, errors.New("wif belongs to a different network")
+	}
 
-	hexa = hexa[2:len(hexa)]
-	hexa = hexa[0 : len(hexa)-8]
+	hexa := hex.EncodeToString(payload[1:33])
 
 	var privateKey PrivateKey
 	privateKey.WIF = wif
 	privateKey.Hex = hexa
 	privateKey.Key, _ = new(big.Int).SetString(hexa, 16)
+	privateKey.Compressed = compressed
 	privateKey.Network = network
 
+	if privateKey.Key.Sign() == 0 || privateKey.Key.Cmp(secp256k1.N) >= 0 {
+		return nil, errors.New("private key not in 1..n-1")
+	}
+
 	return &privateKey, nil
 }
 
@@ -113,6 +124,19 @@ func PrivateFromHex(hexa string, network *Network) (*PrivateKey, error) {
 	return &p, nil
 }
 
+// SetCompressed sets whether the public key of the private key is compressed and updates its WIF
+func (p *PrivateKey) SetCompressed(compressed bool) {
+	prefix, _ := hex.DecodeString(p.Network.PrivKeyPrefix)
+
+	payload := append(prefix, paddedBytes(p.Key, 32)...)
+	if compressed {
+		payload = append(payload, 0x01)
+	}
+
+	p.WIF = base58CheckEncode(payload)
+	p.Compressed = compressed
+}
+
 // GeneratePrivateKey returns a PrivateKey
 func GeneratePrivateKey(network *Network) *PrivateKey {
 
@@ -150,7 +174,14 @@ func AddPrivateKeys(p1 *PrivateKey, p2 *PrivateKey) (*PrivateKey, error) {
 	hexa := bigIntToHex(pKey)
 
 	privateKey, err := PrivateFromHex(hexa, p1.Network)
-	return privateKey, err
+	if err != nil {
+		return nil, err
+	}
+	/* The result keeps the compression of the first key */
+	if p1.Compressed {
+		privateKey.SetCompressed(true)
+	}
+	return privateKey, nil
 }
 
 // MultiplyPrivateKeys merge two private keys together by multiplication
@@ -171,5 +202,12 @@ func MultiplyPrivateKeys(p1 *PrivateKey, p2 *PrivateKey) (*PrivateKey, error) {
 	hexa := bigIntToHex(pKey)
 
 	privateKey, err := PrivateFromHex(hexa, p1.Network)
-	return privateKey, err
+	if err != nil {
+		return nil, err
+	}
+	/* The result keeps the compression of the first key */
+	if p1.Compressed {
+		privateKey.SetCompressed(true)
+	}
+	return privateKey, nil
 }
@@ -121,6 +121,65 @@ func TestPrivateFromWIF(t *testing.T) {
 	assert.NotNil(t, err)
 }
 
+func TestCompressedWIF(t *testing.T) {
+	var wifArray = []struct {
+		WIF        string
+		Hex        string
+		Compressed bool
+		Network    *Network
+	}{
+		/* Bitcoin Core key_io_valid.json */
+		{"5JuW2AMDYu4xVwRG9DZW18VbzQrGcd5RCgb99sS6ehJsNQXu5b9", "8f8943bf956de595665c38ffff23827e17c10cdc1c27a028caae6c9810626198", false, MainNetwork},
+		{"L5nJeqKmpHp4P7F8ZYyjwc5a7P4d8EabuGAzfGJk7yC1BJyzNaEd", "ff778740f88ddcf102aeb81daee289c044c4a4571c4b6f287400f4b8e0b843f8", true, MainNetwork},
+		{"5HsL2nZuEebU5nM3RxNVQD9GcAnvNMahqQskf4fkqHe54zwd14e", "06e8649790a90615a46d22dd762e0c42615336745356c2e16147c0f3d46b40d5", false, MainNetwork},
+		{"KwuVvu6hsuEMHrfFWJQV64tRrWX3QzqHH18JuAHYqYV6dqBvNKxd", "147804bf8a0dfff35939a611c7f5a60ac107f33f33d6059f273d2079ab1d90f2", true, MainNetwork},
+		{"92ZdE5HoLafywnTBbzPxbvRmp75pSfzvdU3XaZGh1cToipgdHVh", "80c32d81e91bdea04cd7a3819b32275fc3298af4c7ec87eb0099527d041ced5c", false, TestNetwork},
+		{"cV83kKisF3RQSvXbUCm9ox3kaz5JjEUBWcx8tNydfGJcyeUxuH47", "e0fcd4ce4e3d0e3de091f21415bb7cd011fac288c42020a879f28c2a4387df9b", true, TestNetwork},
+		{"921M1RNxghFcsVGqAJksQVbSgx36Yz4u6vebfz1wDujNvgNt93B", "3777b341c45e2a9b9bf6bfb71dc7d129f64f1b9406ed4f93ade8f56065f1b732", false, TestNetwork},
+		{"cNEnbfF2fcxmmCLWqMAaq6fxJvVkwMbyU3kCbpQznz4Z1j6TZDGb", "1397b0d4a03e1ab2c54dd9af99ce1ecbfb90c80a58886da95e1181a55703d96b", true, TestNetwork},
+		{"91mn1wYKEB1zyof1VFm8tMtocZx1oBrKKRCu9GCpgZvPmBLEJjp", "18a86e5a6c6977ddba0daca7fba5190f67ba56ccdc1b3f31308972236c2e4776", false, RegTestNetwork},
+		{"cPisAUdLvqqAr6MYtXnrWvgvyUAwuNyuTvZkDGw6miPhZdaiSDNH", "3fdfec1371cedcdb8c190ca6ff8ad603f817edc0d93c2a687c7b36dd66e70f2a", true, RegTestNetwork},
+	}
+
+	for _, value := range wifArray {
+		privateKey, err := PrivateFromWIF(value.WIF, value.Network)
+		assert.Nil(t, err)
+		assert.Equal(t, value.Hex, privateKey.Hex)
+		assert.Equal(t, value.Compressed, privateKey.Compressed)
+
+		/* Round trip */
+		exported, err := PrivateFromHex(value.Hex, value.Network)
+		assert.Nil(t, err)
+		exported.SetCompressed(value.Compressed)
+		assert.Equal(t, value.WIF, exported.WIF)
+
+		/* The flag drives the default address format */
+		publicKey, valid := privateKey.GetPublicKey()
+		assert.True(t, valid)
+		assert.Equal(t, value.Compressed, publicKey.Compressed)
+		address, err := publicKey.DefaultAddress()
+		assert.Nil(t, err)
+		expected, err := publicKey.Address(value.Compressed)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, address)
+	}
+
+	/* Network byte mismatch */
+	_, err := PrivateFromWIF("L5nJeqKmpHp4P7F8ZYyjwc5a7P4d8EabuGAzfGJk7yC1BJyzNaEd", TestNetwork)
+	assert.NotNil(t, err)
+	_, err = PrivateFromWIF("cV83kKisF3RQSvXbUCm9ox3kaz5JjEUBWcx8tNydfGJcyeUxuH47", MainNetwork)
+	assert.NotNil(t, err)
+	_, err = PrivateFromWIF("5JuW2AMDYu4xVwRG9DZW18VbzQrGcd5RCgb99sS6ehJsNQXu5b9", TestNetwork)
+	assert.NotNil(t, err)
+
+	/* Payload of a valid checksum with an invalid compression byte */
+	_, err = PrivateFromWIF(base58CheckEncode(append(append([]byte{0x80}, make([]byte, 31)...), 0x01, 0x02)), MainNetwork)
+	assert.NotNil(t, err)
+	/* Zero key */
+	_, err = PrivateFromWIF(base58CheckEncode(append([]byte{0x80}, make([]byte, 32)...)), MainNetwork)
+	assert.NotNil(t, err)
+}
+
 func TestWIF(t *testing.T) {
 	wifArray := []string{
 		"5Jw5VXRjdyojUobJQ96Psr8zmXZKHsjYgpLXf536ozN6uLZpNDD",
@@ -314,3 +373,24 @@ func TestMultiplyPrivateKeys(t *testing.T) {
 	}
 
 }
+
+func TestCombinePrivateKeysCompressed(t *testing.T) {
+	/* The results keep the compression of the keys */
+	p1, _ := PrivateFromWIF("931krx7yFnVSoHb1JxeN1W2rrhWARbNfiK3BA3xVZZrfgALvkMy", TestNetwork)
+	p2, _ := PrivateFromWIF("92iDRThnHRquXbFLgZVdYzFjVUYz8difQtwjeMRRULKNbxNWkKV", TestNetwork)
+	p1.SetCompressed(true)
+	p2.SetCompressed(true)
+
+	for wif, combine := range map[string]func(*PrivateKey, *PrivateKey) (*PrivateKey, error){
+		"92CJwm76271zxzN5RQBBeXPp6fci5F6xjXF4WK169vbLcAqsJnz": AddPrivateKeys,
+		"93EZpcjYBGSUyracS6r2S7zuies3L5JTaA3aWq8e7MuzEsgJN79": MultiplyPrivateKeys,
+	} {
+		privateKey, err := combine(p1, p2)
+		assert.Nil(t, err)
+		assert.True(t, privateKey.Compressed)
+
+		expected, _ := PrivateFromWIF(wif, TestNetwork)
+		expected.SetCompressed(true)
+		assert.Equal(t, expected.WIF, privateKey.WIF)
+	}
+}
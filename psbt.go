@@ -0,0 +1,1538 @@
+package btc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"sort"
+
+	"golang.org/x/crypto/ripemd160"
+)
+
+/* psbtMagic starts every PSBT: "psbt" followed by 0xff */
+var psbtMagic = []byte{0x70, 0x73, 0x62, 0x74, 0xff}
+
+/* Global key types */
+const (
+	psbtGlobalUnsignedTx       = 0x00
+	psbtGlobalXPub             = 0x01
+	psbtGlobalTxVersion        = 0x02
+	psbtGlobalFallbackLockTime = 0x03
+	psbtGlobalInputCount       = 0x04
+	psbtGlobalOutputCount      = 0x05
+	psbtGlobalTxModifiable     = 0x06
+	psbtGlobalVersion          = 0xfb
+)
+
+/* Input key types, taproot fields are kept as unknown records */
+const (
+	psbtInNonWitnessUtxo         = 0x00
+	psbtInWitnessUtxo            = 0x01
+	psbtInPartialSig             = 0x02
+	psbtInSigHashType            = 0x03
+	psbtInRedeemScript           = 0x04
+	psbtInWitnessScript          = 0x05
+	psbtInBip32Derivation        = 0x06
+	psbtInFinalScriptSig         = 0x07
+	psbtInFinalScriptWitness     = 0x08
+	psbtInRIPEMD160              = 0x0a
+	psbtInSHA256                 = 0x0b
+	psbtInHash160                = 0x0c
+	psbtInHash256                = 0x0d
+	psbtInPreviousTxID           = 0x0e
+	psbtInOutputIndex            = 0x0f
+	psbtInSequence               = 0x10
+	psbtInRequiredTimeLockTime   = 0x11
+	psbtInRequiredHeightLockTime = 0x12
+)
+
+/* Output key types */
+const (
+	psbtOutRedeemScript    = 0x00
+	psbtOutWitnessScript   = 0x01
+	psbtOutBip32Derivation = 0x02
+	psbtOutAmount          = 0x03
+	psbtOutScript          = 0x04
+)
+
+/* psbtProprietary is the key type of proprietary records in all maps */
+const psbtProprietary = 0xfc
+
+/* Known key types which have no key data */
+var (
+	psbtGlobalKeyless = map[uint64]bool{
+		psbtGlobalUnsignedTx: true, psbtGlobalTxVersion: true, psbtGlobalFallbackLockTime: true, psbtGlobalInputCount: true,
+		psbtGlobalOutputCount: true, psbtGlobalTxModifiable: true, psbtGlobalVersion: true,
+	}
+	psbtInputKeyless = map[uint64]bool{
+		psbtInNonWitnessUtxo: true, psbtInWitnessUtxo: true, psbtInSigHashType: true, psbtInRedeemScript: true,
+		psbtInWitnessScript: true, psbtInFinalScriptSig: true, psbtInFinalScriptWitness: true, psbtInPreviousTxID: true,
+		psbtInOutputIndex: true, psbtInSequence: true, psbtInRequiredTimeLockTime: true, psbtInRequiredHeightLockTime: true,
+	}
+	psbtOutputKeyless = map[uint64]bool{
+		psbtOutRedeemScript: true, psbtOutWitnessScript: true, psbtOutAmount: true, psbtOutScript: true,
+	}
+)
+
+// Flags of the PSBT_GLOBAL_TX_MODIFIABLE field (BIP370)
+const (
+	PSBTInputsModifiable  byte = 0x01
+	PSBTOutputsModifiable byte = 0x02
+	PSBTHasSigHashSingle  byte = 0x04
+)
+
+/* psbtRecord is a key-value pair with its key type decoded */
+type psbtRecord struct {
+	keyType uint64
+	keyData []byte
+	key     []byte
+	value   []byte
+}
+
+// NewPSBT creates a version 0 PSBT from an unsigned transaction
+func NewPSBT(tx *Transaction) (*PSBT, error) {
+	for _, in := range tx.Inputs {
+		if len(in.ScriptSig) > 0 || len(in.Witness) > 0 {
+			return nil, errors.New("transaction inputs must not be signed")
+		}
+	}
+
+	unsignedTx, err := readUnsignedTransaction(tx.SerializeNoWitness())
+	if err != nil {
+		return nil, err
+	}
+
+	p := &PSBT{UnsignedTx: unsignedTx}
+	for range tx.Inputs {
+		p.Inputs = append(p.Inputs, &PSBTInput{})
+	}
+	for range tx.Outputs {
+		p.Outputs = append(p.Outputs, &PSBTOutput{})
+	}
+	return p, nil
+}
+
+// NewPSBTV2 creates an empty version 2 PSBT to which inputs and outputs can be added
+func NewPSBTV2(txVersion int32) *PSBT {
+	modifiable := PSBTInputsModifiable | PSBTOutputsModifiable
+	return &PSBT{
+		Version:      2,
+		TxVersion:    txVersion,
+		TxModifiable: &modifiable,
+	}
+}
+
+// PSBTFromBase64 parses a PSBT from its base64 encoding
+func PSBTFromBase64(s string) (*PSBT, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return PSBTFromBytes(b)
+}
+
+// PSBTFromBytes parses a binary PSBT and checks it is valid for its version
+func PSBTFromBytes(b []byte) (*PSBT, error) {
+	if len(b) < len(psbtMagic) || !bytes.Equal(b[:len(psbtMagic)], psbtMagic) {
+		return nil, errors.New("invalid psbt magic bytes")
+	}
+	r := &byteReader{b: b, pos: len(psbtMagic)}
+
+	var p PSBT
+	records, err := readPSBTMap(r)
+	if err != nil {
+		return nil, err
+	}
+	inputCount, outputCount, err := p.parseGlobal(records)
+	if err != nil {
+		return nil, err
+	}
+
+	if inputCount > r.remaining() || outputCount > r.remaining() {
+		return nil, errors.New("psbt map count exceeds data size")
+	}
+	for i := 0; i < inputCount; i++ {
+		records, err := readPSBTMap(r)
+		if err != nil {
+			return nil, err
+		}
+		in, err := parsePSBTInput(records, p.Version)
+		if err != nil {
+			return nil, err
+		}
+		p.Inputs = append(p.Inputs, in)
+	}
+	for i := 0; i < outputCount; i++ {
+		records, err := readPSBTMap(r)
+		if err != nil {
+			return nil, err
+		}
+		out, err := parsePSBTOutput(records, p.Version)
+		if err != nil {
+			return nil, err
+		}
+		p.Outputs = append(p.Outputs, out)
+	}
+
+	if r.remaining() != 0 {
+		return nil, errors.New("unexpected data after psbt")
+	}
+
+	/* Non-witness UTXOs must be the transactions spent by the inputs */
+	for i := range p.Inputs {
+		if _, _, err := p.spentOutput(i); err != nil {
+			return nil, err
+		}
+	}
+
+	return &p, nil
+}
+
+/* readPSBTMap reads the key-value pairs of a map up to its separator and rejects duplicate keys */
+func readPSBTMap(r *byteReader) ([]*psbtRecord, error) {
+	var records []*psbtRecord
+	keys := make(map[string]bool)
+	for {
+		key, err := r.readVarBytes()
+		if err != nil {
+			return nil, err
+		}
+		/* An empty key is the separator */
+		if len(key) == 0 {
+			return records, nil
+		}
+
+		value, err := r.readVarBytes()
+		if err != nil {
+			return nil, err
+		}
+
+		if keys[string(key)] {
+			return nil, errors.New("duplicate psbt key")
+		}
+		keys[string(key)] = true
+
+		keyReader := &byteReader{b: key}
+		keyType, err := keyReader.readCompactSize()
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, &psbtRecord{
+			keyType: keyType,
+			keyData: key[keyReader.pos:],
+			key:     key,
+			value:   value,
+		})
+	}
+}
+
+/* checkPSBTFields rejects the key types which are not allowed in a version, and checks the required ones are present */
+func checkPSBTFields(seen map[uint64]bool, forbidden []uint64, required []uint64) error {
+	for _, keyType := range forbidden {
+		if seen[keyType] {
+			return errors.New("psbt field not allowed in this version")
+		}
+	}
+	for _, keyType := range required {
+		if !seen[keyType] {
+			return errors.New("missing required psbt field")
+		}
+	}
+	return nil
+}
+
+/* parseGlobal fills the global fields and returns the number of inputs and outputs */
+func (p *PSBT) parseGlobal(records []*psbtRecord) (int, int, error) {
+	var inputCount, outputCount uint64
+	seen := make(map[uint64]bool)
+	for _, record := range records {
+		seen[record.keyType] = true
+		if err := checkPSBTKey(record, psbtGlobalKeyless); err != nil {
+			return 0, 0, err
+		}
+
+		var err error
+		switch record.keyType {
+		case psbtGlobalUnsignedTx:
+			p.UnsignedTx, err = readUnsignedTransaction(record.value)
+			if err != nil {
+				return 0, 0, err
+			}
+			for _, in := range p.UnsignedTx.Inputs {
+				if len(in.ScriptSig) > 0 {
+					return 0, 0, errors.New("unsigned transaction has a scriptSig")
+				}
+			}
+		case psbtGlobalXPub:
+			derivation, err := parsePSBTDerivation(record, true)
+			if err != nil {
+				return 0, 0, err
+			}
+			p.XPubs = append(p.XPubs, derivation)
+		case psbtGlobalTxVersion:
+			version, err := psbtUint32(record.value)
+			if err != nil {
+				return 0, 0, err
+			}
+			p.TxVersion = int32(version)
+		case psbtGlobalFallbackLockTime:
+			lockTime, err := psbtUint32(record.value)
+			if err != nil {
+				return 0, 0, err
+			}
+			p.FallbackLockTime = &lockTime
+		case psbtGlobalInputCount:
+			inputCount, err = psbtCompactSize(record.value)
+		case psbtGlobalOutputCount:
+			outputCount, err = psbtCompactSize(record.value)
+		case psbtGlobalTxModifiable:
+			if len(record.value) != 1 {
+				return 0, 0, errors.New("invalid psbt value size")
+			}
+			modifiable := record.value[0]
+			p.TxModifiable = &modifiable
+		case psbtGlobalVersion:
+			p.Version, err = psbtUint32(record.value)
+			if err == nil && p.Version != 0 && p.Version != 2 {
+				err = errors.New("unsupported psbt version")
+			}
+		case psbtProprietary:
+			p.Proprietary = append(p.Proprietary, record.export())
+		default:
+			p.Unknown = append(p.Unknown, record.export())
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	v2Fields := []uint64{psbtGlobalTxVersion, psbtGlobalFallbackLockTime, psbtGlobalInputCount, psbtGlobalOutputCount, psbtGlobalTxModifiable}
+	if p.Version == 0 {
+		if err := checkPSBTFields(seen, v2Fields, []uint64{psbtGlobalUnsignedTx}); err != nil {
+			return 0, 0, err
+		}
+		return len(p.UnsignedTx.Inputs), len(p.UnsignedTx.Outputs), nil
+	}
+
+	if err := checkPSBTFields(seen, []uint64{psbtGlobalUnsignedTx}, []uint64{psbtGlobalTxVersion, psbtGlobalInputCount, psbtGlobalOutputCount}); err != nil {
+		return 0, 0, err
+	}
+	if inputCount > 0xffffffff || outputCount > 0xffffffff {
+		return 0, 0, errors.New("psbt map count exceeds data size")
+	}
+	return int(inputCount), int(outputCount), nil
+}
+
+func parsePSBTInput(records []*psbtRecord, version uint32) (*PSBTInput, error) {
+	var in PSBTInput
+	seen := make(map[uint64]bool)
+	for _, record := range records {
+		seen[record.keyType] = true
+		if err := checkPSBTKey(record, psbtInputKeyless); err != nil {
+			return nil, err
+		}
+
+		var err error
+		switch record.keyType {
+		case psbtInNonWitnessUtxo:
+			in.NonWitnessUtxo, err = TransactionFromBytes(record.value)
+		case psbtInWitnessUtxo:
+			in.WitnessUtxo, err = readPSBTTxOut(record.value)
+		case psbtInPartialSig:
+			if _, err := publicFromBytes(record.keyData, nil); err != nil {
+				return nil, err
+			}
+			in.PartialSigs = append(in.PartialSigs, &PSBTPartialSig{PublicKey: record.keyData, Signature: record.value})
+		case psbtInSigHashType:
+			var hashType uint32
+			hashType, err = psbtUint32(record.value)
+			sigHashType := SigHashType(hashType)
+			in.SigHashType = &sigHashType
+		case psbtInRedeemScript:
+			in.RedeemScript = record.value
+		case psbtInWitnessScript:
+			in.WitnessScript = record.value
+		case psbtInBip32Derivation:
+			var derivation *PSBTDerivation
+			derivation, err = parsePSBTDerivation(record, false)
+			in.Derivations = append(in.Derivations, derivation)
+		case psbtInFinalScriptSig:
+			in.FinalScriptSig = record.value
+		case psbtInFinalScriptWitness:
+			in.FinalScriptWitness, err = readPSBTWitness(record.value)
+		case psbtInRIPEMD160, psbtInSHA256, psbtInHash160, psbtInHash256:
+			err = in.addPreimage(record.keyType, record.keyData, record.value)
+		case psbtInPreviousTxID:
+			if len(record.value) != 32 {
+				return nil, errors.New("invalid psbt value size")
+			}
+			in.PreviousOutPoint.Hash = record.value
+		case psbtInOutputIndex:
+			in.PreviousOutPoint.Index, err = psbtUint32(record.value)
+		case psbtInSequence:
+			var sequence uint32
+			sequence, err = psbtUint32(record.value)
+			in.Sequence = &sequence
+		case psbtInRequiredTimeLockTime:
+			var lockTime uint32
+			lockTime, err = psbtUint32(record.value)
+			if err == nil && lockTime < LockTimeThreshold {
+				err = errors.New("required time lock time is a block height")
+			}
+			in.RequiredTimeLockTime = &lockTime
+		case psbtInRequiredHeightLockTime:
+			var lockTime uint32
+			lockTime, err = psbtUint32(record.value)
+			if err == nil && (lockTime == 0 || lockTime >= LockTimeThreshold) {
+				err = errors.New("required height lock time is not a block height")
+			}
+			in.RequiredHeightLockTime = &lockTime
+		case psbtProprietary:
+			in.Proprietary = append(in.Proprietary, record.export())
+		default:
+			in.Unknown = append(in.Unknown, record.export())
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	v2Fields := []uint64{psbtInPreviousTxID, psbtInOutputIndex, psbtInSequence, psbtInRequiredTimeLockTime, psbtInRequiredHeightLockTime}
+	var err error
+	if version == 0 {
+		err = checkPSBTFields(seen, v2Fields, nil)
+	} else {
+		err = checkPSBTFields(seen, nil, []uint64{psbtInPreviousTxID, psbtInOutputIndex})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &in, nil
+}
+
+func parsePSBTOutput(records []*psbtRecord, version uint32) (*PSBTOutput, error) {
+	var out PSBTOutput
+	seen := make(map[uint64]bool)
+	for _, record := range records {
+		seen[record.keyType] = true
+		if err := checkPSBTKey(record, psbtOutputKeyless); err != nil {
+			return nil, err
+		}
+
+		switch record.keyType {
+		case psbtOutRedeemScript:
+			out.RedeemScript = record.value
+		case psbtOutWitnessScript:
+			out.WitnessScript = record.value
+		case psbtOutBip32Derivation:
+			derivation, err := parsePSBTDerivation(record, false)
+			if err != nil {
+				return nil, err
+			}
+			out.Derivations = append(out.Derivations, derivation)
+		case psbtOutAmount:
+			if len(record.value) != 8 {
+				return nil, errors.New("invalid psbt value size")
+			}
+			out.Amount = int64(binary.LittleEndian.Uint64(record.value))
+		case psbtOutScript:
+			out.Script = record.value
+		case psbtProprietary:
+			out.Proprietary = append(out.Proprietary, record.export())
+		default:
+			out.Unknown = append(out.Unknown, record.export())
+		}
+	}
+
+	var err error
+	if version == 0 {
+		err = checkPSBTFields(seen, []uint64{psbtOutAmount, psbtOutScript}, nil)
+	} else {
+		err = checkPSBTFields(seen, nil, []uint64{psbtOutAmount, psbtOutScript})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+/* checkPSBTKey rejects key data on the key types which have none, and malformed proprietary keys */
+func checkPSBTKey(record *psbtRecord, keyless map[uint64]bool) error {
+	if keyless[record.keyType] && len(record.keyData) != 0 {
+		return errors.New("unexpected psbt key data")
+	}
+
+	/* Proprietary keys are an identifier followed by a subtype */
+	if record.keyType == psbtProprietary {
+		r := &byteReader{b: record.keyData}
+		if _, err := r.readVarBytes(); err != nil {
+			return err
+		}
+		_, err := r.readCompactSize()
+		return err
+	}
+	return nil
+}
+
+func (r *psbtRecord) export() *PSBTRecord {
+	return &PSBTRecord{Key: r.key, Value: r.value}
+}
+
+func psbtUint32(value []byte) (uint32, error) {
+	if len(value) != 4 {
+		return 0, errors.New("invalid psbt value size")
+	}
+	return binary.LittleEndian.Uint32(value), nil
+}
+
+func psbtCompactSize(value []byte) (uint64, error) {
+	r := &byteReader{b: value}
+	n, err := r.readCompactSize()
+	if err != nil {
+		return 0, err
+	}
+	if r.remaining() != 0 {
+		return 0, errors.New("invalid psbt value size")
+	}
+	return n, nil
+}
+
+/* readUnsignedTransaction reads a transaction in legacy serialization, which is not ambiguous when it has no inputs */
+func readUnsignedTransaction(b []byte) (*Transaction, error) {
+	r := &byteReader{b: b}
+	var tx Transaction
+
+	version, err := r.readUint32()
+	if err != nil {
+		return nil, err
+	}
+	tx.Version = int32(version)
+
+	tx.Inputs, err = readInputs(r)
+	if err != nil {
+		return nil, err
+	}
+	tx.Outputs, err = readOutputs(r)
+	if err != nil {
+		return nil, err
+	}
+	tx.LockTime, err = r.readUint32()
+	if err != nil {
+		return nil, err
+	}
+
+	if r.remaining() != 0 {
+		return nil, errors.New("unexpected data after transaction")
+	}
+	return &tx, nil
+}
+
+func readPSBTTxOut(value []byte) (*TxOut, error) {
+	r := &byteReader{b: value}
+	amount, err := r.readUint64()
+	if err != nil {
+		return nil, err
+	}
+	script, err := r.readVarBytes()
+	if err != nil {
+		return nil, err
+	}
+	if r.remaining() != 0 {
+		return nil, errors.New("invalid psbt value size")
+	}
+	return &TxOut{Value: int64(amount), ScriptPubKey: script}, nil
+}
+
+func readPSBTWitness(value []byte) ([][]byte, error) {
+	r := &byteReader{b: value}
+	count, err := r.readCount()
+	if err != nil {
+		return nil, err
+	}
+	witness := make([][]byte, count)
+	for i := range witness {
+		witness[i], err = r.readVarBytes()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if r.remaining() != 0 {
+		return nil, errors.New("invalid psbt value size")
+	}
+	return witness, nil
+}
+
+/* parsePSBTDerivation reads a key and its origin: the master key fingerprint followed by the derivation path */
+func parsePSBTDerivation(record *psbtRecord, xpub bool) (*PSBTDerivation, error) {
+	if xpub {
+		if len(record.keyData) != 78 {
+			return nil, errors.New("invalid extended public key size")
+		}
+	} else if _, err := publicFromBytes(record.keyData, nil); err != nil {
+		return nil, err
+	}
+	if len(record.value) == 0 || len(record.value)%4 != 0 {
+		return nil, errors.New("invalid derivation path length")
+	}
+
+	derivation := &PSBTDerivation{
+		Key:         record.keyData,
+		Fingerprint: record.value[0:4],
+	}
+	for i := 4; i < len(record.value); i += 4 {
+		derivation.Path = append(derivation.Path, binary.LittleEndian.Uint32(record.value[i:i+4]))
+	}
+	return derivation, nil
+}
+
+/* addPreimage checks the preimage matches its hash before adding it */
+func (in *PSBTInput) addPreimage(keyType uint64, hash []byte, preimage []byte) error {
+	var computed []byte
+	preimages := in.preimages(keyType)
+	switch keyType {
+	case psbtInRIPEMD160:
+		ripemd := ripemd160.New()
+		ripemd.Write(preimage)
+		computed = ripemd.Sum(nil)
+	case psbtInSHA256:
+		computed = singleHash(preimage)
+	case psbtInHash160:
+		computed = hash160(preimage)
+	case psbtInHash256:
+		computed = doubleHash(preimage)
+	}
+	if !bytes.Equal(computed, hash) {
+		return errors.New("preimage does not match its hash")
+	}
+
+	if *preimages == nil {
+		*preimages = make(map[string][]byte)
+	}
+	(*preimages)[hex.EncodeToString(hash)] = preimage
+	return nil
+}
+
+func (in *PSBTInput) preimages(keyType uint64) *map[string][]byte {
+	switch keyType {
+	case psbtInRIPEMD160:
+		return &in.RIPEMD160Preimages
+	case psbtInSHA256:
+		return &in.SHA256Preimages
+	case psbtInHash160:
+		return &in.Hash160Preimages
+	}
+	return &in.Hash256Preimages
+}
+
+/* appendPSBTRecord appends a key-value pair */
+func appendPSBTRecord(b []byte, keyType uint64, keyData []byte, value []byte) []byte {
+	key := append(compactSize(keyType), keyData...)
+	return appendVarBytes(appendVarBytes(b, key), value)
+}
+
+/* appendPSBTRecords appends raw records sorted by key */
+func appendPSBTRecords(b []byte, records []*PSBTRecord) []byte {
+	sorted := append([]*PSBTRecord{}, records...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].Key, sorted[j].Key) < 0
+	})
+	for _, record := range sorted {
+		b = appendVarBytes(appendVarBytes(b, record.Key), record.Value)
+	}
+	return b
+}
+
+/* appendPSBTDerivations appends key origins sorted by key */
+func appendPSBTDerivations(b []byte, keyType uint64, derivations []*PSBTDerivation) []byte {
+	sorted := append([]*PSBTDerivation{}, derivations...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].Key, sorted[j].Key) < 0
+	})
+	for _, derivation := range sorted {
+		value := append([]byte{}, derivation.Fingerprint...)
+		for _, index := range derivation.Path {
+			value = appendUint32(value, index)
+		}
+		b = appendPSBTRecord(b, keyType, derivation.Key, value)
+	}
+	return b
+}
+
+func appendPSBTUint32(b []byte, keyType uint64, value uint32) []byte {
+	return appendPSBTRecord(b, keyType, nil, appendUint32(nil, value))
+}
+
+// Serialize returns the binary PSBT, fields are written in key type order like Bitcoin Core does
+func (p *PSBT) Serialize() []byte {
+	b := append([]byte{}, psbtMagic...)
+
+	if p.Version == 0 && p.UnsignedTx != nil {
+		b = appendPSBTRecord(b, psbtGlobalUnsignedTx, nil, p.UnsignedTx.SerializeNoWitness())
+	}
+	b = appendPSBTDerivations(b, psbtGlobalXPub, p.XPubs)
+	if p.Version == 2 {
+		b = appendPSBTUint32(b, psbtGlobalTxVersion, uint32(p.TxVersion))
+		if p.FallbackLockTime != nil {
+			b = appendPSBTUint32(b, psbtGlobalFallbackLockTime, *p.FallbackLockTime)
+		}
+		b = appendPSBTRecord(b, psbtGlobalInputCount, nil, compactSize(uint64(len(p.Inputs))))
+		b = appendPSBTRecord(b, psbtGlobalOutputCount, nil, compactSize(uint64(len(p.Outputs))))
+		if p.TxModifiable != nil {
+			b = appendPSBTRecord(b, psbtGlobalTxModifiable, nil, []byte{*p.TxModifiable})
+		}
+	}
+	if p.Version != 0 {
+		b = appendPSBTUint32(b, psbtGlobalVersion, p.Version)
+	}
+	b = appendPSBTRecords(b, p.Proprietary)
+	b = appendPSBTRecords(b, p.Unknown)
+	b = append(b, 0x00)
+
+	for _, in := range p.Inputs {
+		b = in.serialize(b, p.Version)
+	}
+	for _, out := range p.Outputs {
+		b = out.serialize(b, p.Version)
+	}
+	return b
+}
+
+// Base64 returns the base64 encoding of the PSBT
+func (p *PSBT) Base64() string {
+	return base64.StdEncoding.EncodeToString(p.Serialize())
+}
+
+func (in *PSBTInput) serialize(b []byte, version uint32) []byte {
+	if in.NonWitnessUtxo != nil {
+		b = appendPSBTRecord(b, psbtInNonWitnessUtxo, nil, in.NonWitnessUtxo.Serialize())
+	}
+	if in.WitnessUtxo != nil {
+		value := appendUint64(nil, uint64(in.WitnessUtxo.Value))
+		b = appendPSBTRecord(b, psbtInWitnessUtxo, nil, appendVarBytes(value, in.WitnessUtxo.ScriptPubKey))
+	}
+
+	/* Like Bitcoin Core, partial signatures are sorted by the hash160 of their public key */
+	sigs := append([]*PSBTPartialSig{}, in.PartialSigs...)
+	sort.Slice(sigs, func(i, j int) bool {
+		return bytes.Compare(hash160(sigs[i].PublicKey), hash160(sigs[j].PublicKey)) < 0
+	})
+	for _, sig := range sigs {
+		b = appendPSBTRecord(b, psbtInPartialSig, sig.PublicKey, sig.Signature)
+	}
+
+	if in.SigHashType != nil {
+		b = appendPSBTUint32(b, psbtInSigHashType, uint32(*in.SigHashType))
+	}
+	if len(in.RedeemScript) > 0 {
+		b = appendPSBTRecord(b, psbtInRedeemScript, nil, in.RedeemScript)
+	}
+	if len(in.WitnessScript) > 0 {
+		b = appendPSBTRecord(b, psbtInWitnessScript, nil, in.WitnessScript)
+	}
+	b = appendPSBTDerivations(b, psbtInBip32Derivation, in.Derivations)
+	if len(in.FinalScriptSig) > 0 {
+		b = appendPSBTRecord(b, psbtInFinalScriptSig, nil, in.FinalScriptSig)
+	}
+	if len(in.FinalScriptWitness) > 0 {
+		witness := compactSize(uint64(len(in.FinalScriptWitness)))
+		for _, item := range in.FinalScriptWitness {
+			witness = appendVarBytes(witness, item)
+		}
+		b = appendPSBTRecord(b, psbtInFinalScriptWitness, nil, witness)
+	}
+
+	for _, keyType := range []uint64{psbtInRIPEMD160, psbtInSHA256, psbtInHash160, psbtInHash256} {
+		preimages := *in.preimages(keyType)
+		var hashes []string
+		for hash := range preimages {
+			hashes = append(hashes, hash)
+		}
+		sort.Strings(hashes)
+		for _, hash := range hashes {
+			keyData, _ := hex.DecodeString(hash)
+			b = appendPSBTRecord(b, keyType, keyData, preimages[hash])
+		}
+	}
+
+	if version == 2 {
+		b = appendPSBTRecord(b, psbtInPreviousTxID, nil, paddedOutPointHash(in.PreviousOutPoint))
+		b = appendPSBTUint32(b, psbtInOutputIndex, in.PreviousOutPoint.Index)
+		if in.Sequence != nil {
+			b = appendPSBTUint32(b, psbtInSequence, *in.Sequence)
+		}
+		if in.RequiredTimeLockTime != nil {
+			b = appendPSBTUint32(b, psbtInRequiredTimeLockTime, *in.RequiredTimeLockTime)
+		}
+		if in.RequiredHeightLockTime != nil {
+			b = appendPSBTUint32(b, psbtInRequiredHeightLockTime, *in.RequiredHeightLockTime)
+		}
+	}
+
+	b = appendPSBTRecords(b, in.Proprietary)
+	b = appendPSBTRecords(b, in.Unknown)
+	return append(b, 0x00)
+}
+
+func (out *PSBTOutput) serialize(b []byte, version uint32) []byte {
+	if len(out.RedeemScript) > 0 {
+		b = appendPSBTRecord(b, psbtOutRedeemScript, nil, out.RedeemScript)
+	}
+	if len(out.WitnessScript) > 0 {
+		b = appendPSBTRecord(b, psbtOutWitnessScript, nil, out.WitnessScript)
+	}
+	b = appendPSBTDerivations(b, psbtOutBip32Derivation, out.Derivations)
+	if version == 2 {
+		b = appendPSBTRecord(b, psbtOutAmount, nil, appendUint64(nil, uint64(out.Amount)))
+		b = appendPSBTRecord(b, psbtOutScript, nil, out.Script)
+	}
+	b = appendPSBTRecords(b, out.Proprietary)
+	b = appendPSBTRecords(b, out.Unknown)
+	return append(b, 0x00)
+}
+
+// Transaction returns the unsigned transaction of the PSBT, the lock time of version 2 PSBTs is computed from the inputs
+func (p *PSBT) Transaction() (*Transaction, error) {
+	if p.Version == 0 {
+		if p.UnsignedTx == nil {
+			return nil, errors.New("missing unsigned transaction")
+		}
+		tx := &Transaction{
+			Version:  p.UnsignedTx.Version,
+			LockTime: p.UnsignedTx.LockTime,
+		}
+		for _, in := range p.UnsignedTx.Inputs {
+			input := *in
+			tx.Inputs = append(tx.Inputs, &input)
+		}
+		for _, out := range p.UnsignedTx.Outputs {
+			output := *out
+			tx.Outputs = append(tx.Outputs, &output)
+		}
+		return tx, nil
+	}
+
+	lockTime, err := p.ComputeLockTime()
+	if err != nil {
+		return nil, err
+	}
+
+	tx := &Transaction{
+		Version:  p.TxVersion,
+		LockTime: lockTime,
+	}
+	for _, in := range p.Inputs {
+		sequence := uint32(0xffffffff)
+		if in.Sequence != nil {
+			sequence = *in.Sequence
+		}
+		tx.Inputs = append(tx.Inputs, &TxIn{
+			PreviousOutPoint: in.PreviousOutPoint,
+			Sequence:         sequence,
+		})
+	}
+	for _, out := range p.Outputs {
+		tx.Outputs = append(tx.Outputs, &TxOut{
+			Value:        out.Amount,
+			ScriptPubKey: out.Script,
+		})
+	}
+	return tx, nil
+}
+
+// ComputeLockTime returns the lock time of the transaction (BIP370): the greatest lock time of the type required by all the inputs, heights first
+func (p *PSBT) ComputeLockTime() (uint32, error) {
+	if p.Version == 0 {
+		if p.UnsignedTx == nil {
+			return 0, errors.New("missing unsigned transaction")
+		}
+		return p.UnsignedTx.LockTime, nil
+	}
+
+	required := false
+	heightAllowed, timeAllowed := true, true
+	var height, time uint32
+	for _, in := range p.Inputs {
+		if in.RequiredHeightLockTime == nil && in.RequiredTimeLockTime == nil {
+			continue
+		}
+		required = true
+
+		if in.RequiredHeightLockTime == nil {
+			heightAllowed = false
+		} else if *in.RequiredHeightLockTime > height {
+			height = *in.RequiredHeightLockTime
+		}
+		if in.RequiredTimeLockTime == nil {
+			timeAllowed = false
+		} else if *in.RequiredTimeLockTime > time {
+			time = *in.RequiredTimeLockTime
+		}
+	}
+
+	switch {
+	case !required && p.FallbackLockTime != nil:
+		return *p.FallbackLockTime, nil
+	case !required:
+		return 0, nil
+	case heightAllowed:
+		return height, nil
+	case timeAllowed:
+		return time, nil
+	}
+	return 0, errors.New("inputs require incompatible lock time types")
+}
+
+// UniqueID returns the txid identifying the PSBT, version 2 PSBTs use the unsigned transaction with sequences set to zero
+func (p *PSBT) UniqueID() (string, error) {
+	tx, err := p.Transaction()
+	if err != nil {
+		return "", err
+	}
+	if p.Version == 2 {
+		for _, in := range tx.Inputs {
+			in.Sequence = 0
+		}
+	}
+	return tx.TxID(), nil
+}
+
+/* outPoint returns the outpoint spent by input index */
+func (p *PSBT) outPoint(index int) OutPoint {
+	if p.Version == 0 {
+		return p.UnsignedTx.Inputs[index].PreviousOutPoint
+	}
+	return p.Inputs[index].PreviousOutPoint
+}
+
+/* spentOutput returns the output spent by input index, and whether it only comes from a witness UTXO */
+func (p *PSBT) spentOutput(index int) (*TxOut, bool, error) {
+	in := p.Inputs[index]
+	outPoint := p.outPoint(index)
+
+	if in.NonWitnessUtxo != nil {
+		if in.NonWitnessUtxo.TxID() != outPoint.TxID() {
+			return nil, false, errors.New("non-witness utxo does not match the outpoint")
+		}
+		if int(outPoint.Index) >= len(in.NonWitnessUtxo.Outputs) {
+			return nil, false, errors.New("outpoint index out of range of the non-witness utxo")
+		}
+		return in.NonWitnessUtxo.Outputs[outPoint.Index], false, nil
+	}
+	if in.WitnessUtxo != nil {
+		return in.WitnessUtxo, true, nil
+	}
+	return nil, false, nil
+}
+
+/* hasSignatures returns true if an input is signed or finalized */
+func (p *PSBT) hasSignatures() bool {
+	for _, in := range p.Inputs {
+		if len(in.PartialSigs) > 0 || in.isFinal() {
+			return true
+		}
+	}
+	return false
+}
+
+// AddInput adds an input to a version 2 PSBT whose inputs are modifiable
+func (p *PSBT) AddInput(in *PSBTInput) error {
+	if p.Version != 2 {
+		return errors.New("inputs can only be added to version 2 psbts")
+	}
+	if p.TxModifiable == nil || *p.TxModifiable&PSBTInputsModifiable == 0 {
+		return errors.New("psbt inputs are not modifiable")
+	}
+	if len(in.PreviousOutPoint.Hash) != 32 {
+		return errors.New("txid must be 32 bytes long")
+	}
+	for _, other := range p.Inputs {
+		if bytes.Equal(other.PreviousOutPoint.Hash, in.PreviousOutPoint.Hash) && other.PreviousOutPoint.Index == in.PreviousOutPoint.Index {
+			return errors.New("outpoint is already spent by an input")
+		}
+	}
+
+	/* The lock time must still be computable, and cannot change once inputs are signed */
+	lockTime, err := p.ComputeLockTime()
+	if err != nil {
+		return err
+	}
+	p.Inputs = append(p.Inputs, in)
+	newLockTime, err := p.ComputeLockTime()
+	if err == nil && newLockTime != lockTime && p.hasSignatures() {
+		err = errors.New("input changes the lock time of signed inputs")
+	}
+	if err != nil {
+		p.Inputs = p.Inputs[:len(p.Inputs)-1]
+		return err
+	}
+	return nil
+}
+
+// AddOutput adds an output to a version 2 PSBT whose outputs are modifiable
+func (p *PSBT) AddOutput(out *PSBTOutput) error {
+	if p.Version != 2 {
+		return errors.New("outputs can only be added to version 2 psbts")
+	}
+	if p.TxModifiable == nil || *p.TxModifiable&PSBTOutputsModifiable == 0 {
+		return errors.New("psbt outputs are not modifiable")
+	}
+	p.Outputs = append(p.Outputs, out)
+	return nil
+}
+
+/* scriptHasKey returns true if the script pushes the key or its hash160 */
+func scriptHasKey(script []byte, key []byte) bool {
+	keyHash := hash160(key)
+	pc := 0
+	for pc < len(script) {
+		opcode, data, next, ok := getScriptOp(script, pc)
+		if !ok {
+			return false
+		}
+		if opcode <= OpPushData4 && (bytes.Equal(data, key) || bytes.Equal(data, keyHash)) {
+			return true
+		}
+		pc = next
+	}
+	return false
+}
+
+/* matchScripts finds the redeem script and the witness script of an output script among scripts */
+func matchScripts(script []byte, scripts [][]byte) ([]byte, []byte) {
+	var redeemScript, witnessScript []byte
+	if isPayToScriptHash(script) {
+		for _, s := range scripts {
+			if bytes.Equal(hash160(s), script[2:22]) {
+				redeemScript = s
+				script = s
+				break
+			}
+		}
+	}
+	if Script(script).Type() == P2WSHScript {
+		for _, s := range scripts {
+			if bytes.Equal(singleHash(s), script[2:]) {
+				witnessScript = s
+				break
+			}
+		}
+	}
+	return redeemScript, witnessScript
+}
+
+/* addDerivations adds the key origins of the keys used by scripts */
+func addDerivations(derivations []*PSBTDerivation, origins []*PSBTDerivation, scripts ...[]byte) []*PSBTDerivation {
+	for _, origin := range origins {
+		known := false
+		for _, derivation := range derivations {
+			if bytes.Equal(derivation.Key, origin.Key) {
+				known = true
+			}
+		}
+		if known {
+			continue
+		}
+		for _, script := range scripts {
+			if scriptHasKey(script, origin.Key) {
+				derivations = append(derivations, origin)
+				break
+			}
+		}
+	}
+	return derivations
+}
+
+// Update adds the UTXOs found in prevTxs, the redeem and witness scripts found in scripts and the key origins of the keys they use
+func (p *PSBT) Update(prevTxs []*Transaction, scripts [][]byte, origins []*PSBTDerivation) error {
+	tx, err := p.Transaction()
+	if err != nil {
+		return err
+	}
+
+	for i, in := range p.Inputs {
+		outPoint := tx.Inputs[i].PreviousOutPoint
+		for _, prevTx := range prevTxs {
+			if prevTx.TxID() != outPoint.TxID() || int(outPoint.Index) >= len(prevTx.Outputs) {
+				continue
+			}
+
+			/* Witness outputs only need the spent output */
+			script := prevTx.Outputs[outPoint.Index].ScriptPubKey
+			if redeemScript, _ := matchScripts(script, scripts); redeemScript != nil {
+				script = redeemScript
+			}
+			if _, _, ok := witnessProgram(script); ok {
+				if in.WitnessUtxo == nil {
+					in.WitnessUtxo = prevTx.Outputs[outPoint.Index]
+				}
+			} else if in.NonWitnessUtxo == nil {
+				in.NonWitnessUtxo = prevTx
+			}
+		}
+
+		prevOut, _, err := p.spentOutput(i)
+		if err != nil {
+			return err
+		}
+		if prevOut == nil {
+			continue
+		}
+
+		redeemScript, witnessScript := matchScripts(prevOut.ScriptPubKey, scripts)
+		if len(in.RedeemScript) == 0 {
+			in.RedeemScript = redeemScript
+		}
+		if len(in.WitnessScript) == 0 {
+			in.WitnessScript = witnessScript
+		}
+		in.Derivations = addDerivations(in.Derivations, origins, prevOut.ScriptPubKey, in.RedeemScript, in.WitnessScript)
+	}
+
+	for i, out := range p.Outputs {
+		script := tx.Outputs[i].ScriptPubKey
+		redeemScript, witnessScript := matchScripts(script, scripts)
+		if len(out.RedeemScript) == 0 {
+			out.RedeemScript = redeemScript
+		}
+		if len(out.WitnessScript) == 0 {
+			out.WitnessScript = witnessScript
+		}
+		out.Derivations = addDerivations(out.Derivations, origins, script, out.RedeemScript, out.WitnessScript)
+	}
+	return nil
+}
+
+/* isFinal returns true if the input has a final scriptSig or witness */
+func (in *PSBTInput) isFinal() bool {
+	return len(in.FinalScriptSig) > 0 || len(in.FinalScriptWitness) > 0
+}
+
+/* signingScript returns the script signed by input index (BIP174 simple signer), and whether it is a segwit v0 script code */
+func (p *PSBT) signingScript(index int, prevOut *TxOut, witnessOnly bool) ([]byte, bool, error) {
+	in := p.Inputs[index]
+
+	script := prevOut.ScriptPubKey
+	if len(in.RedeemScript) > 0 {
+		if !bytes.Equal(script, (&Address{Type: P2SHAddress, Hash: hash160(in.RedeemScript)}).Script()) {
+			return nil, false, errors.New("redeem script does not match the spent output")
+		}
+		script = in.RedeemScript
+	}
+
+	switch Script(script).Type() {
+	case P2WPKHScript:
+		return (&Address{Type: P2PKHAddress, Hash: script[2:]}).Script(), true, nil
+	case P2WSHScript:
+		if len(in.WitnessScript) == 0 || !bytes.Equal(singleHash(in.WitnessScript), script[2:]) {
+			return nil, false, errors.New("witness script does not match the spent output")
+		}
+		return in.WitnessScript, true, nil
+	}
+
+	if _, _, ok := witnessProgram(script); ok {
+		return nil, false, errors.New("unsupported witness program")
+	}
+	if witnessOnly {
+		return nil, false, errors.New("witness utxo provided for a non-witness input")
+	}
+	if isPayToScriptHash(script) {
+		return nil, false, errors.New("missing redeem script")
+	}
+	return script, false, nil
+}
+
+// Sign adds the signatures of key to the inputs whose scripts use it, inputs without UTXO are skipped
+func (p *PSBT) Sign(key *PrivateKey) error {
+	tx, err := p.Transaction()
+	if err != nil {
+		return err
+	}
+
+	publicKey, valid := key.GetPublicKey()
+	if !valid {
+		return errors.New("invalid private key")
+	}
+
+	for i, in := range p.Inputs {
+		if in.isFinal() {
+			continue
+		}
+		prevOut, witnessOnly, err := p.spentOutput(i)
+		if err != nil {
+			return err
+		}
+		if prevOut == nil {
+			continue
+		}
+
+		scriptCode, witness, err := p.signingScript(i, prevOut, witnessOnly)
+		if err != nil {
+			return err
+		}
+
+		/* Only sign inputs using the key, in the serialization used by the script */
+		var pubKey []byte
+		for _, compressed := range []bool{true, false} {
+			if serialized := publicKey.serialize(compressed); scriptHasKey(scriptCode, serialized) {
+				pubKey = serialized
+				break
+			}
+		}
+		if pubKey == nil {
+			continue
+		}
+
+		hashType := SigHashAll
+		if in.SigHashType != nil {
+			hashType = *in.SigHashType
+		}
+		base := hashType &^ SigHashAnyoneCanPay
+		if base < SigHashAll || base > SigHashSingle {
+			return errors.New("unsupported sighash type")
+		}
+
+		var signature []byte
+		if witness {
+			signature, err = key.SignWitnessV0Input(tx, i, scriptCode, prevOut.Value, hashType)
+		} else {
+			signature, err = key.SignLegacyInput(tx, i, scriptCode, hashType)
+		}
+		if err != nil {
+			return err
+		}
+		in.addPartialSig(pubKey, signature)
+
+		/* Signatures restrict how version 2 PSBTs can be modified */
+		if p.Version == 2 {
+			var modifiable byte
+			if p.TxModifiable != nil {
+				modifiable = *p.TxModifiable
+			}
+			if hashType&SigHashAnyoneCanPay == 0 {
+				modifiable &^= PSBTInputsModifiable
+			}
+			if base != SigHashNone {
+				modifiable &^= PSBTOutputsModifiable
+			}
+			if base == SigHashSingle {
+				modifiable |= PSBTHasSigHashSingle
+			}
+			p.TxModifiable = &modifiable
+		}
+	}
+	return nil
+}
+
+func (in *PSBTInput) addPartialSig(publicKey []byte, signature []byte) {
+	for _, sig := range in.PartialSigs {
+		if bytes.Equal(sig.PublicKey, publicKey) {
+			sig.Signature = signature
+			return
+		}
+	}
+	in.PartialSigs = append(in.PartialSigs, &PSBTPartialSig{PublicKey: publicKey, Signature: signature})
+}
+
+// CombinePSBT merges the fields of PSBTs of the same transaction
+func CombinePSBT(psbts ...*PSBT) (*PSBT, error) {
+	if len(psbts) == 0 {
+		return nil, errors.New("no psbt to combine")
+	}
+
+	/* Copy the first PSBT so that none of them is modified */
+	combined, err := PSBTFromBytes(psbts[0].Serialize())
+	if err != nil {
+		return nil, err
+	}
+	id, err := combined.UniqueID()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range psbts[1:] {
+		if p.Version != combined.Version || len(p.Inputs) != len(combined.Inputs) || len(p.Outputs) != len(combined.Outputs) {
+			return nil, errors.New("psbts are not for the same transaction")
+		}
+		otherID, err := p.UniqueID()
+		if err != nil {
+			return nil, err
+		}
+		if otherID != id {
+			return nil, errors.New("psbts are not for the same transaction")
+		}
+
+		combined.XPubs = mergeDerivations(combined.XPubs, p.XPubs)
+		if combined.FallbackLockTime == nil {
+			combined.FallbackLockTime = p.FallbackLockTime
+		}
+		if p.TxModifiable != nil {
+			if combined.TxModifiable == nil {
+				modifiable := *p.TxModifiable
+				combined.TxModifiable = &modifiable
+			} else {
+				/* Signers only remove the modifiable flags and add the SIGHASH_SINGLE one */
+				modifiable := *combined.TxModifiable & *p.TxModifiable
+				modifiable |= (*combined.TxModifiable | *p.TxModifiable) & PSBTHasSigHashSingle
+				combined.TxModifiable = &modifiable
+			}
+		}
+		combined.Proprietary = mergeRecords(combined.Proprietary, p.Proprietary)
+		combined.Unknown = mergeRecords(combined.Unknown, p.Unknown)
+
+		for i, in := range p.Inputs {
+			combined.Inputs[i].merge(in)
+		}
+		for i, out := range p.Outputs {
+			combined.Outputs[i].merge(out)
+		}
+	}
+	return combined, nil
+}
+
+func (in *PSBTInput) merge(other *PSBTInput) {
+	if in.NonWitnessUtxo == nil {
+		in.NonWitnessUtxo = other.NonWitnessUtxo
+	}
+	if in.WitnessUtxo == nil {
+		in.WitnessUtxo = other.WitnessUtxo
+	}
+	for _, sig := range other.PartialSigs {
+		known := false
+		for _, s := range in.PartialSigs {
+			if bytes.Equal(s.PublicKey, sig.PublicKey) {
+				known = true
+			}
+		}
+		if !known {
+			in.PartialSigs = append(in.PartialSigs, sig)
+		}
+	}
+	if in.SigHashType == nil {
+		in.SigHashType = other.SigHashType
+	}
+	if len(in.RedeemScript) == 0 {
+		in.RedeemScript = other.RedeemScript
+	}
+	if len(in.WitnessScript) == 0 {
+		in.WitnessScript = other.WitnessScript
+	}
+	in.Derivations = mergeDerivations(in.Derivations, other.Derivations)
+	if len(in.FinalScriptSig) == 0 {
+		in.FinalScriptSig = other.FinalScriptSig
+	}
+	if len(in.FinalScriptWitness) == 0 {
+		in.FinalScriptWitness = other.FinalScriptWitness
+	}
+	for _, keyType := range []uint64{psbtInRIPEMD160, psbtInSHA256, psbtInHash160, psbtInHash256} {
+		preimages := in.preimages(keyType)
+		for hash, preimage := range *other.preimages(keyType) {
+			if *preimages == nil {
+				*preimages = make(map[string][]byte)
+			}
+			if _, ok := (*preimages)[hash]; !ok {
+				(*preimages)[hash] = preimage
+			}
+		}
+	}
+	if in.Sequence == nil {
+		in.Sequence = other.Sequence
+	}
+	if in.RequiredTimeLockTime == nil {
+		in.RequiredTimeLockTime = other.RequiredTimeLockTime
+	}
+	if in.RequiredHeightLockTime == nil {
+		in.RequiredHeightLockTime = other.RequiredHeightLockTime
+	}
+	in.Proprietary = mergeRecords(in.Proprietary, other.Proprietary)
+	in.Unknown = mergeRecords(in.Unknown, other.Unknown)
+}
+
+func (out *PSBTOutput) merge(other *PSBTOutput) {
+	if len(out.RedeemScript) == 0 {
+		out.RedeemScript = other.RedeemScript
+	}
+	if len(out.WitnessScript) == 0 {
+		out.WitnessScript = other.WitnessScript
+	}
+	out.Derivations = mergeDerivations(out.Derivations, other.Derivations)
+	out.Proprietary = mergeRecords(out.Proprietary, other.Proprietary)
+	out.Unknown = mergeRecords(out.Unknown, other.Unknown)
+}
+
+/* mergeDerivations adds the key origins of b whose key is not in a */
+func mergeDerivations(a []*PSBTDerivation, b []*PSBTDerivation) []*PSBTDerivation {
+	for _, derivation := range b {
+		known := false
+		for _, d := range a {
+			if bytes.Equal(d.Key, derivation.Key) {
+				known = true
+			}
+		}
+		if !known {
+			a = append(a, derivation)
+		}
+	}
+	return a
+}
+
+/* mergeRecords adds the records of b whose key is not in a */
+func mergeRecords(a []*PSBTRecord, b []*PSBTRecord) []*PSBTRecord {
+	for _, record := range b {
+		known := false
+		for _, r := range a {
+			if bytes.Equal(r.Key, record.Key) {
+				known = true
+			}
+		}
+		if !known {
+			a = append(a, record)
+		}
+	}
+	return a
+}
+
+/* partialSig returns the signature of key, or of the key whose hash160 is key */
+func (in *PSBTInput) partialSig(key []byte) *PSBTPartialSig {
+	for _, sig := range in.PartialSigs {
+		if bytes.Equal(sig.PublicKey, key) || bytes.Equal(hash160(sig.PublicKey), key) {
+			return sig
+		}
+	}
+	return nil
+}
+
+/* satisfy returns the stack spending a P2PK, P2PKH or P2MS script with the partial signatures */
+func (in *PSBTInput) satisfy(script Script) ([][]byte, error) {
+	switch script.Type() {
+	case P2PKScript:
+		key, _ := script.payToPubKey()
+		if sig := in.partialSig(key); sig != nil {
+			return [][]byte{sig.Signature}, nil
+		}
+	case P2PKHScript, P2WPKHScript:
+		hash, _ := script.Hash()
+		if sig := in.partialSig(hash); sig != nil {
+			return [][]byte{sig.Signature, sig.PublicKey}, nil
+		}
+	case P2MSScript:
+		required, keys, _ := script.multiSig()
+		/* The extra element popped by OP_CHECKMULTISIG, then the signatures in the order of the keys */
+		stack := [][]byte{nil}
+		for _, key := range keys {
+			if sig := in.partialSig(key); sig != nil && len(stack) <= required {
+				stack = append(stack, sig.Signature)
+			}
+		}
+		if len(stack) > required {
+			return stack, nil
+		}
+	default:
+		return nil, errors.New("unsupported script")
+	}
+	return nil, errors.New("not enough signatures")
+}
+
+// FinalizeInput builds the final scriptSig and witness of input index from its partial signatures
+func (p *PSBT) FinalizeInput(index int) error {
+	if index < 0 || index >= len(p.Inputs) {
+		return errors.New("input index out of range")
+	}
+	in := p.Inputs[index]
+	if in.isFinal() {
+		return nil
+	}
+
+	prevOut, _, err := p.spentOutput(index)
+	if err != nil {
+		return err
+	}
+	if prevOut == nil {
+		return errors.New("missing utxo")
+	}
+
+	if in.SigHashType != nil {
+		for _, sig := range in.PartialSigs {
+			if len(sig.Signature) == 0 || SigHashType(sig.Signature[len(sig.Signature)-1]) != *in.SigHashType {
+				return errors.New("signature hash type does not match the input sighash type")
+			}
+		}
+	}
+
+	var scriptSig Script
+	script := Script(prevOut.ScriptPubKey)
+	if script.Type() == P2SHScript {
+		if len(in.RedeemScript) == 0 || !bytes.Equal(hash160(in.RedeemScript), script[2:22]) {
+			return errors.New("redeem script does not match the spent output")
+		}
+		script = in.RedeemScript
+	}
+
+	var witness [][]byte
+	switch script.Type() {
+	case P2WPKHScript:
+		witness, err = in.satisfy(script)
+	case P2WSHScript:
+		if len(in.WitnessScript) == 0 || !bytes.Equal(singleHash(in.WitnessScript), script[2:]) {
+			return errors.New("witness script does not match the spent output")
+		}
+		witness, err = in.satisfy(in.WitnessScript)
+		witness = append(witness, in.WitnessScript)
+	default:
+		if _, _, ok := witnessProgram(script); ok {
+			return errors.New("unsupported witness program")
+		}
+		var stack [][]byte
+		stack, err = in.satisfy(script)
+		for _, item := range stack {
+			scriptSig = scriptSig.PushData(item)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if len(in.RedeemScript) > 0 {
+		scriptSig = scriptSig.PushData(in.RedeemScript)
+	}
+
+	/* Only the UTXOs, the final scripts and the unknown fields are kept */
+	in.FinalScriptSig = scriptSig
+	in.FinalScriptWitness = witness
+	in.PartialSigs = nil
+	in.SigHashType = nil
+	in.RedeemScript = nil
+	in.WitnessScript = nil
+	in.Derivations = nil
+	in.RIPEMD160Preimages = nil
+	in.SHA256Preimages = nil
+	in.Hash160Preimages = nil
+	in.Hash256Preimages = nil
+	return nil
+}
+
+// Finalize finalizes all the inputs, it stops at the first input which cannot be finalized
+func (p *PSBT) Finalize() error {
+	for i := range p.Inputs {
+		if err := p.FinalizeInput(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Extract returns the network transaction of a finalized PSBT
+func (p *PSBT) Extract() (*Transaction, error) {
+	tx, err := p.Transaction()
+	if err != nil {
+		return nil, err
+	}
+	for i, in := range p.Inputs {
+		if !in.isFinal() {
+			return nil, errors.New("psbt is not finalized")
+		}
+		tx.Inputs[i].ScriptSig = in.FinalScriptSig
+		tx.Inputs[i].Witness = in.FinalScriptWitness
+	}
+	return tx, nil
+}
@@ -0,0 +1,304 @@
+package btc
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type psbtVector struct {
+	Description string  `json:"description"`
+	PSBT        string  `json:"psbt"`
+	LockTime    *uint32 `json:"locktime"`
+}
+
+type psbtVectors struct {
+	Invalid      []psbtVector `json:"invalid"`
+	Valid        []psbtVector `json:"valid"`
+	SignerChecks []psbtVector `json:"signer_checks"`
+	LockTime     []psbtVector `json:"locktime"`
+
+	Roles struct {
+		Master        string `json:"master"`
+		CreatorInputs []struct {
+			TxID  string `json:"txid"`
+			Index uint32 `json:"index"`
+		} `json:"creator_inputs"`
+		CreatorOutputs []struct {
+			Script string `json:"script"`
+			Amount int64  `json:"amount"`
+		} `json:"creator_outputs"`
+		RedeemScripts  []string `json:"redeem_scripts"`
+		WitnessScripts []string `json:"witness_scripts"`
+		PreviousTxs    []string `json:"previous_txs"`
+		Derivations    []struct {
+			Key  string `json:"key"`
+			Path string `json:"path"`
+		} `json:"derivations"`
+		Signers         [][]string `json:"signers"`
+		Creator         string     `json:"creator"`
+		Updater         string     `json:"updater"`
+		SigHash         string     `json:"sighash"`
+		Signed          []string   `json:"signed"`
+		Combined        string     `json:"combined"`
+		Finalized       string     `json:"finalized"`
+		Extracted       string     `json:"extracted"`
+		Unknown         []string   `json:"unknown"`
+		UnknownCombined string     `json:"unknown_combined"`
+	} `json:"roles"`
+}
+
+func loadPSBTVectors(t *testing.T) psbtVectors {
+	data, err := ioutil.ReadFile("testdata/psbt_vectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var vectors psbtVectors
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		t.Fatal(err)
+	}
+	return vectors
+}
+
+func TestPSBTParsing(t *testing.T) {
+	vectors := loadPSBTVectors(t)
+
+	for _, v := range vectors.Invalid {
+		_, err := PSBTFromBase64(v.PSBT)
+		assert.NotNil(t, err, v.Description)
+	}
+
+	for _, v := range append(vectors.Valid, vectors.SignerChecks...) {
+		p, err := PSBTFromBase64(v.PSBT)
+		if assert.Nil(t, err, v.Description) {
+			assert.Equal(t, v.PSBT, p.Base64(), v.Description)
+		}
+	}
+
+	_, err := PSBTFromBase64("cHNidP8BAAoAAAAAAAAAAAAAAA==")
+	assert.Nil(t, err)
+	_, err = PSBTFromBase64("cHNidP8BAAoAAAAAAAAAAAAAAAA=")
+	assert.NotNil(t, err)
+	_, err = PSBTFromBase64("not base64")
+	assert.NotNil(t, err)
+}
+
+func TestPSBTLockTime(t *testing.T) {
+	vectors := loadPSBTVectors(t)
+
+	for _, v := range vectors.LockTime {
+		p, err := PSBTFromBase64(v.PSBT)
+		if !assert.Nil(t, err, v.Description) {
+			continue
+		}
+
+		lockTime, err := p.ComputeLockTime()
+		if v.LockTime == nil {
+			assert.NotNil(t, err, v.Description)
+			continue
+		}
+		if assert.Nil(t, err, v.Description) {
+			assert.Equal(t, *v.LockTime, lockTime, v.Description)
+		}
+	}
+}
+
+func TestPSBTRoles(t *testing.T) {
+	vectors := loadPSBTVectors(t)
+	roles := vectors.Roles
+
+	/* Creator */
+	tx := &Transaction{Version: 2}
+	for _, in := range roles.CreatorInputs {
+		outPoint, err := NewOutPoint(in.TxID, in.Index)
+		assert.Nil(t, err)
+		tx.Inputs = append(tx.Inputs, &TxIn{PreviousOutPoint: outPoint, Sequence: 0xffffffff})
+	}
+	for _, out := range roles.CreatorOutputs {
+		script, _ := hex.DecodeString(out.Script)
+		tx.Outputs = append(tx.Outputs, &TxOut{Value: out.Amount, ScriptPubKey: script})
+	}
+	p, err := NewPSBT(tx)
+	assert.Nil(t, err)
+	assert.Equal(t, roles.Creator, p.Base64())
+
+	/* Updater */
+	master, err := ExtendedFromBase58(roles.Master, TestNetwork)
+	assert.Nil(t, err)
+
+	var prevTxs []*Transaction
+	for _, hexa := range roles.PreviousTxs {
+		prevTx, err := TransactionFromHex(hexa)
+		assert.Nil(t, err)
+		prevTxs = append(prevTxs, prevTx)
+	}
+	var scripts [][]byte
+	for _, hexa := range append(roles.RedeemScripts, roles.WitnessScripts...) {
+		script, _ := hex.DecodeString(hexa)
+		scripts = append(scripts, script)
+	}
+	var origins []*PSBTDerivation
+	for _, d := range roles.Derivations {
+		key, err := master.Derive(d.Path)
+		assert.Nil(t, err)
+		assert.Equal(t, d.Key, key.PublicKey.Format(true))
+
+		path, _ := ParsePath(d.Path)
+		origins = append(origins, &PSBTDerivation{
+			Key:         key.PublicKey.serialize(true),
+			Fingerprint: master.Fingerprint(),
+			Path:        path,
+		})
+	}
+	assert.Nil(t, p.Update(prevTxs, scripts, origins))
+	assert.Equal(t, roles.Updater, p.Base64())
+
+	all := SigHashAll
+	for _, in := range p.Inputs {
+		in.SigHashType = &all
+	}
+	assert.Nil(t, p.Update(prevTxs, scripts, origins))
+	assert.Equal(t, roles.SigHash, p.Base64())
+
+	/* Signers */
+	var signed []*PSBT
+	for i, keys := range roles.Signers {
+		p, err := PSBTFromBase64(roles.SigHash)
+		assert.Nil(t, err)
+		for _, wif := range keys {
+			key, err := PrivateFromWIF(wif, TestNetwork)
+			assert.Nil(t, err)
+			assert.Nil(t, p.Sign(key))
+		}
+		assert.Equal(t, roles.Signed[i], p.Base64())
+		signed = append(signed, p)
+	}
+
+	/* Combiner */
+	combined, err := CombinePSBT(signed...)
+	assert.Nil(t, err)
+	assert.Equal(t, roles.Combined, combined.Base64())
+	assert.Equal(t, roles.Signed[0], signed[0].Base64())
+
+	/* Finalizer */
+	_, err = combined.Extract()
+	assert.NotNil(t, err)
+	assert.Nil(t, combined.Finalize())
+	assert.Equal(t, roles.Finalized, combined.Base64())
+
+	/* Extractor */
+	extracted, err := combined.Extract()
+	assert.Nil(t, err)
+	assert.Equal(t, roles.Extracted, extracted.Hex())
+
+	var prevOuts []*TxOut
+	for _, in := range extracted.Inputs {
+		for _, prevTx := range prevTxs {
+			if prevTx.TxID() == in.PreviousOutPoint.TxID() {
+				prevOuts = append(prevOuts, prevTx.Outputs[in.PreviousOutPoint.Index])
+			}
+		}
+	}
+	assert.Nil(t, extracted.Verify(prevOuts, StandardVerifyFlags))
+
+	/* A single signature is not enough for the 2-of-2 multisig inputs */
+	assert.NotNil(t, signed[0].Finalize())
+
+	/* Combining PSBTs of different transactions */
+	other, _ := PSBTFromBase64(vectors.Valid[0].PSBT)
+	_, err = CombinePSBT(signed[0], other)
+	assert.NotNil(t, err)
+
+	/* Unknown fields are preserved and sorted */
+	var unknown []*PSBT
+	for _, s := range roles.Unknown {
+		p, err := PSBTFromBase64(s)
+		assert.Nil(t, err)
+		unknown = append(unknown, p)
+	}
+	combined, err = CombinePSBT(unknown...)
+	assert.Nil(t, err)
+	assert.Equal(t, roles.UnknownCombined, combined.Base64())
+}
+
+func TestPSBTSignerChecks(t *testing.T) {
+	vectors := loadPSBTVectors(t)
+	key, _ := PrivateFromWIF(vectors.Roles.Signers[0][0], TestNetwork)
+
+	for _, v := range vectors.SignerChecks {
+		p, err := PSBTFromBase64(v.PSBT)
+		if assert.Nil(t, err, v.Description) {
+			assert.NotNil(t, p.Sign(key), v.Description)
+		}
+	}
+}
+
+func TestPSBTV2(t *testing.T) {
+	key, _ := PrivateFromHex("0000000000000000000000000000000000000000000000000000000000000001", RegTestNetwork)
+	key.SetCompressed(true)
+	publicKey, _ := key.GetPublicKey()
+	script := append([]byte{Op0, 0x14}, hash160(publicKey.serialize(true))...)
+
+	prevTx := &Transaction{
+		Version: 2,
+		Inputs:  []*TxIn{{PreviousOutPoint: OutPoint{Hash: make([]byte, 32)}, Sequence: 0xffffffff}},
+		Outputs: []*TxOut{{Value: 100000, ScriptPubKey: script}},
+	}
+	prevOutPoint, _ := NewOutPoint(prevTx.TxID(), 0)
+
+	p := NewPSBTV2(2)
+	height := uint32(800000)
+	assert.Nil(t, p.AddInput(&PSBTInput{
+		PreviousOutPoint:       prevOutPoint,
+		WitnessUtxo:            prevTx.Outputs[0],
+		RequiredHeightLockTime: &height,
+	}))
+	assert.Nil(t, p.AddOutput(&PSBTOutput{Amount: 90000, Script: script}))
+
+	/* Inputs requiring an incompatible lock time or spending the same outpoint are rejected */
+	timestamp := uint32(1700000000)
+	secondOutPoint, _ := NewOutPoint(prevTx.TxID(), 1)
+	assert.NotNil(t, p.AddInput(&PSBTInput{PreviousOutPoint: secondOutPoint, RequiredTimeLockTime: &timestamp}))
+	assert.NotNil(t, p.AddInput(&PSBTInput{PreviousOutPoint: prevOutPoint}))
+	assert.Equal(t, 1, len(p.Inputs))
+
+	id, err := p.UniqueID()
+	assert.Nil(t, err)
+
+	parsed, err := PSBTFromBase64(p.Base64())
+	assert.Nil(t, err)
+	assert.Equal(t, p.Base64(), parsed.Base64())
+
+	/* The sequence does not change the unique id */
+	sequence := uint32(0xfffffffd)
+	p.Inputs[0].Sequence = &sequence
+	sequenceID, _ := p.UniqueID()
+	assert.Equal(t, id, sequenceID)
+
+	/* SIGHASH_ALL signatures prevent adding inputs and outputs */
+	assert.Nil(t, p.Sign(key))
+	assert.Equal(t, byte(0), *p.TxModifiable)
+	assert.NotNil(t, p.AddInput(&PSBTInput{PreviousOutPoint: secondOutPoint}))
+	assert.NotNil(t, p.AddOutput(&PSBTOutput{Amount: 1000, Script: script}))
+
+	combined, err := CombinePSBT(parsed, p)
+	assert.Nil(t, err)
+	assert.Equal(t, byte(0), *combined.TxModifiable)
+	assert.Equal(t, 1, len(combined.Inputs[0].PartialSigs))
+
+	assert.Nil(t, p.Finalize())
+	tx, err := p.Extract()
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(800000), tx.LockTime)
+	assert.Nil(t, tx.Verify(prevTx.Outputs, StandardVerifyFlags))
+
+	/* Version 0 PSBTs cannot be modified */
+	v0, _ := NewPSBT(&Transaction{Version: 2})
+	assert.NotNil(t, v0.AddOutput(&PSBTOutput{Amount: 1000, Script: script}))
+	_, err = NewPSBT(&Transaction{Inputs: []*TxIn{{PreviousOutPoint: prevOutPoint, ScriptSig: []byte{Op1}}}})
+	assert.NotNil(t, err)
+}
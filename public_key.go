@@ -28,6 +28,8 @@ func PublicFromHex(hexa string, network *Network) (*PublicKey, error) {
 		publicKey.Y, _ = new(big.Int).SetString(y, 16)
 
 	} else if prefix == "03" || prefix == "02" {
+		publicKey.Compressed = true
+
 		x := hexa[2:66]
 		publicKey.X, _ = new(big.Int).SetString(x, 16)
 
@@ -60,13 +62,17 @@ func PublicFromHex(hexa string, network *Network) (*PublicKey, error) {
 func (p *PrivateKey) GetPublicKey() (*PublicKey, bool) {
 	var publicKey PublicKey
 
-	R, valid := secp256k1.ScalarMult(p.Key, secp256k1.G)
-	if !valid {
+	if p.Key == nil {
+		return nil, false
+	}
+	R := scalarBaseMult(p.Key)
+	if R.IsInfinity() {
 		return nil, false
 	}
 	publicKey.X = R.X
 	publicKey.Y = R.Y
 
+	publicKey.Compressed = p.Compressed
 	publicKey.Network = p.Network
 
 	return &publicKey, true
@@ -104,6 +110,12 @@ func (p *PublicKey) Format(compressed bool) string {
 	return key
 }
 
+/* serialize returns the public key bytes */
+func (p *PublicKey) serialize(compressed bool) []byte {
+	b, _ := hex.DecodeString(p.Format(compressed))
+	return b
+}
+
 // Address computes the base58 public address
 func (p *PublicKey) Address(compressed bool) (string, error) {
 	hexa := p.Format(compressed)
@@ -164,6 +176,32 @@ func (p *PublicKey) Address(compressed bool) (string, error) {
 	return base58.Encode(extendedRipeMd), nil
 }
 
+// DefaultAddress computes the base58 public address using the default serialization of the public key
+func (p *PublicKey) DefaultAddress() (string, error) {
+	return p.Address(p.Compressed)
+}
+
+// SegwitAddress computes the native segwit (P2WPKH) bech32 address
+func (p *PublicKey) SegwitAddress(compressed bool) (string, error) {
+	/* Uncompressed keys are non-standard in witness programs */
+	if !compressed {
+		return "", errors.New("segwit addresses require compressed public keys")
+	}
+
+	return encodeSegwitAddress(p.Network.Bech32HRP, 0, hash160(p.serialize(true)))
+}
+
+// NestedSegwitAddress computes the P2SH-wrapped segwit (P2SH-P2WPKH) address
+func (p *PublicKey) NestedSegwitAddress(compressed bool) (string, error) {
+	if !compressed {
+		return "", errors.New("segwit addresses require compressed public keys")
+	}
+
+	/* The redeem script is the P2WPKH witness program: 0 <hash160(pubkey)> */
+	redeemScript := append([]byte{0x00, 0x14}, hash160(p.serialize(true))...)
+	return ScriptHashAddress(redeemScript, p.Network)
+}
+
 // AddPublicKeys merge two public keys together by addition
 func AddPublicKeys(p1 *PublicKey, p2 *PublicKey, compressed bool) (*PublicKey, error) {
 
@@ -185,10 +223,43 @@ func AddPublicKeys(p1 *PublicKey, p2 *PublicKey, compressed bool) (*PublicKey, e
 		return nil, errors.New("point is not on curve")
 	}
 	publicKey := PublicKey{
-		X:       r.X,
-		Y:       r.Y,
-		Network: p1.Network,
+		X:          r.X,
+		Y:          r.Y,
+		Compressed: compressed,
+		Network:    p1.Network,
 	}
 
 	return &publicKey, nil
 }
+
+/* publicFromBytes parses a compressed, uncompressed or hybrid (0x06/0x07) public key and checks it is on the curve */
+func publicFromBytes(b []byte, network *Network) (*PublicKey, error) {
+	if len(b) == 33 && (b[0] == 0x02 || b[0] == 0x03) {
+		P, err := liftX(new(big.Int).SetBytes(b[1:33]))
+		if err != nil {
+			return nil, err
+		}
+		if b[0] == 0x03 {
+			P = negatePoint(P)
+		}
+		return &PublicKey{X: P.X, Y: P.Y, Compressed: true, Network: network}, nil
+	}
+
+	if len(b) != 65 || (b[0] != 0x04 && b[0] != 0x06 && b[0] != 0x07) {
+		return nil, errors.New("unsupported public key format")
+	}
+
+	x := new(big.Int).SetBytes(b[1:33])
+	y := new(big.Int).SetBytes(b[33:65])
+	if x.Cmp(secp256k1.P) >= 0 || y.Cmp(secp256k1.P) >= 0 {
+		return nil, errors.New("public key coordinate exceeds field size")
+	}
+	/* Hybrid keys also encode the parity of Y in their prefix */
+	if b[0] != 0x04 && uint(b[0]&1) != y.Bit(0) {
+		return nil, errors.New("hybrid public key parity mismatch")
+	}
+	if !secp256k1.IsOnCurve(ecdsa.Point{X: x, Y: y}) {
+		return nil, errors.New("point is not on secp256k1 curve")
+	}
+	return &PublicKey{X: x, Y: y, Network: network}, nil
+}
@@ -46,6 +46,28 @@ func TestGetPublicKey(t *testing.T) {
 	}
 }
 
+func TestGetPublicKeyEdgeKeys(t *testing.T) {
+	/* n - 1 gives -G */
+	var edgeKeys = []struct {
+		Key       string
+		PublicKey string
+	}{
+		{"0000000000000000000000000000000000000000000000000000000000000001", "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"},
+		{"0000000000000000000000000000000000000000000000000000000000000002", "02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5"},
+		{"fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364140", "0379be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"},
+	}
+	for _, value := range edgeKeys {
+		privateKey, err := PrivateFromHex(value.Key, MainNetwork)
+		assert.Nil(t, err)
+
+		publicKey, valid := privateKey.GetPublicKey()
+		assert.True(t, valid, value.Key)
+		if valid {
+			assert.Equal(t, value.PublicKey, publicKey.Format(true), value.Key)
+		}
+	}
+}
+
 func TestFormat(t *testing.T) {
 	var wifArray = []struct {
 		WIF             string
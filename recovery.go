@@ -0,0 +1,110 @@
+package btc
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+)
+
+/* compactHeader is the first header byte of compact signatures, the recovery id and 4 for compressed keys are added to it */
+const compactHeader byte = 27
+
+// RecoverPublicKey computes the public key Q = r^-1 * (s*R - e*G) that signed hash, R is selected by the recovery id (SEC1 4.1.6)
+func (s *Signature) RecoverPublicKey(hash []byte, recoveryID byte, network *Network) (*PublicKey, error) {
+	if recoveryID > 3 {
+		return nil, errors.New("invalid recovery id")
+	}
+	if s.R.Sign() <= 0 || s.R.Cmp(secp256k1.N) >= 0 || s.S.Sign() <= 0 || s.S.Cmp(secp256k1.N) >= 0 {
+		return nil, errors.New("signature is out of range")
+	}
+
+	/* R.x is r, or r + n when it overflowed the curve order, and R.y has the parity of the recovery id */
+	x := new(big.Int).Set(s.R)
+	if recoveryID&2 != 0 {
+		x.Add(x, secp256k1.N)
+	}
+	R, err := liftX(x)
+	if err != nil {
+		return nil, err
+	}
+	if R.Y.Bit(0) != uint(recoveryID&1) {
+		R = negatePoint(R)
+	}
+
+	rInverse := scalarFromBig(s.R).inverse()
+
+	/* u1 = -e*r^-1 mod n, u2 = s*r^-1 mod n */
+	u1 := scalarFromBig(hashToInt(hash)).mul(rInverse).negate()
+	u2 := scalarFromBig(s.S).mul(rInverse)
+
+	Q := doubleScalarMult(u1, u2, R)
+	if Q.IsInfinity() {
+		return nil, errors.New("recovered public key is the point at infinity")
+	}
+
+	return &PublicKey{
+		X:          Q.X,
+		Y:          Q.Y,
+		Compressed: true,
+		Network:    network,
+	}, nil
+}
+
+// RecoverCandidates returns the public keys of the 4 recovery ids, candidates which cannot be recovered are nil
+func (s *Signature) RecoverCandidates(hash []byte, network *Network) []*PublicKey {
+	candidates := make([]*PublicKey, 4)
+	for id := range candidates {
+		candidates[id], _ = s.RecoverPublicKey(hash, byte(id), network)
+	}
+	return candidates
+}
+
+// RecoveryID selects the recovery id under which the public key is recovered from the signature of hash
+func (s *Signature) RecoveryID(hash []byte, publicKey *PublicKey) (byte, error) {
+	serialized := publicKey.serialize(true)
+	for id, candidate := range s.RecoverCandidates(hash, publicKey.Network) {
+		if candidate != nil && bytes.Equal(candidate.serialize(true), serialized) {
+			return byte(id), nil
+		}
+	}
+	return 0, errors.New("public key does not match the signature")
+}
+
+// SignCompact computes the 65 bytes compact signature of hash, its header encodes the recovery id and the compression of the public key
+func (p *PrivateKey) SignCompact(hash []byte) ([]byte, error) {
+	signature, recoveryID, err := p.signRecoverable(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	header := compactHeader + recoveryID
+	if p.Compressed {
+		header += 4
+	}
+
+	compact := append([]byte{header}, paddedBytes(signature.R, 32)...)
+	return append(compact, paddedBytes(signature.S, 32)...), nil
+}
+
+// RecoverCompact recovers the public key of a 65 bytes compact signature of hash, like Bitcoin Core's CPubKey::RecoverCompact
+func RecoverCompact(hash []byte, signature []byte, network *Network) (*PublicKey, error) {
+	if len(signature) != 65 {
+		return nil, errors.New("compact signature must be 65 bytes long")
+	}
+	header := signature[0]
+	if header < compactHeader || header > compactHeader+7 {
+		return nil, errors.New("invalid compact signature header")
+	}
+	recoveryID := (header - compactHeader) & 3
+
+	s := &Signature{
+		R: new(big.Int).SetBytes(signature[1:33]),
+		S: new(big.Int).SetBytes(signature[33:65]),
+	}
+	publicKey, err := s.RecoverPublicKey(hash, recoveryID, network)
+	if err != nil {
+		return nil, err
+	}
+	publicKey.Compressed = (header-compactHeader)&4 != 0
+	return publicKey, nil
+}
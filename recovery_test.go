@@ -0,0 +1,100 @@
+package btc
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecoverCompact(t *testing.T) {
+	hash := doubleHash([]byte("Very deterministic message"))
+
+	/* Deterministic compact signatures of Bitcoin Core's key tests */
+	tests := []struct {
+		wif       string
+		signature string
+	}{
+		{"5HxWvvfubhXpYYpS3tJkw6fq9jE9j18THftkZjHHfmFiWtmAbrj", "1c5dbbddda71772d95ce91cd2d14b592cfbc1dd0aabd6a394b6c2d377bbe59d31d14ddda21494a4e221f0824f0b8b924c43fa43c0ad57dccdaa11f81a6bd4582f6"},
+		{"Kwr371tjA9u2rFSMZjTNun2PXXP3WPZu2afRHTcta6KxEUdm1vEw", "205dbbddda71772d95ce91cd2d14b592cfbc1dd0aabd6a394b6c2d377bbe59d31d14ddda21494a4e221f0824f0b8b924c43fa43c0ad57dccdaa11f81a6bd4582f6"},
+		{"5KC4ejrDjv152FGwP386VD1i2NYc5KkfSMyv1nGy1VGDxGHqVY3", "1c52d8a32079c11e79db95af63bb9600c5b04f21a9ca33dc129c2bfa8ac9dc1cd561d8ae5e0f6c1a16bde3719c64c2fd70e404b6428ab9a69566962e8771b5944d"},
+		{"L3Hq7a8FEQwJkW1M2GNKDW28546Vp5miewcCzSqUD9kCAXrJdS3g", "2052d8a32079c11e79db95af63bb9600c5b04f21a9ca33dc129c2bfa8ac9dc1cd561d8ae5e0f6c1a16bde3719c64c2fd70e404b6428ab9a69566962e8771b5944d"},
+	}
+
+	for _, test := range tests {
+		key, err := PrivateFromWIF(test.wif, MainNetwork)
+		if !assert.Nil(t, err, test.wif) {
+			continue
+		}
+		expected, _ := key.GetPublicKey()
+
+		signature, err := key.SignCompact(hash)
+		assert.Nil(t, err)
+		assert.Equal(t, test.signature, hex.EncodeToString(signature))
+
+		publicKey, err := RecoverCompact(hash, signature, MainNetwork)
+		if !assert.Nil(t, err) {
+			continue
+		}
+		assert.Equal(t, expected.Format(expected.Compressed), publicKey.Format(publicKey.Compressed))
+		assert.Equal(t, key.Compressed, publicKey.Compressed)
+	}
+
+	/* Invalid headers and lengths */
+	signature, _ := hex.DecodeString(tests[0].signature)
+	for _, header := range []byte{0, 26, 35, 0xff} {
+		invalid := append([]byte{header}, signature[1:]...)
+		_, err := RecoverCompact(hash, invalid, MainNetwork)
+		assert.NotNil(t, err)
+	}
+	_, err := RecoverCompact(hash, signature[0:64], MainNetwork)
+	assert.NotNil(t, err)
+
+	/* A different hash recovers a different key */
+	publicKey, err := RecoverCompact(doubleHash([]byte("Very deterministic message.")), signature, MainNetwork)
+	if assert.Nil(t, err) {
+		key, _ := PrivateFromWIF(tests[0].wif, MainNetwork)
+		expected, _ := key.GetPublicKey()
+		assert.NotEqual(t, expected.Format(false), publicKey.Format(false))
+	}
+}
+
+func TestRecoveryID(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		key := GeneratePrivateKey(MainNetwork)
+		publicKey, _ := key.GetPublicKey()
+		hash := doubleHash(key.Key.Bytes())
+
+		signature, err := key.Sign(hash)
+		if !assert.Nil(t, err) {
+			continue
+		}
+
+		/* The selected recovery id recovers the signer among the candidates */
+		id, err := signature.RecoveryID(hash, publicKey)
+		if !assert.Nil(t, err) {
+			continue
+		}
+		candidates := signature.RecoverCandidates(hash, MainNetwork)
+		assert.Len(t, candidates, 4)
+		assert.Equal(t, publicKey.Format(true), candidates[id].Format(true))
+
+		recovered, err := signature.RecoverPublicKey(hash, id, MainNetwork)
+		assert.Nil(t, err)
+		assert.True(t, recovered.Verify(hash, signature))
+
+		/* The recovery id of compact signatures is the one selected */
+		compact, err := key.SignCompact(hash)
+		assert.Nil(t, err)
+		assert.Equal(t, id, (compact[0]-27)&3)
+
+		other, _ := GeneratePrivateKey(MainNetwork).GetPublicKey()
+		_, err = signature.RecoveryID(hash, other)
+		assert.NotNil(t, err)
+	}
+
+	_, err := (&Signature{R: secp256k1.N, S: secp256k1.N}).RecoverPublicKey(make([]byte, 32), 0, MainNetwork)
+	assert.NotNil(t, err)
+	_, err = (&Signature{R: secp256k1.G.X, S: secp256k1.G.X}).RecoverPublicKey(make([]byte, 32), 4, MainNetwork)
+	assert.NotNil(t, err)
+}
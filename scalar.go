@@ -0,0 +1,197 @@
+package btc
+
+import (
+	"math/big"
+	"math/bits"
+)
+
+/* scalar is an integer modulo the curve order n of secp256k1 as 4 little-endian 64 bits limbs, it is always fully reduced */
+type scalar [4]uint64
+
+/* scalarN is the curve order n */
+var scalarN = scalar{0xbfd25e8cd0364141, 0xbaaedce6af48a03b, 0xfffffffffffffffe, 0xffffffffffffffff}
+
+/* scalarC is 2^256 - n, so 2^256 = scalarC mod n */
+var scalarC = [3]uint64{0x402da1732fc9bebf, 0x4551231950b75fc4, 0x1}
+
+/* scalarFromBig returns x mod n, it branches on the value of x so secrets go through scalarFromSecret */
+func scalarFromBig(x *big.Int) scalar {
+	if x.Sign() < 0 || x.Cmp(secp256k1.N) >= 0 {
+		x = new(big.Int).Mod(x, secp256k1.N)
+	}
+	return scalarFromBytes(paddedBytes(x, 32))
+}
+
+/* scalarFromSecret returns x mod n and 1 if x is in [1, n-1], the words of x are read without branching on their values so only their number can leak */
+func scalarFromSecret(x *big.Int) (scalar, uint64) {
+	words := x.Bits()
+	if x.Sign() < 0 || len(words) > 256/bits.UintSize {
+		return scalarFromBig(x), 0
+	}
+
+	var s scalar
+	for i, w := range words {
+		s[i*bits.UintSize/64] |= uint64(w) << uint(i*bits.UintSize%64)
+	}
+	return scalarReduceChecked(s)
+}
+
+/* scalarFromBytes decodes a 32 bytes big-endian integer modulo n */
+func scalarFromBytes(b []byte) scalar {
+	s, _ := scalarFromBytesChecked(b)
+	return s
+}
+
+/* scalarFromBytesChecked decodes a 32 bytes big-endian integer modulo n and returns 1 if it is in [1, n-1], in constant time */
+func scalarFromBytesChecked(b []byte) (scalar, uint64) {
+	var s scalar
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 8; j++ {
+			s[3-i] = s[3-i]<<8 | uint64(b[i*8+j])
+		}
+	}
+	return scalarReduceChecked(s)
+}
+
+/* scalarReduceChecked reduces a 256 bits integer modulo n and returns 1 if it was in [1, n-1], in constant time */
+func scalarReduceChecked(s scalar) (scalar, uint64) {
+	/* Integers of 256 bits are lower than 2n */
+	r, borrow := s.subRaw(scalarN)
+	return scalarSelect(1^borrow, r, s), borrow & (1 ^ s.isZero())
+}
+
+/* bytes returns the 32 bytes big-endian encoding of s */
+func (s scalar) bytes() []byte {
+	b := make([]byte, 32)
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 8; j++ {
+			b[i*8+j] = byte(s[3-i] >> uint(56-8*j))
+		}
+	}
+	return b
+}
+
+/* big returns s as a big integer */
+func (s scalar) big() *big.Int {
+	return new(big.Int).SetBytes(s.bytes())
+}
+
+/* isZero returns 1 if s is 0, in constant time */
+func (s scalar) isZero() uint64 {
+	x := s[0] | s[1] | s[2] | s[3]
+	return 1 ^ (x|-x)>>63
+}
+
+/* nibble returns the i-th group of 4 bits of s */
+func (s scalar) nibble(i int) uint64 {
+	return (s[i/16] >> uint(4*(i%16))) & 0xf
+}
+
+/* scalarSelect returns a if cond is 1 and b if cond is 0, in constant time */
+func scalarSelect(cond uint64, a scalar, b scalar) scalar {
+	return scalar(fieldSelect(cond, fieldElement(a), fieldElement(b)))
+}
+
+/* subRaw returns s - t mod 2^256 and the borrow */
+func (s scalar) subRaw(t scalar) (scalar, uint64) {
+	r, borrow := fieldElement(s).subRaw(fieldElement(t))
+	return scalar(r), borrow
+}
+
+/* add returns s + t mod n */
+func (s scalar) add(t scalar) scalar {
+	var r scalar
+	var carry uint64
+	r[0], carry = bits.Add64(s[0], t[0], 0)
+	r[1], carry = bits.Add64(s[1], t[1], carry)
+	r[2], carry = bits.Add64(s[2], t[2], carry)
+	r[3], carry = bits.Add64(s[3], t[3], carry)
+
+	d, borrow := r.subRaw(scalarN)
+	return scalarSelect(carry|(1^borrow), d, r)
+}
+
+/* negate returns -s mod n */
+func (s scalar) negate() scalar {
+	r, _ := scalarN.subRaw(s)
+	return scalarSelect(s.isZero(), scalar{}, r)
+}
+
+/* mul returns s * t mod n */
+func (s scalar) mul(t scalar) scalar {
+	var p [8]uint64
+	for i := 0; i < 4; i++ {
+		var carry uint64
+		for j := 0; j < 4; j++ {
+			hi, lo := bits.Mul64(s[i], t[j])
+			var c uint64
+			lo, c = bits.Add64(lo, p[i+j], 0)
+			hi += c
+			lo, c = bits.Add64(lo, carry, 0)
+			hi += c
+			p[i+j] = lo
+			carry = hi
+		}
+		p[i+4] = carry
+	}
+	return scalarReduce(p)
+}
+
+/* scalarFold returns lo + hi*c with c = 2^256 - n */
+func scalarFold(lo [4]uint64, hi [4]uint64) [8]uint64 {
+	var r [8]uint64
+	copy(r[:4], lo[:])
+	for i := 0; i < 4; i++ {
+		var carry uint64
+		for j := 0; j < 3; j++ {
+			h, l := bits.Mul64(hi[i], scalarC[j])
+			var c uint64
+			l, c = bits.Add64(l, r[i+j], 0)
+			h += c
+			l, c = bits.Add64(l, carry, 0)
+			h += c
+			r[i+j] = l
+			carry = h
+		}
+		for k := i + 3; k < 8; k++ {
+			r[k], carry = bits.Add64(r[k], carry, 0)
+		}
+	}
+	return r
+}
+
+/* scalarReduce reduces a 512 bits integer modulo n, c has 129 bits so each fold shrinks the integer by 127 bits */
+func scalarReduce(t [8]uint64) scalar {
+	/* t < 2^512, r < 2^386 */
+	r := scalarFold([4]uint64{t[0], t[1], t[2], t[3]}, [4]uint64{t[4], t[5], t[6], t[7]})
+	/* r < 2^260 */
+	r = scalarFold([4]uint64{r[0], r[1], r[2], r[3]}, [4]uint64{r[4], r[5], r[6], 0})
+	/* r < 2^256 + 2^133 */
+	r = scalarFold([4]uint64{r[0], r[1], r[2], r[3]}, [4]uint64{r[4], 0, 0, 0})
+	/* An overflow leaves a small value, r < 2^256 */
+	r = scalarFold([4]uint64{r[0], r[1], r[2], r[3]}, [4]uint64{r[4], 0, 0, 0})
+
+	s := scalar{r[0], r[1], r[2], r[3]}
+	d, borrow := s.subRaw(scalarN)
+	return scalarSelect(1^borrow, d, s)
+}
+
+/* inverse returns s^-1 = s^(n-2) mod n with a fixed 4 bits window, the inverse of 0 is 0 */
+func (s scalar) inverse() scalar {
+	var table [16]scalar
+	table[0] = scalar{1, 0, 0, 0}
+	for i := 1; i < 16; i++ {
+		table[i] = table[i-1].mul(s)
+	}
+
+	/* The exponent is public, only the base has to be kept secret */
+	e, _ := scalarN.subRaw(scalar{2, 0, 0, 0})
+	r := table[e.nibble(63)]
+	for i := 62; i >= 0; i-- {
+		for j := 0; j < 4; j++ {
+			r = r.mul(r)
+		}
+		r = r.mul(table[e.nibble(i)])
+	}
+	return r
+}
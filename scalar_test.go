@@ -0,0 +1,78 @@
+package btc
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScalarArithmetic(t *testing.T) {
+	N := secp256k1.N
+	nMinus := func(i int64) *big.Int { return new(big.Int).Sub(N, big.NewInt(i)) }
+	values := []*big.Int{
+		big.NewInt(0), big.NewInt(1), big.NewInt(2), nMinus(1), nMinus(2),
+		new(big.Int).Rsh(N, 1), new(big.Int).Lsh(big.NewInt(1), 255), new(big.Int).Lsh(big.NewInt(1), 128),
+	}
+	r := rand.New(rand.NewSource(2))
+	for i := 0; i < 200; i++ {
+		values = append(values, new(big.Int).Rand(r, N))
+	}
+
+	for i, a := range values {
+		b := values[(i*5+1)%len(values)]
+		sa := scalarFromBig(a)
+		sb := scalarFromBig(b)
+		assertBigEqual(t, a, sa.big())
+
+		/* Secrets give the same scalar, only 0 is out of [1, n-1] */
+		secret, inRange := scalarFromSecret(a)
+		assert.Equal(t, sa, secret, a.String())
+		assert.Equal(t, uint64(a.Sign()), inRange, a.String())
+		_, inRange = scalarFromBytesChecked(paddedBytes(a, 32))
+		assert.Equal(t, uint64(a.Sign()), inRange, a.String())
+
+		expected := new(big.Int).Add(a, b)
+		assertBigEqual(t, expected.Mod(expected, N), sa.add(sb).big(), a.String())
+		expected = new(big.Int).Mul(a, b)
+		assertBigEqual(t, expected.Mod(expected, N), sa.mul(sb).big(), a.String())
+		expected = new(big.Int).Neg(a)
+		assertBigEqual(t, expected.Mod(expected, N), sa.negate().big(), a.String())
+
+		if a.Sign() == 0 {
+			assert.Equal(t, uint64(1), sa.isZero())
+			continue
+		}
+		assertBigEqual(t, new(big.Int).ModInverse(a, N), sa.inverse().big(), a.String())
+
+		/* Recombining the wNAF digits gives back the scalar */
+		for _, w := range []uint{pointWindow, generatorWindow} {
+			digits := sa.wnaf(w)
+			sum := new(big.Int)
+			for j := len(digits) - 1; j >= 0; j-- {
+				d := digits[j]
+				sum.Lsh(sum, 1)
+				sum.Add(sum, big.NewInt(int64(d)))
+				if d != 0 {
+					assert.Equal(t, int8(1), d&1)
+					assert.True(t, int(d) < 1<<(w-1) && int(d) > -(1<<(w-1)))
+				}
+			}
+			assertBigEqual(t, a, sum, a.String())
+		}
+	}
+
+	/* 32 bytes integers are reduced modulo n */
+	assertBigEqual(t, big.NewInt(3), scalarFromBytes(paddedBytes(new(big.Int).Add(N, big.NewInt(3)), 32)).big())
+	assertBigEqual(t, new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), new(big.Int).Add(N, big.NewInt(1))), scalarFromBytes(paddedBytes(new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1)), 32)).big())
+
+	/* Secrets out of [1, n-1] are reduced and reported */
+	for _, x := range []*big.Int{N, new(big.Int).Add(N, big.NewInt(3)), new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(-3)} {
+		s, inRange := scalarFromSecret(x)
+		assert.Equal(t, uint64(0), inRange, x.String())
+		assertBigEqual(t, new(big.Int).Mod(x, N), s.big(), x.String())
+	}
+	_, inRange := scalarFromBytesChecked(paddedBytes(N, 32))
+	assert.Equal(t, uint64(0), inRange)
+}
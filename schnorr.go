@@ -0,0 +1,245 @@
+package btc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math/big"
+
+	"github.com/aureleoules/ecdsa"
+)
+
+/* taggedHash computes sha256(sha256(tag) || sha256(tag) || data) as defined in BIP340 */
+func taggedHash(tag string, data ...[]byte) []byte {
+	tagHash := sha256.Sum256([]byte(tag))
+
+	sha := sha256.New()
+	sha.Write(tagHash[:])
+	sha.Write(tagHash[:])
+	for _, d := range data {
+		sha.Write(d)
+	}
+	return sha.Sum(nil)
+}
+
+/* liftX returns the point with the given x coordinate and an even y */
+func liftX(x *big.Int) (ecdsa.Point, error) {
+	if x.Sign() < 0 || x.Cmp(secp256k1.P) >= 0 {
+		return ecdsa.Point{}, errors.New("x coordinate exceeds field size")
+	}
+
+	/* c = x^3 + 7 mod p, y = c^((p+1)/4) mod p */
+	fx := fieldFromBig(x)
+	c := fx.square().mul(fx).add(fieldFromBig(secp256k1.B))
+	y, isSquare := c.sqrt()
+	if isSquare == 0 {
+		return ecdsa.Point{}, errors.New("point is not on secp256k1 curve")
+	}
+
+	y = fieldSelect(y.isOdd(), y.negate(), y)
+	return ecdsa.Point{X: new(big.Int).Set(x), Y: y.big()}, nil
+}
+
+/* negatePoint returns -P */
+func negatePoint(P ecdsa.Point) ecdsa.Point {
+	if P.IsInfinity() {
+		return P
+	}
+	return ecdsa.Point{X: P.X, Y: new(big.Int).Sub(secp256k1.P, P.Y)}
+}
+
+// PublicFromXOnly imports a public key from its 32 bytes x-only hex value (BIP340)
+func PublicFromXOnly(hexa string, network *Network) (*PublicKey, error) {
+	b, err := hex.DecodeString(hexa)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != 32 {
+		return nil, errors.New("x-only public key must be 32 bytes long")
+	}
+
+	P, err := liftX(new(big.Int).SetBytes(b))
+	if err != nil {
+		return nil, err
+	}
+
+	return &PublicKey{
+		X:          P.X,
+		Y:          P.Y,
+		Compressed: true,
+		Network:    network,
+	}, nil
+}
+
+// FormatXOnly returns the 32 bytes x-only public key in hex (BIP340)
+func (p *PublicKey) FormatXOnly() string {
+	return paddedHex(p.X)
+}
+
+/* evenYScalar returns the key, negated in constant time if its public key has an odd Y, and the public key */
+func (p *PrivateKey) evenYScalar() (scalar, ecdsa.Point, error) {
+	if p.Key == nil {
+		return scalar{}, ecdsa.Point{}, errors.New("invalid private key")
+	}
+	d, valid := scalarFromSecret(p.Key)
+	if valid == 0 {
+		return scalar{}, ecdsa.Point{}, errors.New("invalid private key")
+	}
+
+	P := baseMult(d).point()
+	return scalarSelect(uint64(P.Y.Bit(0)), d.negate(), d), P, nil
+}
+
+// EvenY returns the private key itself or its negation, whichever has a public key with an even Y
+func (p *PrivateKey) EvenY() (*PrivateKey, error) {
+	d, _, err := p.evenYScalar()
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, err := PrivateFromHex(hex.EncodeToString(d.bytes()), p.Network)
+	if err != nil {
+		return nil, err
+	}
+	if p.Compressed {
+		privateKey.SetCompressed(true)
+	}
+	return privateKey, nil
+}
+
+// SignSchnorr computes the BIP340 signature of a message, auxRand is 32 bytes of fresh randomness or nil
+func (p *PrivateKey) SignSchnorr(message []byte, auxRand []byte) ([]byte, error) {
+	/* The key and the nonce stay scalars, only the public R and s are converted back */
+	d, P, err := p.evenYScalar()
+	if err != nil {
+		return nil, err
+	}
+
+	if auxRand == nil {
+		auxRand = make([]byte, 32)
+		_, err := rand.Read(auxRand)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(auxRand) != 32 {
+		return nil, errors.New("auxiliary randomness must be 32 bytes long")
+	}
+
+	px := paddedBytes(P.X, 32)
+
+	/* t = bytes(d) xor hash_BIP0340/aux(a) */
+	t := taggedHash("BIP0340/aux", auxRand)
+	for i, b := range d.bytes() {
+		t[i] ^= b
+	}
+
+	k := scalarFromBytes(taggedHash("BIP0340/nonce", t, px, message))
+	if k.isZero() == 1 {
+		return nil, errors.New("invalid nonce")
+	}
+
+	R := baseMult(k).point()
+	k = scalarSelect(uint64(R.Y.Bit(0)), k.negate(), k)
+	rx := paddedBytes(R.X, 32)
+
+	e := scalarFromBytes(taggedHash("BIP0340/challenge", rx, px, message))
+
+	/* s = k + e*d mod n */
+	s := k.add(e.mul(d))
+
+	signature := append(rx, s.bytes()...)
+
+	/* Make sure the signature is valid before returning it */
+	publicKey := &PublicKey{X: P.X, Y: P.Y, Network: p.Network}
+	if !publicKey.VerifySchnorr(message, signature) {
+		return nil, errors.New("produced an invalid signature")
+	}
+	return signature, nil
+}
+
+/* parseSchnorr decodes the x-only public key and the signature of a BIP340 verification */
+func parseSchnorr(p *PublicKey, message []byte, signature []byte) (P ecdsa.Point, rx *big.Int, s *big.Int, e *big.Int, err error) {
+	if len(signature) != 64 {
+		err = errors.New("signature must be 64 bytes long")
+		return
+	}
+
+	P, err = liftX(p.X)
+	if err != nil {
+		return
+	}
+
+	rx = new(big.Int).SetBytes(signature[0:32])
+	if rx.Cmp(secp256k1.P) >= 0 {
+		err = errors.New("signature R exceeds field size")
+		return
+	}
+	s = new(big.Int).SetBytes(signature[32:64])
+	if s.Cmp(secp256k1.N) >= 0 {
+		err = errors.New("signature S exceeds curve order")
+		return
+	}
+
+	e = new(big.Int).SetBytes(taggedHash("BIP0340/challenge", signature[0:32], paddedBytes(P.X, 32), message))
+	e.Mod(e, secp256k1.N)
+	return
+}
+
+// VerifySchnorr checks a BIP340 signature of message against the x-only public key
+func (p *PublicKey) VerifySchnorr(message []byte, signature []byte) bool {
+	P, rx, s, e, err := parseSchnorr(p, message, signature)
+	if err != nil {
+		return false
+	}
+
+	/* R = s*G - e*P */
+	R := doubleScalarMult(scalarFromBig(s), scalarFromBig(e).negate(), P)
+	if R.IsInfinity() || R.Y.Bit(0) != 0 {
+		return false
+	}
+	return R.X.Cmp(rx) == 0
+}
+
+// VerifySchnorrBatch checks several BIP340 signatures at once, it returns true only if all of them are valid, an empty batch is valid and slices of different lengths are not
+func VerifySchnorrBatch(publicKeys []*PublicKey, messages [][]byte, signatures [][]byte) bool {
+	if len(publicKeys) != len(messages) || len(publicKeys) != len(signatures) {
+		return false
+	}
+
+	/* Check (a1*s1 + ... + au*su)*G - a1*R1 - ... - au*Ru - a1*e1*P1 - ... - au*eu*Pu = 0 with a single variable time multiplication */
+	var left scalar
+	scalars := make([]scalar, 0, 2*len(publicKeys))
+	points := make([]jacobianPoint, 0, 2*len(publicKeys))
+	for i := range publicKeys {
+		P, rx, s, e, err := parseSchnorr(publicKeys[i], messages[i], signatures[i])
+		if err != nil {
+			return false
+		}
+		R, err := liftX(rx)
+		if err != nil {
+			return false
+		}
+
+		/* a1 = 1, the other coefficients are random */
+		a := scalar{1, 0, 0, 0}
+		if i > 0 {
+			random := make([]byte, 32)
+			_, err = rand.Read(random)
+			if err != nil {
+				return false
+			}
+			a = scalarFromBytes(random)
+			if a.isZero() == 1 {
+				return false
+			}
+		}
+
+		left = left.add(a.mul(scalarFromBig(s)))
+		scalars = append(scalars, a.negate(), a.mul(scalarFromBig(e)).negate())
+		points = append(points, jacobianFromPoint(R), jacobianFromPoint(P))
+	}
+
+	return multiMult(left, scalars, points).isInfinity() == 1
+}
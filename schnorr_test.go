@@ -0,0 +1,108 @@
+package btc
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchnorr(t *testing.T) {
+	file, err := os.Open("testdata/bip340_vectors.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var publicKeys []*PublicKey
+	var messages, signatures [][]byte
+
+	/* index, secret key, public key, aux_rand, message, signature, verification result, comment */
+	for _, record := range records[1:] {
+		index := record[0]
+		message, _ := hex.DecodeString(record[4])
+		signature, _ := hex.DecodeString(record[5])
+		expected := record[6] == "TRUE"
+
+		if record[1] != "" {
+			privateKey, err := PrivateFromHex(record[1], MainNetwork)
+			assert.Nil(t, err)
+
+			publicKey, valid := privateKey.GetPublicKey()
+			assert.True(t, valid)
+			assert.Equal(t, strings.ToLower(record[2]), publicKey.FormatXOnly(), index)
+
+			auxRand, _ := hex.DecodeString(record[3])
+			sig, err := privateKey.SignSchnorr(message, auxRand)
+			assert.Nil(t, err, index)
+			assert.Equal(t, strings.ToLower(record[5]), hex.EncodeToString(sig), index)
+		}
+
+		publicKey, err := PublicFromXOnly(record[2], MainNetwork)
+		if err != nil {
+			assert.False(t, expected, index)
+			continue
+		}
+		assert.Equal(t, expected, publicKey.VerifySchnorr(message, signature), index)
+
+		if expected {
+			publicKeys = append(publicKeys, publicKey)
+			messages = append(messages, message)
+			signatures = append(signatures, signature)
+		}
+	}
+
+	assert.True(t, VerifySchnorrBatch(publicKeys, messages, signatures))
+
+	/* A single invalid signature fails the whole batch */
+	invalid := append([]byte{}, signatures[0]...)
+	invalid[63] ^= 0x01
+	assert.False(t, VerifySchnorrBatch(publicKeys, messages, append([][]byte{invalid}, signatures[1:]...)))
+	assert.False(t, VerifySchnorrBatch(publicKeys, messages[1:], signatures))
+	assert.False(t, VerifySchnorrBatch(publicKeys, messages, signatures[1:]))
+	assert.False(t, VerifySchnorrBatch(nil, nil, signatures))
+
+	/* An empty batch has no invalid signature */
+	assert.True(t, VerifySchnorrBatch(nil, nil, nil))
+}
+
+func TestSignSchnorrRandom(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		privateKey := GeneratePrivateKey(TestNetwork)
+		publicKey, _ := privateKey.GetPublicKey()
+		message := []byte(privateKey.WIF)
+
+		signature, err := privateKey.SignSchnorr(message, nil)
+		assert.Nil(t, err)
+		assert.True(t, publicKey.VerifySchnorr(message, signature))
+
+		/* The x-only key of the even Y private key is the same */
+		evenKey, err := privateKey.EvenY()
+		assert.Nil(t, err)
+		evenPublicKey, _ := evenKey.GetPublicKey()
+		assert.Equal(t, uint(0), evenPublicKey.Y.Bit(0))
+		assert.Equal(t, publicKey.FormatXOnly(), evenPublicKey.FormatXOnly())
+	}
+
+	_, err := GeneratePrivateKey(MainNetwork).SignSchnorr([]byte{}, []byte{0x00})
+	assert.NotNil(t, err)
+
+	/* Keys out of [1, n-1] are rejected */
+	for _, key := range []*PrivateKey{{Network: MainNetwork}, {Key: big.NewInt(0), Network: MainNetwork}, {Key: secp256k1.N, Network: MainNetwork}} {
+		_, err = key.SignSchnorr([]byte{}, nil)
+		assert.NotNil(t, err, key.Key)
+		_, err = key.EvenY()
+		assert.NotNil(t, err, key.Key)
+		_, err = key.TaprootTweak(nil)
+		assert.NotNil(t, err, key.Key)
+	}
+}
@@ -0,0 +1,673 @@
+package btc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"math"
+	"strings"
+)
+
+// Opcodes
+const (
+	Op0                   byte = 0x00
+	OpFalse               byte = Op0
+	OpPushData1           byte = 0x4c
+	OpPushData2           byte = 0x4d
+	OpPushData4           byte = 0x4e
+	Op1Negate             byte = 0x4f
+	OpReserved            byte = 0x50
+	Op1                   byte = 0x51
+	OpTrue                byte = Op1
+	Op2                   byte = 0x52
+	Op3                   byte = 0x53
+	Op4                   byte = 0x54
+	Op5                   byte = 0x55
+	Op6                   byte = 0x56
+	Op7                   byte = 0x57
+	Op8                   byte = 0x58
+	Op9                   byte = 0x59
+	Op10                  byte = 0x5a
+	Op11                  byte = 0x5b
+	Op12                  byte = 0x5c
+	Op13                  byte = 0x5d
+	Op14                  byte = 0x5e
+	Op15                  byte = 0x5f
+	Op16                  byte = 0x60
+	OpNop                 byte = 0x61
+	OpVer                 byte = 0x62
+	OpIf                  byte = 0x63
+	OpNotIf               byte = 0x64
+	OpVerIf               byte = 0x65
+	OpVerNotIf            byte = 0x66
+	OpElse                byte = 0x67
+	OpEndIf               byte = 0x68
+	OpVerify              byte = 0x69
+	OpReturn              byte = 0x6a
+	OpToAltStack          byte = 0x6b
+	OpFromAltStack        byte = 0x6c
+	Op2Drop               byte = 0x6d
+	Op2Dup                byte = 0x6e
+	Op3Dup                byte = 0x6f
+	Op2Over               byte = 0x70
+	Op2Rot                byte = 0x71
+	Op2Swap               byte = 0x72
+	OpIfDup               byte = 0x73
+	OpDepth               byte = 0x74
+	OpDrop                byte = 0x75
+	OpDup                 byte = 0x76
+	OpNip                 byte = 0x77
+	OpOver                byte = 0x78
+	OpPick                byte = 0x79
+	OpRoll                byte = 0x7a
+	OpRot                 byte = 0x7b
+	OpSwap                byte = 0x7c
+	OpTuck                byte = 0x7d
+	OpCat                 byte = 0x7e
+	OpSubStr              byte = 0x7f
+	OpLeft                byte = 0x80
+	OpRight               byte = 0x81
+	OpSize                byte = 0x82
+	OpInvert              byte = 0x83
+	OpAnd                 byte = 0x84
+	OpOr                  byte = 0x85
+	OpXor                 byte = 0x86
+	OpEqual               byte = 0x87
+	OpEqualVerify         byte = 0x88
+	OpReserved1           byte = 0x89
+	OpReserved2           byte = 0x8a
+	Op1Add                byte = 0x8b
+	Op1Sub                byte = 0x8c
+	Op2Mul                byte = 0x8d
+	Op2Div                byte = 0x8e
+	OpNegate              byte = 0x8f
+	OpAbs                 byte = 0x90
+	OpNot                 byte = 0x91
+	Op0NotEqual           byte = 0x92
+	OpAdd                 byte = 0x93
+	OpSub                 byte = 0x94
+	OpMul                 byte = 0x95
+	OpDiv                 byte = 0x96
+	OpMod                 byte = 0x97
+	OpLShift              byte = 0x98
+	OpRShift              byte = 0x99
+	OpBoolAnd             byte = 0x9a
+	OpBoolOr              byte = 0x9b
+	OpNumEqual            byte = 0x9c
+	OpNumEqualVerify      byte = 0x9d
+	OpNumNotEqual         byte = 0x9e
+	OpLessThan            byte = 0x9f
+	OpGreaterThan         byte = 0xa0
+	OpLessThanOrEqual     byte = 0xa1
+	OpGreaterThanOrEqual  byte = 0xa2
+	OpMin                 byte = 0xa3
+	OpMax                 byte = 0xa4
+	OpWithin              byte = 0xa5
+	OpRipemd160           byte = 0xa6
+	OpSha1                byte = 0xa7
+	OpSha256              byte = 0xa8
+	OpHash160             byte = 0xa9
+	OpHash256             byte = 0xaa
+	OpCodeSeparator       byte = 0xab
+	OpCheckSig            byte = 0xac
+	OpCheckSigVerify      byte = 0xad
+	OpCheckMultiSig       byte = 0xae
+	OpCheckMultiSigVerify byte = 0xaf
+	OpNop1                byte = 0xb0
+	OpCheckLockTimeVerify byte = 0xb1
+	OpNop2                byte = OpCheckLockTimeVerify
+	OpCheckSequenceVerify byte = 0xb2
+	OpNop3                byte = OpCheckSequenceVerify
+	OpNop4                byte = 0xb3
+	OpNop5                byte = 0xb4
+	OpNop6                byte = 0xb5
+	OpNop7                byte = 0xb6
+	OpNop8                byte = 0xb7
+	OpNop9                byte = 0xb8
+	OpNop10               byte = 0xb9
+	OpCheckSigAdd         byte = 0xba
+	OpInvalidOpcode       byte = 0xff
+)
+
+// Script limits
+const (
+	MaxScriptSize         = 10000
+	MaxScriptElementSize  = 520
+	MaxOpsPerScript       = 201
+	MaxPubKeysPerMultiSig = 20
+	MaxStackSize          = 1000
+)
+
+/* getScriptOp reads the opcode at pc and its pushed data, next is where the read stopped even when it fails (like Bitcoin Core's GetScriptOp) */
+func getScriptOp(script []byte, pc int) (opcode byte, data []byte, next int, ok bool) {
+	if pc >= len(script) {
+		return OpInvalidOpcode, nil, pc, false
+	}
+	opcode = script[pc]
+	pc++
+
+	if opcode > OpPushData4 {
+		return opcode, nil, pc, true
+	}
+
+	var size int
+	switch opcode {
+	case OpPushData1:
+		if len(script)-pc < 1 {
+			return OpInvalidOpcode, nil, pc, false
+		}
+		size = int(script[pc])
+		pc++
+	case OpPushData2:
+		if len(script)-pc < 2 {
+			return OpInvalidOpcode, nil, pc, false
+		}
+		size = int(binary.LittleEndian.Uint16(script[pc:]))
+		pc += 2
+	case OpPushData4:
+		if len(script)-pc < 4 {
+			return OpInvalidOpcode, nil, pc, false
+		}
+		n := binary.LittleEndian.Uint32(script[pc:])
+		pc += 4
+		if uint64(n) > uint64(len(script)-pc) {
+			return OpInvalidOpcode, nil, pc, false
+		}
+		size = int(n)
+	default:
+		size = int(opcode)
+	}
+
+	if len(script)-pc < size {
+		return OpInvalidOpcode, nil, pc, false
+	}
+	return opcode, script[pc : pc+size], pc + size, true
+}
+
+/* pushData returns the script pushing data with the smallest length prefix, like Bitcoin Core's CScript << data */
+func pushData(data []byte) []byte {
+	var script []byte
+	switch {
+	case len(data) < int(OpPushData1):
+		script = []byte{byte(len(data))}
+	case len(data) <= 0xff:
+		script = []byte{OpPushData1, byte(len(data))}
+	case len(data) <= 0xffff:
+		script = []byte{OpPushData2, byte(len(data)), byte(len(data) >> 8)}
+	default:
+		script = appendUint32([]byte{OpPushData4}, uint32(len(data)))
+	}
+	return append(script, data...)
+}
+
+/* checkMinimalPush returns true if data is pushed with the smallest possible opcode */
+func checkMinimalPush(data []byte, opcode byte) bool {
+	switch {
+	case len(data) == 0:
+		return opcode == Op0
+	case len(data) == 1 && data[0] >= 1 && data[0] <= 16:
+		/* Should have used OP_1 to OP_16 */
+		return false
+	case len(data) == 1 && data[0] == 0x81:
+		/* Should have used OP_1NEGATE */
+		return false
+	case len(data) <= 75:
+		return int(opcode) == len(data)
+	case len(data) <= 0xff:
+		return opcode == OpPushData1
+	case len(data) <= 0xffff:
+		return opcode == OpPushData2
+	}
+	return true
+}
+
+/* isPushOnly returns true if the script only contains push opcodes (OP_RESERVED included) */
+func isPushOnly(script []byte) bool {
+	pc := 0
+	for pc < len(script) {
+		opcode, _, next, ok := getScriptOp(script, pc)
+		if !ok || opcode > Op16 {
+			return false
+		}
+		pc = next
+	}
+	return true
+}
+
+/* isPayToScriptHash returns true for OP_HASH160 <20 bytes> OP_EQUAL */
+func isPayToScriptHash(script []byte) bool {
+	return len(script) == 23 && script[0] == OpHash160 && script[1] == 0x14 && script[22] == OpEqual
+}
+
+/* witnessProgram returns the version and program of a witness output script: a version opcode followed by a 2 to 40 bytes push */
+func witnessProgram(script []byte) (int, []byte, bool) {
+	if len(script) < 4 || len(script) > 42 {
+		return 0, nil, false
+	}
+	if script[0] != Op0 && (script[0] < Op1 || script[0] > Op16) {
+		return 0, nil, false
+	}
+	if int(script[1])+2 != len(script) {
+		return 0, nil, false
+	}
+
+	version := 0
+	if script[0] != Op0 {
+		version = int(script[0]-Op1) + 1
+	}
+	return version, script[2:], true
+}
+
+/* isOpSuccess returns true for the opcodes that make a tapscript succeed unconditionally (BIP342) */
+func isOpSuccess(opcode byte) bool {
+	return opcode == 80 || opcode == 98 || (opcode >= 126 && opcode <= 129) ||
+		(opcode >= 131 && opcode <= 134) || (opcode >= 137 && opcode <= 138) ||
+		(opcode >= 141 && opcode <= 142) || (opcode >= 149 && opcode <= 153) ||
+		(opcode >= 187 && opcode <= 254)
+}
+
+/* findAndDelete removes every push of b found at an opcode boundary of script and returns the number of matches */
+func findAndDelete(script []byte, b []byte) ([]byte, int) {
+	found := 0
+	var result []byte
+	pc, begin := 0, 0
+	for {
+		result = append(result, script[begin:pc]...)
+		for len(script)-pc >= len(b) && bytes.Equal(script[pc:pc+len(b)], b) {
+			pc += len(b)
+			found++
+		}
+		begin = pc
+
+		_, _, next, ok := getScriptOp(script, pc)
+		pc = next
+		if !ok {
+			break
+		}
+	}
+
+	if found == 0 {
+		return script, 0
+	}
+	return append(result, script[begin:]...), found
+}
+
+var (
+	errScriptNumOverflow   = errors.New("script number overflow")
+	errScriptNumNotMinimal = errors.New("non-minimally encoded script number")
+)
+
+/* scriptNum is a number read from or written to the stack: little-endian with a sign bit */
+type scriptNum int64
+
+/* parseScriptNum reads a number of at most maxSize bytes */
+func parseScriptNum(b []byte, requireMinimal bool, maxSize int) (scriptNum, error) {
+	if len(b) > maxSize {
+		return 0, errScriptNumOverflow
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+
+	/* The most significant byte, without its sign bit, can only be zero if the next byte needs its high bit */
+	if requireMinimal && b[len(b)-1]&0x7f == 0 {
+		if len(b) == 1 || b[len(b)-2]&0x80 == 0 {
+			return 0, errScriptNumNotMinimal
+		}
+	}
+
+	var n int64
+	for i := range b {
+		n |= int64(b[i]) << uint(8*i)
+	}
+	if b[len(b)-1]&0x80 != 0 {
+		return -scriptNum(n &^ (int64(0x80) << uint(8*(len(b)-1)))), nil
+	}
+	return scriptNum(n), nil
+}
+
+/* bytes returns the minimal encoding of the number */
+func (n scriptNum) bytes() []byte {
+	if n == 0 {
+		return nil
+	}
+
+	negative := n < 0
+	abs := uint64(n)
+	if negative {
+		abs = uint64(-n)
+	}
+
+	var b []byte
+	for abs > 0 {
+		b = append(b, byte(abs))
+		abs >>= 8
+	}
+
+	if b[len(b)-1]&0x80 != 0 {
+		if negative {
+			b = append(b, 0x80)
+		} else {
+			b = append(b, 0x00)
+		}
+	} else if negative {
+		b[len(b)-1] |= 0x80
+	}
+	return b
+}
+
+/* int32 returns the number clamped to the int32 range */
+func (n scriptNum) int32() int {
+	if n > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	if n < math.MinInt32 {
+		return math.MinInt32
+	}
+	return int(n)
+}
+
+/* opcodeNames are the names of the opcodes in Bitcoin Core, data push opcodes 0x01-0x4b and undefined opcodes are unnamed */
+var opcodeNames = map[byte]string{
+	Op0: "OP_0", OpPushData1: "OP_PUSHDATA1", OpPushData2: "OP_PUSHDATA2", OpPushData4: "OP_PUSHDATA4",
+	Op1Negate: "OP_1NEGATE", OpReserved: "OP_RESERVED",
+	Op1: "OP_1", Op2: "OP_2", Op3: "OP_3", Op4: "OP_4", Op5: "OP_5", Op6: "OP_6", Op7: "OP_7", Op8: "OP_8",
+	Op9: "OP_9", Op10: "OP_10", Op11: "OP_11", Op12: "OP_12", Op13: "OP_13", Op14: "OP_14", Op15: "OP_15", Op16: "OP_16",
+
+	OpNop: "OP_NOP", OpVer: "OP_VER", OpIf: "OP_IF", OpNotIf: "OP_NOTIF", OpVerIf: "OP_VERIF", OpVerNotIf: "OP_VERNOTIF",
+	OpElse: "OP_ELSE", OpEndIf: "OP_ENDIF", OpVerify: "OP_VERIFY", OpReturn: "OP_RETURN",
+
+	OpToAltStack: "OP_TOALTSTACK", OpFromAltStack: "OP_FROMALTSTACK", Op2Drop: "OP_2DROP", Op2Dup: "OP_2DUP",
+	Op3Dup: "OP_3DUP", Op2Over: "OP_2OVER", Op2Rot: "OP_2ROT", Op2Swap: "OP_2SWAP", OpIfDup: "OP_IFDUP",
+	OpDepth: "OP_DEPTH", OpDrop: "OP_DROP", OpDup: "OP_DUP", OpNip: "OP_NIP", OpOver: "OP_OVER", OpPick: "OP_PICK",
+	OpRoll: "OP_ROLL", OpRot: "OP_ROT", OpSwap: "OP_SWAP", OpTuck: "OP_TUCK",
+
+	OpCat: "OP_CAT", OpSubStr: "OP_SUBSTR", OpLeft: "OP_LEFT", OpRight: "OP_RIGHT", OpSize: "OP_SIZE",
+
+	OpInvert: "OP_INVERT", OpAnd: "OP_AND", OpOr: "OP_OR", OpXor: "OP_XOR", OpEqual: "OP_EQUAL",
+	OpEqualVerify: "OP_EQUALVERIFY", OpReserved1: "OP_RESERVED1", OpReserved2: "OP_RESERVED2",
+
+	Op1Add: "OP_1ADD", Op1Sub: "OP_1SUB", Op2Mul: "OP_2MUL", Op2Div: "OP_2DIV", OpNegate: "OP_NEGATE",
+	OpAbs: "OP_ABS", OpNot: "OP_NOT", Op0NotEqual: "OP_0NOTEQUAL", OpAdd: "OP_ADD", OpSub: "OP_SUB",
+	OpMul: "OP_MUL", OpDiv: "OP_DIV", OpMod: "OP_MOD", OpLShift: "OP_LSHIFT", OpRShift: "OP_RSHIFT",
+	OpBoolAnd: "OP_BOOLAND", OpBoolOr: "OP_BOOLOR", OpNumEqual: "OP_NUMEQUAL", OpNumEqualVerify: "OP_NUMEQUALVERIFY",
+	OpNumNotEqual: "OP_NUMNOTEQUAL", OpLessThan: "OP_LESSTHAN", OpGreaterThan: "OP_GREATERTHAN",
+	OpLessThanOrEqual: "OP_LESSTHANOREQUAL", OpGreaterThanOrEqual: "OP_GREATERTHANOREQUAL",
+	OpMin: "OP_MIN", OpMax: "OP_MAX", OpWithin: "OP_WITHIN",
+
+	OpRipemd160: "OP_RIPEMD160", OpSha1: "OP_SHA1", OpSha256: "OP_SHA256", OpHash160: "OP_HASH160",
+	OpHash256: "OP_HASH256", OpCodeSeparator: "OP_CODESEPARATOR", OpCheckSig: "OP_CHECKSIG",
+	OpCheckSigVerify: "OP_CHECKSIGVERIFY", OpCheckMultiSig: "OP_CHECKMULTISIG",
+	OpCheckMultiSigVerify: "OP_CHECKMULTISIGVERIFY",
+
+	OpNop1: "OP_NOP1", OpCheckLockTimeVerify: "OP_CHECKLOCKTIMEVERIFY", OpCheckSequenceVerify: "OP_CHECKSEQUENCEVERIFY",
+	OpNop4: "OP_NOP4", OpNop5: "OP_NOP5", OpNop6: "OP_NOP6", OpNop7: "OP_NOP7", OpNop8: "OP_NOP8",
+	OpNop9: "OP_NOP9", OpNop10: "OP_NOP10",
+
+	OpCheckSigAdd: "OP_CHECKSIGADD", OpInvalidOpcode: "OP_INVALIDOPCODE",
+}
+
+/* opcodeValues maps the opcode names to their value, with the usual aliases */
+var opcodeValues = func() map[string]byte {
+	values := map[string]byte{
+		"OP_FALSE": OpFalse,
+		"OP_TRUE":  OpTrue,
+		"OP_NOP2":  OpNop2,
+		"OP_NOP3":  OpNop3,
+	}
+	for opcode, name := range opcodeNames {
+		values[name] = opcode
+	}
+	return values
+}()
+
+// OpcodeName returns the name of an opcode, OP_UNKNOWN for data pushes and undefined opcodes
+func OpcodeName(opcode byte) string {
+	name, ok := opcodeNames[opcode]
+	if !ok {
+		return "OP_UNKNOWN"
+	}
+	return name
+}
+
+// OpcodeFromName returns the opcode of a name, the OP_ prefix is optional
+func OpcodeFromName(name string) (byte, bool) {
+	if !strings.HasPrefix(name, "OP_") {
+		name = "OP_" + name
+	}
+	opcode, ok := opcodeValues[name]
+	return opcode, ok
+}
+
+// Script types
+const (
+	NonStandardScript ScriptType = iota
+	P2PKScript
+	P2PKHScript
+	P2SHScript
+	P2MSScript
+	P2WPKHScript
+	P2WSHScript
+	P2TRScript
+	/* Witness programs of future witness versions or lengths */
+	WitnessUnknownScript
+	/* OP_RETURN followed by pushes only, the output is provably unspendable */
+	NullDataScript
+)
+
+// ScriptFromHex parses a script from its hex value
+func ScriptFromHex(hexa string) (Script, error) {
+	b, err := hex.DecodeString(hexa)
+	if err != nil {
+		return nil, err
+	}
+	return Script(b), nil
+}
+
+// ParseScriptASM parses a script from its ASM representation: OP_ prefixed opcode names and data pushes in hex, separated by spaces
+func ParseScriptASM(asm string) (Script, error) {
+	script := Script{}
+	for _, token := range strings.Fields(asm) {
+		if strings.HasPrefix(token, "OP_") {
+			opcode, ok := OpcodeFromName(token)
+			if !ok {
+				return nil, errors.New("unknown opcode " + token)
+			}
+			/* The data of pushes is given in hex */
+			if opcode >= OpPushData1 && opcode <= OpPushData4 {
+				return nil, errors.New(token + " must be replaced by the pushed data")
+			}
+			script = script.AddOpcode(opcode)
+			continue
+		}
+
+		data, err := hex.DecodeString(token)
+		if err != nil {
+			return nil, errors.New("invalid data push " + token)
+		}
+		script = script.PushData(data)
+	}
+	return script, nil
+}
+
+// AddOpcode returns the script followed by an opcode
+func (s Script) AddOpcode(opcode byte) Script {
+	return append(s, opcode)
+}
+
+// PushData returns the script followed by the smallest push of data, OP_0, OP_1NEGATE and OP_1 to OP_16 included
+func (s Script) PushData(data []byte) Script {
+	switch {
+	case len(data) == 0:
+		return append(s, Op0)
+	case len(data) == 1 && data[0] >= 1 && data[0] <= 16:
+		return append(s, Op1+data[0]-1)
+	case len(data) == 1 && data[0] == 0x81:
+		return append(s, Op1Negate)
+	}
+	return append(s, pushData(data)...)
+}
+
+// Hex returns the script in hex
+func (s Script) Hex() string {
+	return hex.EncodeToString(s)
+}
+
+// Disassemble returns the ASM representation of the script
+func (s Script) Disassemble() (string, error) {
+	var tokens []string
+	pc := 0
+	for pc < len(s) {
+		opcode, data, next, ok := getScriptOp(s, pc)
+		if !ok {
+			return "", errors.New("truncated data push")
+		}
+		pc = next
+
+		if opcode > Op0 && opcode <= OpPushData4 {
+			/* An empty push has no hex, it pushes the same value as OP_0 */
+			if len(data) == 0 {
+				tokens = append(tokens, OpcodeName(Op0))
+				continue
+			}
+			tokens = append(tokens, hex.EncodeToString(data))
+			continue
+		}
+		tokens = append(tokens, OpcodeName(opcode))
+	}
+	return strings.Join(tokens, " "), nil
+}
+
+// Type returns the standard template the script matches
+func (s Script) Type() ScriptType {
+	if version, program, ok := witnessProgram(s); ok {
+		switch {
+		case version == 0 && len(program) == 20:
+			return P2WPKHScript
+		case version == 0 && len(program) == 32:
+			return P2WSHScript
+		case version == 0:
+			return NonStandardScript
+		case version == 1 && len(program) == 32:
+			return P2TRScript
+		}
+		return WitnessUnknownScript
+	}
+
+	switch {
+	case isPayToScriptHash(s):
+		return P2SHScript
+	case len(s) == 25 && s[0] == OpDup && s[1] == OpHash160 && s[2] == 0x14 && s[23] == OpEqualVerify && s[24] == OpCheckSig:
+		return P2PKHScript
+	case len(s) > 0 && s[0] == OpReturn && isPushOnly(s[1:]):
+		return NullDataScript
+	}
+
+	if _, ok := s.payToPubKey(); ok {
+		return P2PKScript
+	}
+	if _, _, ok := s.multiSig(); ok {
+		return P2MSScript
+	}
+	return NonStandardScript
+}
+
+/* validPubKeySize returns true if the size of a public key matches its prefix */
+func validPubKeySize(key []byte) bool {
+	if len(key) == 33 {
+		return key[0] == 0x02 || key[0] == 0x03
+	}
+	return len(key) == 65 && (key[0] == 0x04 || key[0] == 0x06 || key[0] == 0x07)
+}
+
+/* payToPubKey matches <pubkey> OP_CHECKSIG */
+func (s Script) payToPubKey() ([]byte, bool) {
+	if len(s) < 2 || s[len(s)-1] != OpCheckSig {
+		return nil, false
+	}
+	opcode, key, next, ok := getScriptOp(s, 0)
+	if !ok || opcode > OpPushData4 || next != len(s)-1 || !validPubKeySize(key) {
+		return nil, false
+	}
+	return key, true
+}
+
+/* multiSig matches OP_m <pubkey>... OP_n OP_CHECKMULTISIG */
+func (s Script) multiSig() (int, [][]byte, bool) {
+	if len(s) < 3 || s[len(s)-1] != OpCheckMultiSig || s[0] < Op1 || s[0] > Op16 {
+		return 0, nil, false
+	}
+	required := int(s[0]-Op1) + 1
+
+	var keys [][]byte
+	pc := 1
+	for {
+		opcode, key, next, ok := getScriptOp(s, pc)
+		if !ok || opcode > OpPushData4 || !validPubKeySize(key) {
+			break
+		}
+		keys = append(keys, key)
+		pc = next
+	}
+
+	if pc != len(s)-2 || s[pc] < Op1 || s[pc] > Op16 {
+		return 0, nil, false
+	}
+	if int(s[pc]-Op1)+1 != len(keys) || required > len(keys) {
+		return 0, nil, false
+	}
+	return required, keys, true
+}
+
+// PublicKeys returns the public keys of P2PK and P2MS scripts, and the output key of P2TR scripts
+func (s Script) PublicKeys(network *Network) ([]*PublicKey, error) {
+	var keys [][]byte
+	switch s.Type() {
+	case P2PKScript:
+		key, _ := s.payToPubKey()
+		keys = [][]byte{key}
+	case P2MSScript:
+		_, keys, _ = s.multiSig()
+	case P2TRScript:
+		key, err := PublicFromXOnly(hex.EncodeToString(s[2:]), network)
+		if err != nil {
+			return nil, err
+		}
+		return []*PublicKey{key}, nil
+	default:
+		return nil, errors.New("script does not contain public keys")
+	}
+
+	publicKeys := make([]*PublicKey, len(keys))
+	for i, key := range keys {
+		publicKey, err := publicFromBytes(key, network)
+		if err != nil {
+			return nil, err
+		}
+		publicKeys[i] = publicKey
+	}
+	return publicKeys, nil
+}
+
+// RequiredSignatures returns the number of signatures needed to spend a P2MS script
+func (s Script) RequiredSignatures() (int, error) {
+	required, _, ok := s.multiSig()
+	if !ok {
+		return 0, errors.New("script is not a multisig script")
+	}
+	return required, nil
+}
+
+// Hash returns the public key hash of P2PKH and P2WPKH scripts, the script hash of P2SH and P2WSH scripts
+func (s Script) Hash() ([]byte, error) {
+	switch s.Type() {
+	case P2PKHScript:
+		return s[3:23], nil
+	case P2SHScript:
+		return s[2:22], nil
+	case P2WPKHScript, P2WSHScript:
+		return s[2:], nil
+	}
+	return nil, errors.New("script does not contain a hash")
+}
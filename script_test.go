@@ -0,0 +1,188 @@
+package btc
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScriptASM(t *testing.T) {
+	var scripts = []struct {
+		Hex  string
+		ASM  string
+		Type ScriptType
+	}{
+		{"76a91462e907b15cbf27d5425399ebf6f0fb50ebb88f1888ac", "OP_DUP OP_HASH160 62e907b15cbf27d5425399ebf6f0fb50ebb88f18 OP_EQUALVERIFY OP_CHECKSIG", P2PKHScript},
+		{"a914748284390f9e263a4b766a75d0633c50426eb87587", "OP_HASH160 748284390f9e263a4b766a75d0633c50426eb875 OP_EQUAL", P2SHScript},
+		{"0014751e76e8199196d454941c45d1b3a323f1433bd6", "OP_0 751e76e8199196d454941c45d1b3a323f1433bd6", P2WPKHScript},
+		{"00201863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262", "OP_0 1863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262", P2WSHScript},
+		{"5120a60869f0dbcf1dc659c9cecbaf8050135ea9e8cdc487053f1dc6880949dc684c", "OP_1 a60869f0dbcf1dc659c9cecbaf8050135ea9e8cdc487053f1dc6880949dc684c", P2TRScript},
+		/* Genesis block output */
+		{"4104678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5fac", "04678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5f OP_CHECKSIG", P2PKScript},
+		{"5121022df8750480ad5b26950b25c7ba79d3e37d75f640f8e5d9bcd5b150a0f85014da2103e3818b65bcc73a7d64064106a859cc1a5a728c4345ff0b641209fba0d90de6e952ae", "OP_1 022df8750480ad5b26950b25c7ba79d3e37d75f640f8e5d9bcd5b150a0f85014da 03e3818b65bcc73a7d64064106a859cc1a5a728c4345ff0b641209fba0d90de6e9 OP_2 OP_CHECKMULTISIG", P2MSScript},
+		{"6a0b68656c6c6f20776f726c64", "OP_RETURN 68656c6c6f20776f726c64", NullDataScript},
+		{"6a", "OP_RETURN", NullDataScript},
+		/* Pay to anchor */
+		{"51024e73", "OP_1 4e73", WitnessUnknownScript},
+		{"0015751e76e8199196d454941c45d1b3a323f1433bd6ff", "OP_0 751e76e8199196d454941c45d1b3a323f1433bd6ff", NonStandardScript},
+		{"", "", NonStandardScript},
+		{"4f0063516782b168", "OP_1NEGATE OP_0 OP_IF OP_1 OP_ELSE OP_SIZE OP_CHECKLOCKTIMEVERIFY OP_ENDIF", NonStandardScript},
+		{"6a76", "OP_RETURN OP_DUP", NonStandardScript},
+		{"ba", "OP_CHECKSIGADD", NonStandardScript},
+		/* Multisig with more signatures than keys */
+		{"52210279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f8179851ae", "OP_2 0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798 OP_1 OP_CHECKMULTISIG", NonStandardScript},
+	}
+
+	for _, s := range scripts {
+		script, err := ScriptFromHex(s.Hex)
+		assert.Nil(t, err)
+		assert.Equal(t, s.Type, script.Type(), s.ASM)
+
+		asm, err := script.Disassemble()
+		assert.Nil(t, err)
+		assert.Equal(t, s.ASM, asm)
+
+		parsed, err := ParseScriptASM(s.ASM)
+		assert.Nil(t, err)
+		assert.Equal(t, s.Hex, parsed.Hex())
+	}
+}
+
+func TestParseScriptASM(t *testing.T) {
+	var scripts = []struct {
+		ASM string
+		Hex string
+	}{
+		/* Pushes are minimal */
+		{"", ""},
+		{"00", "0100"},
+		{"01 10 81 11", "51604f0111"},
+		{"OP_TRUE OP_FALSE OP_NOP2 OP_NOP3", "5100b1b2"},
+		{"  OP_DUP\tOP_DROP\n", "7675"},
+	}
+
+	for _, s := range scripts {
+		script, err := ParseScriptASM(s.ASM)
+		assert.Nil(t, err, s.ASM)
+		assert.Equal(t, s.Hex, script.Hex(), s.ASM)
+	}
+
+	/* Pushes of 76 to 255, 256 to 65535 and more bytes */
+	for size, prefix := range map[int]string{75: "4b", 76: "4c4c", 255: "4cff", 256: "4d0001", 520: "4d0802", 65536: "4e00000100"} {
+		script, err := ParseScriptASM(hex.EncodeToString(make([]byte, size)))
+		assert.Nil(t, err)
+		assert.Equal(t, prefix, script.Hex()[:len(prefix)])
+		assert.Equal(t, len(prefix)/2+size, len(script))
+	}
+
+	for _, asm := range []string{"OP_UNKNOWN", "OP_DUPE", "DUP", "0", "abc", "<20 bytes>", "OP_PUSHDATA1 00"} {
+		_, err := ParseScriptASM(asm)
+		assert.NotNil(t, err, asm)
+	}
+
+	/* Truncated pushes */
+	for _, hexa := range []string{"01", "4c", "4c02ff", "4d0100", "4e01000000"} {
+		script, err := ScriptFromHex(hexa)
+		assert.Nil(t, err)
+		_, err = script.Disassemble()
+		assert.NotNil(t, err, hexa)
+		assert.Equal(t, NonStandardScript, script.Type())
+	}
+
+	/* Empty non-minimal pushes */
+	for _, hexa := range []string{"4c00", "4d0000", "4e00000000"} {
+		script, err := ScriptFromHex(hexa + "76")
+		assert.Nil(t, err)
+		asm, err := script.Disassemble()
+		assert.Nil(t, err)
+		assert.Equal(t, "OP_0 OP_DUP", asm, hexa)
+
+		parsed, err := ParseScriptASM(asm)
+		assert.Nil(t, err, hexa)
+		assert.Equal(t, "0076", parsed.Hex())
+	}
+
+	_, err := ScriptFromHex("zz")
+	assert.NotNil(t, err)
+}
+
+func TestOpcodeName(t *testing.T) {
+	for opcode := 0; opcode < 256; opcode++ {
+		name := OpcodeName(byte(opcode))
+		if name == "OP_UNKNOWN" {
+			assert.True(t, (opcode > 0 && opcode < int(OpPushData1)) || (opcode > int(OpCheckSigAdd) && opcode < int(OpInvalidOpcode)), name)
+			continue
+		}
+
+		value, ok := OpcodeFromName(name)
+		assert.True(t, ok)
+		assert.Equal(t, byte(opcode), value)
+
+		/* The prefix is optional */
+		value, ok = OpcodeFromName(name[3:])
+		assert.True(t, ok)
+		assert.Equal(t, byte(opcode), value)
+	}
+
+	_, ok := OpcodeFromName("OP_UNKNOWN")
+	assert.False(t, ok)
+}
+
+func TestScriptExtraction(t *testing.T) {
+	var scripts = []struct {
+		Hex        string
+		PublicKeys []string
+		Hash       string
+		Required   int
+	}{
+		{"76a91462e907b15cbf27d5425399ebf6f0fb50ebb88f1888ac", nil, "62e907b15cbf27d5425399ebf6f0fb50ebb88f18", 0},
+		{"a914748284390f9e263a4b766a75d0633c50426eb87587", nil, "748284390f9e263a4b766a75d0633c50426eb875", 0},
+		{"0014751e76e8199196d454941c45d1b3a323f1433bd6", nil, "751e76e8199196d454941c45d1b3a323f1433bd6", 0},
+		{"00201863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262", nil, "1863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262", 0},
+		{"5120a60869f0dbcf1dc659c9cecbaf8050135ea9e8cdc487053f1dc6880949dc684c", []string{"a60869f0dbcf1dc659c9cecbaf8050135ea9e8cdc487053f1dc6880949dc684c"}, "", 0},
+		{"4104678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5fac", []string{"04678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5f"}, "", 0},
+		{"5121022df8750480ad5b26950b25c7ba79d3e37d75f640f8e5d9bcd5b150a0f85014da2103e3818b65bcc73a7d64064106a859cc1a5a728c4345ff0b641209fba0d90de6e952ae", []string{"022df8750480ad5b26950b25c7ba79d3e37d75f640f8e5d9bcd5b150a0f85014da", "03e3818b65bcc73a7d64064106a859cc1a5a728c4345ff0b641209fba0d90de6e9"}, "", 1},
+		{"6a0b68656c6c6f20776f726c64", nil, "", 0},
+	}
+
+	for _, s := range scripts {
+		script, _ := ScriptFromHex(s.Hex)
+
+		keys, err := script.PublicKeys(MainNetwork)
+		if s.PublicKeys == nil {
+			assert.NotNil(t, err)
+		} else if assert.Nil(t, err) && assert.Equal(t, len(s.PublicKeys), len(keys)) {
+			for i, key := range keys {
+				assert.Equal(t, MainNetwork, key.Network)
+				if script.Type() == P2TRScript {
+					assert.Equal(t, s.PublicKeys[i], key.FormatXOnly())
+				} else {
+					assert.Equal(t, s.PublicKeys[i], key.Format(key.Compressed))
+				}
+			}
+		}
+
+		hash, err := script.Hash()
+		if s.Hash == "" {
+			assert.NotNil(t, err)
+		} else {
+			assert.Nil(t, err)
+			assert.Equal(t, s.Hash, hex.EncodeToString(hash))
+		}
+
+		required, err := script.RequiredSignatures()
+		if s.Required == 0 {
+			assert.NotNil(t, err)
+		} else {
+			assert.Nil(t, err)
+			assert.Equal(t, s.Required, required)
+		}
+	}
+
+	/* Keys of the right size which are not on the curve */
+	script, _ := ParseScriptASM("020000000000000000000000000000000000000000000000000000000000000000 OP_CHECKSIG")
+	assert.Equal(t, P2PKScript, script.Type())
+	_, err := script.PublicKeys(MainNetwork)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,341 @@
+package btc
+
+import (
+	"crypto/sha256"
+	"errors"
+)
+
+// Signature hash types
+const (
+	SigHashDefault      SigHashType = 0x00
+	SigHashAll          SigHashType = 0x01
+	SigHashNone         SigHashType = 0x02
+	SigHashSingle       SigHashType = 0x03
+	SigHashAnyoneCanPay SigHashType = 0x80
+)
+
+/* sigHashOne is returned by the legacy algorithm when SIGHASH_SINGLE has no matching output */
+var sigHashOne = append([]byte{0x01}, make([]byte, 31)...)
+
+/* serializeScriptCode serializes a legacy script code without its OP_CODESEPARATORs */
+func serializeScriptCode(script []byte) []byte {
+	var code []byte
+	separators := 0
+	begin := 0
+	pc := 0
+	for {
+		opcode, _, next, ok := getScriptOp(script, pc)
+		pc = next
+		if !ok {
+			break
+		}
+		if opcode == OpCodeSeparator {
+			code = append(code, script[begin:pc-1]...)
+			begin = pc
+			separators++
+		}
+	}
+	/* Like Bitcoin Core, bytes after an invalid push are dropped but still counted in the length */
+	if begin != len(script) {
+		code = append(code, script[begin:pc]...)
+	}
+
+	return append(compactSize(uint64(len(script)-separators)), code...)
+}
+
+// LegacySigHash computes the signature hash of input index spending subScript, for pre-segwit scripts
+func (tx *Transaction) LegacySigHash(index int, subScript []byte, hashType SigHashType) ([]byte, error) {
+	if index < 0 || index >= len(tx.Inputs) {
+		return nil, errors.New("input index out of range")
+	}
+
+	base := hashType & 0x1f
+	anyoneCanPay := hashType&SigHashAnyoneCanPay != 0
+
+	/* SIGHASH_SINGLE without a matching output signs the number one */
+	if base == SigHashSingle && index >= len(tx.Outputs) {
+		return append([]byte{}, sigHashOne...), nil
+	}
+
+	b := appendUint32(nil, uint32(tx.Version))
+
+	inputs := tx.Inputs
+	if anyoneCanPay {
+		inputs = tx.Inputs[index : index+1]
+	}
+	b = append(b, compactSize(uint64(len(inputs)))...)
+	for i, in := range inputs {
+		current := anyoneCanPay || i == index
+
+		b = append(b, paddedOutPointHash(in.PreviousOutPoint)...)
+		b = appendUint32(b, in.PreviousOutPoint.Index)
+		if current {
+			b = append(b, serializeScriptCode(subScript)...)
+		} else {
+			b = append(b, 0x00)
+		}
+		if !current && (base == SigHashSingle || base == SigHashNone) {
+			b = appendUint32(b, 0)
+		} else {
+			b = appendUint32(b, in.Sequence)
+		}
+	}
+
+	var outputs []*TxOut
+	switch base {
+	case SigHashNone:
+	case SigHashSingle:
+		outputs = tx.Outputs[0 : index+1]
+	default:
+		outputs = tx.Outputs
+	}
+	b = append(b, compactSize(uint64(len(outputs)))...)
+	for i, out := range outputs {
+		/* Outputs before the signed one are replaced by empty outputs of value -1 */
+		if base == SigHashSingle && i != index {
+			b = appendUint64(b, 0xffffffffffffffff)
+			b = append(b, 0x00)
+			continue
+		}
+		b = appendUint64(b, uint64(out.Value))
+		b = appendVarBytes(b, out.ScriptPubKey)
+	}
+
+	b = appendUint32(b, tx.LockTime)
+	b = appendUint32(b, uint32(hashType))
+
+	return doubleHash(b), nil
+}
+
+// WitnessV0SigHash computes the BIP143 signature hash of input index spending amount satoshis with scriptCode
+func (tx *Transaction) WitnessV0SigHash(index int, scriptCode []byte, amount int64, hashType SigHashType) ([]byte, error) {
+	if index < 0 || index >= len(tx.Inputs) {
+		return nil, errors.New("input index out of range")
+	}
+
+	base := hashType & 0x1f
+	anyoneCanPay := hashType&SigHashAnyoneCanPay != 0
+
+	hashPrevouts := make([]byte, 32)
+	hashSequence := make([]byte, 32)
+	hashOutputs := make([]byte, 32)
+
+	if !anyoneCanPay {
+		var prevouts []byte
+		for _, in := range tx.Inputs {
+			prevouts = append(prevouts, paddedOutPointHash(in.PreviousOutPoint)...)
+			prevouts = appendUint32(prevouts, in.PreviousOutPoint.Index)
+		}
+		hashPrevouts = doubleHash(prevouts)
+	}
+
+	if !anyoneCanPay && base != SigHashSingle && base != SigHashNone {
+		var sequences []byte
+		for _, in := range tx.Inputs {
+			sequences = appendUint32(sequences, in.Sequence)
+		}
+		hashSequence = doubleHash(sequences)
+	}
+
+	if base != SigHashSingle && base != SigHashNone {
+		var outputs []byte
+		for _, out := range tx.Outputs {
+			outputs = appendUint64(outputs, uint64(out.Value))
+			outputs = appendVarBytes(outputs, out.ScriptPubKey)
+		}
+		hashOutputs = doubleHash(outputs)
+	} else if base == SigHashSingle && index < len(tx.Outputs) {
+		output := appendUint64(nil, uint64(tx.Outputs[index].Value))
+		output = appendVarBytes(output, tx.Outputs[index].ScriptPubKey)
+		hashOutputs = doubleHash(output)
+	}
+
+	in := tx.Inputs[index]
+
+	b := appendUint32(nil, uint32(tx.Version))
+	b = append(b, hashPrevouts...)
+	b = append(b, hashSequence...)
+	b = append(b, paddedOutPointHash(in.PreviousOutPoint)...)
+	b = appendUint32(b, in.PreviousOutPoint.Index)
+	b = appendVarBytes(b, scriptCode)
+	b = appendUint64(b, uint64(amount))
+	b = appendUint32(b, in.Sequence)
+	b = append(b, hashOutputs...)
+	b = appendUint32(b, tx.LockTime)
+	b = appendUint32(b, uint32(hashType))
+
+	return doubleHash(b), nil
+}
+
+// TaprootSigHash computes the BIP341 signature hash of input index, prevOuts are the outputs spent by all inputs and leafHash is nil for key path spends
+func (tx *Transaction) TaprootSigHash(index int, prevOuts []*TxOut, hashType SigHashType, leafHash []byte) ([]byte, error) {
+	return tx.taprootSigHash(index, prevOuts, hashType, leafHash, 0xffffffff)
+}
+
+func (tx *Transaction) taprootSigHash(index int, prevOuts []*TxOut, hashType SigHashType, leafHash []byte, codeSepPos uint32) ([]byte, error) {
+	msg, err := tx.taprootSigMsg(index, prevOuts, hashType, leafHash, codeSepPos)
+	if err != nil {
+		return nil, err
+	}
+	return taggedHash("TapSighash", msg), nil
+}
+
+/* taprootSigMsg returns the epoch byte followed by SigMsg(hash_type, ext_flag) || ext */
+func (tx *Transaction) taprootSigMsg(index int, prevOuts []*TxOut, hashType SigHashType, leafHash []byte, codeSepPos uint32) ([]byte, error) {
+	if index < 0 || index >= len(tx.Inputs) {
+		return nil, errors.New("input index out of range")
+	}
+	if len(prevOuts) != len(tx.Inputs) {
+		return nil, errors.New("one spent output is required per input")
+	}
+	if hashType > 0x03 && (hashType < 0x81 || hashType > 0x83) {
+		return nil, errors.New("invalid taproot hash type")
+	}
+	if leafHash != nil && len(leafHash) != 32 {
+		return nil, errors.New("leaf hash must be 32 bytes long")
+	}
+
+	output := hashType & 0x03
+	if hashType == SigHashDefault {
+		output = SigHashAll
+	}
+	anyoneCanPay := hashType&SigHashAnyoneCanPay != 0
+
+	/* Epoch */
+	msg := []byte{0x00}
+
+	msg = append(msg, byte(hashType))
+	msg = appendUint32(msg, uint32(tx.Version))
+	msg = appendUint32(msg, tx.LockTime)
+
+	if !anyoneCanPay {
+		var prevouts, amounts, scriptPubKeys, sequences []byte
+		for i, in := range tx.Inputs {
+			prevouts = append(prevouts, paddedOutPointHash(in.PreviousOutPoint)...)
+			prevouts = appendUint32(prevouts, in.PreviousOutPoint.Index)
+			amounts = appendUint64(amounts, uint64(prevOuts[i].Value))
+			scriptPubKeys = appendVarBytes(scriptPubKeys, prevOuts[i].ScriptPubKey)
+			sequences = appendUint32(sequences, in.Sequence)
+		}
+		msg = append(msg, singleHash(prevouts)...)
+		msg = append(msg, singleHash(amounts)...)
+		msg = append(msg, singleHash(scriptPubKeys)...)
+		msg = append(msg, singleHash(sequences)...)
+	}
+
+	if output == SigHashAll {
+		var outputs []byte
+		for _, out := range tx.Outputs {
+			outputs = appendUint64(outputs, uint64(out.Value))
+			outputs = appendVarBytes(outputs, out.ScriptPubKey)
+		}
+		msg = append(msg, singleHash(outputs)...)
+	}
+
+	in := tx.Inputs[index]
+	annex := taprootAnnex(in.Witness)
+
+	/* spend_type = ext_flag * 2 + annex_present */
+	var spendType byte
+	if leafHash != nil {
+		spendType = 2
+	}
+	if annex != nil {
+		spendType++
+	}
+	msg = append(msg, spendType)
+
+	if anyoneCanPay {
+		msg = append(msg, paddedOutPointHash(in.PreviousOutPoint)...)
+		msg = appendUint32(msg, in.PreviousOutPoint.Index)
+		msg = appendUint64(msg, uint64(prevOuts[index].Value))
+		msg = appendVarBytes(msg, prevOuts[index].ScriptPubKey)
+		msg = appendUint32(msg, in.Sequence)
+	} else {
+		msg = appendUint32(msg, uint32(index))
+	}
+
+	if annex != nil {
+		msg = append(msg, singleHash(appendVarBytes(nil, annex))...)
+	}
+
+	if output == SigHashSingle {
+		if index >= len(tx.Outputs) {
+			return nil, errors.New("no output matches the input for SIGHASH_SINGLE")
+		}
+		out := appendUint64(nil, uint64(tx.Outputs[index].Value))
+		out = appendVarBytes(out, tx.Outputs[index].ScriptPubKey)
+		msg = append(msg, singleHash(out)...)
+	}
+
+	if leafHash != nil {
+		/* tapleaf_hash || key_version || codesep_pos */
+		msg = append(msg, leafHash...)
+		msg = append(msg, 0x00)
+		msg = appendUint32(msg, codeSepPos)
+	}
+
+	return msg, nil
+}
+
+/* taprootAnnex returns the annex of a witness, the last element if there are at least two and it starts with 0x50 */
+func taprootAnnex(witness [][]byte) []byte {
+	if len(witness) >= 2 {
+		last := witness[len(witness)-1]
+		if len(last) > 0 && last[0] == 0x50 {
+			return last
+		}
+	}
+	return nil
+}
+
+/* singleHash computes sha256(b) */
+func singleHash(b []byte) []byte {
+	hash := sha256.Sum256(b)
+	return hash[:]
+}
+
+// SignLegacyInput signs input index of tx spending subScript, it returns the DER signature followed by the hash type
+func (p *PrivateKey) SignLegacyInput(tx *Transaction, index int, subScript []byte, hashType SigHashType) ([]byte, error) {
+	hash, err := tx.LegacySigHash(index, subScript, hashType)
+	if err != nil {
+		return nil, err
+	}
+	return p.signHashWithType(hash, hashType)
+}
+
+// SignWitnessV0Input signs segwit v0 input index of tx spending amount satoshis with scriptCode, it returns the DER signature followed by the hash type
+func (p *PrivateKey) SignWitnessV0Input(tx *Transaction, index int, scriptCode []byte, amount int64, hashType SigHashType) ([]byte, error) {
+	hash, err := tx.WitnessV0SigHash(index, scriptCode, amount, hashType)
+	if err != nil {
+		return nil, err
+	}
+	return p.signHashWithType(hash, hashType)
+}
+
+func (p *PrivateKey) signHashWithType(hash []byte, hashType SigHashType) ([]byte, error) {
+	signature, err := p.Sign(hash)
+	if err != nil {
+		return nil, err
+	}
+	return append(signature.DER(), byte(hashType)), nil
+}
+
+// SignTaprootInput signs taproot input index of tx with a BIP340 signature, the key must already be tweaked for key path spends
+func (p *PrivateKey) SignTaprootInput(tx *Transaction, index int, prevOuts []*TxOut, hashType SigHashType, leafHash []byte) ([]byte, error) {
+	hash, err := tx.TaprootSigHash(index, prevOuts, hashType, leafHash)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := p.SignSchnorr(hash, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	/* SIGHASH_DEFAULT signatures are 64 bytes long */
+	if hashType != SigHashDefault {
+		signature = append(signature, byte(hashType))
+	}
+	return signature, nil
+}
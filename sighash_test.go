@@ -0,0 +1,223 @@
+package btc
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLegacySigHash(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/sighash.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/* raw_transaction, script, input_index, hashType, signature_hash (result) */
+	var vectors [][]interface{}
+	err = json.Unmarshal(data, &vectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, vector := range vectors[1:] {
+		tx, err := TransactionFromHex(vector[0].(string))
+		assert.Nil(t, err)
+		script, _ := hex.DecodeString(vector[1].(string))
+		index := int(vector[2].(float64))
+		hashType := SigHashType(uint32(int32(vector[3].(float64))))
+
+		hash, err := tx.LegacySigHash(index, script, hashType)
+		assert.Nil(t, err)
+		assert.Equal(t, vector[4].(string), hex.EncodeToString(reverseBytes(hash)))
+	}
+}
+
+func TestWitnessV0SigHash(t *testing.T) {
+	/* BIP143 examples */
+	var vectors = []struct {
+		Tx         string
+		Index      int
+		ScriptCode string
+		Amount     int64
+		HashType   SigHashType
+		SigHash    string
+	}{
+		/* Native P2WPKH */
+		{"0100000002fff7f7881a8099afa6940d42d1e7f6362bec38171ea3edf433541db4e4ad969f0000000000eeffffffef51e1b804cc89d182d279655c3aa89e815b1b309fe287d9b2b55d57b90ec68a0100000000ffffffff02202cb206000000001976a9148280b37df378db99f66f85c95a783a76ac7a6d5988ac9093510d000000001976a9143bde42dbee7e4dbe6a21b2d50ce2f0167faa815988ac11000000", 1, "76a9141d0f172a0ecb48aee1be1f2687d2963ae33f71a188ac", 600000000, SigHashAll, "c37af31116d1b27caf68aae9e3ac82f1477929014d5b917657d0eb49478cb670"},
+		/* P2SH-P2WPKH */
+		{"0100000001db6b1b20aa0fd7b23880be2ecbd4a98130974cf4748fb66092ac4d3ceb1a54770100000000feffffff02b8b4eb0b000000001976a914a457b684d7f0d539a46a45bbc043f35b59d0d96388ac0008af2f000000001976a914fd270b1ee6abcaea97fea7ad0402e8bd8ad6d77c88ac92040000", 0, "76a91479091972186c449eb1ded22b78e40d009bdf008988ac", 1000000000, SigHashAll, "64f3b0f4dd2bb3aa1ce8566d220cc74dda9df97d8490cc81d89d735c92e59fb6"},
+		/* Native P2WSH with OP_CODESEPARATOR and out of range SIGHASH_SINGLE */
+		{"0100000002fe3dc9208094f3ffd12645477b3dc56f60ec4fa8e6f5d67c565d1c6b9216b36e0000000000ffffffff0815cf020f013ed6cf91d29f4202e8a58726b1ac6c79da47c23d1bee0a6925f80000000000ffffffff0100f2052a010000001976a914a30741f8145e5acadf23f751864167f32e0963f788ac00000000", 1, "21026dccc749adc2a9d0d89497ac511f760f45c47dc5ed9cf352a58ac706453880aeadab210255a9626aebf5e29c0e6538428ba0d1dcf6ca98ffdf086aa8ced5e0d0215ea465ac", 4900000000, SigHashSingle, "82dde6e4f1e94d02c2b7ad03d2115d691f48d064e9d52f58194a6637e4194391"},
+		{"0100000002fe3dc9208094f3ffd12645477b3dc56f60ec4fa8e6f5d67c565d1c6b9216b36e0000000000ffffffff0815cf020f013ed6cf91d29f4202e8a58726b1ac6c79da47c23d1bee0a6925f80000000000ffffffff0100f2052a010000001976a914a30741f8145e5acadf23f751864167f32e0963f788ac00000000", 1, "210255a9626aebf5e29c0e6538428ba0d1dcf6ca98ffdf086aa8ced5e0d0215ea465ac", 4900000000, SigHashSingle, "fef7bd749cce710c5c052bd796df1af0d935e59cea63736268bcbe2d2134fc47"},
+		/* Native P2WSH with SINGLE|ANYONECANPAY */
+		{"0100000002e9b542c5176808107ff1df906f46bb1f2583b16112b95ee5380665ba7fcfc0010000000000ffffffff80e68831516392fcd100d186b3c2c7b95c80b53c77e77c35ba03a66b429a2a1b0000000000ffffffff0280969800000000001976a914de4b231626ef508c9a74a8517e6783c0546d6b2888ac80969800000000001976a9146648a8cd4531e1ec47f35916de8e259237294d1e88ac00000000", 0, "0063ab68210392972e2eb617b2388771abe27235fd5ac44af8e61693261550447a4c3e39da98ac", 16777215, SigHashSingle | SigHashAnyoneCanPay, "e9071e75e25b8a1e298a72f0d2e9f4f95a0f5cdf86a533cda597eb402ed13b3a"},
+		{"0100000002e9b542c5176808107ff1df906f46bb1f2583b16112b95ee5380665ba7fcfc0010000000000ffffffff80e68831516392fcd100d186b3c2c7b95c80b53c77e77c35ba03a66b429a2a1b0000000000ffffffff0280969800000000001976a914de4b231626ef508c9a74a8517e6783c0546d6b2888ac80969800000000001976a9146648a8cd4531e1ec47f35916de8e259237294d1e88ac00000000", 1, "68210392972e2eb617b2388771abe27235fd5ac44af8e61693261550447a4c3e39da98ac", 16777215, SigHashSingle | SigHashAnyoneCanPay, "cd72f1f1a433ee9df816857fad88d8ebd97e09a75cd481583eb841c330275e54"},
+		/* P2SH-P2WSH 6-of-6 multisig */
+		{"010000000136641869ca081e70f394c6948e8af409e18b619df2ed74aa106c1ca29787b96e0100000000ffffffff0200e9a435000000001976a914389ffce9cd9ae88dcc0631e88a821ffdbe9bfe2688acc0832f05000000001976a9147480a33f950689af511e6e84c138dbbd3c3ee41588ac00000000", 0, "56210307b8ae49ac90a048e9b53357a2354b3334e9c8bee813ecb98e99a7e07e8c3ba32103b28f0c28bfab54554ae8c658ac5c3e0ce6e79ad336331f78c428dd43eea8449b21034b8113d703413d57761b8b9781957b8c0ac1dfe69f492580ca4195f50376ba4a21033400f6afecb833092a9a21cfdf1ed1376e58c5d1f47de74683123987e967a8f42103a6d48b1131e94ba04d9737d61acdaa1322008af9602b3b14862c07a1789aac162102d8b661b0b3302ee2f162b09e07a55ad5dfbe673a9f01d9f0c19617681024306b56ae", 987654321, SigHashAll, "185c0be5263dce5b4bb50a047973c1b6272bfbd0103a89444597dc40b248ee7c"},
+		{"010000000136641869ca081e70f394c6948e8af409e18b619df2ed74aa106c1ca29787b96e0100000000ffffffff0200e9a435000000001976a914389ffce9cd9ae88dcc0631e88a821ffdbe9bfe2688acc0832f05000000001976a9147480a33f950689af511e6e84c138dbbd3c3ee41588ac00000000", 0, "56210307b8ae49ac90a048e9b53357a2354b3334e9c8bee813ecb98e99a7e07e8c3ba32103b28f0c28bfab54554ae8c658ac5c3e0ce6e79ad336331f78c428dd43eea8449b21034b8113d703413d57761b8b9781957b8c0ac1dfe69f492580ca4195f50376ba4a21033400f6afecb833092a9a21cfdf1ed1376e58c5d1f47de74683123987e967a8f42103a6d48b1131e94ba04d9737d61acdaa1322008af9602b3b14862c07a1789aac162102d8b661b0b3302ee2f162b09e07a55ad5dfbe673a9f01d9f0c19617681024306b56ae", 987654321, SigHashNone, "e9733bc60ea13c95c6527066bb975a2ff29a925e80aa14c213f686cbae5d2f36"},
+		{"010000000136641869ca081e70f394c6948e8af409e18b619df2ed74aa106c1ca29787b96e0100000000ffffffff0200e9a435000000001976a914389ffce9cd9ae88dcc0631e88a821ffdbe9bfe2688acc0832f05000000001976a9147480a33f950689af511e6e84c138dbbd3c3ee41588ac00000000", 0, "56210307b8ae49ac90a048e9b53357a2354b3334e9c8bee813ecb98e99a7e07e8c3ba32103b28f0c28bfab54554ae8c658ac5c3e0ce6e79ad336331f78c428dd43eea8449b21034b8113d703413d57761b8b9781957b8c0ac1dfe69f492580ca4195f50376ba4a21033400f6afecb833092a9a21cfdf1ed1376e58c5d1f47de74683123987e967a8f42103a6d48b1131e94ba04d9737d61acdaa1322008af9602b3b14862c07a1789aac162102d8b661b0b3302ee2f162b09e07a55ad5dfbe673a9f01d9f0c19617681024306b56ae", 987654321, SigHashSingle, "1e1f1c303dc025bd664acb72e583e933fae4cff9148bf78c157d1e8f78530aea"},
+		{"010000000136641869ca081e70f394c6948e8af409e18b619df2ed74aa106c1ca29787b96e0100000000ffffffff0200e9a435000000001976a914389ffce9cd9ae88dcc0631e88a821ffdbe9bfe2688acc0832f05000000001976a9147480a33f950689af511e6e84c138dbbd3c3ee41588ac00000000", 0, "56210307b8ae49ac90a048e9b53357a2354b3334e9c8bee813ecb98e99a7e07e8c3ba32103b28f0c28bfab54554ae8c658ac5c3e0ce6e79ad336331f78c428dd43eea8449b21034b8113d703413d57761b8b9781957b8c0ac1dfe69f492580ca4195f50376ba4a21033400f6afecb833092a9a21cfdf1ed1376e58c5d1f47de74683123987e967a8f42103a6d48b1131e94ba04d9737d61acdaa1322008af9602b3b14862c07a1789aac162102d8b661b0b3302ee2f162b09e07a55ad5dfbe673a9f01d9f0c19617681024306b56ae", 987654321, SigHashAll | SigHashAnyoneCanPay, "2a67f03e63a6a422125878b40b82da593be8d4efaafe88ee528af6e5a9955c6e"},
+		{"010000000136641869ca081e70f394c6948e8af409e18b619df2ed74aa106c1ca29787b96e0100000000ffffffff0200e9a435000000001976a914389ffce9cd9ae88dcc0631e88a821ffdbe9bfe2688acc0832f05000000001976a9147480a33f950689af511e6e84c138dbbd3c3ee41588ac00000000", 0, "56210307b8ae49ac90a048e9b53357a2354b3334e9c8bee813ecb98e99a7e07e8c3ba32103b28f0c28bfab54554ae8c658ac5c3e0ce6e79ad336331f78c428dd43eea8449b21034b8113d703413d57761b8b9781957b8c0ac1dfe69f492580ca4195f50376ba4a21033400f6afecb833092a9a21cfdf1ed1376e58c5d1f47de74683123987e967a8f42103a6d48b1131e94ba04d9737d61acdaa1322008af9602b3b14862c07a1789aac162102d8b661b0b3302ee2f162b09e07a55ad5dfbe673a9f01d9f0c19617681024306b56ae", 987654321, SigHashNone | SigHashAnyoneCanPay, "781ba15f3779d5542ce8ecb5c18716733a5ee42a6f51488ec96154934e2c890a"},
+		{"010000000136641869ca081e70f394c6948e8af409e18b619df2ed74aa106c1ca29787b96e0100000000ffffffff0200e9a435000000001976a914389ffce9cd9ae88dcc0631e88a821ffdbe9bfe2688acc0832f05000000001976a9147480a33f950689af511e6e84c138dbbd3c3ee41588ac00000000", 0, "56210307b8ae49ac90a048e9b53357a2354b3334e9c8bee813ecb98e99a7e07e8c3ba32103b28f0c28bfab54554ae8c658ac5c3e0ce6e79ad336331f78c428dd43eea8449b21034b8113d703413d57761b8b9781957b8c0ac1dfe69f492580ca4195f50376ba4a21033400f6afecb833092a9a21cfdf1ed1376e58c5d1f47de74683123987e967a8f42103a6d48b1131e94ba04d9737d61acdaa1322008af9602b3b14862c07a1789aac162102d8b661b0b3302ee2f162b09e07a55ad5dfbe673a9f01d9f0c19617681024306b56ae", 987654321, SigHashSingle | SigHashAnyoneCanPay, "511e8e52ed574121fc1b654970395502128263f62662e076dc6baf05c2e6a99b"},
+		/* No FindAndDelete */
+		{"010000000169c12106097dc2e0526493ef67f21269fe888ef05c7a3a5dacab38e1ac8387f14c1d000000ffffffff0101000000000000000000000000", 0, "ad4830450220487fb382c4974de3f7d834c1b617fe15860828c7f96454490edd6d891556dcc9022100baf95feb48f845d5bfc9882eb6aeefa1bc3790e39f59eaa46ff7f15ae626c53e01", 200000, SigHashAll, "71c9cd9b2869b9c70b01b1f0360c148f42dee72297db312638df136f43311f23"},
+	}
+
+	for _, vector := range vectors {
+		tx, err := TransactionFromHex(vector.Tx)
+		assert.Nil(t, err)
+		scriptCode, _ := hex.DecodeString(vector.ScriptCode)
+
+		hash, err := tx.WitnessV0SigHash(vector.Index, scriptCode, vector.Amount, vector.HashType)
+		assert.Nil(t, err)
+		assert.Equal(t, vector.SigHash, hex.EncodeToString(hash))
+	}
+}
+
+func TestSignWitnessV0Input(t *testing.T) {
+	/* BIP143 signatures are deterministic (RFC6979) */
+	var vectors = []struct {
+		Tx         string
+		Index      int
+		ScriptCode string
+		Amount     int64
+		HashType   SigHashType
+		Key        string
+		Signature  string
+	}{
+		{"0100000002fff7f7881a8099afa6940d42d1e7f6362bec38171ea3edf433541db4e4ad969f0000000000eeffffffef51e1b804cc89d182d279655c3aa89e815b1b309fe287d9b2b55d57b90ec68a0100000000ffffffff02202cb206000000001976a9148280b37df378db99f66f85c95a783a76ac7a6d5988ac9093510d000000001976a9143bde42dbee7e4dbe6a21b2d50ce2f0167faa815988ac11000000", 1, "76a9141d0f172a0ecb48aee1be1f2687d2963ae33f71a188ac", 600000000, SigHashAll, "619c335025c7f4012e556c2a58b2506e30b8511b53ade95ea316fd8c3286feb9", "304402203609e17b84f6a7d30c80bfa610b5b4542f32a8a0d5447a12fb1366d7f01cc44a0220573a954c4518331561406f90300e8f3358f51928d43c212a8caed02de67eebee01"},
+		{"0100000002e9b542c5176808107ff1df906f46bb1f2583b16112b95ee5380665ba7fcfc0010000000000ffffffff80e68831516392fcd100d186b3c2c7b95c80b53c77e77c35ba03a66b429a2a1b0000000000ffffffff0280969800000000001976a914de4b231626ef508c9a74a8517e6783c0546d6b2888ac80969800000000001976a9146648a8cd4531e1ec47f35916de8e259237294d1e88ac00000000", 0, "0063ab68210392972e2eb617b2388771abe27235fd5ac44af8e61693261550447a4c3e39da98ac", 16777215, SigHashSingle | SigHashAnyoneCanPay, "f52b3484edd96598e02a9c89c4492e9c1e2031f471c49fd721fe68b3ce37780d", "3045022100f6a10b8604e6dc910194b79ccfc93e1bc0ec7c03453caaa8987f7d6c3413566002206216229ede9b4d6ec2d325be245c5b508ff0339bf1794078e20bfe0babc7ffe683"},
+	}
+
+	for _, vector := range vectors {
+		tx, err := TransactionFromHex(vector.Tx)
+		assert.Nil(t, err)
+		scriptCode, _ := hex.DecodeString(vector.ScriptCode)
+		privateKey, err := PrivateFromHex(vector.Key, MainNetwork)
+		assert.Nil(t, err)
+
+		signature, err := privateKey.SignWitnessV0Input(tx, vector.Index, scriptCode, vector.Amount, vector.HashType)
+		assert.Nil(t, err)
+		assert.Equal(t, vector.Signature, hex.EncodeToString(signature))
+	}
+}
+
+func TestTaprootSigHash(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/bip341_vectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var vectors struct {
+		KeyPathSpending []struct {
+			Given struct {
+				RawUnsignedTx string
+				UtxosSpent    []struct {
+					ScriptPubKey string
+					AmountSats   int64
+				}
+			}
+			InputSpending []struct {
+				Given struct {
+					TxinIndex       int
+					InternalPrivkey string
+					MerkleRoot      *string
+					HashType        SigHashType
+				}
+				Intermediary struct {
+					SigMsg  string
+					SigHash string
+				}
+				Expected struct {
+					Witness []string
+				}
+			}
+		}
+	}
+	err = json.Unmarshal(data, &vectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, spending := range vectors.KeyPathSpending {
+		tx, err := TransactionFromHex(spending.Given.RawUnsignedTx)
+		assert.Nil(t, err)
+
+		var prevOuts []*TxOut
+		for _, utxo := range spending.Given.UtxosSpent {
+			script, _ := hex.DecodeString(utxo.ScriptPubKey)
+			prevOuts = append(prevOuts, &TxOut{Value: utxo.AmountSats, ScriptPubKey: script})
+		}
+
+		for _, input := range spending.InputSpending {
+			index := input.Given.TxinIndex
+			hashType := input.Given.HashType
+
+			msg, err := tx.taprootSigMsg(index, prevOuts, hashType, nil, 0xffffffff)
+			assert.Nil(t, err)
+			assert.Equal(t, input.Intermediary.SigMsg, hex.EncodeToString(msg))
+
+			hash, err := tx.TaprootSigHash(index, prevOuts, hashType, nil)
+			assert.Nil(t, err)
+			assert.Equal(t, input.Intermediary.SigHash, hex.EncodeToString(hash))
+
+			/* Sign with the tweaked key and check the signature against the output key */
+			privateKey, err := PrivateFromHex(input.Given.InternalPrivkey, MainNetwork)
+			assert.Nil(t, err)
+			var merkleRoot []byte
+			if input.Given.MerkleRoot != nil {
+				merkleRoot, _ = hex.DecodeString(*input.Given.MerkleRoot)
+			}
+			tweaked, err := privateKey.TaprootTweak(merkleRoot)
+			assert.Nil(t, err)
+
+			signature, err := tweaked.SignTaprootInput(tx, index, prevOuts, hashType, nil)
+			assert.Nil(t, err)
+			expected, _ := hex.DecodeString(input.Expected.Witness[0])
+			assert.Equal(t, len(expected), len(signature))
+			assert.Equal(t, expected[64:], signature[64:])
+
+			/* The vectors are signed with all-zero auxiliary randomness */
+			deterministic, err := tweaked.SignSchnorr(hash, make([]byte, 32))
+			assert.Nil(t, err)
+			assert.Equal(t, expected[0:64], deterministic)
+
+			outputKey, err := PublicFromXOnly(hex.EncodeToString(prevOuts[index].ScriptPubKey[2:]), MainNetwork)
+			assert.Nil(t, err)
+			assert.True(t, outputKey.VerifySchnorr(hash, signature[0:64]))
+			assert.True(t, outputKey.VerifySchnorr(hash, expected[0:64]))
+		}
+	}
+}
+
+func TestTaprootSigHashInvalid(t *testing.T) {
+	tx := &Transaction{
+		Version: 2,
+		Inputs:  []*TxIn{{}, {}},
+		Outputs: []*TxOut{{Value: 1000}},
+	}
+	prevOuts := []*TxOut{{Value: 2000}, {Value: 3000}}
+
+	_, err := tx.TaprootSigHash(0, prevOuts, SigHashAll, nil)
+	assert.Nil(t, err)
+	_, err = tx.TaprootSigHash(0, prevOuts, 0x04, nil)
+	assert.NotNil(t, err)
+	_, err = tx.TaprootSigHash(0, prevOuts, SigHashAnyoneCanPay, nil)
+	assert.NotNil(t, err)
+	_, err = tx.TaprootSigHash(0, prevOuts[0:1], SigHashAll, nil)
+	assert.NotNil(t, err)
+	_, err = tx.TaprootSigHash(2, prevOuts, SigHashAll, nil)
+	assert.NotNil(t, err)
+
+	/* SIGHASH_SINGLE requires a matching output */
+	_, err = tx.TaprootSigHash(1, prevOuts, SigHashSingle, nil)
+	assert.NotNil(t, err)
+
+	/* The legacy algorithm signs the number one instead */
+	hash, err := tx.LegacySigHash(1, nil, SigHashSingle)
+	assert.Nil(t, err)
+	assert.Equal(t, "0000000000000000000000000000000000000000000000000000000000000001", hex.EncodeToString(reverseBytes(hash)))
+}
@@ -0,0 +1,349 @@
+package btc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+)
+
+// Sign computes the deterministic (RFC6979) ECDSA signature of a 32 bytes hash
+func (p *PrivateKey) Sign(hash []byte) (*Signature, error) {
+	signature, _, err := p.signRecoverable(hash)
+	return signature, err
+}
+
+/* signRecoverable computes the signature of hash and the recovery id of its public key */
+func (p *PrivateKey) signRecoverable(hash []byte) (*Signature, byte, error) {
+	if len(hash) != 32 {
+		return nil, 0, errors.New("hash must be 32 bytes long")
+	}
+	/* The key and the nonces stay scalars, only the public R and s are converted back */
+	d, valid := scalarFromSecret(p.Key)
+	if valid == 0 {
+		return nil, 0, errors.New("invalid private key")
+	}
+
+	e := scalarFromBig(hashToInt(hash))
+	nonces := newRFC6979(d, hash)
+
+	for {
+		k := nonces.next()
+
+		/* r = (k*G).x mod n */
+		R := baseMult(k).point()
+		r := new(big.Int).Mod(R.X, secp256k1.N)
+		if r.Sign() == 0 {
+			continue
+		}
+
+		/* s = k^-1 * (e + r*d) mod n */
+		s := k.inverse().mul(e.add(scalarFromBig(r).mul(d)))
+		if s.isZero() == 1 {
+			continue
+		}
+
+		/* The recovery id is the parity of R.y, plus 2 if R.x overflowed the curve order */
+		recoveryID := byte(R.Y.Bit(0))
+		if R.X.Cmp(secp256k1.N) >= 0 {
+			recoveryID |= 2
+		}
+
+		signature := &Signature{R: r, S: s.big()}
+		/* Negating S negates R */
+		if !signature.IsLowS() {
+			recoveryID ^= 1
+		}
+		signature.normalize()
+		return signature, recoveryID, nil
+	}
+}
+
+// Verify checks that signature is a valid ECDSA signature of hash by the public key
+func (p *PublicKey) Verify(hash []byte, signature *Signature) bool {
+	if signature == nil || signature.R == nil || signature.S == nil {
+		return false
+	}
+	if signature.R.Sign() <= 0 || signature.R.Cmp(secp256k1.N) >= 0 {
+		return false
+	}
+	if signature.S.Sign() <= 0 || signature.S.Cmp(secp256k1.N) >= 0 {
+		return false
+	}
+	if !secp256k1.IsOnCurve(p.point()) {
+		return false
+	}
+
+	w := scalarFromBig(signature.S).inverse()
+
+	/* u1 = e*w mod n, u2 = r*w mod n */
+	u1 := scalarFromBig(hashToInt(hash)).mul(w)
+	u2 := scalarFromBig(signature.R).mul(w)
+
+	/* R = u1*G + u2*Q */
+	R := doubleScalarMult(u1, u2, p.point())
+	if R.IsInfinity() {
+		return false
+	}
+
+	return new(big.Int).Mod(R.X, secp256k1.N).Cmp(signature.R) == 0
+}
+
+// IsLowS returns true if S is lower or equal to half the curve order
+func (s *Signature) IsLowS() bool {
+	halfOrder := new(big.Int).Rsh(secp256k1.N, 1)
+	return s.S.Cmp(halfOrder) <= 0
+}
+
+/* normalize replaces S by n - S when S is greater than half the curve order */
+func (s *Signature) normalize() {
+	if !s.IsLowS() {
+		s.S = new(big.Int).Sub(secp256k1.N, s.S)
+	}
+}
+
+// DER returns the strict DER encoding of the signature
+func (s *Signature) DER() []byte {
+	r := derInteger(s.R)
+	sb := derInteger(s.S)
+
+	der := []byte{0x30, byte(4 + len(r) + len(sb))}
+	der = append(der, 0x02, byte(len(r)))
+	der = append(der, r...)
+	der = append(der, 0x02, byte(len(sb)))
+	der = append(der, sb...)
+	return der
+}
+
+/* derInteger returns the minimal big-endian encoding of a positive integer */
+func derInteger(n *big.Int) []byte {
+	b := n.Bytes()
+	if len(b) == 0 {
+		return []byte{0x00}
+	}
+	/* Prepend 0x00 if the first bit is set, otherwise the integer would be negative */
+	if b[0]&0x80 != 0 {
+		b = append([]byte{0x00}, b...)
+	}
+	return b
+}
+
+// SignatureFromDER parses a strict DER (BIP66) encoded signature
+func SignatureFromDER(der []byte) (*Signature, error) {
+	err := checkDER(der)
+	if err != nil {
+		return nil, err
+	}
+
+	lenR := int(der[3])
+	lenS := int(der[5+lenR])
+
+	signature := &Signature{
+		R: new(big.Int).SetBytes(der[4 : 4+lenR]),
+		S: new(big.Int).SetBytes(der[6+lenR : 6+lenR+lenS]),
+	}
+
+	if signature.R.Sign() == 0 || signature.R.Cmp(secp256k1.N) >= 0 {
+		return nil, errors.New("signature R is out of range")
+	}
+	if signature.S.Sign() == 0 || signature.S.Cmp(secp256k1.N) >= 0 {
+		return nil, errors.New("signature S is out of range")
+	}
+
+	return signature, nil
+}
+
+/* checkDER applies the BIP66 encoding rules to a DER signature without sighash byte */
+func checkDER(der []byte) error {
+	/* Format: 0x30 [total-length] 0x02 [R-length] [R] 0x02 [S-length] [S] */
+	if len(der) < 8 || len(der) > 72 {
+		return errors.New("invalid DER signature length")
+	}
+	if der[0] != 0x30 {
+		return errors.New("invalid DER signature compound marker")
+	}
+	if int(der[1]) != len(der)-2 {
+		return errors.New("invalid DER signature total length")
+	}
+
+	lenR := int(der[3])
+	if 5+lenR >= len(der) {
+		return errors.New("invalid DER signature R length")
+	}
+	lenS := int(der[5+lenR])
+	if lenR+lenS+6 != len(der) {
+		return errors.New("invalid DER signature S length")
+	}
+
+	if der[2] != 0x02 {
+		return errors.New("invalid DER signature R marker")
+	}
+	if lenR == 0 {
+		return errors.New("DER signature R is empty")
+	}
+	if der[4]&0x80 != 0 {
+		return errors.New("DER signature R is negative")
+	}
+	if lenR > 1 && der[4] == 0x00 && der[5]&0x80 == 0 {
+		return errors.New("DER signature R is not minimally encoded")
+	}
+
+	if der[lenR+4] != 0x02 {
+		return errors.New("invalid DER signature S marker")
+	}
+	if lenS == 0 {
+		return errors.New("DER signature S is empty")
+	}
+	if der[lenR+6]&0x80 != 0 {
+		return errors.New("DER signature S is negative")
+	}
+	if lenS > 1 && der[lenR+6] == 0x00 && der[lenR+7]&0x80 == 0 {
+		return errors.New("DER signature S is not minimally encoded")
+	}
+
+	return nil
+}
+
+/* hashToInt converts a hash to an integer modulo n */
+func hashToInt(hash []byte) *big.Int {
+	e := new(big.Int).SetBytes(hash)
+	if len(hash) > 32 {
+		e.Rsh(e, uint(len(hash)-32)*8)
+	}
+	return e.Mod(e, secp256k1.N)
+}
+
+/* rfc6979 generates deterministic nonces as described in RFC6979 section 3.2 */
+type rfc6979 struct {
+	k []byte
+	v []byte
+}
+
+func newRFC6979(key scalar, hash []byte, extra ...[]byte) *rfc6979 {
+	/* int2octets(x) || bits2octets(h1) || additional data */
+	data := append(key.bytes(), paddedBytes(hashToInt(hash), 32)...)
+	for _, e := range extra {
+		data = append(data, e...)
+	}
+
+	g := &rfc6979{
+		k: make([]byte, 32),
+		v: make([]byte, 32),
+	}
+	for i := range g.v {
+		g.v[i] = 0x01
+	}
+
+	g.k = g.hmac(g.v, []byte{0x00}, data)
+	g.v = g.hmac(g.v)
+	g.k = g.hmac(g.v, []byte{0x01}, data)
+	g.v = g.hmac(g.v)
+
+	return g
+}
+
+func (g *rfc6979) hmac(data ...[]byte) []byte {
+	mac := hmac.New(sha256.New, g.k)
+	for _, d := range data {
+		mac.Write(d)
+	}
+	return mac.Sum(nil)
+}
+
+/* next returns the next candidate nonce in [1, n-1], the candidates are checked in constant time */
+func (g *rfc6979) next() scalar {
+	for {
+		g.v = g.hmac(g.v)
+		k, valid := scalarFromBytesChecked(g.v)
+
+		/* Prepare the next candidate in case this one is rejected */
+		g.k = g.hmac(g.v, []byte{0x00})
+		g.v = g.hmac(g.v)
+		if valid == 1 {
+			return k
+		}
+	}
+}
+
+/* signatureFromDERLax parses a signature that is not necessarily strict DER, like Bitcoin Core's ecdsa_signature_parse_der_lax */
+func signatureFromDERLax(der []byte) (*Signature, bool) {
+	pos := 0
+
+	/* Sequence tag and length, the length is ignored */
+	if pos == len(der) || der[pos] != 0x30 {
+		return nil, false
+	}
+	pos++
+	if pos == len(der) {
+		return nil, false
+	}
+	lenByte := int(der[pos])
+	pos++
+	if lenByte&0x80 != 0 {
+		lenByte -= 0x80
+		if lenByte > len(der)-pos {
+			return nil, false
+		}
+		pos += lenByte
+	}
+
+	var integers [2][]byte
+	for i := range integers {
+		if pos == len(der) || der[pos] != 0x02 {
+			return nil, false
+		}
+		pos++
+
+		if pos == len(der) {
+			return nil, false
+		}
+		length := int(der[pos])
+		pos++
+		if length&0x80 != 0 {
+			lenByte := length - 0x80
+			if lenByte > len(der)-pos {
+				return nil, false
+			}
+			for lenByte > 0 && der[pos] == 0 {
+				pos++
+				lenByte--
+			}
+			if lenByte >= 4 {
+				return nil, false
+			}
+			length = 0
+			for ; lenByte > 0; lenByte-- {
+				length = length<<8 + int(der[pos])
+				pos++
+			}
+		}
+		if length > len(der)-pos {
+			return nil, false
+		}
+
+		integers[i] = der[pos : pos+length]
+		/* Only R is skipped, S may be followed by garbage */
+		if i == 0 {
+			pos += length
+		}
+	}
+
+	signature := &Signature{R: new(big.Int), S: new(big.Int)}
+	for i, integer := range integers {
+		for len(integer) > 0 && integer[0] == 0 {
+			integer = integer[1:]
+		}
+		if len(integer) > 32 {
+			return &Signature{R: new(big.Int), S: new(big.Int)}, true
+		}
+		if i == 0 {
+			signature.R.SetBytes(integer)
+		} else {
+			signature.S.SetBytes(integer)
+		}
+	}
+	if signature.R.Cmp(secp256k1.N) >= 0 || signature.S.Cmp(secp256k1.N) >= 0 {
+		return &Signature{R: new(big.Int), S: new(big.Int)}, true
+	}
+	return signature, true
+}
@@ -0,0 +1,134 @@
+package btc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSign(t *testing.T) {
+	/* RFC6979 test vectors from Trezor and CoreBitcoin */
+	var vectors = []struct {
+		Key       string
+		Message   string
+		Nonce     string
+		Signature string
+	}{
+		{"cca9fbcc1b41e5a95d369eaa6ddcff73b61a4efaa279cfc6567e8daa39cbaf50", "sample", "2df40ca70e639d89528a6b670d9d48d9165fdc0febc0974056bdce192b8e16a3", "3045022100af340daf02cc15c8d5d08d7735dfe6b98a474ed373bdb5fbecf7571be52b384202205009fb27f37034a9b24b707b7c6b79ca23ddef9e25f7282e8a797efe53a8f124"},
+		{"0000000000000000000000000000000000000000000000000000000000000001", "Satoshi Nakamoto", "8f8a276c19f4149656b280621e358cce24f5f52542772691ee69063b74f15d15", "3045022100934b1ea10a4b3c1757e2b0c017d0b6143ce3c9a7e6a4a49860d7a6ab210ee3d802202442ce9d2b916064108014783e923ec36b49743e2ffa1c4496f01a512aafd9e5"},
+		{"fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364140", "Satoshi Nakamoto", "33a19b60e25fb6f4435af53a3d42d493644827367e6453928554f43e49aa6f90", "3045022100fd567d121db66e382991534ada77a6bd3106f0a1098c231e47993447cd6af2d002206b39cd0eb1bc8603e159ef5c20a5c8ad685a45b06ce9bebed3f153d10d93bed5"},
+		{"f8b8af8ce3c7cca5e300d33939540c10d45ce001b8f252bfbc57ba0342904181", "Alan Turing", "525a82b70e67874398067543fd84c83d30c175fdc45fdeee082fe13b1d7cfdf1", "304402207063ae83e7f62bbb171798131b4a0564b956930092b33b07b395615d9ec7e15c022058dfcc1e00a35e1572f366ffe34ba0fc47db1e7189759b9fb233c5b05ab388ea"},
+		{"0000000000000000000000000000000000000000000000000000000000000001", "All those moments will be lost in time, like tears in rain. Time to die...", "38aa22d72376b4dbc472e06c3ba403ee0a394da63fc58d88686c611aba98d6b3", "30450221008600dbd41e348fe5c9465ab92d23e3db8b98b873beecd930736488696438cb6b0220547fe64427496db33bf66019dacbf0039c04199abb0122918601db38a72cfc21"},
+		{"e91671c46231f833a6406ccbea0e3e392c76c167bac1cb013f6f1013980455c2", "There is a computer disease that anybody who works with computers knows about. It's a very serious disease and it interferes completely with the work. The trouble with computers is that you 'play' with them!", "1f4b84c23a86a221d233f2521be018d9318639d5b8bbd6374a8a59232d16ad3d", "3045022100b552edd27580141f3b2a5463048cb7cd3e047b97c9f98076c32dbdf85a68718b0220279fa72dd19bfae05577e06c7c0c1900c371fcd5893f7e1d56a37d30174671f6"},
+	}
+
+	for _, value := range vectors {
+		privateKey, err := PrivateFromHex(value.Key, MainNetwork)
+		assert.Nil(t, err)
+		hash := sha256.Sum256([]byte(value.Message))
+
+		key, inRange := scalarFromSecret(privateKey.Key)
+		assert.Equal(t, uint64(1), inRange)
+		nonce := newRFC6979(key, hash[:]).next()
+		assert.Equal(t, value.Nonce, hex.EncodeToString(nonce.bytes()), value.Message)
+
+		signature, err := privateKey.Sign(hash[:])
+		assert.Nil(t, err)
+		assert.Equal(t, value.Signature, hex.EncodeToString(signature.DER()), value.Message)
+		assert.True(t, signature.IsLowS())
+
+		publicKey, valid := privateKey.GetPublicKey()
+		assert.True(t, valid)
+		assert.True(t, publicKey.Verify(hash[:], signature), value.Message)
+	}
+}
+
+func TestSignBitcoinCore(t *testing.T) {
+	/* Deterministic signatures from Bitcoin Core key_tests.cpp */
+	var vectors = []struct {
+		WIF       string
+		Signature string
+	}{
+		{"5HxWvvfubhXpYYpS3tJkw6fq9jE9j18THftkZjHHfmFiWtmAbrj", "304402205dbbddda71772d95ce91cd2d14b592cfbc1dd0aabd6a394b6c2d377bbe59d31d022014ddda21494a4e221f0824f0b8b924c43fa43c0ad57dccdaa11f81a6bd4582f6"},
+		{"5KC4ejrDjv152FGwP386VD1i2NYc5KkfSMyv1nGy1VGDxGHqVY3", "3044022052d8a32079c11e79db95af63bb9600c5b04f21a9ca33dc129c2bfa8ac9dc1cd5022061d8ae5e0f6c1a16bde3719c64c2fd70e404b6428ab9a69566962e8771b5944d"},
+	}
+
+	hash := doubleHash([]byte("Very deterministic message"))
+	for _, value := range vectors {
+		privateKey, err := PrivateFromWIF(value.WIF, MainNetwork)
+		assert.Nil(t, err)
+
+		signature, err := privateKey.Sign(hash)
+		assert.Nil(t, err)
+		assert.Equal(t, value.Signature, hex.EncodeToString(signature.DER()))
+
+		publicKey, valid := privateKey.GetPublicKey()
+		assert.True(t, valid)
+		assert.True(t, publicKey.Verify(hash, signature))
+
+		/* Wrong hash */
+		assert.False(t, publicKey.Verify(doubleHash(hash), signature))
+
+		/* High S signatures are still valid */
+		highS := &Signature{R: signature.R, S: new(big.Int).Sub(secp256k1.N, signature.S)}
+		assert.False(t, highS.IsLowS())
+		assert.True(t, publicKey.Verify(hash, highS))
+
+		/* Out of range values */
+		assert.False(t, publicKey.Verify(hash, &Signature{R: big.NewInt(0), S: signature.S}))
+		assert.False(t, publicKey.Verify(hash, &Signature{R: signature.R, S: secp256k1.N}))
+	}
+
+	_, err := GeneratePrivateKey(MainNetwork).Sign([]byte{0x01})
+	assert.NotNil(t, err)
+}
+
+func TestSignatureFromDER(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		privateKey := GeneratePrivateKey(MainNetwork)
+		hash := sha256.Sum256([]byte(privateKey.WIF))
+
+		signature, err := privateKey.Sign(hash[:])
+		assert.Nil(t, err)
+
+		parsed, err := SignatureFromDER(signature.DER())
+		assert.Nil(t, err)
+		assert.Equal(t, signature.R, parsed.R)
+		assert.Equal(t, signature.S, parsed.S)
+	}
+
+	var invalid = []struct {
+		DER    string
+		Reason string
+	}{
+		{"300602010102010100", "too long"},
+		{"30050201010201", "too short"},
+		{"3106020101020101", "wrong compound marker"},
+		{"3007020101020101", "wrong total length"},
+		{"3006030101020101", "wrong R marker"},
+		{"3006020101030101", "wrong S marker"},
+		{"30050200020101", "empty R"},
+		{"30050201010200", "empty S"},
+		{"3006020181020101", "negative R"},
+		{"3006020101020181", "negative S"},
+		{"300702020001020101", "R with excessive padding"},
+		{"300702010102020001", "S with excessive padding"},
+		{"3006020100020101", "R is zero"},
+		{"30260221fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141020101", "R is negative"},
+		{"3027022100fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141020101", "R equals n"},
+	}
+	for _, value := range invalid {
+		der, _ := hex.DecodeString(value.DER)
+		_, err := SignatureFromDER(der)
+		assert.NotNil(t, err, value.Reason)
+	}
+
+	/* Smallest valid signature */
+	signature, err := SignatureFromDER([]byte{0x30, 0x06, 0x02, 0x01, 0x01, 0x02, 0x01, 0x01})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), signature.R.Int64())
+	assert.Equal(t, int64(1), signature.S.Int64())
+}
@@ -8,6 +8,18 @@ import (
 type Network struct {
 	PrivKeyPrefix    string
 	PubKeyHashPrefix string
+	ScriptHashPrefix string
+	Bech32HRP        string
+
+	/* CoinType is the BIP44 coin type of the network */
+	CoinType uint32
+
+	ExtendedPrivKeyPrefix string
+	ExtendedPubKeyPrefix  string
+
+	/* SLIP132PrivKeyPrefixes and SLIP132PubKeyPrefixes are the versions of extended keys of the segwit script types */
+	SLIP132PrivKeyPrefixes map[ExtendedKeyType]string
+	SLIP132PubKeyPrefixes  map[ExtendedKeyType]string
 }
 
 // PrivateKey struct
@@ -16,6 +28,9 @@ type PrivateKey struct {
 	Hex string
 	WIF string
 
+	/* Compressed is true if the public key of the private key is serialized compressed */
+	Compressed bool
+
 	Network *Network
 }
 
@@ -24,5 +39,305 @@ type PublicKey struct {
 	X *big.Int
 	Y *big.Int
 
+	/* Compressed is the default serialization of the public key */
+	Compressed bool
+
+	Network *Network
+}
+
+// ExtendedKeyType is the script type an extended key is used for, encoded in its SLIP-132 version
+type ExtendedKeyType int
+
+// ExtendedKey struct
+type ExtendedKey struct {
+	/* PrivateKey is nil for extended public keys */
+	PrivateKey *PrivateKey
+	PublicKey  *PublicKey
+
+	ChainCode         []byte
+	Depth             byte
+	ParentFingerprint []byte
+	ChildNumber       uint32
+
+	/* Type selects the version of the serialization, it is inherited by children */
+	Type ExtendedKeyType
+
+	Network *Network
+}
+
+// AddressType is the kind of output script an address pays to
+type AddressType int
+
+// Address struct
+type Address struct {
+	Type AddressType
+
+	/* Hash is the hash160 of P2PKH and P2SH addresses */
+	Hash []byte
+
+	/* WitnessVersion and WitnessProgram are only set for segwit addresses */
+	WitnessVersion byte
+	WitnessProgram []byte
+
+	Network *Network
+}
+
+// OutPoint struct
+type OutPoint struct {
+	/* Hash is the txid of the previous transaction in internal byte order */
+	Hash  []byte
+	Index uint32
+}
+
+// TxIn struct
+type TxIn struct {
+	PreviousOutPoint OutPoint
+	ScriptSig        []byte
+	Sequence         uint32
+
+	Witness [][]byte
+}
+
+// TxOut struct
+type TxOut struct {
+	Value        int64
+	ScriptPubKey []byte
+}
+
+// Transaction struct
+type Transaction struct {
+	Version  int32
+	Inputs   []*TxIn
+	Outputs  []*TxOut
+	LockTime uint32
+}
+
+// SigHashType selects the parts of a transaction committed to by a signature
+type SigHashType uint32
+
+// Signature struct
+type Signature struct {
+	R *big.Int
+	S *big.Int
+}
+
+// ScriptFlags selects the rules enforced when verifying scripts
+type ScriptFlags uint32
+
+// Script is a serialized Bitcoin script
+type Script []byte
+
+// ScriptType is the standard template an output script matches
+type ScriptType int
+
+// PSBT struct
+type PSBT struct {
+	/* Version is 0 for BIP174 PSBTs and 2 for BIP370 PSBTs */
+	Version uint32
+
+	/* UnsignedTx is only set in version 0 */
+	UnsignedTx *Transaction
+	XPubs      []*PSBTDerivation
+
+	/* TxVersion, FallbackLockTime and TxModifiable are only set in version 2, optional fields are nil when missing */
+	TxVersion        int32
+	FallbackLockTime *uint32
+	TxModifiable     *byte
+
+	Inputs  []*PSBTInput
+	Outputs []*PSBTOutput
+
+	Proprietary []*PSBTRecord
+	Unknown     []*PSBTRecord
+}
+
+// PSBTInput struct
+type PSBTInput struct {
+	NonWitnessUtxo *Transaction
+	WitnessUtxo    *TxOut
+
+	PartialSigs   []*PSBTPartialSig
+	SigHashType   *SigHashType
+	RedeemScript  []byte
+	WitnessScript []byte
+	Derivations   []*PSBTDerivation
+
+	FinalScriptSig     []byte
+	FinalScriptWitness [][]byte
+
+	/* Preimages are indexed by the hex of their hash */
+	RIPEMD160Preimages map[string][]byte
+	SHA256Preimages    map[string][]byte
+	Hash160Preimages   map[string][]byte
+	Hash256Preimages   map[string][]byte
+
+	/* PreviousOutPoint, Sequence and the required lock times are only set in version 2 */
+	PreviousOutPoint       OutPoint
+	Sequence               *uint32
+	RequiredTimeLockTime   *uint32
+	RequiredHeightLockTime *uint32
+
+	Proprietary []*PSBTRecord
+	Unknown     []*PSBTRecord
+}
+
+// PSBTOutput struct
+type PSBTOutput struct {
+	RedeemScript  []byte
+	WitnessScript []byte
+	Derivations   []*PSBTDerivation
+
+	/* Amount and Script are only set in version 2 */
+	Amount int64
+	Script []byte
+
+	Proprietary []*PSBTRecord
+	Unknown     []*PSBTRecord
+}
+
+// PSBTPartialSig struct
+type PSBTPartialSig struct {
+	PublicKey []byte
+	/* Signature is the DER signature followed by its hash type */
+	Signature []byte
+}
+
+// PSBTDerivation struct
+type PSBTDerivation struct {
+	/* Key is a public key in inputs and outputs, and a serialized extended public key in the global map */
+	Key         []byte
+	Fingerprint []byte
+	Path        []uint32
+}
+
+// PSBTRecord struct
+type PSBTRecord struct {
+	/* Key starts with the key type */
+	Key   []byte
+	Value []byte
+}
+
+// DescriptorType is the script expression of an output script descriptor
+type DescriptorType int
+
+// DescriptorRange is how the last derivation step of a ranged key is derived
+type DescriptorRange int
+
+// Descriptor struct
+type Descriptor struct {
+	Type DescriptorType
+
+	/* Keys of pk, pkh, wpkh, multi and sortedmulti, the internal key of tr */
+	Keys []*DescriptorKey
+	/* Threshold is the number of signatures required by multi and sortedmulti */
+	Threshold int
+
+	/* Sub is the script wrapped by sh and wsh */
+	Sub *Descriptor
+	/* Tree is the script tree of tr, nil for key path only outputs */
+	Tree *DescriptorTree
+
+	/* Destination and Raw are the output of addr and raw */
+	Destination *Address
+	Raw         []byte
+}
+
+// DescriptorTree struct
+type DescriptorTree struct {
+	/* Leaf is set for leaves, Left and Right for branches */
+	Leaf  *Descriptor
+	Left  *DescriptorTree
+	Right *DescriptorTree
+}
+
+// DescriptorKey struct
+type DescriptorKey struct {
+	/* Fingerprint and OriginPath are the optional key origin */
+	Fingerprint []byte
+	OriginPath  []uint32
+
+	/* Only one of PublicKey, PrivateKey and ExtendedKey is set */
+	PublicKey   *PublicKey
+	PrivateKey  *PrivateKey
+	ExtendedKey *ExtendedKey
+
+	/* Path is derived from ExtendedKey, followed by the index of ranged keys */
+	Path  []uint32
+	Range DescriptorRange
+
+	/* XOnly keys are serialized without their prefix byte in tr */
+	XOnly bool
+	/* Apostrophe is true if hardened steps are written with ' instead of h */
+	Apostrophe bool
+}
+
+// MiniscriptContext is the kind of script a miniscript is compiled to
+type MiniscriptContext int
+
+// MiniscriptFragment is the kind of a miniscript expression
+type MiniscriptFragment int
+
+// MiniscriptType is the set of type properties of a miniscript expression
+type MiniscriptType uint32
+
+// Miniscript struct
+type Miniscript struct {
+	Fragment MiniscriptFragment
+	/* K is the threshold of thresh, multi and multi_a, and the lock time of older and after */
+	K    uint32
+	Keys []*PublicKey
+	/* Data is the hash of sha256, hash256, ripemd160 and hash160 */
+	Data []byte
+	Subs []*Miniscript
+
+	Context MiniscriptContext
+	Type    MiniscriptType
+
+	/* Properties computed when the expression is built */
+	ops           miniscriptOps
+	stackSize     miniscriptStackSize
+	witnessSize   miniscriptWitnessSize
+	scriptSize    int
+	duplicateKeys bool
+}
+
+// MiniscriptSatisfier struct
+type MiniscriptSatisfier struct {
+	/* Signatures are indexed by the hex of the public key as serialized in the script */
+	Signatures map[string][]byte
+
+	/* Preimages are indexed by the hex of their hash */
+	RIPEMD160Preimages map[string][]byte
+	SHA256Preimages    map[string][]byte
+	Hash160Preimages   map[string][]byte
+	Hash256Preimages   map[string][]byte
+
+	/* TxVersion, Sequence and LockTime of the spending transaction decide which timelocks are satisfied */
+	TxVersion int32
+	Sequence  uint32
+	LockTime  uint32
+}
+
+// MultiSig struct
+type MultiSig struct {
+	/* Threshold is the number of signatures required out of the keys */
+	Threshold int
+	/* PublicKeys are sorted as in BIP67 */
+	PublicKeys []*PublicKey
+
+	Network *Network
+}
+
+// AccountPurpose is the BIP43 purpose of an account, which selects its script type
+type AccountPurpose uint32
+
+// Account struct
+type Account struct {
+	Purpose AccountPurpose
+	Index   uint32
+
+	/* ExtendedKey is the account key at m/purpose'/coin_type'/account' */
+	ExtendedKey *ExtendedKey
+
 	Network *Network
 }
@@ -0,0 +1,95 @@
+package btc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"math/big"
+)
+
+// TapLeafVersion is the leaf version of tapscript leaves (BIP342)
+const TapLeafVersion byte = 0xc0
+
+// TapLeafHash computes the hash of a script tree leaf (BIP341)
+func TapLeafHash(leafVersion byte, script []byte) []byte {
+	data := append([]byte{leafVersion}, compactSize(uint64(len(script)))...)
+	return taggedHash("TapLeaf", data, script)
+}
+
+// TapBranchHash computes the hash of a script tree branch from the hashes of its two children (BIP341)
+func TapBranchHash(a []byte, b []byte) []byte {
+	/* Children are sorted lexicographically */
+	if bytes.Compare(a, b) > 0 {
+		a, b = b, a
+	}
+	return taggedHash("TapBranch", a, b)
+}
+
+/* taprootTweak computes t = hash_TapTweak(P.x || merkleRoot) of an internal key */
+func taprootTweak(x *big.Int, merkleRoot []byte) (*big.Int, error) {
+	if merkleRoot != nil && len(merkleRoot) != 32 {
+		return nil, errors.New("merkle root must be 32 bytes long")
+	}
+
+	t := new(big.Int).SetBytes(taggedHash("TapTweak", paddedBytes(x, 32), merkleRoot))
+	if t.Cmp(secp256k1.N) >= 0 {
+		return nil, errors.New("invalid taproot tweak")
+	}
+	return t, nil
+}
+
+// TaprootTweak returns the output key Q = P + t*G of an internal key, merkleRoot is nil for key-path only outputs
+func (p *PublicKey) TaprootTweak(merkleRoot []byte) (*PublicKey, error) {
+	P, err := liftX(p.X)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := taprootTweak(P.X, merkleRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	Q := addPoints(P, scalarBaseMult(t))
+	if Q.IsInfinity() {
+		return nil, errors.New("invalid taproot output key")
+	}
+
+	return &PublicKey{
+		X:          Q.X,
+		Y:          Q.Y,
+		Compressed: true,
+		Network:    p.Network,
+	}, nil
+}
+
+// TaprootAddress computes the taproot (P2TR) bech32m address of an internal key, merkleRoot is nil for key-path only outputs
+func (p *PublicKey) TaprootAddress(merkleRoot []byte) (string, error) {
+	Q, err := p.TaprootTweak(merkleRoot)
+	if err != nil {
+		return "", err
+	}
+
+	return encodeSegwitAddress(p.Network.Bech32HRP, 1, paddedBytes(Q.X, 32))
+}
+
+// TaprootTweak returns the private key spending the taproot output of the internal key through the key path
+func (p *PrivateKey) TaprootTweak(merkleRoot []byte) (*PrivateKey, error) {
+	d, P, err := p.evenYScalar()
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := taprootTweak(P.X, merkleRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	/* d + t mod n */
+	key := d.add(scalarFromBig(t))
+	if key.isZero() == 1 {
+		return nil, errors.New("invalid taproot tweaked key")
+	}
+
+	return PrivateFromHex(hex.EncodeToString(key.bytes()), p.Network)
+}
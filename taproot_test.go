@@ -0,0 +1,146 @@
+package btc
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type bip341Vectors struct {
+	ScriptPubKey []struct {
+		Given struct {
+			InternalPubkey string
+			ScriptTree     json.RawMessage
+		}
+		Intermediary struct {
+			LeafHashes    []string
+			MerkleRoot    *string
+			Tweak         string
+			TweakedPubkey string
+		}
+		Expected struct {
+			ScriptPubKey  string
+			Bip350Address string
+		}
+	}
+	KeyPathSpending []struct {
+		InputSpending []struct {
+			Given struct {
+				InternalPrivkey string
+				MerkleRoot      *string
+			}
+			Intermediary struct {
+				InternalPubkey string
+				TweakedPrivkey string
+			}
+		}
+	}
+}
+
+func loadBIP341Vectors(t *testing.T) *bip341Vectors {
+	data, err := ioutil.ReadFile("testdata/bip341_vectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var vectors bip341Vectors
+	err = json.Unmarshal(data, &vectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &vectors
+}
+
+/* scriptTreeHash computes the merkle root of a BIP341 test vector script tree and collects its leaf hashes */
+func scriptTreeHash(t *testing.T, tree json.RawMessage, leafHashes *[]string) []byte {
+	var branch []json.RawMessage
+	if json.Unmarshal(tree, &branch) == nil {
+		return TapBranchHash(scriptTreeHash(t, branch[0], leafHashes), scriptTreeHash(t, branch[1], leafHashes))
+	}
+
+	var leaf struct {
+		ID          int
+		Script      string
+		LeafVersion byte
+	}
+	err := json.Unmarshal(tree, &leaf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script, _ := hex.DecodeString(leaf.Script)
+
+	hash := TapLeafHash(leaf.LeafVersion, script)
+	for len(*leafHashes) <= leaf.ID {
+		*leafHashes = append(*leafHashes, "")
+	}
+	(*leafHashes)[leaf.ID] = hex.EncodeToString(hash)
+	return hash
+}
+
+func TestTaprootAddress(t *testing.T) {
+	vectors := loadBIP341Vectors(t)
+
+	for _, vector := range vectors.ScriptPubKey {
+		internalKey, err := PublicFromXOnly(vector.Given.InternalPubkey, MainNetwork)
+		assert.Nil(t, err)
+
+		var merkleRoot []byte
+		var leafHashes []string
+		if string(vector.Given.ScriptTree) != "null" {
+			merkleRoot = scriptTreeHash(t, vector.Given.ScriptTree, &leafHashes)
+		}
+		assert.Equal(t, vector.Intermediary.LeafHashes, leafHashes)
+		if vector.Intermediary.MerkleRoot != nil {
+			assert.Equal(t, *vector.Intermediary.MerkleRoot, hex.EncodeToString(merkleRoot))
+		} else {
+			assert.Nil(t, merkleRoot)
+		}
+
+		outputKey, err := internalKey.TaprootTweak(merkleRoot)
+		assert.Nil(t, err)
+		assert.Equal(t, vector.Intermediary.TweakedPubkey, outputKey.FormatXOnly())
+		assert.Equal(t, vector.Expected.ScriptPubKey, "5120"+outputKey.FormatXOnly())
+
+		address, err := internalKey.TaprootAddress(merkleRoot)
+		assert.Nil(t, err)
+		assert.Equal(t, vector.Expected.Bip350Address, address)
+
+		version, program, err := decodeSegwitAddress("bc", address)
+		assert.Nil(t, err)
+		assert.Equal(t, byte(1), version)
+		assert.Equal(t, vector.Intermediary.TweakedPubkey, hex.EncodeToString(program))
+	}
+}
+
+func TestTaprootPrivateTweak(t *testing.T) {
+	vectors := loadBIP341Vectors(t)
+
+	for _, spending := range vectors.KeyPathSpending {
+		for _, input := range spending.InputSpending {
+			privateKey, err := PrivateFromHex(input.Given.InternalPrivkey, MainNetwork)
+			assert.Nil(t, err)
+
+			var merkleRoot []byte
+			if input.Given.MerkleRoot != nil {
+				merkleRoot, _ = hex.DecodeString(*input.Given.MerkleRoot)
+			}
+
+			tweaked, err := privateKey.TaprootTweak(merkleRoot)
+			assert.Nil(t, err)
+			assert.Equal(t, input.Intermediary.TweakedPrivkey, tweaked.Hex)
+
+			/* The tweaked private key matches the tweaked internal public key */
+			internalKey, err := PublicFromXOnly(input.Intermediary.InternalPubkey, MainNetwork)
+			assert.Nil(t, err)
+			outputKey, err := internalKey.TaprootTweak(merkleRoot)
+			assert.Nil(t, err)
+			assert.Equal(t, outputKey.FormatXOnly(), paddedHex(scalarBaseMult(tweaked.Key).X))
+		}
+	}
+
+	_, err := (&PublicKey{X: secp256k1.G.X, Y: secp256k1.G.Y, Network: MainNetwork}).TaprootTweak([]byte{0x01})
+	assert.NotNil(t, err)
+}
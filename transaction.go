@@ -0,0 +1,336 @@
+package btc
+
+import (
+	"encoding/hex"
+	"errors"
+)
+
+// WitnessScaleFactor is the weight of a non-witness byte
+const WitnessScaleFactor = 4
+
+// NewOutPoint creates an outpoint from a txid in its usual (reversed) hex form
+func NewOutPoint(txid string, index uint32) (OutPoint, error) {
+	hash, err := hex.DecodeString(txid)
+	if err != nil {
+		return OutPoint{}, err
+	}
+	if len(hash) != 32 {
+		return OutPoint{}, errors.New("txid must be 32 bytes long")
+	}
+
+	return OutPoint{
+		Hash:  reverseBytes(hash),
+		Index: index,
+	}, nil
+}
+
+// TxID returns the txid of the outpoint in hex
+func (o OutPoint) TxID() string {
+	return hex.EncodeToString(reverseBytes(o.Hash))
+}
+
+/* isNull returns true for the outpoint of coinbase inputs */
+func (o OutPoint) isNull() bool {
+	for _, b := range o.Hash {
+		if b != 0 {
+			return false
+		}
+	}
+	return o.Index == 0xffffffff
+}
+
+// TransactionFromHex parses a raw transaction from its hex value
+func TransactionFromHex(hexa string) (*Transaction, error) {
+	b, err := hex.DecodeString(hexa)
+	if err != nil {
+		return nil, err
+	}
+	return TransactionFromBytes(b)
+}
+
+// TransactionFromBytes parses a raw transaction in legacy or segwit (BIP144) serialization
+func TransactionFromBytes(b []byte) (*Transaction, error) {
+	r := &byteReader{b: b}
+	tx, err := readTransaction(r)
+	if err != nil {
+		return nil, err
+	}
+	if r.remaining() != 0 {
+		return nil, errors.New("unexpected data after transaction")
+	}
+	return tx, nil
+}
+
+/* readTransaction reads a transaction the same way Bitcoin Core does */
+func readTransaction(r *byteReader) (*Transaction, error) {
+	var tx Transaction
+
+	version, err := r.readUint32()
+	if err != nil {
+		return nil, err
+	}
+	tx.Version = int32(version)
+
+	tx.Inputs, err = readInputs(r)
+	if err != nil {
+		return nil, err
+	}
+
+	/* An empty input list is the segwit marker, followed by the flag */
+	var flag byte
+	if len(tx.Inputs) == 0 {
+		flag, err = r.readByte()
+		if err != nil {
+			return nil, err
+		}
+		if flag != 0 {
+			tx.Inputs, err = readInputs(r)
+			if err != nil {
+				return nil, err
+			}
+			tx.Outputs, err = readOutputs(r)
+			if err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		tx.Outputs, err = readOutputs(r)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if flag&1 != 0 {
+		flag ^= 1
+		for _, in := range tx.Inputs {
+			count, err := r.readCount()
+			if err != nil {
+				return nil, err
+			}
+			in.Witness = make([][]byte, count)
+			for i := range in.Witness {
+				in.Witness[i], err = r.readVarBytes()
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		if !tx.HasWitness() {
+			return nil, errors.New("superfluous witness record")
+		}
+	}
+	if flag != 0 {
+		return nil, errors.New("unknown transaction optional data")
+	}
+
+	tx.LockTime, err = r.readUint32()
+	if err != nil {
+		return nil, err
+	}
+
+	return &tx, nil
+}
+
+func readInputs(r *byteReader) ([]*TxIn, error) {
+	count, err := r.readCount()
+	if err != nil {
+		return nil, err
+	}
+
+	inputs := make([]*TxIn, count)
+	for i := range inputs {
+		var in TxIn
+
+		hash, err := r.read(32)
+		if err != nil {
+			return nil, err
+		}
+		in.PreviousOutPoint.Hash = append([]byte{}, hash...)
+		in.PreviousOutPoint.Index, err = r.readUint32()
+		if err != nil {
+			return nil, err
+		}
+		in.ScriptSig, err = r.readVarBytes()
+		if err != nil {
+			return nil, err
+		}
+		in.Sequence, err = r.readUint32()
+		if err != nil {
+			return nil, err
+		}
+
+		inputs[i] = &in
+	}
+	return inputs, nil
+}
+
+func readOutputs(r *byteReader) ([]*TxOut, error) {
+	count, err := r.readCount()
+	if err != nil {
+		return nil, err
+	}
+
+	outputs := make([]*TxOut, count)
+	for i := range outputs {
+		value, err := r.readUint64()
+		if err != nil {
+			return nil, err
+		}
+		script, err := r.readVarBytes()
+		if err != nil {
+			return nil, err
+		}
+
+		outputs[i] = &TxOut{
+			Value:        int64(value),
+			ScriptPubKey: script,
+		}
+	}
+	return outputs, nil
+}
+
+// HasWitness returns true if at least one input has witness data
+func (tx *Transaction) HasWitness() bool {
+	for _, in := range tx.Inputs {
+		if len(in.Witness) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// IsCoinbase returns true if the transaction creates new coins
+func (tx *Transaction) IsCoinbase() bool {
+	return len(tx.Inputs) == 1 && tx.Inputs[0].PreviousOutPoint.isNull()
+}
+
+// Serialize returns the raw transaction, with witness data (BIP144) if any
+func (tx *Transaction) Serialize() []byte {
+	return tx.serialize(tx.HasWitness())
+}
+
+// SerializeNoWitness returns the raw transaction in legacy serialization
+func (tx *Transaction) SerializeNoWitness() []byte {
+	return tx.serialize(false)
+}
+
+func (tx *Transaction) serialize(witness bool) []byte {
+	b := appendUint32(nil, uint32(tx.Version))
+	if witness {
+		/* Marker and flag */
+		b = append(b, 0x00, 0x01)
+	}
+
+	b = append(b, compactSize(uint64(len(tx.Inputs)))...)
+	for _, in := range tx.Inputs {
+		b = append(b, paddedOutPointHash(in.PreviousOutPoint)...)
+		b = appendUint32(b, in.PreviousOutPoint.Index)
+		b = appendVarBytes(b, in.ScriptSig)
+		b = appendUint32(b, in.Sequence)
+	}
+
+	b = append(b, compactSize(uint64(len(tx.Outputs)))...)
+	for _, out := range tx.Outputs {
+		b = appendUint64(b, uint64(out.Value))
+		b = appendVarBytes(b, out.ScriptPubKey)
+	}
+
+	if witness {
+		for _, in := range tx.Inputs {
+			b = append(b, compactSize(uint64(len(in.Witness)))...)
+			for _, item := range in.Witness {
+				b = appendVarBytes(b, item)
+			}
+		}
+	}
+
+	return appendUint32(b, tx.LockTime)
+}
+
+/* paddedOutPointHash returns the 32 bytes hash of an outpoint, zeros if it is not set */
+func paddedOutPointHash(o OutPoint) []byte {
+	if len(o.Hash) == 32 {
+		return o.Hash
+	}
+	return make([]byte, 32)
+}
+
+// Hex returns the raw transaction in hex
+func (tx *Transaction) Hex() string {
+	return hex.EncodeToString(tx.Serialize())
+}
+
+// TxID returns the hash of the transaction without witness data, in hex
+func (tx *Transaction) TxID() string {
+	return hex.EncodeToString(reverseBytes(doubleHash(tx.SerializeNoWitness())))
+}
+
+// WTxID returns the hash of the transaction with witness data (BIP141), in hex
+func (tx *Transaction) WTxID() string {
+	return hex.EncodeToString(reverseBytes(doubleHash(tx.Serialize())))
+}
+
+// Weight returns the weight of the transaction (BIP141)
+func (tx *Transaction) Weight() int {
+	base := len(tx.SerializeNoWitness())
+	total := len(tx.Serialize())
+	return base*(WitnessScaleFactor-1) + total
+}
+
+// VSize returns the virtual size of the transaction, its weight divided by 4 rounded up
+func (tx *Transaction) VSize() int {
+	return (tx.Weight() + WitnessScaleFactor - 1) / WitnessScaleFactor
+}
+
+// MaxMoney is the maximum amount of satoshis that can ever exist
+const MaxMoney = 21000000 * 100000000
+
+/* maxBlockWeight is the maximum weight of a block (BIP141) */
+const maxBlockWeight = 4000000
+
+// Check applies the context-free consensus rules to the transaction (Bitcoin Core's CheckTransaction)
+func (tx *Transaction) Check() error {
+	if len(tx.Inputs) == 0 {
+		return errors.New("transaction has no inputs")
+	}
+	if len(tx.Outputs) == 0 {
+		return errors.New("transaction has no outputs")
+	}
+	if len(tx.SerializeNoWitness())*WitnessScaleFactor > maxBlockWeight {
+		return errors.New("transaction is too big")
+	}
+
+	var total int64
+	for _, out := range tx.Outputs {
+		if out.Value < 0 || out.Value > MaxMoney {
+			return errors.New("output value out of range")
+		}
+		total += out.Value
+		if total > MaxMoney {
+			return errors.New("total output value out of range")
+		}
+	}
+
+	spent := make(map[string]bool)
+	for _, in := range tx.Inputs {
+		key := string(paddedOutPointHash(in.PreviousOutPoint)) + string(appendUint32(nil, in.PreviousOutPoint.Index))
+		if spent[key] {
+			return errors.New("duplicate inputs")
+		}
+		spent[key] = true
+	}
+
+	if tx.IsCoinbase() {
+		size := len(tx.Inputs[0].ScriptSig)
+		if size < 2 || size > 100 {
+			return errors.New("invalid coinbase script size")
+		}
+		return nil
+	}
+	for _, in := range tx.Inputs {
+		if in.PreviousOutPoint.isNull() {
+			return errors.New("input spends a null outpoint")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package btc
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransactionSerialization(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/transactions.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/* Transactions of two mainnet blocks, including their coinbases */
+	var transactions []struct {
+		Hex    string
+		TxID   string
+		WTxID  string
+		Weight int
+		VSize  int
+	}
+	err = json.Unmarshal(data, &transactions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	coinbases := 0
+	for _, value := range transactions {
+		tx, err := TransactionFromHex(value.Hex)
+		assert.Nil(t, err, value.TxID)
+		if err != nil {
+			continue
+		}
+
+		assert.Equal(t, value.Hex, tx.Hex())
+		assert.Equal(t, value.TxID, tx.TxID())
+		assert.Equal(t, value.WTxID, tx.WTxID())
+		assert.Equal(t, value.Weight, tx.Weight())
+		assert.Equal(t, value.VSize, tx.VSize())
+		assert.Equal(t, value.TxID != value.WTxID, tx.HasWitness())
+
+		if tx.IsCoinbase() {
+			coinbases++
+		}
+
+		/* Parsing the legacy serialization drops the witness */
+		legacy, err := TransactionFromBytes(tx.SerializeNoWitness())
+		assert.Nil(t, err)
+		assert.Equal(t, value.TxID, legacy.TxID())
+		assert.Equal(t, value.TxID, legacy.WTxID())
+	}
+	assert.Equal(t, 2, coinbases)
+}
+
+func TestTransactionBuild(t *testing.T) {
+	outPoint, err := NewOutPoint("4d49a71ec9da436f71ec4ee231d04f292a29cd316f598bb7068feccabdc59485", 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "4d49a71ec9da436f71ec4ee231d04f292a29cd316f598bb7068feccabdc59485", outPoint.TxID())
+
+	script, _ := hex.DecodeString("0014751e76e8199196d454941c45d1b3a323f1433bd6")
+	tx := &Transaction{
+		Version: 2,
+		Inputs: []*TxIn{
+			{PreviousOutPoint: outPoint, ScriptSig: []byte{}, Sequence: 0xfffffffd},
+		},
+		Outputs: []*TxOut{
+			{Value: 100000, ScriptPubKey: script},
+		},
+	}
+	assert.Equal(t, "02000000018594c5bdcaec8f06b78b596f31cd292a294fd031e24eec716f43dac91ea7494d0000000000fdffffff01a086010000000000160014751e76e8199196d454941c45d1b3a323f1433bd600000000", tx.Hex())
+	assert.Equal(t, 4*82, tx.Weight())
+
+	tx.Inputs[0].Witness = [][]byte{{0x01, 0x02}, {}}
+	assert.Equal(t, "020000000001018594c5bdcaec8f06b78b596f31cd292a294fd031e24eec716f43dac91ea7494d0000000000fdffffff01a086010000000000160014751e76e8199196d454941c45d1b3a323f1433bd6020201020000000000", tx.Hex())
+	/* 82 base bytes, marker, flag and 5 witness bytes */
+	assert.Equal(t, 3*82+89, tx.Weight())
+	assert.Equal(t, 84, tx.VSize())
+
+	parsed, err := TransactionFromHex(tx.Hex())
+	assert.Nil(t, err)
+	assert.Equal(t, tx, parsed)
+
+	_, err = NewOutPoint("4d49a71ec9da436f", 0)
+	assert.NotNil(t, err)
+}
+
+func TestTransactionInvalid(t *testing.T) {
+	var invalid = []struct {
+		Hex    string
+		Reason string
+	}{
+		{"", "empty"},
+		{"02000000018594c5bdcaec8f06b78b596f31cd292a294fd031e24eec716f43dac91ea7494d0000000000fdffffff01a086010000000000160014751e76e8199196d454941c45d1b3a323f1433bd6000000", "truncated lock time"},
+		{"02000000018594c5bdcaec8f06b78b596f31cd292a294fd031e24eec716f43dac91ea7494d0000000000fdffffff01a086010000000000160014751e76e8199196d454941c45d1b3a323f1433bd60000000000", "trailing data"},
+		{"020000000001018594c5bdcaec8f06b78b596f31cd292a294fd031e24eec716f43dac91ea7494d0000000000fdffffff01a086010000000000160014751e76e8199196d454941c45d1b3a323f1433bd60000000000", "superfluous witness record"},
+		{"020000000003018594c5bdcaec8f06b78b596f31cd292a294fd031e24eec716f43dac91ea7494d0000000000fdffffff01a086010000000000160014751e76e8199196d454941c45d1b3a323f1433bd6020201020000000000", "unknown flag"},
+		{"0200000001fd01008594c5bdcaec8f06b78b596f31cd292a294fd031e24eec716f43dac91ea7494d0000000000fdffffff01a086010000000000160014751e76e8199196d454941c45d1b3a323f1433bd600000000", "non-canonical input count"},
+		{"02000000ff", "input count too large"},
+	}
+	for _, value := range invalid {
+		_, err := TransactionFromHex(value.Hex)
+		assert.NotNil(t, err, value.Reason)
+	}
+}
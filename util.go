@@ -1,9 +1,16 @@
 package btc
 
 import (
+	"bytes"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"math/big"
+
+	"github.com/mr-tron/base58"
+	"golang.org/x/crypto/ripemd160"
 )
 
 func generateRandomBigInt() *big.Int {
@@ -19,3 +26,203 @@ func generateRandomBigInt() *big.Int {
 func bigIntToHex(n *big.Int) string {
 	return fmt.Sprintf("%x", n)
 }
+
+/* paddedBytes returns the big-endian bytes of n left-padded with zeros to size */
+func paddedBytes(n *big.Int, size int) []byte {
+	b := n.Bytes()
+	if len(b) >= size {
+		return b
+	}
+	padded := make([]byte, size)
+	copy(padded[size-len(b):], b)
+	return padded
+}
+
+/* paddedHex returns the 32 bytes hex value of n */
+func paddedHex(n *big.Int) string {
+	return fmt.Sprintf("%064x", n)
+}
+
+/* doubleHash computes sha256(sha256(b)) */
+func doubleHash(b []byte) []byte {
+	hash := sha256.Sum256(b)
+	hash = sha256.Sum256(hash[:])
+	return hash[:]
+}
+
+/* hash160 computes ripemd160(sha256(b)) */
+func hash160(b []byte) []byte {
+	hash := sha256.Sum256(b)
+	ripemd := ripemd160.New()
+	ripemd.Write(hash[:])
+	return ripemd.Sum(nil)
+}
+
+/* base58CheckEncode appends the 4 bytes checksum to payload and encodes it to base 58 */
+func base58CheckEncode(payload []byte) string {
+	checksum := doubleHash(payload)[0:4]
+	return base58.Encode(append(append([]byte{}, payload...), checksum...))
+}
+
+/* base58CheckDecode decodes a base 58 string and verifies its checksum */
+func base58CheckDecode(s string) ([]byte, error) {
+	decoded, err := base58.Decode(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(decoded) < 4 {
+		return nil, errors.New("invalid base58 length")
+	}
+
+	payload := decoded[0 : len(decoded)-4]
+	if !bytes.Equal(doubleHash(payload)[0:4], decoded[len(decoded)-4:]) {
+		return nil, errors.New("invalid checksum")
+	}
+	return payload, nil
+}
+
+/* compactSize encodes n as a variable length integer */
+func compactSize(n uint64) []byte {
+	switch {
+	case n < 0xfd:
+		return []byte{byte(n)}
+	case n <= 0xffff:
+		return []byte{0xfd, byte(n), byte(n >> 8)}
+	case n <= 0xffffffff:
+		return []byte{0xfe, byte(n), byte(n >> 8), byte(n >> 16), byte(n >> 24)}
+	}
+	return []byte{0xff, byte(n), byte(n >> 8), byte(n >> 16), byte(n >> 24), byte(n >> 32), byte(n >> 40), byte(n >> 48), byte(n >> 56)}
+}
+
+/* byteReader reads little-endian and variable length values from a buffer */
+type byteReader struct {
+	b   []byte
+	pos int
+}
+
+func (r *byteReader) remaining() int {
+	return len(r.b) - r.pos
+}
+
+func (r *byteReader) read(n int) ([]byte, error) {
+	if n < 0 || n > r.remaining() {
+		return nil, errors.New("unexpected end of data")
+	}
+	b := r.b[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+func (r *byteReader) readByte() (byte, error) {
+	b, err := r.read(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+func (r *byteReader) readUint32() (uint32, error) {
+	b, err := r.read(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(b), nil
+}
+
+func (r *byteReader) readUint64() (uint64, error) {
+	b, err := r.read(8)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(b), nil
+}
+
+/* readCompactSize reads a variable length integer and rejects non-canonical encodings */
+func (r *byteReader) readCompactSize() (uint64, error) {
+	prefix, err := r.readByte()
+	if err != nil {
+		return 0, err
+	}
+
+	var n, min uint64
+	switch prefix {
+	case 0xfd:
+		b, err := r.read(2)
+		if err != nil {
+			return 0, err
+		}
+		n, min = uint64(binary.LittleEndian.Uint16(b)), 0xfd
+	case 0xfe:
+		v, err := r.readUint32()
+		if err != nil {
+			return 0, err
+		}
+		n, min = uint64(v), 0x10000
+	case 0xff:
+		n, err = r.readUint64()
+		if err != nil {
+			return 0, err
+		}
+		min = 0x100000000
+	default:
+		return uint64(prefix), nil
+	}
+
+	if n < min {
+		return 0, errors.New("non-canonical compact size")
+	}
+	return n, nil
+}
+
+/* readCount reads a number of elements, each at least one byte long, that fits in the remaining data */
+func (r *byteReader) readCount() (int, error) {
+	n, err := r.readCompactSize()
+	if err != nil {
+		return 0, err
+	}
+	if n > uint64(r.remaining()) {
+		return 0, errors.New("element count exceeds data size")
+	}
+	return int(n), nil
+}
+
+/* readVarBytes reads a byte string prefixed by its length */
+func (r *byteReader) readVarBytes() ([]byte, error) {
+	n, err := r.readCompactSize()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(r.remaining()) {
+		return nil, errors.New("unexpected end of data")
+	}
+	b, err := r.read(int(n))
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{}, b...), nil
+}
+
+/* appendUint32 appends the little-endian encoding of v */
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+/* appendUint64 appends the little-endian encoding of v */
+func appendUint64(b []byte, v uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(v)), uint32(v>>32))
+}
+
+/* appendVarBytes appends data prefixed by its length */
+func appendVarBytes(b []byte, data []byte) []byte {
+	b = append(b, compactSize(uint64(len(data)))...)
+	return append(b, data...)
+}
+
+/* reverseBytes returns a reversed copy of b, used to display hashes */
+func reverseBytes(b []byte) []byte {
+	reversed := make([]byte, len(b))
+	for i := range b {
+		reversed[len(b)-1-i] = b[i]
+	}
+	return reversed
+}